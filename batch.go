@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"GoBrowserAgent/internal/batch"
+	"GoBrowserAgent/internal/service/llm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runBatch 执行batch子命令，返回进程退出码
+func runBatch(args []string) int {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	configPath := flags.String("config", llm.GetConfigPath(), "配置文件路径")
+	input := flags.String("in", "", "输入JSONL文件，每行包含id、prompt以及可选的system、model、max_tokens、temperature、top_p")
+	output := flags.String("out", "", "输出JSONL文件")
+	concurrency := flags.Int("concurrency", 4, "并发请求数")
+	rate := flags.Float64("rate", 0, "每秒最多发起的请求数，0表示不限速")
+	retries := flags.Int("retries", 2, "单条请求失败后的重试次数")
+	resume := flags.Bool("resume", false, "跳过输出文件中已成功完成的id并追加写入")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: GoBrowserAgent batch -in requests.jsonl -out results.jsonl [选项]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *input == "" || *output == "" {
+		flags.Usage()
+		return 2
+	}
+
+	llmService, _, err := newLLMService(*configPath, nil)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	// 收到中断信号时停止派发新请求，已完成的结果保留在输出文件中
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner := batch.NewRunner(llmService, batch.Options{
+		Concurrency:   *concurrency,
+		RatePerSecond: *rate,
+		Retries:       *retries,
+		RetryDelay:    time.Second,
+		Resume:        *resume,
+	})
+
+	summary, err := runner.Run(ctx, *input, *output)
+	if summary != nil {
+		logrus.Infof("批处理完成: 共%d条, 跳过%d条, 成功%d条, 失败%d条, 耗时%s",
+			summary.Total, summary.Skipped, summary.Succeeded, summary.Failed, summary.Duration.Round(time.Millisecond))
+	}
+	if err != nil {
+		logrus.Errorf("批处理失败: %v", err)
+		return 1
+	}
+	if summary.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"GoBrowserAgent/internal/eval"
+	"GoBrowserAgent/internal/httpclient"
+	"GoBrowserAgent/internal/service/llm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runEval 执行eval子命令，返回进程退出码
+func runEval(args []string) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	configPath := flags.String("config", llm.GetConfigPath(), "配置文件路径")
+	suitePath := flags.String("suite", "", "评测集JSON文件")
+	models := flags.String("models", "", "逗号分隔的模型列表，覆盖评测集中的models")
+	output := flags.String("out", "eval_report", "报告输出路径前缀，生成.md和.json两个文件")
+	concurrency := flags.Int("concurrency", 4, "并发请求数")
+	replay := flags.String("replay", "", "从录制文件回放LLM响应，完全离线运行")
+	record := flags.String("record", "", "调用真实接口并将响应录制到文件")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: GoBrowserAgent eval -suite cases.json [选项]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *suitePath == "" || (*replay != "" && *record != "") {
+		flags.Usage()
+		return 2
+	}
+
+	suite, err := eval.LoadSuite(*suitePath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	if *models != "" {
+		suite.Models = nil
+		for _, name := range strings.Split(*models, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				suite.Models = append(suite.Models, eval.ModelSpec{Name: name})
+			}
+		}
+	}
+
+	var replayConfig *httpclient.ReplayConfig
+	switch {
+	case *replay != "":
+		replayConfig = &httpclient.ReplayConfig{Mode: httpclient.ReplayModeReplay, File: *replay}
+	case *record != "":
+		replayConfig = &httpclient.ReplayConfig{Mode: httpclient.ReplayModeRecord, File: *record}
+	}
+
+	llmService, _, err := newLLMService(*configPath, replayConfig)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := eval.NewRunner(llmService, *concurrency).Run(ctx, suite)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		logrus.Errorf("编码评测报告失败: %v", err)
+		return 1
+	}
+	if err := os.WriteFile(*output+".json", data, 0644); err != nil {
+		logrus.Errorf("写入评测报告失败: %v", err)
+		return 1
+	}
+	markdown := report.Markdown()
+	if err := os.WriteFile(*output+".md", []byte(markdown), 0644); err != nil {
+		logrus.Errorf("写入评测报告失败: %v", err)
+		return 1
+	}
+
+	fmt.Print(markdown)
+	logrus.Infof("评测报告已保存到: %s.md, %s.json", *output, *output)
+
+	for _, m := range report.Models {
+		if m.Passed < m.Total {
+			return 1
+		}
+	}
+	return 0
+}
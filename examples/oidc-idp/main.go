@@ -0,0 +1,228 @@
+// oidc-idp 是用于本地测试OIDC登录的最小身份提供方，不要用于生产环境
+//
+// 用法:
+//
+//	go run ./examples/oidc-idp -addr 127.0.0.1:9096 -redirect http://localhost:8080/api/auth/oidc/callback
+//
+// 登录页面输入任意用户名即可登录，签名密钥在每次启动时重新生成。
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// authCode 已签发、尚未兑换的授权码
+type authCode struct {
+	username    string
+	nonce       string
+	redirectURI string
+	challenge   string
+	expires     time.Time
+}
+
+type idp struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURI  string
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*authCode
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="zh-CN"><head><meta charset="UTF-8"><title>测试身份提供方</title></head>
+<body style="font-family:sans-serif;max-width:360px;margin:80px auto">
+<h3>测试身份提供方</h3>
+<form method="post">
+{{range $k, $v := .}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+<p><input name="username" placeholder="用户名" autofocus required></p>
+<p><button type="submit">登录</button></p>
+</form></body></html>`))
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9096", "监听地址")
+	clientID := flag.String("client-id", "gobrowseragent", "客户端ID")
+	clientSecret := flag.String("client-secret", "secret", "客户端密钥")
+	redirect := flag.String("redirect", "http://localhost:8080/api/auth/oidc/callback", "允许的回调地址")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("生成签名密钥失败: %v", err)
+	}
+	p := &idp{
+		issuer:       "http://" + *addr,
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		redirectURI:  *redirect,
+		key:          key,
+		codes:        make(map[string]*authCode),
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	http.HandleFunc("/authorize", p.handleAuthorize)
+	http.HandleFunc("/token", p.handleToken)
+	http.HandleFunc("/jwks", p.handleJWKS)
+
+	log.Printf("测试身份提供方: %s (client_id=%s, client_secret=%s)", p.issuer, p.clientID, p.clientSecret)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (p *idp) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// handleAuthorize GET显示登录页面，POST签发授权码并跳转回客户端
+func (p *idp) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+	if params.Get("client_id") != p.clientID || params.Get("redirect_uri") != p.redirectURI {
+		http.Error(w, "client_id或redirect_uri无效", http.StatusBadRequest)
+		return
+	}
+	if params.Get("response_type") != "code" || params.Get("code_challenge_method") != "S256" {
+		http.Error(w, "只支持授权码流程和S256 PKCE", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		hidden := url.Values{}
+		for _, name := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			hidden.Set(name, params.Get(name))
+		}
+		loginPage.Execute(w, hidden)
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = &authCode{
+		username:    params.Get("username"),
+		nonce:       params.Get("nonce"),
+		redirectURI: params.Get("redirect_uri"),
+		challenge:   params.Get("code_challenge"),
+		expires:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	target := p.redirectURI + "?" + url.Values{"code": {code}, "state": {params.Get("state")}}.Encode()
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleToken 校验客户端凭据和PKCE后签发ID令牌
+func (p *idp) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != p.clientID || secret != p.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	code, ok := p.codes[r.Form.Get("code")]
+	delete(p.codes, r.Form.Get("code"))
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || time.Now().After(code.expires) || code.redirectURI != r.Form.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != code.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := p.sign(map[string]interface{}{
+		"iss":                p.issuer,
+		"sub":                code.username,
+		"aud":                p.clientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              code.nonce,
+		"preferred_username": code.username,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *idp) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// sign 生成RS256签名的JWT
+func (p *idp) sign(claims map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "test"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func randomString() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
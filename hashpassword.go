@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"GoBrowserAgent/internal/auth"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runHashPassword 执行hash-password子命令，从标准输入读取密码并输出可写入配置文件的摘要
+func runHashPassword(args []string) int {
+	flags := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: echo -n 密码 | GoBrowserAgent hash-password")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "请输入密码: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		logrus.Errorf("读取密码失败: %v", err)
+		return 1
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		logrus.Error("密码不能为空")
+		return 2
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	fmt.Println(hash)
+	return 0
+}
@@ -0,0 +1,255 @@
+// Package apierror 定义HTTP接口统一的错误模型
+//
+// 每个错误有稳定的错误码、对应的HTTP状态码和中英文提示。只有提示和Details会返回给调用方，
+// 内部原因（Err）只和请求ID一起写入日志，排查问题时按响应中的request_id查找。
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"GoBrowserAgent/internal/service/llm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Error 返回给调用方的错误
+type Error struct {
+	Code string
+	// Params 替换提示中的{name}占位符
+	Params map[string]string
+	// Details 对调用方安全的补充信息，例如校验失败的原因，不做翻译
+	Details interface{}
+	// RetryAfter 建议的重试等待秒数，大于0时写入Retry-After头
+	RetryAfter int
+	// Err 内部原因，只写入日志
+	Err error
+}
+
+// Coder 可以转换为接口错误的错误类型，例如权限不足
+type Coder interface {
+	APIError() *Error
+}
+
+// New 创建错误
+func New(code string) *Error {
+	return &Error{Code: code}
+}
+
+// Wrap 创建带有内部原因的错误
+func Wrap(code string, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+// MethodNotAllowed 请求方法不受支持
+func MethodNotAllowed(allowed ...string) *Error {
+	return New(CodeMethodNotAllowed).With("allowed", strings.Join(allowed, "、"))
+}
+
+// With 设置提示中的占位符
+func (e *Error) With(key, value string) *Error {
+	if e.Params == nil {
+		e.Params = make(map[string]string)
+	}
+	e.Params[key] = value
+	return e
+}
+
+// WithDetails 设置补充信息
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// Error 返回中文提示和内部原因，用于日志
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message(LangZH), e.Err)
+	}
+	return e.Message(LangZH)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status 返回HTTP状态码
+func (e *Error) Status() int {
+	if entry, ok := catalog[e.Code]; ok {
+		return entry.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Message 返回指定语言的提示
+func (e *Error) Message(lang string) string {
+	entry, ok := catalog[e.Code]
+	if !ok {
+		entry = catalog[CodeInternal]
+	}
+	text := entry.zh
+	if lang == LangEN {
+		text = entry.en
+	}
+	if strings.Contains(text, "{wait}") {
+		text = strings.ReplaceAll(text, "{wait}", formatWait(lang, e.RetryAfter))
+	}
+	for key, value := range e.Params {
+		text = strings.ReplaceAll(text, "{"+key+"}", value)
+	}
+	return text
+}
+
+// From 把任意错误转换为接口错误，无法识别的错误视为服务器内部错误
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.APIError()
+	}
+	return Wrap(CodeInternal, err)
+}
+
+// FromDecode 把解析请求体时的错误转换为接口错误
+func FromDecode(err error) *Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return Wrap(CodePayloadTooLarge, err)
+	}
+	return Wrap(CodeBadRequest, err)
+}
+
+// Upstream 把LLM调用错误转换为接口错误
+//
+// 只有请求本身有误（上游返回400）时才在Details中给出上游的错误内容，
+// 其他情况（例如服务自己的API密钥无效）不向调用方暴露细节。
+func Upstream(err error) *Error {
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadRequest:
+			return Wrap(CodeUpstreamRejected, err).WithDetails(apiErr.Body)
+		case http.StatusTooManyRequests:
+			return Wrap(CodeUpstreamRateLimited, err)
+		}
+		return Wrap(CodeUpstreamError, err)
+	}
+	return Wrap(CodeUpstreamUnreachable, err)
+}
+
+// Body 错误响应体
+type Body struct {
+	Error Detail `json:"error"`
+}
+
+// Detail 错误响应体中的error字段
+type Detail struct {
+	Code       string      `json:"code"`
+	Message    string      `json:"message"`
+	Details    interface{} `json:"details,omitempty"`
+	RetryAfter int         `json:"retry_after,omitempty"`
+	RequestID  string      `json:"request_id,omitempty"`
+}
+
+// Write 写入错误响应，/v1/*下使用OpenAI兼容的格式
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+	status := e.Status()
+	requestID := RequestID(r.Context())
+	log(r, e, status)
+
+	header := w.Header()
+	if e.RetryAfter > 0 {
+		header.Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
+	if e.Code == CodeMethodNotAllowed && e.Params["allowed"] != "" {
+		header.Set("Allow", strings.ReplaceAll(e.Params["allowed"], "、", ", "))
+	}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	var body interface{} = Body{Error: e.Detail(Language(r), requestID)}
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		body = e.OpenAIBody(Language(r), requestID)
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logrus.Errorf("编码错误响应失败: %v", err)
+	}
+}
+
+// Detail 返回指定语言的错误内容
+func (e *Error) Detail(lang, requestID string) Detail {
+	return Detail{
+		Code:       e.Code,
+		Message:    e.Message(lang),
+		Details:    e.Details,
+		RetryAfter: e.RetryAfter,
+		RequestID:  requestID,
+	}
+}
+
+// OpenAIBody 返回OpenAI兼容格式的错误响应体，供/v1网关使用
+func (e *Error) OpenAIBody(lang, requestID string) map[string]interface{} {
+	entry, ok := catalog[e.Code]
+	if !ok {
+		entry = catalog[CodeInternal]
+	}
+	message := e.Message(lang)
+	switch details := e.Details.(type) {
+	case string:
+		if details != "" {
+			message += ": " + details
+		}
+	case error:
+		message += ": " + details.Error()
+	}
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":    message,
+			"type":       entry.openAIType,
+			"param":      nil,
+			"code":       e.Code,
+			"request_id": requestID,
+		},
+	}
+}
+
+// log 服务端错误记录内部原因，客户端错误只在调试日志中记录
+func log(r *http.Request, e *Error, status int) {
+	if status >= http.StatusInternalServerError {
+		logrus.Errorf("[%s] %s %s: %v", RequestID(r.Context()), r.Method, r.URL.Path, e)
+		return
+	}
+	logrus.Debugf("[%s] %s %s: %d %s: %v", RequestID(r.Context()), r.Method, r.URL.Path, status, e.Code, e)
+}
+
+// formatWait 把等待秒数格式化为便于阅读的文字
+func formatWait(lang string, seconds int) string {
+	if seconds < 1 {
+		seconds = 1
+	}
+	if lang == LangEN {
+		switch {
+		case seconds < 60:
+			return fmt.Sprintf("%ds", seconds)
+		case seconds < 3600:
+			return fmt.Sprintf("%d min", (seconds+59)/60)
+		}
+		return fmt.Sprintf("%d h", (seconds+3599)/3600)
+	}
+	switch {
+	case seconds < 60:
+		return fmt.Sprintf("%d秒", seconds)
+	case seconds < 3600:
+		return fmt.Sprintf("%d分钟", (seconds+59)/60)
+	}
+	return fmt.Sprintf("%d小时", (seconds+3599)/3600)
+}
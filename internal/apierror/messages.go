@@ -0,0 +1,128 @@
+package apierror
+
+import "net/http"
+
+// 稳定的错误码，客户端应按错误码而不是错误文字判断错误类型
+const (
+	CodeBadRequest        = "bad_request"
+	CodeMissingParameter  = "missing_parameter"
+	CodeInvalidParameter  = "invalid_parameter"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodePayloadTooLarge   = "payload_too_large"
+	CodeMessageEmpty      = "message_empty"
+	CodeInvalidImport     = "invalid_import"
+	CodeInvalidPolicy     = "invalid_policy"
+	CodeUnsupportedFormat = "unsupported_format"
+	CodeValidationFailed  = "validation_failed"
+
+	CodeUnauthenticated    = "unauthenticated"
+	CodeInvalidToken       = "invalid_token"
+	CodeUnsupportedAuth    = "unsupported_authorization"
+	CodeLoginExpired       = "login_expired"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeAuthDisabled       = "auth_disabled"
+	CodeCrossSiteRequest   = "cross_site_request"
+	CodeOIDCNotConfigured  = "oidc_not_configured"
+	CodeIdPUnreachable     = "idp_unreachable"
+	CodeLoginRejected      = "login_rejected"
+	CodeLoginStateInvalid  = "login_state_invalid"
+	CodeLoginFailed        = "login_failed"
+	CodeLoginNotAllowed    = "login_not_allowed"
+
+	CodePermissionDenied = "permission_denied"
+	CodeNoRole           = "no_role"
+	CodeSiteNotAllowed   = "site_not_allowed"
+
+	CodeNotFound         = "not_found"
+	CodeSessionNotFound  = "session_not_found"
+	CodeModelNotFound    = "model_not_found"
+	CodeTaskNotFound     = "task_not_found"
+	CodeApprovalNotFound = "approval_not_found"
+
+	CodeChatBusy         = "chat_busy"
+	CodeNotRunning       = "not_running"
+	CodeTaskInputBusy    = "task_input_busy"
+	CodeResumeIncomplete = "resume_incomplete"
+	CodeInvalidMessage   = "invalid_message"
+	CodeUnknownMessage   = "unknown_message_type"
+	CodeWebSocketVersion = "unsupported_websocket_version"
+
+	CodeRateLimited        = "rate_limited"
+	CodeConcurrencyLimited = "concurrency_limited"
+	CodeQuotaExceeded      = "quota_exceeded"
+
+	CodeUpstreamRejected    = "upstream_rejected"
+	CodeUpstreamRateLimited = "upstream_rate_limited"
+	CodeUpstreamError       = "upstream_error"
+	CodeUpstreamUnreachable = "upstream_unreachable"
+
+	CodeInternal     = "internal"
+	CodeShuttingDown = "shutting_down"
+)
+
+// entry 错误码对应的HTTP状态码、OpenAI错误类型和各语言的提示
+//
+// 提示中的{name}由Error.Params替换，{wait}替换为按语言格式化的RetryAfter。
+type entry struct {
+	status     int
+	openAIType string
+	zh         string
+	en         string
+}
+
+var catalog = map[string]entry{
+	CodeBadRequest:        {http.StatusBadRequest, "invalid_request_error", "无效的请求格式", "Malformed request body"},
+	CodeMissingParameter:  {http.StatusBadRequest, "invalid_request_error", "缺少参数{name}", "Missing required parameter {name}"},
+	CodeInvalidParameter:  {http.StatusBadRequest, "invalid_request_error", "参数{name}无效", "Invalid parameter {name}"},
+	CodeMethodNotAllowed:  {http.StatusMethodNotAllowed, "invalid_request_error", "只支持{allowed}请求", "Only {allowed} requests are supported"},
+	CodePayloadTooLarge:   {http.StatusRequestEntityTooLarge, "invalid_request_error", "请求内容过大", "Request body too large"},
+	CodeMessageEmpty:      {http.StatusBadRequest, "invalid_request_error", "消息不能为空", "Message must not be empty"},
+	CodeInvalidImport:     {http.StatusBadRequest, "invalid_request_error", "导入文件无效", "Invalid import file"},
+	CodeInvalidPolicy:     {http.StatusBadRequest, "invalid_request_error", "权限策略无效", "Invalid access policy"},
+	CodeUnsupportedFormat: {http.StatusBadRequest, "invalid_request_error", "不支持的格式{format}", "Unsupported format {format}"},
+	CodeValidationFailed:  {http.StatusBadRequest, "invalid_request_error", "请求校验失败", "Request validation failed"},
+
+	CodeUnauthenticated:    {http.StatusUnauthorized, "authentication_error", "请先登录", "Authentication required"},
+	CodeInvalidToken:       {http.StatusUnauthorized, "authentication_error", "API令牌无效", "Invalid API token"},
+	CodeUnsupportedAuth:    {http.StatusUnauthorized, "authentication_error", "不支持的Authorization格式", "Unsupported Authorization header"},
+	CodeLoginExpired:       {http.StatusUnauthorized, "authentication_error", "登录已过期，请重新登录", "Your session has expired, please sign in again"},
+	CodeInvalidCredentials: {http.StatusUnauthorized, "authentication_error", "用户名或密码错误", "Invalid username or password"},
+	CodeAuthDisabled:       {http.StatusBadRequest, "invalid_request_error", "未启用认证", "Authentication is not enabled"},
+	CodeCrossSiteRequest:   {http.StatusForbidden, "permission_error", "拒绝跨站请求", "Cross-site request rejected"},
+	CodeOIDCNotConfigured:  {http.StatusNotFound, "invalid_request_error", "未配置OIDC登录", "OIDC sign-in is not configured"},
+	CodeIdPUnreachable:     {http.StatusBadGateway, "api_error", "无法连接身份提供方", "Could not reach the identity provider"},
+	CodeLoginRejected:      {http.StatusUnauthorized, "authentication_error", "身份提供方拒绝了登录", "The identity provider rejected the sign-in"},
+	CodeLoginStateInvalid:  {http.StatusBadRequest, "authentication_error", "登录状态无效，请重新登录", "Sign-in state is invalid, please try again"},
+	CodeLoginFailed:        {http.StatusUnauthorized, "authentication_error", "登录失败", "Sign-in failed"},
+	CodeLoginNotAllowed:    {http.StatusForbidden, "permission_error", "该用户无权登录", "This user is not allowed to sign in"},
+
+	CodePermissionDenied: {http.StatusForbidden, "permission_error", "无权执行{permission}", "Permission {permission} is required"},
+	CodeNoRole:           {http.StatusForbidden, "permission_error", "没有绑定任何角色，无权执行{permission}", "No role is bound to you, permission {permission} is required"},
+	CodeSiteNotAllowed:   {http.StatusForbidden, "permission_error", "不允许访问站点{host}", "Access to site {host} is not allowed"},
+
+	CodeNotFound:         {http.StatusNotFound, "invalid_request_error", "资源不存在", "Not found"},
+	CodeSessionNotFound:  {http.StatusNotFound, "invalid_request_error", "会话不存在", "Conversation not found"},
+	CodeModelNotFound:    {http.StatusNotFound, "invalid_request_error", "模型{model}不存在或不允许通过网关调用", "Model {model} does not exist or is not available through the gateway"},
+	CodeTaskNotFound:     {http.StatusNotFound, "invalid_request_error", "任务不存在或已结束", "Task not found or already finished"},
+	CodeApprovalNotFound: {http.StatusNotFound, "invalid_request_error", "审批请求不存在或已处理", "Approval request not found or already answered"},
+
+	CodeChatBusy:         {http.StatusConflict, "invalid_request_error", "该对话正在生成回复，请先停止或等待完成", "A reply is already being generated for this conversation"},
+	CodeNotRunning:       {http.StatusConflict, "invalid_request_error", "没有进行中的生成", "No reply is being generated"},
+	CodeTaskInputBusy:    {http.StatusConflict, "invalid_request_error", "任务尚未处理之前的指令，请稍后再试", "The task has not processed the previous instruction yet"},
+	CodeResumeIncomplete: {http.StatusConflict, "invalid_request_error", "部分消息已过期无法补发，请重新加载对话", "Some messages expired and cannot be replayed, please reload the conversation"},
+	CodeInvalidMessage:   {http.StatusBadRequest, "invalid_request_error", "无效的消息格式", "Invalid message"},
+	CodeUnknownMessage:   {http.StatusBadRequest, "invalid_request_error", "不支持的消息类型{type}", "Unsupported message type {type}"},
+	CodeWebSocketVersion: {http.StatusUpgradeRequired, "invalid_request_error", "不支持的WebSocket版本，请使用版本13", "Unsupported WebSocket version, version 13 is required"},
+
+	CodeRateLimited:        {http.StatusTooManyRequests, "rate_limit_error", "请求过于频繁，请{wait}后重试", "Too many requests, retry in {wait}"},
+	CodeConcurrencyLimited: {http.StatusTooManyRequests, "rate_limit_error", "同时进行的请求过多（上限{limit}个），请{wait}后重试", "Too many concurrent requests (limit {limit}), retry in {wait}"},
+	CodeQuotaExceeded:      {http.StatusTooManyRequests, "rate_limit_error", "今日请求配额已用完，请{wait}后重试", "Daily request quota exhausted, retry in {wait}"},
+
+	CodeUpstreamRejected:    {http.StatusBadRequest, "invalid_request_error", "上游LLM服务拒绝了请求", "The LLM provider rejected the request"},
+	CodeUpstreamRateLimited: {http.StatusTooManyRequests, "rate_limit_error", "上游LLM服务繁忙，请稍后重试", "The LLM provider is busy, please retry later"},
+	CodeUpstreamError:       {http.StatusBadGateway, "api_error", "上游LLM服务返回错误", "The LLM provider returned an error"},
+	CodeUpstreamUnreachable: {http.StatusBadGateway, "api_error", "无法连接上游LLM服务", "Could not reach the LLM provider"},
+
+	CodeInternal:     {http.StatusInternalServerError, "api_error", "服务器内部错误", "Internal server error"},
+	CodeShuttingDown: {http.StatusServiceUnavailable, "api_error", "服务器正在关闭", "The server is shutting down"},
+}
@@ -0,0 +1,87 @@
+package apierror
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// 支持的提示语言
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+// maxRequestIDLength 接受的客户端请求ID的最大长度
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+// Middleware 为每个请求分配请求ID并写入X-Request-ID响应头
+//
+// 客户端或反向代理已经带有合法的X-Request-ID时沿用，便于跨服务追踪。
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestID 返回请求ID，没有经过Middleware时为空
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Language 根据lang参数或Accept-Language选择提示语言，默认中文
+func Language(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return matchLanguage(lang)
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(tag)
+		if strings.HasPrefix(tag, "zh") {
+			return LangZH
+		}
+		if strings.HasPrefix(tag, "en") {
+			return LangEN
+		}
+	}
+	return LangZH
+}
+
+// matchLanguage 把语言标签归一为支持的语言
+func matchLanguage(tag string) string {
+	if strings.HasPrefix(strings.ToLower(tag), "en") {
+		return LangEN
+	}
+	return LangZH
+}
+
+// validRequestID 只接受由字母、数字和-_.组成的短ID，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
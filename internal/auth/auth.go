@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/httpclient"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 认证方式
+const (
+	MethodToken    = "token"
+	MethodPassword = "password"
+	MethodOIDC     = "oidc"
+)
+
+// Identity 已认证的调用方
+type Identity struct {
+	// Name 用户名或API令牌名称，用于显示
+	Name string `json:"name"`
+	// Method 认证方式：token、password或oidc
+	Method string `json:"method"`
+	// Subject 带认证方式前缀的唯一标识，例如password:alice、token:ci、oidc:<sub>，
+	// 权限绑定、限流和数据归属都按它区分调用方，不同认证方式的同名用户不会混淆
+	Subject string `json:"subject"`
+}
+
+// NewIdentity 创建调用方身份，id为认证方式内的唯一标识：用户名、令牌名称或OIDC的sub
+func NewIdentity(method, id, name string) Identity {
+	return Identity{Name: name, Method: method, Subject: SubjectOf(method, id)}
+}
+
+// SubjectOf 返回带认证方式前缀的调用方标识
+func SubjectOf(method, id string) string {
+	return method + ":" + id
+}
+
+type contextKey struct{}
+
+// WithIdentity 返回携带调用方身份的context
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext 返回请求的调用方身份，未启用认证时不存在
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok
+}
+
+// publicPaths 启用认证后仍可匿名访问的接口
+var publicPaths = map[string]bool{
+	"/api/auth/providers":     true,
+	"/api/auth/login":         true,
+	"/api/auth/logout":        true,
+	"/api/auth/oidc/login":    true,
+	"/api/auth/oidc/callback": true,
+	"/api/openapi.json":       true,
+}
+
+// Authenticator 校验API令牌和登录会话
+type Authenticator struct {
+	config   *Config
+	tokens   map[string]string // 令牌SHA-256摘要 -> 令牌名称
+	users    map[string]string // 用户名 -> 密码摘要
+	sessions *sessionStore
+	oidc     *oidcProvider
+}
+
+// NewAuthenticator 根据配置创建认证器，httpClient用于访问OIDC身份提供方
+func NewAuthenticator(config *Config, httpClient *httpclient.Client) (*Authenticator, error) {
+	if config == nil {
+		config = GetDefaultConfig()
+	}
+	if config.SessionHours <= 0 {
+		config.SessionHours = GetDefaultConfig().SessionHours
+	}
+	if config.CookieName == "" {
+		config.CookieName = GetDefaultConfig().CookieName
+	}
+
+	a := &Authenticator{
+		config:   config,
+		tokens:   make(map[string]string),
+		users:    make(map[string]string),
+		sessions: newSessionStore(time.Duration(config.SessionHours) * time.Hour),
+	}
+
+	for i, token := range config.Tokens {
+		name := token.Name
+		if name == "" {
+			name = fmt.Sprintf("token-%d", i+1)
+		}
+		digest := strings.ToLower(token.TokenSHA256)
+		if token.Token != "" {
+			sum := sha256.Sum256([]byte(token.Token))
+			digest = hex.EncodeToString(sum[:])
+		}
+		if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("API令牌%s缺少token或有效的token_sha256", name)
+		}
+		a.tokens[digest] = name
+	}
+
+	for _, user := range config.Users {
+		if user.Username == "" {
+			return nil, fmt.Errorf("用户名不能为空")
+		}
+		if !ValidPasswordHash(user.PasswordHash) {
+			return nil, fmt.Errorf("用户%s的password_hash格式无效，请使用hash-password子命令生成", user.Username)
+		}
+		a.users[user.Username] = user.PasswordHash
+	}
+
+	if config.OIDC != nil && config.OIDC.Issuer != "" {
+		provider, err := newOIDCProvider(config.OIDC, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = provider
+	}
+
+	if config.Enabled {
+		if len(a.tokens) == 0 && len(a.users) == 0 && a.oidc == nil {
+			return nil, fmt.Errorf("已启用认证，但没有配置任何API令牌、用户或OIDC")
+		}
+		logrus.Infof("已启用认证: %d个API令牌, %d个用户, OIDC: %v", len(a.tokens), len(a.users), a.oidc != nil)
+	}
+	return a, nil
+}
+
+// Enabled 是否启用认证
+func (a *Authenticator) Enabled() bool {
+	return a.config.Enabled
+}
+
+// Middleware 在启用认证时拦截未认证的/api/*和/v1/*请求，并把调用方身份放入请求context
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.config.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, err := a.authenticate(r)
+		if err == nil {
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+			return
+		}
+		if !a.protected(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err.Status() == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="GoBrowserAgent"`)
+		}
+		apierror.Write(w, r, err)
+	})
+}
+
+// protected 判断路径是否需要认证，/v1/*是OpenAI兼容的LLM网关
+func (a *Authenticator) protected(path string) bool {
+	return (strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/v1/")) && !publicPaths[path]
+}
+
+// authenticate 依次尝试Authorization头中的API令牌和登录会话Cookie
+func (a *Authenticator) authenticate(r *http.Request) (*Identity, *apierror.Error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return nil, apierror.New(apierror.CodeUnsupportedAuth)
+		}
+		name, ok := a.lookupToken(strings.TrimSpace(token))
+		if !ok {
+			return nil, apierror.New(apierror.CodeInvalidToken)
+		}
+		identity := NewIdentity(MethodToken, name, name)
+		return &identity, nil
+	}
+
+	cookie, err := r.Cookie(a.config.CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, apierror.New(apierror.CodeUnauthenticated)
+	}
+	sess, ok := a.sessions.get(cookie.Value)
+	if !ok {
+		return nil, apierror.New(apierror.CodeLoginExpired)
+	}
+	// Cookie会被浏览器自动携带，修改类请求需要确认来自本站页面
+	if !safeMethod(r.Method) && !SameOrigin(r) {
+		return nil, apierror.New(apierror.CodeCrossSiteRequest)
+	}
+	identity := sess.identity
+	return &identity, nil
+}
+
+// lookupToken 按摘要查找API令牌，返回令牌名称
+func (a *Authenticator) lookupToken(token string) (string, bool) {
+	sum := sha256.Sum256([]byte(token))
+	digest := hex.EncodeToString(sum[:])
+	for candidate, name := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(digest)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// startSession 创建登录会话并写入Cookie
+func (a *Authenticator) startSession(w http.ResponseWriter, r *http.Request, identity Identity) error {
+	id, expires, err := a.sessions.create(identity)
+	if err != nil {
+		return fmt.Errorf("创建登录会话失败: %v", err)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.config.CookieName,
+		Value:    id,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   a.secureCookie(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	logrus.Infof("用户%s已登录 (%s)", identity.Name, identity.Subject)
+	return nil
+}
+
+// endSession 删除登录会话并清除Cookie
+func (a *Authenticator) endSession(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(a.config.CookieName); err == nil {
+		a.sessions.delete(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.config.CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.secureCookie(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// secureCookie 判断Cookie是否需要Secure标记
+func (a *Authenticator) secureCookie(r *http.Request) bool {
+	return a.config.SecureCookie || isHTTPS(r)
+}
+
+// isHTTPS 判断请求是否通过HTTPS到达，包括经由反向代理转发的情况
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// safeMethod 判断请求方法是否不会修改状态
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// SameOrigin 判断请求是否来自同源页面，浏览器不带Origin和Referer时（例如非浏览器客户端）视为同源
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Errorf("编码响应失败: %v", err)
+	}
+}
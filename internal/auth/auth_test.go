@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GoBrowserAgent/internal/apierror"
+)
+
+// testPasswordHash 生成迭代次数较少的密码摘要，避免测试因PBKDF2变慢
+func testPasswordHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := pbkdf2SHA256([]byte(password), salt, 1000, passwordKeySize)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, 1000,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// newTestServer 返回带认证中间件的处理程序，/api/whoami返回调用方标识
+func newTestServer(t *testing.T, config *Config) (*Authenticator, http.Handler) {
+	t.Helper()
+	a, err := NewAuthenticator(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/providers", a.handleProviders)
+	mux.HandleFunc("/api/auth/login", a.handleLogin)
+	mux.HandleFunc("/api/auth/logout", a.handleLogout)
+	mux.HandleFunc("/api/auth/me", a.handleMe)
+	mux.HandleFunc("/api/auth/oidc/login", a.handleOIDCLogin)
+	mux.HandleFunc("/api/auth/oidc/callback", a.handleOIDCCallback)
+	mux.HandleFunc("/api/whoami", func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := FromContext(r.Context())
+		if identity == nil {
+			w.Write([]byte("anonymous"))
+			return
+		}
+		w.Write([]byte(identity.Subject))
+	})
+	return a, a.Middleware(mux)
+}
+
+func testConfig() *Config {
+	config := GetDefaultConfig()
+	config.Enabled = true
+	config.Tokens = []TokenConfig{{Name: "ci", Token: "secret-token"}}
+	config.Users = []UserConfig{{Username: "alice", PasswordHash: testPasswordHash("wonderland")}}
+	return config
+}
+
+// errorCode 返回错误响应中的错误码
+func errorCode(w *httptest.ResponseRecorder) string {
+	var body apierror.Body
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return body.Error.Code
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestBearerToken(t *testing.T) {
+	_, h := newTestServer(t, testConfig())
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		status int
+		want   string
+	}{
+		{"valid token", "/api/whoami", "Bearer secret-token", http.StatusOK, "token:ci"},
+		{"lowercase scheme", "/api/whoami", "bearer  secret-token ", http.StatusOK, "token:ci"},
+		{"gateway path", "/v1/whoami", "Bearer wrong", http.StatusUnauthorized, apierror.CodeInvalidToken},
+		{"wrong token", "/api/whoami", "Bearer wrong", http.StatusUnauthorized, apierror.CodeInvalidToken},
+		{"basic auth", "/api/whoami", "Basic YWxpY2U6d29uZGVybGFuZA==", http.StatusUnauthorized, apierror.CodeUnsupportedAuth},
+		{"empty bearer", "/api/whoami", "Bearer ", http.StatusUnauthorized, apierror.CodeUnsupportedAuth},
+		{"no credentials", "/api/whoami", "", http.StatusUnauthorized, apierror.CodeUnauthenticated},
+		{"public path", "/api/auth/providers", "", http.StatusOK, `"password":true`},
+		{"public path with bad token", "/api/auth/providers", "Bearer wrong", http.StatusOK, `"enabled":true`},
+		{"page outside api", "/index.html", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := serve(h, r)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized {
+				if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+					t.Errorf("missing WWW-Authenticate header")
+				}
+				if code := errorCode(w); code != tt.want && !strings.Contains(w.Body.String(), tt.want) {
+					t.Errorf("error = %s, want %s", w.Body, tt.want)
+				}
+				return
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want %s", w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSHA256(t *testing.T) {
+	config := testConfig()
+	// sha256("secret-token")
+	config.Tokens = []TokenConfig{{Name: "hashed", TokenSHA256: "930BBDC51B6AED5C2A5678FD6E28DEE7A05E8A4B643CFC0B4427C3EFB86C0D94"}}
+	_, h := newTestServer(t, config)
+	r := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+	r.Header.Set("Authorization", "Bearer secret-token")
+	if w := serve(h, r); w.Body.String() != "token:hashed" {
+		t.Fatalf("token by digest = %d %s", w.Code, w.Body)
+	}
+
+	config.Tokens = []TokenConfig{{Name: "short", TokenSHA256: "abcd"}}
+	if _, err := NewAuthenticator(config, nil); err == nil {
+		t.Fatal("short digest accepted")
+	}
+	config.Tokens = nil
+	config.Users = nil
+	if _, err := NewAuthenticator(config, nil); err == nil {
+		t.Fatal("enabled without credentials accepted")
+	}
+}
+
+func login(h http.Handler, username, password string, header http.Header) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"username":%q,"password":%q}`, username, password)
+	r := httptest.NewRequest(http.MethodPost, "http://agent.local/api/auth/login", strings.NewReader(body))
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	return serve(h, r)
+}
+
+func TestPasswordLogin(t *testing.T) {
+	a, h := newTestServer(t, testConfig())
+
+	for _, tt := range []struct{ username, password string }{
+		{"alice", "wrong"},
+		{"mallory", "wonderland"},
+		{"", ""},
+	} {
+		if w := login(h, tt.username, tt.password, nil); errorCode(w) != apierror.CodeInvalidCredentials {
+			t.Fatalf("login(%q, %q) = %d %s", tt.username, tt.password, w.Code, w.Body)
+		}
+	}
+	if w := login(h, "alice", "wonderland", http.Header{"Origin": {"https://evil.example"}}); errorCode(w) != apierror.CodeCrossSiteRequest {
+		t.Fatalf("cross-site login = %d %s", w.Code, w.Body)
+	}
+
+	w := login(h, "alice", "wonderland", http.Header{"Origin": {"http://agent.local"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login = %d %s", w.Code, w.Body)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	cookie := cookies[0]
+	if cookie.Name != a.config.CookieName || !cookie.HttpOnly || cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Fatalf("cookie = %+v", cookie)
+	}
+
+	request := func(method string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://agent.local/api/whoami", nil)
+		r.AddCookie(cookie)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		return serve(h, r)
+	}
+	if w := request(http.MethodGet, http.Header{"Origin": {"https://evil.example"}}); w.Body.String() != "password:alice" {
+		t.Fatalf("GET with cookie = %d %s", w.Code, w.Body)
+	}
+	if w := request(http.MethodPost, nil); w.Body.String() != "password:alice" {
+		t.Fatalf("POST without Origin = %d %s", w.Code, w.Body)
+	}
+	if w := request(http.MethodPost, http.Header{"Origin": {"http://agent.local"}}); w.Body.String() != "password:alice" {
+		t.Fatalf("same-origin POST = %d %s", w.Code, w.Body)
+	}
+	if w := request(http.MethodDelete, http.Header{"Referer": {"https://evil.example/page"}}); errorCode(w) != apierror.CodeCrossSiteRequest {
+		t.Fatalf("cross-site DELETE = %d %s", w.Code, w.Body)
+	}
+
+	// 退出后Cookie失效
+	r := httptest.NewRequest(http.MethodPost, "http://agent.local/api/auth/logout", nil)
+	r.AddCookie(cookie)
+	if w := serve(h, r); w.Code != http.StatusNoContent || w.Result().Cookies()[0].MaxAge != -1 {
+		t.Fatalf("logout = %d %v", w.Code, w.Result().Cookies())
+	}
+	if w := request(http.MethodGet, nil); errorCode(w) != apierror.CodeLoginExpired {
+		t.Fatalf("after logout = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestPasswordLoginSecureCookie(t *testing.T) {
+	_, h := newTestServer(t, testConfig())
+	w := login(h, "alice", "wonderland", http.Header{"X-Forwarded-Proto": {"https"}})
+	if w.Code != http.StatusOK || !w.Result().Cookies()[0].Secure {
+		t.Fatalf("login behind HTTPS proxy = %d %v", w.Code, w.Result().Cookies())
+	}
+
+	config := testConfig()
+	config.Enabled = false
+	_, h = newTestServer(t, config)
+	if w := login(h, "alice", "wonderland", nil); errorCode(w) != apierror.CodeAuthDisabled {
+		t.Fatalf("login with auth disabled = %d %s", w.Code, w.Body)
+	}
+	if w := serve(h, httptest.NewRequest(http.MethodGet, "/api/whoami", nil)); w.Body.String() != "anonymous" {
+		t.Fatalf("auth disabled = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		origin  string
+		referer string
+		want    bool
+	}{
+		{"no headers", "agent.local", "", "", true},
+		{"same origin", "agent.local", "http://agent.local", "", true},
+		{"same origin with port", "agent.local:8080", "https://agent.local:8080", "", true},
+		{"host case", "Agent.Local", "http://agent.local", "", true},
+		{"same referer", "agent.local", "", "http://agent.local/chat?x=1", true},
+		{"other origin", "agent.local", "https://evil.example", "", false},
+		{"other port", "agent.local:8080", "http://agent.local:9090", "", false},
+		{"subdomain", "agent.local", "http://x.agent.local", "", false},
+		{"suffix attack", "agent.local", "http://agent.local.evil.example", "", false},
+		{"null origin", "agent.local", "null", "", false},
+		{"origin wins over referer", "agent.local", "https://evil.example", "http://agent.local/", false},
+		{"other referer", "agent.local", "", "https://evil.example/agent.local", false},
+		{"malformed origin", "agent.local", "http://%zz", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/whoami", nil)
+			r.Host = tt.host
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			if got := SameOrigin(r); got != tt.want {
+				t.Fatalf("SameOrigin = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config 存储认证配置
+type Config struct {
+	// Enabled 是否启用认证，启用后所有/api/*接口都需要登录或携带API令牌
+	Enabled bool `json:"enabled"`
+	// Tokens 供脚本调用的静态API令牌
+	Tokens []TokenConfig `json:"tokens"`
+	// Users 用户名密码登录的用户
+	Users []UserConfig `json:"users"`
+	// SessionHours 登录会话的有效期（小时）
+	SessionHours int `json:"session_hours"`
+	// CookieName 登录会话Cookie的名称
+	CookieName string `json:"cookie_name"`
+	// SecureCookie 为true时Cookie总是带Secure标记，否则仅在HTTPS请求中带上
+	SecureCookie bool `json:"secure_cookie"`
+	// OIDC 可选的OpenID Connect登录
+	OIDC *OIDCConfig `json:"oidc"`
+}
+
+// TokenConfig 静态API令牌
+type TokenConfig struct {
+	// Name 令牌名称，用于日志和用量统计
+	Name string `json:"name"`
+	// Token 令牌明文，建议改用TokenSHA256
+	Token string `json:"token,omitempty"`
+	// TokenSHA256 令牌的SHA-256摘要（十六进制）
+	TokenSHA256 string `json:"token_sha256,omitempty"`
+}
+
+// UserConfig 用户名密码登录的用户
+type UserConfig struct {
+	Username string `json:"username"`
+	// PasswordHash 由hash-password子命令生成的密码摘要
+	PasswordHash string `json:"password_hash"`
+}
+
+// OIDCConfig OpenID Connect登录配置
+type OIDCConfig struct {
+	// Issuer 身份提供方地址，用于获取/.well-known/openid-configuration
+	Issuer string `json:"issuer"`
+	// ClientID 在身份提供方注册的客户端ID
+	ClientID string `json:"client_id"`
+	// ClientSecret 客户端密钥
+	ClientSecret string `json:"client_secret"`
+	// RedirectURL 回调地址，需指向/api/auth/oidc/callback
+	RedirectURL string `json:"redirect_url"`
+	// Scopes 申请的权限范围，默认为openid profile email
+	Scopes []string `json:"scopes"`
+	// UsernameClaim 作为显示名称的ID令牌字段，默认依次尝试preferred_username、email、sub；
+	// 权限绑定和限流始终按sub识别用户
+	UsernameClaim string `json:"username_claim"`
+	// AllowedUsers 允许登录的用户名或oidc:<sub>，为空时允许身份提供方认证的所有用户
+	AllowedUsers []string `json:"allowed_users"`
+}
+
+// LoadConfig 从配置文件加载认证配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		Auth *Config `json:"auth"`
+	}{
+		Auth: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.Auth, nil
+}
+
+// GetDefaultConfig 获取默认配置，默认不启用认证
+func GetDefaultConfig() *Config {
+	return &Config{
+		SessionHours: 12,
+		CookieName:   "gba_session",
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"GoBrowserAgent/internal/apierror"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxLoginBodySize 登录请求体的最大字节数
+const maxLoginBodySize = 64 << 10
+
+// LoginRequest 用户名密码登录请求
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ProvidersResponse 页面据此决定显示哪些登录方式
+type ProvidersResponse struct {
+	Enabled  bool `json:"enabled"`
+	Password bool `json:"password"`
+	OIDC     bool `json:"oidc"`
+}
+
+// RegisterHandlers 注册登录相关的HTTP处理程序
+func (a *Authenticator) RegisterHandlers() {
+	http.HandleFunc("/api/auth/providers", a.handleProviders)
+	http.HandleFunc("/api/auth/login", a.handleLogin)
+	http.HandleFunc("/api/auth/logout", a.handleLogout)
+	http.HandleFunc("/api/auth/me", a.handleMe)
+	http.HandleFunc("/api/auth/oidc/login", a.handleOIDCLogin)
+	http.HandleFunc("/api/auth/oidc/callback", a.handleOIDCCallback)
+}
+
+// handleProviders 返回可用的登录方式
+func (a *Authenticator) handleProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+	writeJSON(w, http.StatusOK, ProvidersResponse{
+		Enabled:  a.config.Enabled,
+		Password: len(a.users) > 0,
+		OIDC:     a.oidc != nil,
+	})
+}
+
+// handleLogin 用户名密码登录
+func (a *Authenticator) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodPost))
+		return
+	}
+	if !a.config.Enabled {
+		apierror.Write(w, r, apierror.New(apierror.CodeAuthDisabled))
+		return
+	}
+	if !SameOrigin(r) {
+		apierror.Write(w, r, apierror.New(apierror.CodeCrossSiteRequest))
+		return
+	}
+
+	var req LoginRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLoginBodySize)).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.FromDecode(err))
+		return
+	}
+
+	hash, ok := a.users[req.Username]
+	if !ok {
+		// 用户不存在时同样计算一次摘要，避免通过响应时间判断用户名是否存在
+		hash = dummyHash()
+	}
+	if !VerifyPassword(req.Password, hash) || !ok {
+		logrus.Warnf("用户%q登录失败，来源: %s", req.Username, r.RemoteAddr)
+		apierror.Write(w, r, apierror.New(apierror.CodeInvalidCredentials))
+		return
+	}
+
+	identity := NewIdentity(MethodPassword, req.Username, req.Username)
+	if err := a.startSession(w, r, identity); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, identity)
+}
+
+// handleLogout 退出登录
+func (a *Authenticator) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodPost))
+		return
+	}
+	if !SameOrigin(r) {
+		apierror.Write(w, r, apierror.New(apierror.CodeCrossSiteRequest))
+		return
+	}
+	a.endSession(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMe 返回当前登录的用户
+func (a *Authenticator) handleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+	identity, ok := FromContext(r.Context())
+	if !ok {
+		// 未启用认证时中间件不会设置身份
+		writeJSON(w, http.StatusOK, map[string]bool{"enabled": false})
+		return
+	}
+	writeJSON(w, http.StatusOK, identity)
+}
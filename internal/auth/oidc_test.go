@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+)
+
+// fakeIdP 测试用的OIDC身份提供方，令牌端点按最近一次授权请求中的nonce签发ID令牌
+type fakeIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	discoveryRequests atomic.Int32
+	// discoveryFail 为true时发现文档返回500
+	discoveryFail atomic.Bool
+
+	mu sync.Mutex
+	// claims 写入ID令牌的字段，nonce和challenge来自授权地址
+	claims    map[string]interface{}
+	nonce     string
+	challenge string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{t: t, key: key, claims: map[string]interface{}{"sub": "u-1", "preferred_username": "alice"}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		idp.discoveryRequests.Add(1)
+		if idp.discoveryFail.Load() {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		claims := map[string]interface{}{
+			"iss": idp.server.URL, "aud": "agent", "nonce": idp.nonce,
+			"exp": time.Now().Add(time.Hour).Unix(), "iat": time.Now().Unix(),
+		}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(claims)})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// sign 生成RS256签名的JWT
+func (idp *fakeIdP) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *fakeIdP) config() *Config {
+	config := GetDefaultConfig()
+	config.Enabled = true
+	config.OIDC = &OIDCConfig{
+		Issuer:      idp.server.URL,
+		ClientID:    "agent",
+		RedirectURL: "http://agent.local/api/auth/oidc/callback",
+	}
+	return config
+}
+
+// startLogin 发起登录，记录授权地址中的nonce和challenge，返回state Cookie
+func (idp *fakeIdP) startLogin(h http.Handler) *http.Cookie {
+	idp.t.Helper()
+	w := serve(h, httptest.NewRequest(http.MethodGet, "http://agent.local/api/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		idp.t.Fatalf("oidc login = %d %s", w.Code, w.Body)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), idp.server.URL+"/authorize?") {
+		idp.t.Fatalf("redirect = %s", w.Header().Get("Location"))
+	}
+	query := location.Query()
+	if query.Get("client_id") != "agent" || query.Get("code_challenge_method") != "S256" || query.Get("scope") != "openid profile email" {
+		idp.t.Fatalf("authorize query = %v", query)
+	}
+	idp.mu.Lock()
+	idp.nonce = query.Get("nonce")
+	idp.challenge = query.Get("code_challenge")
+	idp.mu.Unlock()
+
+	cookie := w.Result().Cookies()[0]
+	if cookie.Name != oidcStateCookie || cookie.Value != query.Get("state") || !cookie.HttpOnly {
+		idp.t.Fatalf("state cookie = %+v", cookie)
+	}
+	return cookie
+}
+
+// callback 模拟身份提供方跳转回来，返回跳转地址和会话Cookie
+func callback(h http.Handler, state string, cookie *http.Cookie, code string) (string, *http.Cookie) {
+	r := httptest.NewRequest(http.MethodGet, "http://agent.local/api/auth/oidc/callback?"+url.Values{"state": {state}, "code": {code}}.Encode(), nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := serve(h, r)
+	for _, c := range w.Result().Cookies() {
+		if c.Name != oidcStateCookie && c.Value != "" {
+			return w.Header().Get("Location"), c
+		}
+	}
+	return w.Header().Get("Location"), nil
+}
+
+// loginErrorCode 返回回调失败时跳转地址中的错误码
+func loginErrorCode(location string) string {
+	u, _ := url.Parse(location)
+	return u.Query().Get("login_error_code")
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newFakeIdP(t)
+	_, h := newTestServer(t, idp.config())
+
+	state := idp.startLogin(h)
+	location, session := callback(h, state.Value, state, "good-code")
+	if location != "/" || session == nil {
+		t.Fatalf("callback = %s, session %v", location, session)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+	r.AddCookie(session)
+	if w := serve(h, r); w.Body.String() != "oidc:u-1" {
+		t.Fatalf("whoami = %s", w.Body)
+	}
+
+	// 同一个state只能使用一次
+	if location, _ := callback(h, state.Value, state, "good-code"); loginErrorCode(location) != apierror.CodeLoginFailed {
+		t.Fatalf("replayed callback = %s", location)
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	idp := newFakeIdP(t)
+	config := idp.config()
+	config.OIDC.AllowedUsers = []string{"alice", "oidc:u-2"}
+	_, h := newTestServer(t, config)
+
+	tests := []struct {
+		name   string
+		prep   func(state *http.Cookie) (string, *http.Cookie, string)
+		claims map[string]interface{}
+		code   string
+	}{
+		{"state without cookie", func(c *http.Cookie) (string, *http.Cookie, string) {
+			return c.Value, nil, "good-code"
+		}, nil, apierror.CodeLoginStateInvalid},
+		{"state differs from cookie", func(c *http.Cookie) (string, *http.Cookie, string) {
+			return "forged", c, "good-code"
+		}, nil, apierror.CodeLoginStateInvalid},
+		{"unknown state", func(c *http.Cookie) (string, *http.Cookie, string) {
+			return "forged", &http.Cookie{Name: oidcStateCookie, Value: "forged"}, "good-code"
+		}, nil, apierror.CodeLoginFailed},
+		{"bad code", func(c *http.Cookie) (string, *http.Cookie, string) {
+			return c.Value, c, "bad-code"
+		}, nil, apierror.CodeLoginFailed},
+		{"nonce mismatch", nil, map[string]interface{}{"nonce": "other"}, apierror.CodeLoginFailed},
+		{"audience mismatch", nil, map[string]interface{}{"aud": []string{"someone-else"}}, apierror.CodeLoginFailed},
+		{"issuer mismatch", nil, map[string]interface{}{"iss": "https://evil.example"}, apierror.CodeLoginFailed},
+		{"expired", nil, map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, apierror.CodeLoginFailed},
+		{"not allowed", nil, map[string]interface{}{"sub": "u-3", "preferred_username": "mallory"}, apierror.CodeLoginNotAllowed},
+		{"allowed by subject", nil, map[string]interface{}{"sub": "u-2", "preferred_username": "bob"}, ""},
+		{"allowed by name", nil, map[string]interface{}{"preferred_username": "Alice"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.mu.Lock()
+			idp.claims = map[string]interface{}{"sub": "u-1", "preferred_username": "alice"}
+			for k, v := range tt.claims {
+				idp.claims[k] = v
+			}
+			idp.mu.Unlock()
+
+			state := idp.startLogin(h)
+			query, cookie, code := state.Value, state, "good-code"
+			if tt.prep != nil {
+				query, cookie, code = tt.prep(state)
+			}
+			location, session := callback(h, query, cookie, code)
+			if got := loginErrorCode(location); got != tt.code {
+				t.Fatalf("callback = %s, want %s", location, tt.code)
+			}
+			if (session != nil) != (tt.code == "") {
+				t.Fatalf("session cookie = %v", session)
+			}
+		})
+	}
+}
+
+func TestOIDCPendingLimit(t *testing.T) {
+	idp := newFakeIdP(t)
+	a, _ := newTestServer(t, idp.config())
+	p := a.oidc
+	ctx := context.Background()
+
+	first, firstState, err := p.authCodeURL(ctx)
+	if err != nil || first == "" {
+		t.Fatal(err)
+	}
+	// 确保第一个登录最早发起，另外放一个已超时的登录
+	p.mu.Lock()
+	p.pending[firstState].created = time.Now().Add(-time.Minute)
+	p.pending["expired"] = &pendingLogin{created: time.Now().Add(-oidcLoginTimeout - time.Second)}
+	p.mu.Unlock()
+
+	var last string
+	for i := 0; i < maxPendingOIDCLogins; i++ {
+		if _, last, err = p.authCodeURL(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) != maxPendingOIDCLogins {
+		t.Fatalf("pending logins = %d, want %d", len(p.pending), maxPendingOIDCLogins)
+	}
+	if _, ok := p.pending["expired"]; ok {
+		t.Error("expired login was kept")
+	}
+	if _, ok := p.pending[firstState]; ok {
+		t.Error("oldest login was not evicted")
+	}
+	if _, ok := p.pending[last]; !ok {
+		t.Error("newest login is missing")
+	}
+}
+
+func TestOIDCDiscoveryBackoff(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.discoveryFail.Store(true)
+	a, h := newTestServer(t, idp.config())
+	p := a.oidc
+
+	// 获取失败后在重试间隔内直接返回上次的错误，不再请求身份提供方
+	for i := 0; i < 3; i++ {
+		w := serve(h, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+		if errorCode(w) != apierror.CodeIdPUnreachable {
+			t.Fatalf("login with failing IdP = %d %s", w.Code, w.Body)
+		}
+	}
+	if n := idp.discoveryRequests.Load(); n != 1 {
+		t.Fatalf("discovery requests = %d, want 1", n)
+	}
+
+	idp.discoveryFail.Store(false)
+	p.mu.Lock()
+	p.discoveryFailedAt = time.Now().Add(-oidcDiscoveryRetry)
+	p.mu.Unlock()
+
+	// 重试间隔过后，并发的登录请求只获取一次发现文档，之后使用缓存
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.getDiscovery(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	idp.startLogin(h)
+	if n := idp.discoveryRequests.Load(); n != 2 {
+		t.Fatalf("discovery requests = %d, want 2", n)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	config := idp.config()
+	config.OIDC.Issuer = idp.server.URL + "/"
+	a, _ := newTestServer(t, config)
+	if _, err := a.oidc.getDiscovery(context.Background()); err != nil {
+		t.Fatalf("issuer with trailing slash: %v", err)
+	}
+
+	other := newFakeIdP(t)
+	config = other.config()
+	// 发现文档中的issuer与配置不同时拒绝
+	config.OIDC.Issuer = other.server.URL + "/tenant"
+	a, _ = newTestServer(t, config)
+	if _, err := a.oidc.getDiscovery(context.Background()); err == nil {
+		t.Fatal("discovery from another path accepted")
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"net/http"
+
+	"GoBrowserAgent/internal/openapi"
+)
+
+// Routes 返回RegisterHandlers注册的接口，用于生成OpenAPI文档和校验请求
+func (a *Authenticator) Routes() []openapi.Route {
+	login := openapi.SchemaOf(LoginRequest{})
+	login.Required = []string{"username", "password"}
+
+	identity := openapi.SchemaOf(Identity{})
+	identity.Properties["method"].Enum = []interface{}{MethodToken, MethodPassword, MethodOIDC}
+
+	redirect := map[string]*openapi.Response{"302": {Description: "跳转"}}
+
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/api/auth/providers", Operation: openapi.Operation{
+			Tags:        []string{"auth"},
+			Summary:     "可用的登录方式",
+			OperationID: "authProviders",
+			Public:      true,
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("是否启用认证以及支持的登录方式", openapi.SchemaOf(ProvidersResponse{})),
+			},
+		}},
+		{Method: http.MethodPost, Path: "/api/auth/login", Operation: openapi.Operation{
+			Tags:        []string{"auth"},
+			Summary:     "用户名密码登录",
+			Description: "成功后设置会话Cookie。只接受同源请求。",
+			OperationID: "login",
+			Public:      true,
+			MaxBodySize: maxLoginBodySize,
+			RequestBody: openapi.JSONBody("", login),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("登录的用户", identity),
+				"401": openapi.JSONResponse("用户名或密码错误", openapi.Ref("Error")),
+			},
+		}},
+		{Method: http.MethodPost, Path: "/api/auth/logout", Operation: openapi.Operation{
+			Tags:        []string{"auth"},
+			Summary:     "退出登录",
+			Description: "清除会话Cookie。只接受同源请求。",
+			OperationID: "logout",
+			Public:      true,
+			Responses:   map[string]*openapi.Response{"204": {Description: "已退出"}},
+		}},
+		{Method: http.MethodGet, Path: "/api/auth/me", Operation: openapi.Operation{
+			Tags:        []string{"auth"},
+			Summary:     "当前用户",
+			Description: "未启用认证时返回{\"enabled\": false}。",
+			OperationID: "me",
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("当前调用方的身份", identity),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/auth/oidc/login", Operation: openapi.Operation{
+			Tags:        []string{"auth"},
+			Summary:     "OIDC登录",
+			Description: "在浏览器中打开，跳转到身份提供方的授权页面。",
+			OperationID: "oidcLogin",
+			Public:      true,
+			Responses:   redirect,
+		}},
+		{Method: http.MethodGet, Path: "/api/auth/oidc/callback", Operation: openapi.Operation{
+			Tags:        []string{"auth"},
+			Summary:     "OIDC回调",
+			Description: "身份提供方授权后跳转到此地址，成功后跳回首页；失败时跳回首页并带上login_error和login_error_code参数。",
+			OperationID: "oidcCallback",
+			Public:      true,
+			Parameters: []openapi.Parameter{
+				openapi.Query("code", "授权码", false, openapi.String("")),
+				openapi.Query("state", "发起登录时生成的state", false, openapi.String("")),
+				openapi.Query("error", "身份提供方返回的错误", false, openapi.String("")),
+			},
+			Responses: redirect,
+		}},
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// loginSession 登录会话，只保存在内存中，服务重启后需要重新登录
+type loginSession struct {
+	identity Identity
+	expires  time.Time
+}
+
+// sessionStore 登录会话存储
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*loginSession
+	ttl      time.Duration
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{
+		sessions: make(map[string]*loginSession),
+		ttl:      ttl,
+	}
+}
+
+// create 为用户创建登录会话并返回会话ID
+func (s *sessionStore) create(identity Identity) (string, time.Time, error) {
+	id, err := randomString(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeExpired()
+	s.sessions[id] = &loginSession{identity: identity, expires: expires}
+	return id, expires, nil
+}
+
+// get 返回未过期的登录会话
+func (s *sessionStore) get(id string) (*loginSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	return sess, true
+}
+
+// delete 删除登录会话
+func (s *sessionStore) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// removeExpired 清理过期会话，调用方需持有s.mu
+func (s *sessionStore) removeExpired() {
+	now := time.Now()
+	for id, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// randomString 生成n字节随机数的十六进制字符串
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
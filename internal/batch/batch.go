@@ -0,0 +1,366 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/internal/service/llm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Request 定义输入JSONL中的一条聊天请求
+type Request struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	System string `json:"system,omitempty"`
+	Model  string `json:"model,omitempty"`
+	// MaxTokens、Temperature、TopP 省略时使用LLM配置中的默认值，可以显式设置为0
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+}
+
+// Result 定义输出JSONL中的一条结果
+type Result struct {
+	ID         string     `json:"id"`
+	Model      string     `json:"model,omitempty"`
+	Output     string     `json:"output,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Usage      *llm.Usage `json:"usage,omitempty"`
+	LatencyMS  int64      `json:"latency_ms"`
+	Attempts   int        `json:"attempts"`
+	FinishedAt time.Time  `json:"finished_at"`
+}
+
+// Options 批处理运行参数
+type Options struct {
+	// Concurrency 同时进行的请求数
+	Concurrency int
+	// RatePerSecond 每秒最多发起的请求数，0表示不限速
+	RatePerSecond float64
+	// Retries 单条请求失败后的重试次数
+	Retries int
+	// RetryDelay 重试前的等待时间，每次重试翻倍
+	RetryDelay time.Duration
+	// Resume 为true时跳过输出文件中已成功完成的id，并追加写入
+	Resume bool
+}
+
+// Summary 批处理运行统计
+type Summary struct {
+	Total     int
+	Skipped   int
+	Succeeded int
+	Failed    int
+	Duration  time.Duration
+}
+
+// Runner 批量推理执行器
+type Runner struct {
+	Service *llm.Service
+	Options Options
+}
+
+// NewRunner 创建新的批量推理执行器
+func NewRunner(service *llm.Service, options Options) *Runner {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = time.Second
+	}
+	return &Runner{
+		Service: service,
+		Options: options,
+	}
+}
+
+// Run 读取inputPath中的请求，执行后将结果写入outputPath
+func (r *Runner) Run(ctx context.Context, inputPath, outputPath string) (*Summary, error) {
+	started := time.Now()
+
+	requests, err := ReadRequests(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{Total: len(requests)}
+
+	// 断点续跑时跳过已完成的请求
+	completed := map[string]bool{}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if r.Options.Resume {
+		completed, err = CompletedIDs(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	pending := make([]Request, 0, len(requests))
+	for _, req := range requests {
+		if completed[req.ID] {
+			summary.Skipped++
+			continue
+		}
+		pending = append(pending, req)
+	}
+	if summary.Skipped > 0 {
+		logrus.Infof("跳过%d条已完成的请求", summary.Skipped)
+	}
+
+	out, err := os.OpenFile(outputPath, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开输出文件失败: %v", err)
+	}
+	defer out.Close()
+
+	if r.Options.Resume {
+		if err := terminateLastLine(out); err != nil {
+			return nil, fmt.Errorf("修复输出文件失败: %v", err)
+		}
+	}
+
+	var writeMu sync.Mutex
+	writer := bufio.NewWriter(out)
+	writeResult := func(result *Result) error {
+		line, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		// 每条结果立即落盘，保证中断后可以续跑
+		return writer.Flush()
+	}
+
+	var limiter <-chan time.Time
+	if r.Options.RatePerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.Options.RatePerSecond))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	jobs := make(chan Request)
+	var wg sync.WaitGroup
+	var statsMu sync.Mutex
+	var writeErr error
+
+	for i := 0; i < r.Options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				result := r.execute(ctx, req, limiter)
+				if ctx.Err() != nil && result.Error != "" {
+					// 被取消的请求不写入结果，续跑时会重新执行
+					continue
+				}
+
+				statsMu.Lock()
+				if result.Error != "" {
+					summary.Failed++
+					logrus.Warnf("请求%s失败: %s", result.ID, result.Error)
+				} else {
+					summary.Succeeded++
+				}
+				statsMu.Unlock()
+
+				if err := writeResult(result); err != nil {
+					statsMu.Lock()
+					writeErr = fmt.Errorf("写入结果失败: %v", err)
+					statsMu.Unlock()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, req := range pending {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- req:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary.Duration = time.Since(started)
+
+	if writeErr != nil {
+		return summary, writeErr
+	}
+	if ctx.Err() != nil {
+		return summary, fmt.Errorf("批处理被中断: %v", ctx.Err())
+	}
+	return summary, nil
+}
+
+// execute 执行单条请求，失败时按配置重试
+func (r *Runner) execute(ctx context.Context, req Request, limiter <-chan time.Time) *Result {
+	result := &Result{ID: req.ID, Model: req.Model}
+
+	chatReq := llm.ChatRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	}
+	if req.System != "" {
+		chatReq.Messages = append(chatReq.Messages, llm.ChatMessage{Role: "system", Content: req.System})
+	}
+	chatReq.Messages = append(chatReq.Messages, llm.ChatMessage{Role: "user", Content: req.Prompt})
+
+	delay := r.Options.RetryDelay
+	for attempt := 0; attempt <= r.Options.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		if limiter != nil {
+			select {
+			case <-ctx.Done():
+			case <-limiter:
+			}
+		}
+		if ctx.Err() != nil {
+			result.Error = ctx.Err().Error()
+			break
+		}
+
+		result.Attempts = attempt + 1
+		started := time.Now()
+		resp, err := r.Service.Complete(ctx, chatReq)
+		result.LatencyMS = time.Since(started).Milliseconds()
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+
+		result.Error = ""
+		result.Output = resp.Choices[0].Message.Content
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+		usage := resp.Usage
+		result.Usage = &usage
+		break
+	}
+
+	result.FinishedAt = time.Now()
+	return result
+}
+
+// ReadRequests 读取JSONL格式的请求文件，跳过空行，缺少id的请求以行号作为id
+func ReadRequests(path string) ([]Request, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开输入文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var requests []Request
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			return nil, fmt.Errorf("第%d行解析失败: %v", lineNo, err)
+		}
+		if req.Prompt == "" {
+			return nil, fmt.Errorf("第%d行缺少prompt", lineNo)
+		}
+		if req.ID == "" {
+			req.ID = fmt.Sprintf("line-%d", lineNo)
+		}
+		if prev, ok := seen[req.ID]; ok {
+			return nil, fmt.Errorf("第%d行的id %q与第%d行重复", lineNo, req.ID, prev)
+		}
+		seen[req.ID] = lineNo
+		requests = append(requests, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取输入文件失败: %v", err)
+	}
+
+	return requests, nil
+}
+
+// CompletedIDs 读取已有输出文件中成功完成的请求id，文件不存在时返回空集合
+func CompletedIDs(path string) (map[string]bool, error) {
+	completed := map[string]bool{}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return completed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开输出文件失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			// 中断时可能留下不完整的最后一行，忽略即可
+			continue
+		}
+		if result.Error == "" && result.ID != "" {
+			completed[result.ID] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取输出文件失败: %v", err)
+	}
+
+	return completed, nil
+}
+
+// terminateLastLine 在文件最后一行未以换行结束时补上换行，避免续跑写入的结果与中断残留的半行粘连
+func terminateLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	reader, err := os.Open(file.Name())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	last := make([]byte, 1)
+	if _, err := reader.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = file.Write([]byte{'\n'})
+	return err
+}
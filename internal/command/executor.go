@@ -0,0 +1,211 @@
+// Package command 在浏览器中执行解析后的命令，供交互模式和脚本模式共用
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/vault"
+	"GoBrowserAgent/pkg/browser"
+	"GoBrowserAgent/pkg/parser"
+	"GoBrowserAgent/pkg/task"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Executor 持有一个浏览器页面，按顺序执行命令
+//
+// 浏览器在第一次执行页面命令时才启动，只查看帮助或直接退出时不会启动Chromium。
+type Executor struct {
+	Registry *parser.Registry
+	// Vault 凭据保险库，login命令从中读取站点配置并保存登录状态，state命令在其中保存和恢复浏览器状态；
+	// 为nil时login只使用命令中的用户名和密码，state命令不可用
+	Vault *vault.Vault
+	// Access 按context中的调用方校验task.run、目标站点和secret.manage权限，为nil时不做检查（命令行）
+	Access *rbac.Enforcer
+
+	config    *browser.Config
+	transport http.RoundTripper
+
+	mu      sync.Mutex
+	browser browser.Browser
+	page    browser.Page
+}
+
+// NewExecutor 创建执行器，transport用于http后端的出站请求，为nil时使用默认传输
+func NewExecutor(config *browser.Config, transport http.RoundTripper) *Executor {
+	return &Executor{
+		Registry:  parser.NewDefaultRegistry(),
+		config:    config,
+		transport: transport,
+	}
+}
+
+// NewExecutorWithBrowser 创建在已有浏览器上执行命令的执行器，Close时关闭该浏览器
+func NewExecutorWithBrowser(b browser.Browser) *Executor {
+	return &Executor{
+		Registry: parser.NewDefaultRegistry(),
+		browser:  b,
+	}
+}
+
+// Page 返回当前页面，浏览器还没有启动时启动浏览器并打开页面
+func (e *Executor) Page(ctx context.Context) (browser.Page, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.page != nil {
+		return e.page, nil
+	}
+
+	if e.browser == nil {
+		var b browser.Browser
+		var err error
+		if e.config.Backend == browser.BackendHTTP {
+			b = browser.NewHTTPBrowser(e.config, e.transport)
+		} else {
+			logrus.Info("正在启动浏览器")
+			b, err = browser.New(ctx, e.config)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("启动浏览器失败: %v", err)
+		}
+		e.browser = b
+	}
+	page, err := e.browser.NewPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.page = page
+	return page, nil
+}
+
+// CurrentPage 返回已经打开的页面，浏览器还没有启动时返回nil，不会启动浏览器
+func (e *Executor) CurrentPage() browser.Page {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.page
+}
+
+// Execute 把命令转换为任务并在当前页面上执行
+func (e *Executor) Execute(ctx context.Context, cmd *parser.Command) (*task.Result, error) {
+	t, err := task.FromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.authorize(ctx, t); err != nil {
+		return nil, err
+	}
+	switch t := t.(type) {
+	case *task.LoginTask:
+		if err := e.prepareLogin(ctx, t); err != nil {
+			return nil, err
+		}
+		if err := e.Access.CheckSite(ctx, t.URL); err != nil {
+			return nil, err
+		}
+	case *task.StateTask:
+		if e.Vault != nil {
+			if err := e.Access.Check(ctx, rbac.PermSecretManage, "state:"+t.StateName); err != nil {
+				return nil, err
+			}
+			t.States = e.Vault
+		}
+	}
+	page, err := e.Page(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t.Run(ctx, page)
+}
+
+// authorize 校验运行任务的权限，任务要打开的地址还需要在调用方角色的sites中
+//
+// 登录任务的地址可能来自站点配置，由Execute在补全之后再校验站点。
+func (e *Executor) authorize(ctx context.Context, t task.Task) error {
+	var target string
+	switch t := t.(type) {
+	case *task.NavigateTask:
+		target = t.URL
+	case *task.SearchTask:
+		target = t.URL
+	case *task.FormTask:
+		target = t.URL
+	}
+	if target != "" {
+		return e.Access.CheckSite(ctx, target)
+	}
+	return e.Access.Check(ctx, rbac.PermTaskRun, t.Name())
+}
+
+// prepareLogin 按名称或登录页地址查找站点配置，补全登录任务中没有给出的参数
+//
+// 给出地址但没有匹配的站点配置时只使用命令中的用户名和密码，也不保存登录状态。
+// 读取站点配置需要secret.manage权限。
+func (e *Executor) prepareLogin(ctx context.Context, t *task.LoginTask) error {
+	site := t.Profile
+	if site == "" {
+		site = t.URL
+	}
+	if e.Vault == nil {
+		if t.Profile != "" {
+			return fmt.Errorf("未配置凭据保险库，无法使用站点配置%s", t.Profile)
+		}
+		return nil
+	}
+	if t.Profile == "" && !e.Access.Allowed(ctx, rbac.PermSecretManage) {
+		// 无权读取保险库时不按地址匹配站点配置
+		return nil
+	}
+	if err := e.Access.Check(ctx, rbac.PermSecretManage, "profile:"+site); err != nil {
+		return err
+	}
+	profile, err := e.Vault.Lookup(site)
+	if errors.Is(err, vault.ErrNotFound) && t.Profile == "" {
+		logrus.Debugf("没有与%s匹配的站点配置", site)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.URL == "" {
+		t.URL = profile.URL
+	}
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&t.Username, profile.Username)
+	fill(&t.Password, profile.Password)
+	fill(&t.UsernameSelector, profile.UsernameSelector)
+	fill(&t.PasswordSelector, profile.PasswordSelector)
+	fill(&t.SubmitSelector, profile.SubmitSelector)
+	if t.Success == nil && profile.Success != "" {
+		cond, err := parser.ParseSuccessCondition(profile.Success)
+		if err != nil {
+			return fmt.Errorf("站点%s: %v", profile.Name, err)
+		}
+		t.Success = cond
+	}
+	t.Sessions = e.Vault
+	t.Session = profile.Name
+	t.SessionTTL = e.Vault.SessionTTL(profile)
+	return nil
+}
+
+// Close 关闭浏览器
+func (e *Executor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.browser == nil {
+		return nil
+	}
+	err := e.browser.Close()
+	e.browser, e.page = nil, nil
+	return err
+}
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/vault"
+	"GoBrowserAgent/pkg/browser"
+)
+
+const testPolicy = `{
+  "default_role": "viewer",
+  "roles": {
+    "viewer":   { "permissions": ["artifact.view"] },
+    "operator": { "permissions": ["task.run"], "sites": ["127.0.0.1"] },
+    "admin":    { "permissions": ["*"], "sites": ["*"] }
+  },
+  "bindings": { "password:alice": ["operator"], "password:root": ["admin"] }
+}`
+
+func newTestExecutor(t *testing.T) *Executor {
+	t.Helper()
+	dir := t.TempDir()
+	policyFile := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(policyFile, []byte(testPolicy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	access, err := rbac.NewEnforcer(&rbac.Config{PolicyFile: policyFile}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(vault.KeyEnv, "")
+	v, err := vault.Open(&vault.Config{
+		Path:     filepath.Join(dir, "vault.enc"),
+		KeyFile:  filepath.Join(dir, "vault.key"),
+		StateDir: filepath.Join(dir, "states"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := browser.GetDefaultConfig()
+	config.Backend = browser.BackendHTTP
+	e := NewExecutor(config, nil)
+	e.Vault = v
+	e.Access = access
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func asUser(name string) context.Context {
+	identity := auth.NewIdentity(auth.MethodPassword, name, name)
+	return auth.WithIdentity(context.Background(), &identity)
+}
+
+func TestExecutorAccess(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>ok</title></head><body></body></html>"))
+	}))
+	defer site.Close()
+
+	e := newTestExecutor(t)
+	tests := []struct {
+		name string
+		user string
+		line string
+		code string
+	}{
+		{"allowed site", "alice", "go " + site.URL, ""},
+		{"site not allowed", "alice", "go https://www.baidu.com", apierror.CodeSiteNotAllowed},
+		{"search on other site", "alice", "search https://www.baidu.com query=golang input=#kw", apierror.CodeSiteNotAllowed},
+		{"login page not allowed", "alice", "login https://www.baidu.com/login username=a password=b", apierror.CodeSiteNotAllowed},
+		{"current page task", "alice", "wait 1ms", ""},
+		{"no task.run", "bob", "wait 1ms", apierror.CodePermissionDenied},
+		{"state needs secret.manage", "alice", "state save demo", apierror.CodePermissionDenied},
+		{"profile needs secret.manage", "alice", "login crm", apierror.CodePermissionDenied},
+		{"admin state", "root", "state save demo", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := e.Registry.Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.line, err)
+			}
+			_, err = e.Execute(asUser(tt.user), cmd)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("Execute: %v", err)
+				}
+				return
+			}
+			var denied *rbac.DeniedError
+			if !errors.As(err, &denied) || denied.Code != tt.code {
+				t.Fatalf("Execute = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/taskbus"
+	"GoBrowserAgent/internal/vault"
+	"GoBrowserAgent/pkg/browser"
+	"GoBrowserAgent/pkg/parser"
+	"GoBrowserAgent/pkg/task"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxTaskCommands 一个任务最多包含的命令数，包括运行中补充的命令
+const MaxTaskCommands = 100
+
+// ErrRunnerClosed 服务正在关闭，不再接受新任务
+var ErrRunnerClosed = errors.New("服务正在关闭，不再接受新任务")
+
+// Runner 在后台运行网页提交的浏览器任务
+//
+// 每个任务使用独立的执行器和浏览器，依次执行其中的命令，并通过任务事件总线报告每条命令的结果。
+// 执行Confirm中的命令前请求用户批准；用户在任务进行中补充的命令排在剩余的命令之后执行。
+type Runner struct {
+	Bus *taskbus.Bus
+	// Access 任务中的每条命令按提交任务的调用方校验权限和目标站点
+	Access *rbac.Enforcer
+	// Vault 凭据保险库，为nil时任务中的login只能使用命令中的用户名和密码
+	Vault *vault.Vault
+	// Confirm 执行前需要用户批准的命令名称
+	Confirm map[string]bool
+
+	config    *browser.Config
+	transport http.RoundTripper
+	registry  *parser.Registry
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+// NewRunner 创建任务运行器，默认在登录、提交表单和执行页面脚本前请求批准
+func NewRunner(config *browser.Config, transport http.RoundTripper, bus *taskbus.Bus) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		Bus:       bus,
+		Confirm:   map[string]bool{"login": true, "form": true, "script": true},
+		config:    config,
+		transport: transport,
+		registry:  parser.NewDefaultRegistry(),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Parse 解析任务中的命令，空行和#开头的注释会被忽略，只接受操作页面的命令
+func (r *Runner) Parse(lines []string) ([]*parser.Command, error) {
+	var cmds []*parser.Command
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, err := r.registry.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("第%d条命令: %v", i+1, err)
+		}
+		if _, err := task.FromCommand(cmd); err != nil {
+			return nil, fmt.Errorf("第%d条命令: %v", i+1, err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("任务中没有命令")
+	}
+	if len(cmds) > MaxTaskCommands {
+		return nil, fmt.Errorf("任务最多包含%d条命令", MaxTaskCommands)
+	}
+	return cmds, nil
+}
+
+// Start 登记任务并在后台依次执行命令，ctx携带调用方身份
+//
+// 任务不随提交它的请求或连接结束，只在用户取消、命令失败或Close时停止。
+func (r *Runner) Start(ctx context.Context, owner string, cmds []*parser.Command) (*taskbus.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrRunnerClosed
+	}
+
+	taskCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(r.ctx, cancel)
+	t := r.Bus.Start(taskCtx, owner, fmt.Sprintf("共%d条命令", len(cmds)))
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		defer cancel()
+		defer stop()
+		t.Finish(r.run(t, cmds))
+	}()
+	return t, nil
+}
+
+// Close 取消进行中的任务并等待浏览器关闭
+func (r *Runner) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待浏览器任务结束超时: %v", ctx.Err())
+	}
+}
+
+// run 执行任务中的命令，返回第一条失败命令的错误
+func (r *Runner) run(t *taskbus.Task, cmds []*parser.Command) (err error) {
+	executor := NewExecutor(r.config, r.transport)
+	executor.Registry = r.registry
+	executor.Vault = r.Vault
+	executor.Access = r.Access
+	defer func() {
+		if err := executor.Close(); err != nil {
+			logrus.Warnf("关闭任务%s的浏览器失败: %v", t.ID, err)
+		}
+	}()
+	// 页面或浏览器中的意外错误只结束这一个任务
+	defer func() {
+		if p := recover(); p != nil {
+			logrus.Errorf("运行浏览器任务时发生panic: %v\n%s", p, debug.Stack())
+			err = fmt.Errorf("任务异常终止")
+		}
+	}()
+
+	ctx := t.Context()
+	queue := cmds
+	total := len(cmds)
+	for step := 1; ; step++ {
+		queue, total = r.takeInputs(t, queue, total)
+		if len(queue) == 0 {
+			return nil
+		}
+		cmd := queue[0]
+		queue = queue[1:]
+		summary := describe(cmd)
+
+		if r.Confirm[cmd.Name] {
+			answer, err := t.RequestApproval(summary, fmt.Sprintf("第%d条命令", step))
+			if err != nil {
+				return err
+			}
+			if !answer.Approved {
+				t.Publish(taskbus.EventProgress, "已跳过: "+summary, map[string]interface{}{"step": step, "command": summary, "skipped": true})
+				continue
+			}
+		}
+
+		result, err := executor.Execute(ctx, cmd)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%s: %w", summary, err)
+		}
+		data := map[string]interface{}{"step": step, "command": summary, "url": result.URL, "title": result.Title}
+		if len(result.Data) > 0 {
+			data["data"] = result.Data
+		}
+		t.Publish(taskbus.EventProgress, result.Message, data)
+	}
+}
+
+// takeInputs 把用户补充的命令追加到队列末尾，无法解析或超过命令数上限的补充会被忽略并报告
+func (r *Runner) takeInputs(t *taskbus.Task, queue []*parser.Command, total int) ([]*parser.Command, int) {
+	for {
+		select {
+		case line := <-t.Inputs():
+			cmds, err := r.Parse([]string{line})
+			if err == nil && total+len(cmds) > MaxTaskCommands {
+				err = fmt.Errorf("任务最多包含%d条命令", MaxTaskCommands)
+			}
+			if err != nil {
+				t.Publish(taskbus.EventProgress, "忽略补充的命令: "+err.Error(), map[string]interface{}{"rejected": true})
+				continue
+			}
+			queue = append(queue, cmds...)
+			total += len(cmds)
+		default:
+			return queue, total
+		}
+	}
+}
+
+// describe 返回在事件和审批请求中展示的命令，login命令不显示用户名和密码
+func describe(cmd *parser.Command) string {
+	if cmd.Name == "login" {
+		return "login " + cmd.String("site")
+	}
+	return cmd.Line
+}
@@ -0,0 +1,181 @@
+package command
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"GoBrowserAgent/internal/taskbus"
+	"GoBrowserAgent/pkg/browser"
+)
+
+func newTestRunner(t *testing.T) (*Runner, string) {
+	t.Helper()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>" + r.URL.Path + "</title></head><body></body></html>"))
+	}))
+	t.Cleanup(site.Close)
+
+	config := browser.GetDefaultConfig()
+	config.Backend = browser.BackendHTTP
+	r := NewRunner(config, nil, taskbus.New())
+	r.Confirm = map[string]bool{"go": true}
+	t.Cleanup(func() { r.Close(context.Background()) })
+	return r, site.URL
+}
+
+// nextEvent 等待下一个任务事件
+func nextEvent(t *testing.T, events <-chan taskbus.Event) taskbus.Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for task event")
+	}
+	return taskbus.Event{}
+}
+
+func TestRunnerApprovalAndInput(t *testing.T) {
+	r, site := newTestRunner(t)
+	events, unsubscribe := r.Bus.Subscribe("alice")
+	defer unsubscribe()
+
+	cmds, err := r.Parse([]string{"# 注释", "", "go " + site + "/first", "go " + site + "/second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := r.Start(context.Background(), "alice", cmds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Type != taskbus.EventStarted || e.TaskID != task.ID {
+		t.Fatalf("first event = %+v", e)
+	}
+
+	// 第一条命令获得批准后执行，等待第二条命令批准时补充一条命令
+	e := nextEvent(t, events)
+	if e.Type != taskbus.EventApprovalRequest {
+		t.Fatalf("event = %+v, want approval_request", e)
+	}
+	if err := r.Bus.Resolve("bob", e.ApprovalID, taskbus.ApprovalResult{Approved: true}); err != taskbus.ErrApprovalNotFound {
+		t.Fatalf("Resolve by another user = %v", err)
+	}
+	if err := r.Bus.Resolve("alice", e.ApprovalID, taskbus.ApprovalResult{Approved: true}); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events) // approval_result
+	if e := nextEvent(t, events); e.Type != taskbus.EventProgress || e.Data["title"] != "/first" {
+		t.Fatalf("event = %+v, want progress of /first", e)
+	}
+
+	e = nextEvent(t, events)
+	if e.Type != taskbus.EventApprovalRequest {
+		t.Fatalf("event = %+v, want approval_request", e)
+	}
+	if err := r.Bus.SendInput("alice", task.ID, "go "+site+"/third"); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events) // input
+	if err := r.Bus.Resolve("alice", e.ApprovalID, taskbus.ApprovalResult{Approved: false}); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events) // approval_result
+	if e := nextEvent(t, events); e.Type != taskbus.EventProgress || e.Data["skipped"] != true {
+		t.Fatalf("event = %+v, want skipped second command", e)
+	}
+
+	e = nextEvent(t, events)
+	if e.Type != taskbus.EventApprovalRequest {
+		t.Fatalf("event = %+v, want approval for the added command", e)
+	}
+	r.Bus.Resolve("alice", e.ApprovalID, taskbus.ApprovalResult{Approved: true})
+	nextEvent(t, events) // approval_result
+	if e := nextEvent(t, events); e.Type != taskbus.EventProgress || e.Data["title"] != "/third" {
+		t.Fatalf("event = %+v, want progress of /third", e)
+	}
+	if e := nextEvent(t, events); e.Type != taskbus.EventFinished {
+		t.Fatalf("event = %+v, want finished", e)
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	r, site := newTestRunner(t)
+	events, unsubscribe := r.Bus.Subscribe("alice")
+	defer unsubscribe()
+
+	cmds, err := r.Parse([]string{"go " + site})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := r.Start(context.Background(), "alice", cmds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events) // started
+	nextEvent(t, events) // approval_request
+
+	if err := r.Bus.Cancel("bob", task.ID); err != taskbus.ErrNotFound {
+		t.Fatalf("Cancel by another user = %v", err)
+	}
+	if err := r.Bus.Cancel("alice", task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Type != taskbus.EventCancelled {
+		t.Fatalf("event = %+v, want cancelled", e)
+	}
+	if err := r.Bus.Cancel("alice", task.ID); err != taskbus.ErrNotFound {
+		t.Fatalf("Cancel finished task = %v", err)
+	}
+}
+
+func TestRunnerClose(t *testing.T) {
+	r, site := newTestRunner(t)
+	events, unsubscribe := r.Bus.Subscribe("")
+	defer unsubscribe()
+
+	cmds, _ := r.Parse([]string{"go " + site})
+	if _, err := r.Start(context.Background(), "", cmds); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events) // started
+	nextEvent(t, events) // approval_request
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Type != taskbus.EventCancelled {
+		t.Fatalf("event = %+v, want cancelled", e)
+	}
+	if _, err := r.Start(context.Background(), "", cmds); err != ErrRunnerClosed {
+		t.Fatalf("Start after Close = %v", err)
+	}
+}
+
+func TestRunnerParse(t *testing.T) {
+	r, _ := newTestRunner(t)
+	for _, lines := range [][]string{
+		{},
+		{"# 只有注释"},
+		{"help"},
+		{"go"},
+		{"unknown https://example.com"},
+	} {
+		if _, err := r.Parse(lines); err == nil {
+			t.Errorf("Parse(%q) succeeded", lines)
+		}
+	}
+
+	many := make([]string, MaxTaskCommands+1)
+	for i := range many {
+		many[i] = "wait 1ms"
+	}
+	if _, err := r.Parse(many); err == nil {
+		t.Error("Parse accepted too many commands")
+	}
+}
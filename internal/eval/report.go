@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSON 将报告编码为缩进的JSON
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Markdown 将报告渲染为Markdown，包含模型汇总和用例通过矩阵
+func (r *Report) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# 评测报告: %s\n\n", r.Suite)
+	fmt.Fprintf(&b, "开始时间: %s，耗时: %s\n\n", r.StartedAt.Format("2006-01-02 15:04:05"), r.Duration)
+
+	b.WriteString("## 模型汇总\n\n")
+	b.WriteString("| 模型 | 通过 | 通过率 | 平均耗时 | Token | 成本 |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, m := range r.Models {
+		fmt.Fprintf(&b, "| %s | %d/%d | %.1f%% | %dms | %d | %.4f |\n",
+			escapeCell(m.Model), m.Passed, m.Total, m.PassRate*100, m.AvgLatencyMS, m.TotalTokens, m.Cost)
+	}
+
+	b.WriteString("\n## 用例矩阵\n\n| 用例 |")
+	for _, m := range r.Models {
+		fmt.Fprintf(&b, " %s |", escapeCell(m.Model))
+	}
+	b.WriteString("\n|---|")
+	for range r.Models {
+		b.WriteString("---|")
+	}
+	b.WriteString("\n")
+	for i, caseID := range r.Cases {
+		fmt.Fprintf(&b, "| %s |", escapeCell(caseID))
+		for _, result := range r.Results[i] {
+			mark := "✅"
+			if !result.Passed {
+				mark = "❌"
+			}
+			fmt.Fprintf(&b, " %s %dms |", mark, result.LatencyMS)
+		}
+		b.WriteString("\n")
+	}
+
+	// 失败详情
+	var failures strings.Builder
+	for i, caseID := range r.Cases {
+		for _, result := range r.Results[i] {
+			if result.Passed {
+				continue
+			}
+			fmt.Fprintf(&failures, "### %s @ %s\n\n", caseID, result.Model)
+			for _, f := range result.Failures {
+				fmt.Fprintf(&failures, "- %s\n", f)
+			}
+			if result.Error != "" {
+				fmt.Fprintf(&failures, "- 错误: %s\n", result.Error)
+			}
+			if result.Output != "" {
+				fmt.Fprintf(&failures, "\n```\n%s\n```\n", strings.ReplaceAll(result.Output, "```", "'''"))
+			}
+			failures.WriteString("\n")
+		}
+	}
+	if failures.Len() > 0 {
+		b.WriteString("\n## 失败详情\n\n")
+		b.WriteString(failures.String())
+	}
+
+	return b.String()
+}
+
+// escapeCell 转义Markdown表格单元格中的竖线和换行
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,229 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/internal/service/llm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// judgePrompt 评审模型的系统提示
+const judgePrompt = `你是一个严格的评测员。根据给定的评分标准判断回答是否合格。
+只输出一个JSON对象，格式为 {"pass": true 或 false, "reason": "简短理由"}，不要输出其他内容。`
+
+// CaseResult 单个用例在单个模型上的结果
+type CaseResult struct {
+	CaseID    string    `json:"case_id"`
+	Model     string    `json:"model"`
+	Passed    bool      `json:"passed"`
+	Output    string    `json:"output"`
+	Error     string    `json:"error,omitempty"`
+	Failures  []string  `json:"failures,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	Usage     llm.Usage `json:"usage"`
+	Cost      float64   `json:"cost"`
+}
+
+// ModelSummary 单个模型的汇总
+type ModelSummary struct {
+	Model        string  `json:"model"`
+	Passed       int     `json:"passed"`
+	Total        int     `json:"total"`
+	PassRate     float64 `json:"pass_rate"`
+	AvgLatencyMS int64   `json:"avg_latency_ms"`
+	TotalTokens  int     `json:"total_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// Report 评测报告
+type Report struct {
+	Suite     string         `json:"suite"`
+	StartedAt time.Time      `json:"started_at"`
+	Duration  string         `json:"duration"`
+	Models    []ModelSummary `json:"models"`
+	Cases     []string       `json:"cases"`
+	// Results 按用例、模型顺序排列: Results[i][j]为第i个用例在第j个模型上的结果
+	Results [][]CaseResult `json:"results"`
+}
+
+// Runner 评测执行器
+type Runner struct {
+	Service     *llm.Service
+	Concurrency int
+}
+
+// NewRunner 创建评测执行器
+func NewRunner(service *llm.Service, concurrency int) *Runner {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Runner{
+		Service:     service,
+		Concurrency: concurrency,
+	}
+}
+
+// Run 对评测集中的所有模型执行全部用例
+func (r *Runner) Run(ctx context.Context, suite *Suite) (*Report, error) {
+	models := suite.Models
+	if len(models) == 0 {
+		// 未指定模型时使用配置中的默认模型
+		models = []ModelSpec{{Name: r.Service.Config.Model}}
+	}
+
+	judge := suite.Judge
+	if judge == "" {
+		judge = models[0].Name
+	}
+
+	report := &Report{
+		Suite:     suite.Name,
+		StartedAt: time.Now(),
+		Results:   make([][]CaseResult, len(suite.Cases)),
+	}
+	for i, c := range suite.Cases {
+		report.Cases = append(report.Cases, c.ID)
+		report.Results[i] = make([]CaseResult, len(models))
+	}
+
+	type job struct{ caseIdx, modelIdx int }
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for w := 0; w < r.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				c := suite.Cases[j.caseIdx]
+				model := models[j.modelIdx]
+				system := c.System
+				if system == "" {
+					system = suite.System
+				}
+				report.Results[j.caseIdx][j.modelIdx] = r.runCase(ctx, c, model, system, judge)
+			}
+		}()
+	}
+
+dispatch:
+	for i := range suite.Cases {
+		for j := range models {
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case jobs <- job{i, j}:
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("评测被中断: %v", ctx.Err())
+	}
+
+	for j, model := range models {
+		summary := ModelSummary{Model: model.Name, Total: len(suite.Cases)}
+		var latency int64
+		for i := range suite.Cases {
+			result := report.Results[i][j]
+			if result.Passed {
+				summary.Passed++
+			}
+			latency += result.LatencyMS
+			summary.TotalTokens += result.Usage.TotalTokens
+			summary.Cost += result.Cost
+		}
+		if summary.Total > 0 {
+			summary.PassRate = float64(summary.Passed) / float64(summary.Total)
+			summary.AvgLatencyMS = latency / int64(summary.Total)
+		}
+		report.Models = append(report.Models, summary)
+	}
+
+	report.Duration = time.Since(report.StartedAt).Round(time.Millisecond).String()
+	return report, nil
+}
+
+// runCase 在指定模型上执行单个用例并检查断言
+func (r *Runner) runCase(ctx context.Context, c Case, model ModelSpec, system, judge string) CaseResult {
+	result := CaseResult{CaseID: c.ID, Model: model.Name}
+
+	req := llm.ChatRequest{
+		Model:       model.Name,
+		MaxTokens:   model.MaxTokens,
+		Temperature: model.Temperature,
+	}
+	if system != "" {
+		req.Messages = append(req.Messages, llm.ChatMessage{Role: "system", Content: system})
+	}
+	req.Messages = append(req.Messages, llm.ChatMessage{Role: "user", Content: c.Input})
+
+	started := time.Now()
+	resp, err := r.Service.Complete(ctx, req)
+	result.LatencyMS = time.Since(started).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.Failures = []string{"调用模型失败"}
+		logrus.Warnf("用例%s在模型%s上调用失败: %v", c.ID, model.Name, err)
+		return result
+	}
+
+	result.Output = resp.Choices[0].Message.Content
+	result.Usage = resp.Usage
+	result.Cost = (float64(resp.Usage.PromptTokens)*model.InputPrice + float64(resp.Usage.CompletionTokens)*model.OutputPrice) / 1000
+
+	for _, assertion := range c.Expect {
+		var failure string
+		if assertion.Type == AssertLLMJudge {
+			failure = r.judge(ctx, judge, c.Input, result.Output, assertion.Rubric)
+		} else {
+			failure = assertion.checkLocal(result.Output)
+		}
+		if failure != "" {
+			result.Failures = append(result.Failures, failure)
+		}
+	}
+
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// judge 让评审模型按评分标准判断回答，返回失败原因
+func (r *Runner) judge(ctx context.Context, model, input, output, rubric string) string {
+	prompt := fmt.Sprintf("评分标准:\n%s\n\n用户问题:\n%s\n\n待评回答:\n%s", rubric, input, output)
+	resp, err := r.Service.Complete(ctx, llm.ChatRequest{
+		Model: model,
+		Messages: []llm.ChatMessage{
+			{Role: "system", Content: judgePrompt},
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return fmt.Sprintf("评审模型调用失败: %v", err)
+	}
+
+	content := resp.Choices[0].Message.Content
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return fmt.Sprintf("评审模型返回格式无效: %s", content)
+	}
+
+	var verdict struct {
+		Pass   bool   `json:"pass"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &verdict); err != nil {
+		return fmt.Sprintf("评审模型返回格式无效: %s", content)
+	}
+	if !verdict.Pass {
+		return "评审未通过: " + verdict.Reason
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"GoBrowserAgent/internal/jsonschema"
+)
+
+// 断言类型
+const (
+	AssertContains    = "contains"
+	AssertNotContains = "not_contains"
+	AssertRegex       = "regex"
+	AssertJSONSchema  = "json_schema"
+	AssertLLMJudge    = "llm_judge"
+)
+
+// Suite 评测集定义
+type Suite struct {
+	Name string `json:"name"`
+	// System 所有用例共用的系统提示，用例可单独覆盖
+	System string `json:"system"`
+	// Models 参与对比的模型
+	Models []ModelSpec `json:"models"`
+	// Judge llm_judge断言使用的评审模型，为空时使用第一个被测模型
+	Judge string `json:"judge"`
+	Cases []Case `json:"cases"`
+}
+
+// ModelSpec 被测模型及其参数和价格
+type ModelSpec struct {
+	Name string `json:"name"`
+	// MaxTokens、Temperature 省略时使用LLM配置中的默认值，可以显式设置为0
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	// InputPrice/OutputPrice 每千token的价格，用于估算成本
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+}
+
+// Case 单个评测用例
+type Case struct {
+	ID     string      `json:"id"`
+	System string      `json:"system,omitempty"`
+	Input  string      `json:"input"`
+	Expect []Assertion `json:"expect"`
+}
+
+// Assertion 对模型输出的期望
+type Assertion struct {
+	Type string `json:"type"`
+	// Value contains/not_contains的子串或regex的正则表达式
+	Value      string `json:"value,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+	// Schema json_schema断言使用的JSON Schema
+	Schema json.RawMessage `json:"schema,omitempty"`
+	// Rubric llm_judge断言的评分标准
+	Rubric string `json:"rubric,omitempty"`
+
+	regex  *regexp.Regexp
+	schema *jsonschema.Schema
+}
+
+// LoadSuite 从JSON文件加载评测集并检查断言定义
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取评测集失败: %v", err)
+	}
+
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("解析评测集失败: %v", err)
+	}
+	if suite.Name == "" {
+		suite.Name = path
+	}
+
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("评测集中没有用例")
+	}
+
+	seen := map[string]bool{}
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("case-%d", i+1)
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("用例id重复: %s", c.ID)
+		}
+		seen[c.ID] = true
+		if c.Input == "" {
+			return nil, fmt.Errorf("用例%s缺少input", c.ID)
+		}
+		for j := range c.Expect {
+			if err := c.Expect[j].compile(); err != nil {
+				return nil, fmt.Errorf("用例%s的第%d个断言无效: %v", c.ID, j+1, err)
+			}
+		}
+	}
+
+	return &suite, nil
+}
+
+// compile 预编译正则和JSON Schema
+func (a *Assertion) compile() error {
+	switch a.Type {
+	case AssertContains, AssertNotContains:
+		if a.Value == "" {
+			return fmt.Errorf("%s断言需要value", a.Type)
+		}
+	case AssertRegex:
+		pattern := a.Value
+		if a.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		a.regex = re
+	case AssertJSONSchema:
+		schema, err := jsonschema.Parse(a.Schema)
+		if err != nil {
+			return err
+		}
+		a.schema = schema
+	case AssertLLMJudge:
+		if a.Rubric == "" {
+			return fmt.Errorf("llm_judge断言需要rubric")
+		}
+	default:
+		return fmt.Errorf("未知的断言类型: %s", a.Type)
+	}
+	return nil
+}
+
+// checkLocal 执行不依赖LLM的断言，返回失败原因，通过时返回空字符串
+func (a *Assertion) checkLocal(output string) string {
+	switch a.Type {
+	case AssertContains, AssertNotContains:
+		haystack, needle := output, a.Value
+		if a.IgnoreCase {
+			haystack, needle = strings.ToLower(haystack), strings.ToLower(needle)
+		}
+		found := strings.Contains(haystack, needle)
+		if a.Type == AssertContains && !found {
+			return fmt.Sprintf("输出不包含%q", a.Value)
+		}
+		if a.Type == AssertNotContains && found {
+			return fmt.Sprintf("输出包含了%q", a.Value)
+		}
+	case AssertRegex:
+		if !a.regex.MatchString(output) {
+			return fmt.Sprintf("输出不匹配正则%q", a.Value)
+		}
+	case AssertJSONSchema:
+		if err := a.schema.ValidateJSON([]byte(extractJSON(output))); err != nil {
+			return fmt.Sprintf("JSON Schema校验失败: %v", err)
+		}
+	}
+	return ""
+}
+
+// extractJSON 去掉模型常见的```json代码块包裹
+func extractJSON(output string) string {
+	text := strings.TrimSpace(output)
+	if strings.HasPrefix(text, "```") {
+		if idx := strings.Index(text, "\n"); idx >= 0 {
+			text = text[idx+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config 存储LLM网关配置
+type Config struct {
+	// Enabled 是否开放OpenAI兼容的/v1接口，开启前必须先启用认证
+	Enabled bool `json:"enabled"`
+	// Models 允许通过网关调用的模型，为空时只允许LLM配置中的默认模型
+	Models []string `json:"models"`
+	// UsageLog 用量记录文件路径（JSONL），为空时只在内存中统计
+	UsageLog string `json:"usage_log"`
+}
+
+// LoadConfig 从配置文件加载LLM网关配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		Gateway *Config `json:"gateway"`
+	}{
+		Gateway: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.Gateway, nil
+}
+
+// GetDefaultConfig 获取默认配置，默认不开放网关
+func GetDefaultConfig() *Config {
+	return &Config{
+		UsageLog: filepath.Join("data", "usage.jsonl"),
+	}
+}
@@ -0,0 +1,317 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/service/llm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxRequestSize 网关请求体的最大字节数
+const maxRequestSize = 4 << 20
+
+// completionRequest 网关接受的聊天补全请求
+//
+// 在llm.ChatRequest之外允许消息内容为片段数组，并接受max_completion_tokens；
+// 其他未列出的参数不会转发，也不会导致请求被拒绝。
+type completionRequest struct {
+	llm.ChatRequest
+	Messages []completionMessage `json:"messages"`
+	// MaxCompletionTokens max_tokens的新写法，两者都给出时必须相同
+	MaxCompletionTokens *int `json:"max_completion_tokens,omitempty"`
+}
+
+// completionMessage 请求中的消息，content为字符串或内容片段数组
+type completionMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+	Name    string          `json:"name,omitempty"`
+}
+
+// contentPart 内容片段中用于提取文字的字段，片段本身原样转发
+type contentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// chatRequest 检查互相矛盾的参数并转换为发给上游的请求
+func (req *completionRequest) chatRequest() (llm.ChatRequest, *apierror.Error) {
+	chatReq := req.ChatRequest
+	if chatReq.StreamOptions != nil && !chatReq.Stream {
+		return chatReq, apierror.New(apierror.CodeInvalidParameter).With("name", "stream_options").WithDetails("stream_options只能在stream为true时使用")
+	}
+	if req.MaxCompletionTokens != nil {
+		if chatReq.MaxTokens != nil && *chatReq.MaxTokens != *req.MaxCompletionTokens {
+			return chatReq, apierror.New(apierror.CodeInvalidParameter).With("name", "max_completion_tokens").WithDetails("max_tokens与max_completion_tokens不一致")
+		}
+		chatReq.MaxTokens = req.MaxCompletionTokens
+	}
+
+	chatReq.Messages = make([]llm.ChatMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		msg := llm.ChatMessage{Role: m.Role, Name: m.Name}
+		if len(m.Content) == 0 {
+			chatReq.Messages[i] = msg
+			continue
+		}
+		if err := json.Unmarshal(m.Content, &msg.Content); err != nil {
+			var parts []json.RawMessage
+			if err := json.Unmarshal(m.Content, &parts); err != nil {
+				return chatReq, apierror.New(apierror.CodeInvalidParameter).With("name", fmt.Sprintf("messages[%d].content", i))
+			}
+			msg.Parts = parts
+			var text []string
+			for _, raw := range parts {
+				var part contentPart
+				if json.Unmarshal(raw, &part) == nil && part.Type == "text" {
+					text = append(text, part.Text)
+				}
+			}
+			msg.Content = strings.Join(text, "\n")
+		}
+		chatReq.Messages[i] = msg
+	}
+	return chatReq, nil
+}
+
+// Gateway 以OpenAI兼容格式对外提供LLM服务，共用同一套API密钥、出站配置和用量统计
+type Gateway struct {
+	LLMService *llm.Service
+	Access     *rbac.Enforcer
+	Usage      *UsageLedger
+	models     []string
+}
+
+// NewGateway 创建LLM网关，必须在启用认证时才能开放
+func NewGateway(config *Config, llmService *llm.Service, access *rbac.Enforcer, authEnabled bool) (*Gateway, error) {
+	if !authEnabled {
+		return nil, fmt.Errorf("开放LLM网关前必须先在auth中启用认证")
+	}
+
+	usage, err := OpenUsageLedger(config.UsageLog)
+	if err != nil {
+		return nil, err
+	}
+
+	models := config.Models
+	if len(models) == 0 {
+		models = []string{llmService.Config.Model}
+	}
+	logrus.Infof("已开放LLM网关，可用模型: %v", models)
+	return &Gateway{
+		LLMService: llmService,
+		Access:     access,
+		Usage:      usage,
+		models:     models,
+	}, nil
+}
+
+// RegisterHandlers 注册HTTP处理程序
+func (g *Gateway) RegisterHandlers() {
+	http.HandleFunc("/v1/chat/completions", g.requireChat(g.handleChatCompletions))
+	http.HandleFunc("/v1/models", g.requireChat(g.handleModels))
+	http.HandleFunc("/api/admin/usage", g.Access.Require(rbac.PermAuditView, g.handleUsage))
+}
+
+// requireChat 校验chat权限，拒绝时返回OpenAI格式的错误
+func (g *Gateway) requireChat(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := g.Access.Check(r.Context(), rbac.PermChat, r.URL.Path); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleModels 返回可用模型列表
+func (g *Gateway) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	}
+	data := make([]model, 0, len(g.models))
+	for _, name := range g.models {
+		data = append(data, model{ID: name, Object: "model", OwnedBy: "gobrowseragent"})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list", "data": data})
+}
+
+// handleChatCompletions 转发聊天补全请求，支持stream
+func (g *Gateway) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodPost))
+		return
+	}
+
+	var body completionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&body); err != nil {
+		apierror.Write(w, r, apierror.FromDecode(err))
+		return
+	}
+	req, apiErr := body.chatRequest()
+	if apiErr != nil {
+		apierror.Write(w, r, apiErr)
+		return
+	}
+	if len(req.Messages) == 0 {
+		apierror.Write(w, r, apierror.New(apierror.CodeMissingParameter).With("name", "messages"))
+		return
+	}
+	if req.Model == "" {
+		req.Model = g.models[0]
+	}
+	if !g.allowedModel(req.Model) {
+		apierror.Write(w, r, apierror.New(apierror.CodeModelNotFound).With("model", req.Model))
+		return
+	}
+
+	subject := ""
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		subject = identity.Subject
+	}
+	record := UsageRecord{Subject: subject, Model: req.Model, Stream: req.Stream}
+	started := time.Now()
+
+	var resp *llm.ChatResponse
+	var err error
+	if req.Stream {
+		resp, err = g.stream(w, r, req)
+	} else {
+		resp, err = g.LLMService.Complete(r.Context(), req)
+		if err == nil {
+			writeJSON(w, http.StatusOK, resp)
+		}
+	}
+
+	record.LatencyMS = time.Since(started).Milliseconds()
+	if resp != nil {
+		record.PromptTokens = resp.Usage.PromptTokens
+		record.CompletionTokens = resp.Usage.CompletionTokens
+		record.TotalTokens = resp.Usage.TotalTokens
+	}
+	if err != nil {
+		record.Error = err.Error()
+		logrus.Errorf("网关请求失败 (%s): %v", subject, err)
+		var headersSent *streamStartedError
+		if !errors.As(err, &headersSent) {
+			apierror.Write(w, r, apierror.Upstream(err))
+		}
+	}
+	g.Usage.Record(record)
+}
+
+// streamStartedError 表示流式响应已经开始，无法再返回普通的错误响应
+type streamStartedError struct {
+	err error
+}
+
+func (e *streamStartedError) Error() string { return e.err.Error() }
+
+func (e *streamStartedError) Unwrap() error { return e.err }
+
+// stream 以SSE格式转发流式响应
+func (g *Gateway) stream(w http.ResponseWriter, r *http.Request, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	controller := http.NewResponseController(w)
+	started := false
+
+	resp, err := g.LLMService.CompleteStream(r.Context(), req, func(chunk *llm.ChatChunk) error {
+		// 上游总是返回用量以便统计，客户端没有要求时不转发
+		if !includeUsage {
+			if len(chunk.Choices) == 0 {
+				return nil
+			}
+			chunk.Usage = nil
+		}
+
+		if !started {
+			started = true
+			// 流式响应可能超过服务器的写超时
+			controller.SetWriteDeadline(time.Time{})
+			header := w.Header()
+			header.Set("Content-Type", "text/event-stream")
+			header.Set("Cache-Control", "no-cache")
+			header.Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+		}
+
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		return controller.Flush()
+	})
+
+	if err != nil {
+		if !started {
+			return nil, err
+		}
+		// 已经开始输出时以错误事件结束流
+		body := apierror.Upstream(err).OpenAIBody(apierror.Language(r), apierror.RequestID(r.Context()))
+		data, _ := json.Marshal(body)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		controller.Flush()
+		return resp, &streamStartedError{err: err}
+	}
+
+	if !started {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	controller.Flush()
+	return resp, nil
+}
+
+// handleUsage 返回按调用方和模型汇总的网关用量
+func (g *Gateway) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+	writeJSON(w, http.StatusOK, g.Usage.Totals())
+}
+
+// Close 关闭用量记录
+func (g *Gateway) Close() error {
+	return g.Usage.Close()
+}
+
+// allowedModel 判断模型是否允许通过网关调用
+func (g *Gateway) allowedModel(model string) bool {
+	for _, name := range g.models {
+		if name == model {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Errorf("编码响应失败: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GoBrowserAgent/internal/httpclient"
+	"GoBrowserAgent/internal/openapi"
+	"GoBrowserAgent/internal/service/llm"
+)
+
+// newTestGateway 返回经过接口文档校验的网关处理程序和上游收到的请求体
+func newTestGateway(t *testing.T) (http.Handler, <-chan map[string]interface{}) {
+	t.Helper()
+	bodies := make(chan map[string]interface{}, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		bodies <- body
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}],"usage":{"total_tokens":3}}`)
+	}))
+	t.Cleanup(upstream.Close)
+
+	client, err := httpclient.New(httpclient.GetDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := llm.GetDefaultConfig()
+	config.APIEndpoint = upstream.URL
+	config.APIKey = "test"
+	g, err := NewGateway(&Config{}, llm.NewService(config, client), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", g.handleChatCompletions)
+	spec := openapi.New("session")
+	spec.Add(g.Routes()...)
+	return spec.Middleware(mux), bodies
+}
+
+func postCompletion(h http.Handler, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestChatCompletionsForwardsParameters(t *testing.T) {
+	h, bodies := newTestGateway(t)
+	w := postCompletion(h, `{
+		"messages": [
+			{"role": "system", "content": "be brief", "name": "setup"},
+			{"role": "user", "name": "alice", "content": [
+				{"type": "text", "text": "describe"},
+				{"type": "image_url", "image_url": {"url": "https://example.com/a.png", "detail": "low"}}
+			]}
+		],
+		"stop": ["\n\n", "END"],
+		"user": "u-42",
+		"n": 2,
+		"presence_penalty": 0.5,
+		"frequency_penalty": -0.5,
+		"seed": 7,
+		"response_format": {"type": "json_object"},
+		"max_completion_tokens": 20,
+		"tools": [{"type": "function", "function": {"name": "f"}}],
+		"logit_bias": {"50256": -100}
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	body := <-bodies
+	for key, want := range map[string]interface{}{
+		"user": "u-42", "n": 2.0, "presence_penalty": 0.5, "frequency_penalty": -0.5, "seed": 7.0, "max_tokens": 20.0,
+	} {
+		if body[key] != want {
+			t.Errorf("%s = %v, want %v", key, body[key], want)
+		}
+	}
+	if stop, _ := body["stop"].([]interface{}); len(stop) != 2 || stop[1] != "END" {
+		t.Errorf("stop = %v", body["stop"])
+	}
+	if format, _ := body["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("response_format = %v", body["response_format"])
+	}
+	for _, key := range []string{"tools", "logit_bias", "max_completion_tokens"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("%s forwarded", key)
+		}
+	}
+
+	messages := body["messages"].([]interface{})
+	system := messages[0].(map[string]interface{})
+	if system["content"] != "be brief" || system["name"] != "setup" {
+		t.Errorf("system message = %v", system)
+	}
+	user := messages[1].(map[string]interface{})
+	parts, _ := user["content"].([]interface{})
+	if user["name"] != "alice" || len(parts) != 2 {
+		t.Fatalf("user message = %v", user)
+	}
+	image := parts[1].(map[string]interface{})["image_url"].(map[string]interface{})
+	if image["url"] != "https://example.com/a.png" || image["detail"] != "low" {
+		t.Errorf("image part = %v", parts[1])
+	}
+}
+
+func TestChatCompletionsRejectsInvalid(t *testing.T) {
+	h, _ := newTestGateway(t)
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"stream_options without stream", `{"messages":[{"role":"user","content":"hi"}],"stream_options":{"include_usage":true}}`, "invalid_parameter"},
+		{"conflicting max tokens", `{"messages":[{"role":"user","content":"hi"}],"max_tokens":10,"max_completion_tokens":20}`, "invalid_parameter"},
+		{"content of wrong type", `{"messages":[{"role":"user","content":42}]}`, "validation_failed"},
+		{"content part without type", `{"messages":[{"role":"user","content":[{"text":"hi"}]}]}`, "validation_failed"},
+		{"too many stop sequences", `{"messages":[{"role":"user","content":"hi"}],"stop":["a","b","c","d","e"]}`, "validation_failed"},
+		{"no messages", `{"messages":[]}`, "validation_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postCompletion(h, tt.body)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.code) {
+				t.Fatalf("status = %d, body = %s, want %s", w.Code, w.Body, tt.code)
+			}
+		})
+	}
+}
+
+func TestChatCompletionsSameMaxTokens(t *testing.T) {
+	h, bodies := newTestGateway(t)
+	w := postCompletion(h, `{"messages":[{"role":"user","content":"hi"}],"max_tokens":10,"max_completion_tokens":10,"stream":false}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if body := <-bodies; body["max_tokens"] != 10.0 {
+		t.Errorf("max_tokens = %v", body["max_tokens"])
+	}
+}
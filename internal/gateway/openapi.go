@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"GoBrowserAgent/internal/jsonschema"
+	"GoBrowserAgent/internal/openapi"
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/service/llm"
+)
+
+// Routes 返回RegisterHandlers注册的接口，用于生成OpenAPI文档和校验请求
+func (g *Gateway) Routes() []openapi.Route {
+	request := openapi.SchemaOf(completionRequest{})
+	request.Required = []string{"messages"}
+	request.Properties["model"].Description = "模型名称，为空时使用" + g.models[0] + "，可用模型: " + strings.Join(g.models, ", ")
+	request.Properties["messages"].MinItems = openapi.Length(1)
+	message := request.Properties["messages"].Items
+	message.Required = []string{"role", "content"}
+	part := openapi.SchemaOf(contentPart{})
+	part.Required = []string{"type"}
+	part.Description = "内容片段，例如{\"type\":\"text\",\"text\":\"...\"}或{\"type\":\"image_url\",\"image_url\":{\"url\":\"...\"}}，原样转发"
+	message.Properties["content"] = &jsonschema.Schema{
+		Description: "消息内容，字符串或内容片段数组",
+		AnyOf:       []*jsonschema.Schema{openapi.String(""), {Type: jsonschema.TypeList{"array"}, Items: part}},
+	}
+	request.Properties["stream"].Description = "为true时以text/event-stream逐块返回"
+	request.Properties["stream_options"].Description = "include_usage为true时在最后一个数据块中返回用量，只能在stream为true时使用"
+	request.Properties["max_completion_tokens"].Description = "同max_tokens，两者都给出时必须相同"
+	request.Properties["stop"] = &jsonschema.Schema{
+		Description: "停止序列",
+		AnyOf:       []*jsonschema.Schema{openapi.String(""), {Type: jsonschema.TypeList{"array"}, Items: openapi.String(""), MaxItems: openapi.Length(4)}},
+	}
+	request.Properties["response_format"] = &jsonschema.Schema{
+		Type:        jsonschema.TypeList{"object"},
+		Description: "输出格式，例如{\"type\":\"json_object\"}",
+		Required:    []string{"type"},
+		Properties:  map[string]*jsonschema.Schema{"type": openapi.String("")},
+	}
+	// 未列出的参数（例如tools）不转发，但不拒绝请求，便于直接使用各种OpenAI客户端
+
+	models := openapi.SchemaOf(struct {
+		Object string `json:"object"`
+		Data   []struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Created int64  `json:"created"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}{})
+
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/v1/chat/completions", Operation: openapi.Operation{
+			Tags:        []string{"gateway"},
+			Summary:     "聊天补全",
+			Description: "与OpenAI的Chat Completions接口兼容，调用按令牌记录用量。转发文档中列出的参数，其他参数被忽略；互相矛盾的参数返回invalid_parameter。",
+			OperationID: "createChatCompletion",
+			Permission:  string(rbac.PermChat),
+			MaxBodySize: maxRequestSize,
+			RequestBody: openapi.JSONBody("", request),
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "补全结果；stream为true时为SSE，以data: [DONE]结束", Content: map[string]openapi.MediaType{
+					"application/json":  {Schema: openapi.SchemaOf(llm.ChatResponse{})},
+					"text/event-stream": {Schema: openapi.String("")},
+				}},
+			},
+		}},
+		{Method: http.MethodGet, Path: "/v1/models", Operation: openapi.Operation{
+			Tags:        []string{"gateway"},
+			Summary:     "模型列表",
+			OperationID: "listModels",
+			Permission:  string(rbac.PermChat),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("允许通过网关调用的模型", models),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/admin/usage", Operation: openapi.Operation{
+			Tags:        []string{"admin"},
+			Summary:     "网关用量",
+			Description: "按调用方和模型汇总的请求数和token用量。",
+			OperationID: "gatewayUsage",
+			Permission:  string(rbac.PermAuditView),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("累计用量", openapi.SchemaOf([]UsageTotal{})),
+			},
+		}},
+	}
+}
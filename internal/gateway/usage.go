@@ -0,0 +1,147 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UsageRecord 一次网关调用的用量记录
+type UsageRecord struct {
+	Time time.Time `json:"time"`
+	// Subject 带认证方式前缀的调用方标识，例如token:ci
+	Subject          string `json:"subject"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	Stream           bool   `json:"stream"`
+	LatencyMS        int64  `json:"latency_ms"`
+	Error            string `json:"error,omitempty"`
+}
+
+// UsageTotal 某个调用方在某个模型上的累计用量
+type UsageTotal struct {
+	Subject          string `json:"subject"`
+	Model            string `json:"model"`
+	Requests         int    `json:"requests"`
+	Errors           int    `json:"errors"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+}
+
+// UsageLedger 按调用方记录网关用量，追加写入文件并在内存中汇总
+type UsageLedger struct {
+	mu     sync.Mutex
+	file   *os.File
+	totals map[[2]string]*UsageTotal
+}
+
+// OpenUsageLedger 打开用量记录，启动时回放已有记录以恢复累计用量
+func OpenUsageLedger(path string) (*UsageLedger, error) {
+	ledger := &UsageLedger{totals: make(map[[2]string]*UsageTotal)}
+	if path == "" {
+		return ledger, nil
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("创建用量记录目录失败: %v", err)
+		}
+	}
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var record UsageRecord
+			if json.Unmarshal(scanner.Bytes(), &record) == nil {
+				ledger.add(record)
+			}
+		}
+		existing.Close()
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开用量记录失败: %v", err)
+	}
+	ledger.file = file
+	return ledger, nil
+}
+
+// Record 记录一次调用
+func (l *UsageLedger) Record(record UsageRecord) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(record)
+	if l.file == nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		logrus.Errorf("编码用量记录失败: %v", err)
+		return
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		logrus.Errorf("写入用量记录失败: %v", err)
+	}
+}
+
+// Totals 返回按调用方和模型汇总的用量
+func (l *UsageLedger) Totals() []UsageTotal {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	totals := make([]UsageTotal, 0, len(l.totals))
+	for _, total := range l.totals {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Subject != totals[j].Subject {
+			return totals[i].Subject < totals[j].Subject
+		}
+		return totals[i].Model < totals[j].Model
+	})
+	return totals
+}
+
+// Close 关闭用量记录文件
+func (l *UsageLedger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// add 累加到汇总，调用方需持有l.mu或处于初始化阶段
+func (l *UsageLedger) add(record UsageRecord) {
+	key := [2]string{record.Subject, record.Model}
+	total, ok := l.totals[key]
+	if !ok {
+		total = &UsageTotal{Subject: record.Subject, Model: record.Model}
+		l.totals[key] = total
+	}
+	total.Requests++
+	if record.Error != "" {
+		total.Errors++
+	}
+	total.PromptTokens += record.PromptTokens
+	total.CompletionTokens += record.CompletionTokens
+	total.TotalTokens += record.TotalTokens
+}
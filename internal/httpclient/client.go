@@ -0,0 +1,234 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Client 共享的出站HTTP客户端，按目标主机选择连接参数
+//
+// 同一组设置只创建一个http.Transport，因此LLM服务以及后续基于HTTP的浏览器和API客户端都能复用连接。
+type Client struct {
+	defaultClient *http.Client
+	targets       map[string]*http.Client
+}
+
+// New 根据配置创建出站HTTP客户端
+func New(config *Config) (*Client, error) {
+	if config == nil {
+		config = GetDefaultConfig()
+	}
+
+	defaultClient, err := newHTTPClient(config.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("创建默认HTTP客户端失败: %v", err)
+	}
+
+	c := &Client{
+		defaultClient: defaultClient,
+		targets:       make(map[string]*http.Client),
+	}
+
+	// 录制/回放模式下所有出站请求都经过同一个录制文件
+	if config.Replay != nil && config.Replay.Mode != "" {
+		replay, err := NewReplayTransport(*config.Replay, defaultClient.Transport)
+		if err != nil {
+			return nil, err
+		}
+		defaultClient.Transport = replay
+		return c, nil
+	}
+
+	for pattern, override := range config.Targets {
+		client, err := newHTTPClient(merge(config.Settings, override))
+		if err != nil {
+			return nil, fmt.Errorf("创建目标%s的HTTP客户端失败: %v", pattern, err)
+		}
+		c.targets[strings.ToLower(pattern)] = client
+		logrus.Debugf("已为目标%s配置独立的出站HTTP设置", pattern)
+	}
+
+	return c, nil
+}
+
+// For 返回访问指定主机时使用的http.Client
+func (c *Client) For(host string) *http.Client {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if client, ok := c.targets[host]; ok {
+		return client
+	}
+
+	// 通配后缀取最长匹配
+	var matched *http.Client
+	matchedLen := 0
+	for pattern, client := range c.targets {
+		if !strings.HasPrefix(pattern, "*.") {
+			continue
+		}
+		suffix := pattern[1:]
+		if strings.HasSuffix(host, suffix) && len(suffix) > matchedLen {
+			matched = client
+			matchedLen = len(suffix)
+		}
+	}
+	if matched != nil {
+		return matched
+	}
+
+	return c.defaultClient
+}
+
+// ForURL 返回访问指定URL时使用的http.Client
+func (c *Client) ForURL(rawURL string) *http.Client {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return c.defaultClient
+	}
+	return c.For(u.Host)
+}
+
+// Do 按请求的目标主机选择客户端并发送请求
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.For(req.URL.Host).Do(req)
+}
+
+// Transport 返回按请求的目标主机选择连接参数的http.RoundTripper
+//
+// 需要自己的http.Client（例如带Cookie容器、自行处理重定向）的调用方用它复用这里的代理、证书和连接池设置。
+func (c *Client) Transport() http.RoundTripper {
+	return hostTransport{c}
+}
+
+// hostTransport 按主机转发到对应http.Client的传输
+type hostTransport struct {
+	c *Client
+}
+
+func (t hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.c.For(req.URL.Host).Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
+// Default 返回未匹配任何目标覆盖时使用的http.Client
+func (c *Client) Default() *http.Client {
+	return c.defaultClient
+}
+
+// CloseIdleConnections 关闭所有空闲连接
+func (c *Client) CloseIdleConnections() {
+	c.defaultClient.CloseIdleConnections()
+	for _, client := range c.targets {
+		client.CloseIdleConnections()
+	}
+}
+
+// newHTTPClient 根据设置创建http.Client
+func newHTTPClient(s Settings) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := proxyFunc(s.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   seconds(s.DialTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   seconds(s.TLSHandshakeTimeout),
+		IdleConnTimeout:       seconds(s.IdleConnTimeout),
+		MaxIdleConnsPerHost:   s.MaxIdleConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   seconds(s.Timeout),
+	}, nil
+}
+
+// newTLSConfig 根据设置构建TLS配置
+func newTLSConfig(s Settings) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+
+	if s.InsecureSkipVerify {
+		logrus.Warn("出站HTTP已禁用证书校验，请勿在生产环境使用")
+	}
+
+	if s.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书文件中没有有效的证书: %s", s.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if s.CertFile != "" || s.KeyFile != "" {
+		if s.CertFile == "" || s.KeyFile == "" {
+			return nil, fmt.Errorf("客户端证书需要同时配置cert_file和key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// proxyFunc 根据代理配置返回http.Transport使用的代理函数
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch strings.ToLower(proxy) {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "direct", "none":
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("无效的代理地址: %s", proxy)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// seconds 将秒数转换为time.Duration
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
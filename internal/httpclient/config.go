@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Settings 描述一组出站HTTP连接参数
+type Settings struct {
+	// Proxy 代理地址，例如 http://proxy.corp:3128；为空时读取HTTP_PROXY/HTTPS_PROXY/NO_PROXY环境变量，"direct"表示不使用代理
+	Proxy string `json:"proxy"`
+	// CAFile 额外信任的CA证书文件（PEM），会追加到系统证书池
+	CAFile string `json:"ca_file"`
+	// CertFile/KeyFile 客户端证书与私钥（PEM），用于双向TLS
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// InsecureSkipVerify 跳过服务端证书校验，仅用于调试
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	// Timeout 整个请求的超时时间（秒）
+	Timeout int `json:"timeout"`
+	// DialTimeout 建立TCP连接的超时时间（秒）
+	DialTimeout int `json:"dial_timeout"`
+	// TLSHandshakeTimeout TLS握手超时时间（秒）
+	TLSHandshakeTimeout int `json:"tls_handshake_timeout"`
+	// IdleConnTimeout 空闲连接保持时间（秒）
+	IdleConnTimeout int `json:"idle_conn_timeout"`
+	// MaxIdleConnsPerHost 每个主机保留的最大空闲连接数
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+}
+
+// Config 存储出站HTTP传输配置
+type Config struct {
+	Settings
+	// Targets 按目标主机覆盖默认设置，键为主机名（如 api.openai.com）或通配后缀（如 *.corp.example.com）
+	Targets map[string]Settings `json:"targets"`
+	// Replay 录制/回放配置，用于离线运行评测和调试
+	Replay *ReplayConfig `json:"replay,omitempty"`
+}
+
+// LoadConfig 从配置文件加载出站HTTP配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		HTTP *Config `json:"http"`
+	}{
+		HTTP: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.HTTP, nil
+}
+
+// GetDefaultConfig 获取默认配置
+func GetDefaultConfig() *Config {
+	return &Config{
+		Settings: Settings{
+			Timeout:             120,
+			DialTimeout:         30,
+			TLSHandshakeTimeout: 10,
+			IdleConnTimeout:     90,
+			MaxIdleConnsPerHost: 10,
+		},
+	}
+}
+
+// merge 用override中的非零字段覆盖base，返回新的设置
+func merge(base, override Settings) Settings {
+	result := base
+	if override.Proxy != "" {
+		result.Proxy = override.Proxy
+	}
+	if override.CAFile != "" {
+		result.CAFile = override.CAFile
+	}
+	if override.CertFile != "" {
+		result.CertFile = override.CertFile
+	}
+	if override.KeyFile != "" {
+		result.KeyFile = override.KeyFile
+	}
+	if override.InsecureSkipVerify {
+		result.InsecureSkipVerify = true
+	}
+	if override.Timeout > 0 {
+		result.Timeout = override.Timeout
+	}
+	if override.DialTimeout > 0 {
+		result.DialTimeout = override.DialTimeout
+	}
+	if override.TLSHandshakeTimeout > 0 {
+		result.TLSHandshakeTimeout = override.TLSHandshakeTimeout
+	}
+	if override.IdleConnTimeout > 0 {
+		result.IdleConnTimeout = override.IdleConnTimeout
+	}
+	if override.MaxIdleConnsPerHost > 0 {
+		result.MaxIdleConnsPerHost = override.MaxIdleConnsPerHost
+	}
+	return result
+}
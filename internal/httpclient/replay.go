@@ -0,0 +1,233 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ReplayModeRecord 转发真实请求并记录响应
+	ReplayModeRecord = "record"
+	// ReplayModeReplay 只从录制文件返回响应，不访问网络
+	ReplayModeReplay = "replay"
+)
+
+// ReplayConfig 录制/回放配置
+type ReplayConfig struct {
+	Mode string `json:"mode"`
+	File string `json:"file"`
+}
+
+// Interaction 录制文件中的一次请求与响应
+//
+// 手写的模拟响应可以省略request_body，改用request_contains按请求体子串匹配。
+type Interaction struct {
+	Key             string            `json:"key,omitempty"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	RequestContains string            `json:"request_contains,omitempty"`
+	Status          int               `json:"status"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	ResponseBody    string            `json:"response_body"`
+}
+
+// Cassette 录制文件内容
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// ReplayTransport 按录制文件回放或录制HTTP交互的RoundTripper
+type ReplayTransport struct {
+	Mode string
+	File string
+	Next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	byKey    map[string]int
+}
+
+// NewReplayTransport 创建录制/回放传输，next为录制模式下真正发送请求的传输
+func NewReplayTransport(config ReplayConfig, next http.RoundTripper) (*ReplayTransport, error) {
+	if config.Mode != ReplayModeRecord && config.Mode != ReplayModeReplay {
+		return nil, fmt.Errorf("无效的回放模式: %s", config.Mode)
+	}
+	if config.File == "" {
+		return nil, fmt.Errorf("回放模式需要配置录制文件")
+	}
+
+	t := &ReplayTransport{
+		Mode:  config.Mode,
+		File:  config.File,
+		Next:  next,
+		byKey: make(map[string]int),
+	}
+
+	data, err := os.ReadFile(config.File)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("解析录制文件失败: %v", err)
+		}
+	case os.IsNotExist(err) && config.Mode == ReplayModeRecord:
+		// 录制模式下允许从空文件开始
+	default:
+		return nil, fmt.Errorf("读取录制文件失败: %v", err)
+	}
+
+	for i, interaction := range t.cassette.Interactions {
+		if interaction.Key != "" {
+			t.byKey[interaction.Key] = i
+		}
+	}
+
+	logrus.Infof("出站HTTP %s模式: %s, 已有%d条记录", config.Mode, config.File, len(t.cassette.Interactions))
+	return t, nil
+}
+
+// RoundTrip 实现http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key := interactionKey(req.Method, req.URL.String(), body)
+
+	if t.Mode == ReplayModeReplay {
+		interaction, ok := t.lookup(key, req, body)
+		if !ok {
+			return nil, fmt.Errorf("录制文件中没有匹配的响应: %s %s", req.Method, req.URL)
+		}
+		return interaction.response(req), nil
+	}
+
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Key:          key,
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		RequestBody:  string(body),
+		Status:       resp.StatusCode,
+		Headers:      map[string]string{"Content-Type": resp.Header.Get("Content-Type")},
+		ResponseBody: string(respBody),
+	}
+	if err := t.record(interaction); err != nil {
+		logrus.Errorf("写入录制文件失败: %v", err)
+	}
+
+	return resp, nil
+}
+
+// lookup 先按请求摘要精确匹配，再按URL和请求体子串匹配手写的模拟响应
+func (t *ReplayTransport) lookup(key string, req *http.Request, body []byte) (Interaction, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if i, ok := t.byKey[key]; ok {
+		return t.cassette.Interactions[i], true
+	}
+
+	for _, interaction := range t.cassette.Interactions {
+		if interaction.Key != "" {
+			continue
+		}
+		if interaction.Method != "" && !strings.EqualFold(interaction.Method, req.Method) {
+			continue
+		}
+		if interaction.URL != "" && interaction.URL != req.URL.String() {
+			continue
+		}
+		if !strings.Contains(string(body), interaction.RequestContains) {
+			continue
+		}
+		return interaction, true
+	}
+
+	return Interaction{}, false
+}
+
+// record 追加录制记录并写回文件
+func (t *ReplayTransport) record(interaction Interaction) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if i, ok := t.byKey[interaction.Key]; ok {
+		t.cassette.Interactions[i] = interaction
+	} else {
+		t.byKey[interaction.Key] = len(t.cassette.Interactions)
+		t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	}
+
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.File, data, 0644)
+}
+
+// response 根据录制记录构造响应
+func (i Interaction) response(req *http.Request) *http.Response {
+	status := i.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	header := make(http.Header)
+	for k, v := range i.Headers {
+		header.Set(k, v)
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/json")
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(i.ResponseBody)),
+		ContentLength: int64(len(i.ResponseBody)),
+		Request:       req,
+	}
+}
+
+// interactionKey 计算请求摘要
+func interactionKey(method, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,426 @@
+// Package jsonschema 实现JSON Schema（draft-07常用子集）校验
+//
+// 支持的关键字: type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, minLength, maxLength, pattern, format(部分),
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf, not。
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema JSON Schema定义
+type Schema struct {
+	Type                 TypeList           `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Additional        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+}
+
+// TypeList type关键字，可以是单个类型或类型数组
+type TypeList []string
+
+// UnmarshalJSON 同时支持"string"和["string","null"]两种写法
+func (t *TypeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = TypeList{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("type必须是字符串或字符串数组")
+	}
+	*t = multiple
+	return nil
+}
+
+// MarshalJSON 单个类型输出为字符串
+func (t TypeList) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Additional additionalProperties关键字，可以是布尔值或Schema
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+// UnmarshalJSON 同时支持布尔值和Schema
+func (a *Additional) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.Allowed = allowed
+		return nil
+	}
+	a.Allowed = true
+	a.Schema = &Schema{}
+	return json.Unmarshal(data, a.Schema)
+}
+
+// MarshalJSON 输出布尔值或Schema
+func (a Additional) MarshalJSON() ([]byte, error) {
+	if a.Schema != nil {
+		return json.Marshal(a.Schema)
+	}
+	return json.Marshal(a.Allowed)
+}
+
+// ValidationError 单条校验错误
+type ValidationError struct {
+	// Path 出错位置，形如 $.items[0].name
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Errors 校验错误列表
+type Errors []ValidationError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Parse 解析JSON Schema
+func Parse(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("解析JSON Schema失败: %v", err)
+	}
+	if err := schema.compile(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// ValidateJSON 解析JSON文本并校验，JSON格式错误也作为校验错误返回
+func (s *Schema) ValidateJSON(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return Errors{{Path: "$", Message: fmt.Sprintf("不是有效的JSON: %v", err)}}
+	}
+	if decoder.More() {
+		return Errors{{Path: "$", Message: "JSON之后存在多余内容"}}
+	}
+	return s.Validate(value)
+}
+
+// Validate 校验已解码的JSON值（encoding/json解码得到的interface{}）
+func (s *Schema) Validate(value interface{}) error {
+	var errs Errors
+	s.validate("$", normalize(value), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// compile 预先检查正则表达式是否合法
+func (s *Schema) compile() error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		if _, err := compilePattern(s.Pattern); err != nil {
+			return fmt.Errorf("无效的pattern %q: %v", s.Pattern, err)
+		}
+	}
+	children := append([]*Schema{s.Items, s.Not}, s.AllOf...)
+	children = append(children, s.AnyOf...)
+	children = append(children, s.OneOf...)
+	for _, p := range s.Properties {
+		children = append(children, p)
+	}
+	if s.AdditionalProperties != nil {
+		children = append(children, s.AdditionalProperties.Schema)
+	}
+	for _, child := range children {
+		if err := child.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *Errors) {
+	if s == nil {
+		return
+	}
+	add := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 {
+		actual := typeOf(value)
+		matched := false
+		for _, t := range s.Type {
+			if t == actual || (t == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			add("类型应为%s，实际为%s", strings.Join(s.Type, "|"), actual)
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, candidate := range s.Enum {
+			if equal(normalize(candidate), value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("取值必须是%v之一", s.Enum)
+		}
+	}
+
+	if s.Const != nil && !equal(normalize(s.Const), value) {
+		add("取值必须等于%v", s.Const)
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			add("长度不能小于%d", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			add("长度不能大于%d", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			if re, err := compilePattern(s.Pattern); err == nil && !re.MatchString(v) {
+				add("不匹配模式%s", s.Pattern)
+			}
+		}
+		if s.Format != "" && !checkFormat(s.Format, v) {
+			add("不是有效的%s格式", s.Format)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			add("不能小于%v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			add("不能大于%v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+			add("必须大于%v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && v >= *s.ExclusiveMaximum {
+			add("必须小于%v", *s.ExclusiveMaximum)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			add("元素个数不能少于%d", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			add("元素个数不能多于%d", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				add("缺少必填字段%s", name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := path + "." + k
+			if prop, ok := s.Properties[k]; ok {
+				prop.validate(childPath, v[k], errs)
+				continue
+			}
+			if s.AdditionalProperties == nil {
+				continue
+			}
+			if !s.AdditionalProperties.Allowed {
+				*errs = append(*errs, ValidationError{Path: childPath, Message: "不允许的字段"})
+				continue
+			}
+			s.AdditionalProperties.Schema.validate(childPath, v[k], errs)
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		sub.validate(path, value, errs)
+	}
+
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, sub := range s.AnyOf {
+			if sub.matches(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			add("不满足anyOf中的任何一个模式")
+		}
+	}
+
+	if len(s.OneOf) > 0 {
+		count := 0
+		for _, sub := range s.OneOf {
+			if sub.matches(value) {
+				count++
+			}
+		}
+		if count != 1 {
+			add("必须恰好满足oneOf中的一个模式，实际满足%d个", count)
+		}
+	}
+
+	if s.Not != nil && s.Not.matches(value) {
+		add("不能满足not中的模式")
+	}
+}
+
+// matches 判断值是否满足模式
+func (s *Schema) matches(value interface{}) bool {
+	var errs Errors
+	s.validate("$", value, &errs)
+	return len(errs) == 0
+}
+
+// patternCache 缓存已编译的正则表达式，校验可能在多个goroutine中并发进行
+var patternCache sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+// checkFormat 校验常用format，未知的format视为通过
+func checkFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
+	case "email":
+		at := strings.Index(value, "@")
+		return at > 0 && at < len(value)-1
+	}
+	return true
+}
+
+// normalize 将json.Number和各种数字类型统一为float64
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = normalize(item)
+		}
+		return out
+	}
+	return value
+}
+
+// typeOf 返回JSON值的类型名
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// equal 比较两个已归一化的JSON值
+func equal(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
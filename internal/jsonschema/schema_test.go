@@ -0,0 +1,175 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mustParse 解析测试中的Schema，出错时结束测试
+func mustParse(t *testing.T, schema string) *Schema {
+	t.Helper()
+	s, err := Parse([]byte(schema))
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", schema, err)
+	}
+	return s
+}
+
+// messages 返回校验错误，每条写成"路径: 说明"
+func messages(err error) []string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	out := make([]string, len(errs))
+	for i, e := range errs {
+		out[i] = e.Error()
+	}
+	return out
+}
+
+func TestValidate(t *testing.T) {
+	const person = `{
+		"type": "object",
+		"required": ["name", "age"],
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "maxLength": 4},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"role": {"enum": ["admin", "user", 1, null]},
+			"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}, "minItems": 1, "maxItems": 2},
+			"address": {
+				"type": "object",
+				"required": ["city"],
+				"properties": {"city": {"type": "string"}},
+				"additionalProperties": false
+			}
+		},
+		"additionalProperties": {"type": ["string", "null"]}
+	}`
+	tests := []struct {
+		name     string
+		schema   string
+		instance string
+		want     []string
+	}{
+		{"valid", person, `{"name": "张三", "age": 30, "role": "admin", "tags": ["a"], "address": {"city": "北京"}, "note": null}`, nil},
+		{"wrong root type", person, `[]`, []string{"$: 类型应为object，实际为array"}},
+		{"missing required", person, `{}`, []string{"$: 缺少必填字段name", "$: 缺少必填字段age"}},
+		{"integer", person, `{"name": "a", "age": 1.5}`, []string{"$.age: 类型应为integer，实际为number"}},
+		{"integer with zero fraction", person, `{"name": "a", "age": 2.0}`, nil},
+		{"minimum", person, `{"name": "a", "age": -1}`, []string{"$.age: 不能小于0"}},
+		{"maximum", person, `{"name": "a", "age": 151}`, []string{"$.age: 不能大于150"}},
+		{"length counts characters", person, `{"name": "四个汉字", "age": 1}`, nil},
+		{"maxLength", person, `{"name": "五个汉字啊", "age": 1}`, []string{"$.name: 长度不能大于4"}},
+		{"minLength", person, `{"name": "", "age": 1}`, []string{"$.name: 长度不能小于1"}},
+		{"enum", person, `{"name": "a", "age": 1, "role": "root"}`, []string{"$.role: 取值必须是[admin user 1 <nil>]之一"}},
+		{"enum number", person, `{"name": "a", "age": 1, "role": 1.0}`, nil},
+		{"enum null", person, `{"name": "a", "age": 1, "role": null}`, nil},
+		{"items", person, `{"name": "a", "age": 1, "tags": ["ok", "Bad", 3]}`, []string{
+			"$.tags: 元素个数不能多于2",
+			"$.tags[1]: 不匹配模式^[a-z]+$",
+			"$.tags[2]: 类型应为string，实际为integer",
+		}},
+		{"minItems", person, `{"name": "a", "age": 1, "tags": []}`, []string{"$.tags: 元素个数不能少于1"}},
+		{"nested object", person, `{"name": "a", "age": 1, "address": {"zip": "100000"}}`, []string{
+			"$.address: 缺少必填字段city",
+			"$.address.zip: 不允许的字段",
+		}},
+		{"additionalProperties schema", person, `{"name": "a", "age": 1, "extra": 5}`, []string{"$.extra: 类型应为string|null，实际为integer"}},
+		{
+			"nested items",
+			`{"type": "array", "items": {"type": "array", "items": {"type": "object", "properties": {"v": {"exclusiveMinimum": 0, "exclusiveMaximum": 1}}}}}`,
+			`[[{"v": 0.5}], [{"v": 0}, {"v": 1}]]`,
+			[]string{"$[1][0].v: 必须大于0", "$[1][1].v: 必须小于1"},
+		},
+		{"no type constraint", `{"minimum": 5}`, `"text"`, nil},
+		{"const", `{"const": {"a": [1, 2]}}`, `{"a": [1, 2.0]}`, nil},
+		{"const mismatch", `{"const": "x"}`, `"y"`, []string{"$: 取值必须等于x"}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer", "minimum": 10}]}`, `5`, []string{"$: 不满足anyOf中的任何一个模式"}},
+		{"oneOf", `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, `5`, []string{"$: 必须恰好满足oneOf中的一个模式，实际满足2个"}},
+		{"allOf", `{"allOf": [{"type": "string"}, {"maxLength": 1}]}`, `"ab"`, []string{"$: 长度不能大于1"}},
+		{"not", `{"not": {"type": "null"}}`, `null`, []string{"$: 不能满足not中的模式"}},
+		{"format", `{"type": "array", "items": {"format": "date-time"}}`, `["2024-01-02T03:04:05Z", "2024-01-02"]`, []string{"$[1]: 不是有效的date-time格式"}},
+		{"email", `{"format": "email"}`, `"a@"`, []string{"$: 不是有效的email格式"}},
+		{"unknown format", `{"format": "color"}`, `"red"`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mustParse(t, tt.schema).ValidateJSON([]byte(tt.instance))
+			got := messages(err)
+			if err != nil && got == nil {
+				t.Fatalf("error = %v, want Errors", err)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateGoValues(t *testing.T) {
+	s := mustParse(t, `{"type": "object", "properties": {"n": {"type": "integer", "maximum": 3}, "list": {"items": {"type": "number"}}}}`)
+	if err := s.Validate(map[string]interface{}{"n": 3, "list": []interface{}{int64(1), float32(1.5)}}); err != nil {
+		t.Fatalf("Validate = %v", err)
+	}
+	if got := messages(s.Validate(map[string]interface{}{"n": int64(4)})); len(got) != 1 || got[0] != "$.n: 不能大于3" {
+		t.Fatalf("errors = %q", got)
+	}
+}
+
+func TestValidateJSONSyntax(t *testing.T) {
+	s := mustParse(t, `{"type": "integer"}`)
+	tests := []struct {
+		instance string
+		want     string
+	}{
+		{`{`, "$: 不是有效的JSON"},
+		{`1 2`, "$: JSON之后存在多余内容"},
+		{``, "$: 不是有效的JSON"},
+	}
+	for _, tt := range tests {
+		err := s.ValidateJSON([]byte(tt.instance))
+		if got := messages(err); len(got) != 1 || !strings.HasPrefix(got[0], tt.want) {
+			t.Errorf("ValidateJSON(%q) = %q, want %q", tt.instance, got, tt.want)
+		}
+	}
+	// 大整数按json.Number解码，不会因为精度丢失变成小数
+	if err := s.ValidateJSON([]byte(`12345678901234567890`)); err != nil {
+		t.Fatalf("big integer: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{`{"type": 1}`, "type必须是字符串或字符串数组"},
+		{`{"properties": {"a": {"pattern": "("}}}`, `无效的pattern "("`},
+		{`{"items": {"not": {"pattern": "[a-"}}}`, `无效的pattern "[a-"`},
+		{`{"additionalProperties": {"anyOf": [{"pattern": "*"}]}}`, `无效的pattern "*"`},
+		{`{"minItems": "1"}`, "解析JSON Schema失败"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.schema)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%s) error = %v, want %q", tt.schema, err, tt.want)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	src := `{"type":"object","properties":{"a":{"type":["string","null"]}},"additionalProperties":false,"items":{"type":"string"}}`
+	data, err := json.Marshal(mustParse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != src {
+		t.Fatalf("Marshal = %s, want %s", data, src)
+	}
+	data, _ = json.Marshal(mustParse(t, `{"additionalProperties":{"type":"integer"}}`))
+	if string(data) != `{"additionalProperties":{"type":"integer"}}` {
+		t.Fatalf("Marshal = %s", data)
+	}
+}
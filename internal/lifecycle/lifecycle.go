@@ -0,0 +1,129 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook 退出时执行的清理函数
+type Hook struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// Manager 管理进程生命周期
+//
+// 后台任务（例如浏览器自动化任务）应从Context()派生自己的context，开始关闭时会被取消；
+// 需要在退出前执行的清理（关闭浏览器、刷新存储等）通过OnShutdown注册，按注册的逆序执行。
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	hooks    []Hook
+	tasks    sync.WaitGroup
+	shutdown bool
+}
+
+// New 创建生命周期管理器
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Context 返回开始关闭时被取消的context
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// OnShutdown 注册退出时执行的清理函数
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, Hook{Name: name, Fn: fn})
+}
+
+// Go 在后台运行任务，关闭时会取消任务的context并等待其返回
+func (m *Manager) Go(name string, fn func(ctx context.Context)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shutdown {
+		return fmt.Errorf("正在关闭，无法启动任务: %s", name)
+	}
+
+	m.tasks.Add(1)
+	go func() {
+		defer m.tasks.Done()
+		fn(m.ctx)
+	}()
+	return nil
+}
+
+// Shutdown 取消后台任务并等待其退出，然后按注册的逆序执行清理函数
+//
+// ctx的截止时间是整个关闭过程的上限，超时后不再等待剩余任务，但仍会执行清理函数。
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.shutdown {
+		m.mu.Unlock()
+		return nil
+	}
+	m.shutdown = true
+	hooks := append([]Hook(nil), m.hooks...)
+	m.mu.Unlock()
+
+	m.cancel()
+
+	// 等待后台任务响应取消
+	done := make(chan struct{})
+	go func() {
+		m.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logrus.Warn("等待后台任务退出超时")
+	}
+
+	var firstErr error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		started := time.Now()
+
+		// 即使整体已超时，也给每个清理函数一小段时间完成
+		hookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hookTimeout(ctx))
+		err := hook.Fn(hookCtx)
+		cancel()
+
+		if err != nil {
+			logrus.Errorf("关闭%s失败: %v", hook.Name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("关闭%s失败: %v", hook.Name, err)
+			}
+			continue
+		}
+		logrus.Infof("已关闭%s (%s)", hook.Name, time.Since(started).Round(time.Millisecond))
+	}
+	return firstErr
+}
+
+// hookTimeout 返回单个清理函数可用的时间
+func hookTimeout(ctx context.Context) time.Duration {
+	const minTimeout = 5 * time.Second
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return time.Minute
+	}
+	if remaining := time.Until(deadline); remaining > minTimeout {
+		return remaining
+	}
+	return minTimeout
+}
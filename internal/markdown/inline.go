@@ -0,0 +1,558 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxInlineNesting 强调和链接的最大嵌套层数
+	maxInlineNesting = 32
+	// minInlineBudget 行内解析的最少步数，正常的回复远远用不完
+	minInlineBudget = 1 << 20
+)
+
+// inlineBudget 按输入长度给出查找配对符号的总步数
+func inlineBudget(n int) int {
+	if n*64 > minInlineBudget {
+		return n * 64
+	}
+	return minInlineBudget
+}
+
+// inline 渲染一段行内文本，段落内的换行渲染为<br>
+func (r *renderer) inline(text string) string {
+	var b strings.Builder
+	r.inlineTo(&b, text, 0, false)
+	return b.String()
+}
+
+// inlineTo 把text渲染到b，inLink为true时不再生成嵌套的链接
+func (r *renderer) inlineTo(b *strings.Builder, text string, depth int, inLink bool) {
+	if depth > maxInlineNesting {
+		b.WriteString(escapeText(text))
+		return
+	}
+
+	// tags 原始HTML中已打开的标签，在本层结束时补全，保证输出结构完整
+	var tags []string
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			b.WriteString(escapeText(text[start:end]))
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text):
+			next := text[i+1]
+			if next == '\n' {
+				flush(i)
+				b.WriteString("<br>\n")
+				i += 2
+				start = i
+				continue
+			}
+			if isASCIIPunct(next) {
+				flush(i)
+				b.WriteString(html.EscapeString(string(next)))
+				i += 2
+				start = i
+				continue
+			}
+
+		case c == '\n':
+			// 去掉行尾空格，换行按<br>显示，与聊天中的习惯一致
+			flush(len(strings.TrimRight(text[:i], " ")))
+			b.WriteString("<br>\n")
+			i++
+			start = i
+			continue
+
+		case c == '`':
+			n := runLength(text, i, '`')
+			if end := findCodeSpanEnd(text, i+n, n); end >= 0 {
+				flush(i)
+				b.WriteString("<code>")
+				b.WriteString(html.EscapeString(codeSpanContent(text[i+n : end])))
+				b.WriteString("</code>")
+				i = end + n
+				start = i
+				continue
+			}
+			i += n
+			continue
+
+		case c == '*' || c == '_':
+			if next, ok := r.emphasis(b, text, i, depth, inLink, flush); ok {
+				i = next
+				start = i
+				continue
+			}
+			i += runLength(text, i, c)
+			continue
+
+		case c == '~' && runLength(text, i, '~') == 2:
+			if next, ok := r.strikethrough(b, text, i, depth, inLink, flush); ok {
+				i = next
+				start = i
+				continue
+			}
+			i += 2
+			continue
+
+		case c == '!' && i+1 < len(text) && text[i+1] == '[' && !inLink:
+			if label, dest, title, next, ok := r.parseLink(text, i+1); ok {
+				flush(i)
+				// 图片只渲染为链接，避免自动加载外部资源泄露访问者信息
+				if label == "" {
+					label = dest
+				}
+				r.link(b, label, dest, title, depth, `class="image-link"`)
+				i = next
+				start = i
+				continue
+			}
+
+		case c == '[' && !inLink:
+			if label, dest, title, next, ok := r.parseLink(text, i); ok {
+				flush(i)
+				r.link(b, label, dest, title, depth, "")
+				i = next
+				start = i
+				continue
+			}
+
+		case c == '<':
+			if url, n, ok := parseAutolink(text[i:]); ok && !inLink {
+				flush(i)
+				r.link(b, escapeMarkdown(text[i+1:i+n-1]), url, "", depth, "")
+				i += n
+				start = i
+				continue
+			}
+			if name, closing, n, ok := parseTag(text[i:]); ok && allowedTags[name] {
+				flush(i)
+				tags = writeTag(b, tags, name, closing)
+				i += n
+				start = i
+				continue
+			}
+
+		case (c == 'h' || c == 'w') && !inLink && (i == 0 || !isAlnum(text[i-1])):
+			if n := bareURLLength(text[i:]); n > 0 {
+				flush(i)
+				url := text[i : i+n]
+				href := url
+				if strings.HasPrefix(url, "www.") {
+					href = "http://" + url
+				}
+				r.link(b, escapeMarkdown(url), href, "", depth, "")
+				i += n
+				start = i
+				continue
+			}
+		}
+		i++
+	}
+	flush(len(text))
+
+	for j := len(tags) - 1; j >= 0; j-- {
+		b.WriteString("</" + tags[j] + ">")
+	}
+}
+
+// emphasis 处理*和_，找到配对的结束符时渲染为<em>、<strong>或两者
+func (r *renderer) emphasis(b *strings.Builder, text string, i, depth int, inLink bool, flush func(int)) (int, bool) {
+	c := text[i]
+	n := runLength(text, i, c)
+	if !canOpen(text, i, n) {
+		return 0, false
+	}
+
+	closeAt, m := r.findCloser(text, i+n, c)
+	if closeAt < 0 {
+		return 0, false
+	}
+
+	use := n
+	if m < use {
+		use = m
+	}
+	if use > 3 {
+		use = 3
+	}
+
+	flush(i)
+	// 多余的开始符和结束符按原样输出
+	b.WriteString(strings.Repeat(string(c), n-use))
+	open, close := "<em>", "</em>"
+	switch use {
+	case 2:
+		open, close = "<strong>", "</strong>"
+	case 3:
+		open, close = "<em><strong>", "</strong></em>"
+	}
+	b.WriteString(open)
+	r.inlineTo(b, text[i+n:closeAt], depth+1, inLink)
+	b.WriteString(close)
+	b.WriteString(strings.Repeat(string(c), m-use))
+	return closeAt + m, true
+}
+
+// findCloser 查找与开始符配对的结束符，跳过代码和嵌套的同类强调
+func (r *renderer) findCloser(text string, from int, c byte) (int, int) {
+	nested := 0
+	for j := from; j < len(text); {
+		if r.budget--; r.budget <= 0 {
+			return -1, 0
+		}
+		switch text[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			n := runLength(text, j, '`')
+			if end := findCodeSpanEnd(text, j+n, n); end >= 0 {
+				j = end + n
+			} else {
+				j += n
+			}
+			continue
+		case c:
+			n := runLength(text, j, c)
+			opens, closes := canOpen(text, j, n), canClose(text, j, n)
+			switch {
+			case closes && nested == 0:
+				return j, n
+			case closes:
+				nested--
+			case opens:
+				nested++
+			}
+			j += n
+			continue
+		}
+		j++
+	}
+	return -1, 0
+}
+
+// strikethrough 处理~~删除线~~
+func (r *renderer) strikethrough(b *strings.Builder, text string, i, depth int, inLink bool, flush func(int)) (int, bool) {
+	if !canOpen(text, i, 2) {
+		return 0, false
+	}
+	for j := i + 2; j+1 < len(text); j++ {
+		if r.budget--; r.budget <= 0 {
+			return 0, false
+		}
+		if text[j] == '~' && runLength(text, j, '~') == 2 && canClose(text, j, 2) {
+			flush(i)
+			b.WriteString("<del>")
+			r.inlineTo(b, text[i+2:j], depth+1, inLink)
+			b.WriteString("</del>")
+			return j + 2, true
+		}
+		if text[j] == '~' {
+			j += runLength(text, j, '~') - 1
+		}
+	}
+	return 0, false
+}
+
+// parseLink 解析从text[i]（[）开始的[文字](地址 "标题")
+func (r *renderer) parseLink(text string, i int) (label, dest, title string, next int, ok bool) {
+	// 查找配对的]，跳过转义和行内代码
+	level := 0
+	end := -1
+	for j := i; j < len(text) && end < 0; j++ {
+		if r.budget--; r.budget <= 0 {
+			return "", "", "", 0, false
+		}
+		switch text[j] {
+		case '\\':
+			j++
+		case '`':
+			n := runLength(text, j, '`')
+			if e := findCodeSpanEnd(text, j+n, n); e >= 0 {
+				j = e + n - 1
+			} else {
+				j += n - 1
+			}
+		case '[':
+			level++
+		case ']':
+			if level--; level == 0 {
+				end = j
+			}
+		}
+	}
+	if end < 0 || end+1 >= len(text) || text[end+1] != '(' {
+		return "", "", "", 0, false
+	}
+	label = text[i+1 : end]
+
+	j := skipSpaces(text, end+2)
+	// 地址可以用<>包围，否则到空白或不配对的)为止
+	if j < len(text) && text[j] == '<' {
+		k := strings.IndexAny(text[j+1:], ">\n")
+		if k < 0 || text[j+1+k] != '>' {
+			return "", "", "", 0, false
+		}
+		if r.budget -= k; r.budget <= 0 {
+			return "", "", "", 0, false
+		}
+		dest = text[j+1 : j+1+k]
+		j += k + 2
+	} else {
+		parens := 0
+		k := j
+		for ; k < len(text); k++ {
+			if r.budget--; r.budget <= 0 {
+				return "", "", "", 0, false
+			}
+			ch := text[k]
+			if ch == '\\' && k+1 < len(text) {
+				k++
+				continue
+			}
+			if ch == ' ' || ch == '\n' || ch < 0x20 {
+				break
+			}
+			if ch == '(' {
+				parens++
+			}
+			if ch == ')' {
+				if parens == 0 {
+					break
+				}
+				parens--
+			}
+		}
+		dest = text[j:k]
+		j = k
+	}
+
+	j = skipSpaces(text, j)
+	if j < len(text) && (text[j] == '"' || text[j] == '\'' || text[j] == '(') {
+		closer := text[j]
+		if closer == '(' {
+			closer = ')'
+		}
+		k := strings.IndexByte(text[j+1:], closer)
+		if k < 0 {
+			r.budget -= len(text) - j
+			return "", "", "", 0, false
+		}
+		if r.budget -= k; r.budget <= 0 {
+			return "", "", "", 0, false
+		}
+		title = text[j+1 : j+1+k]
+		j = skipSpaces(text, j+k+2)
+	}
+	if j >= len(text) || text[j] != ')' {
+		return "", "", "", 0, false
+	}
+	return label, unescapeMarkdown(dest), unescapeMarkdown(title), j + 1, true
+}
+
+// link 输出链接，地址不安全时只输出文字
+func (r *renderer) link(b *strings.Builder, label, dest, title string, depth int, attrs string) {
+	href, ok := safeURL(html.UnescapeString(dest))
+	if !ok {
+		r.inlineTo(b, label, depth+1, true)
+		return
+	}
+	b.WriteString(`<a href="` + html.EscapeString(href) + `"`)
+	if title != "" {
+		b.WriteString(` title="` + html.EscapeString(html.UnescapeString(title)) + `"`)
+	}
+	if attrs != "" {
+		b.WriteString(" " + attrs)
+	}
+	b.WriteString(` target="_blank" rel="noopener noreferrer nofollow">`)
+	r.inlineTo(b, label, depth+1, true)
+	b.WriteString("</a>")
+}
+
+// findCodeSpanEnd 查找与长度为n的反引号串配对的结束位置
+func findCodeSpanEnd(text string, from, n int) int {
+	for j := from; j < len(text); {
+		k := strings.IndexByte(text[j:], '`')
+		if k < 0 {
+			return -1
+		}
+		j += k
+		m := runLength(text, j, '`')
+		if m == n {
+			return j
+		}
+		j += m
+	}
+	return -1
+}
+
+// codeSpanContent 换行视为空格，两端各有一个空格时去掉
+func codeSpanContent(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+		code = code[1 : len(code)-1]
+	}
+	return code
+}
+
+// canOpen 判断长度为n的分隔符串能否作为开始符（左侧贴合）
+func canOpen(text string, i, n int) bool {
+	next, _ := runeAt(text, i+n)
+	prev, _ := runeBefore(text, i)
+	if next == 0 || unicode.IsSpace(next) {
+		return false
+	}
+	if unicode.IsPunct(next) && prev != 0 && !unicode.IsSpace(prev) && !unicode.IsPunct(prev) {
+		return false
+	}
+	// 下划线不能用于单词内部的强调，例如snake_case_name
+	if text[i] == '_' && prev != 0 && isWordRune(prev) {
+		return false
+	}
+	return true
+}
+
+// canClose 判断长度为n的分隔符串能否作为结束符（右侧贴合）
+func canClose(text string, i, n int) bool {
+	prev, _ := runeBefore(text, i)
+	next, _ := runeAt(text, i+n)
+	if prev == 0 || unicode.IsSpace(prev) {
+		return false
+	}
+	if unicode.IsPunct(prev) && next != 0 && !unicode.IsSpace(next) && !unicode.IsPunct(next) {
+		return false
+	}
+	if text[i] == '_' && next != 0 && isWordRune(next) {
+		return false
+	}
+	return true
+}
+
+// parseAutolink 解析<https://...>形式的自动链接
+func parseAutolink(text string) (string, int, bool) {
+	end := strings.IndexAny(text[1:], "> \n<") + 1
+	if end <= 0 || text[end] != '>' {
+		return "", 0, false
+	}
+	url := text[1:end]
+	colon := strings.IndexByte(url, ':')
+	if colon < 2 || !isScheme(url[:colon]) {
+		if strings.Contains(url, "@") && !strings.ContainsAny(url, ":/") {
+			return "mailto:" + url, end + 1, true
+		}
+		return "", 0, false
+	}
+	return url, end + 1, true
+}
+
+// bareURLLength 识别文本中直接出现的http(s)://和www.网址，返回长度
+func bareURLLength(text string) int {
+	if !strings.HasPrefix(text, "http://") && !strings.HasPrefix(text, "https://") && !strings.HasPrefix(text, "www.") {
+		return 0
+	}
+	n := 0
+	for n < len(text) {
+		c, size := utf8.DecodeRuneInString(text[n:])
+		// 网址后面紧跟中文时在中文处截断
+		if unicode.IsSpace(c) || c == '<' || c == '>' || c == '"' || c == '`' || c >= 0x2E80 {
+			break
+		}
+		n += size
+	}
+	// 去掉句末的标点和不配对的右括号
+	for n > 0 {
+		last := text[n-1]
+		if strings.IndexByte("?!.,:;*_~'", last) >= 0 {
+			n--
+			continue
+		}
+		if last == ')' && strings.Count(text[:n], "(") < strings.Count(text[:n], ")") {
+			n--
+			continue
+		}
+		break
+	}
+	if n <= strings.Index(text, ".")+1 && strings.HasPrefix(text, "www.") {
+		return 0
+	}
+	if strings.HasPrefix(text, "http") && n <= strings.Index(text, "//")+2 {
+		return 0
+	}
+	return n
+}
+
+// escapeText 转义文本，已有的字符实体（例如&nbsp;）先解码再统一转义
+func escapeText(text string) string {
+	return html.EscapeString(html.UnescapeString(text))
+}
+
+// escapeMarkdown 转义自动链接文字中会被当作Markdown语法的字符
+func escapeMarkdown(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if strings.IndexByte("\\`*_~[]<", text[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// unescapeMarkdown 去掉链接地址和标题中的反斜杠转义
+func unescapeMarkdown(text string) string {
+	if !strings.Contains(text, `\`) {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]) {
+			i++
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+func skipSpaces(text string, i int) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func runeAt(text string, i int) (rune, int) {
+	if i >= len(text) {
+		return 0, 0
+	}
+	return utf8.DecodeRuneInString(text[i:])
+}
+
+func runeBefore(text string, i int) (rune, int) {
+	if i <= 0 {
+		return 0, 0
+	}
+	return utf8.DecodeLastRuneInString(text[:i])
+}
+
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isASCIIPunct(c byte) bool {
+	return c >= '!' && c <= '/' || c >= ':' && c <= '@' || c >= '[' && c <= '`' || c >= '{' && c <= '~'
+}
@@ -0,0 +1,651 @@
+// Package markdown 把模型回复中的Markdown转换为可以直接插入页面的安全HTML
+//
+// 支持的语法: 标题、段落、强调、删除线、行内代码、围栏和缩进代码块、引用、
+// 有序/无序/任务列表、表格、分隔线、链接和自动链接。
+//
+// 模型会读取不可信的网页，回复中的内容同样不可信，因此:
+//   - 所有文本都经过转义，原始HTML只保留少量不带属性的格式标签（见sanitize.go）
+//   - 链接只允许http、https和mailto，并在新窗口以rel="noopener noreferrer nofollow"打开
+//   - 图片渲染为指向图片的链接，不会自动加载外部资源
+package markdown
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// maxNesting 引用和列表的最大嵌套层数，超出部分按普通段落渲染
+const maxNesting = 16
+
+// Render 把Markdown渲染为安全的HTML片段
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	r := &renderer{budget: inlineBudget(len(src))}
+	r.blocks(lines, 0, false)
+	return strings.TrimSuffix(r.out.String(), "\n")
+}
+
+type renderer struct {
+	out strings.Builder
+	// budget 行内解析查找配对符号的剩余步数，防止构造的输入耗尽CPU
+	budget int
+}
+
+// blocks 渲染一组行，tight为true时段落不包裹<p>（紧凑列表项）
+func (r *renderer) blocks(lines []string, depth int, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			i++
+			continue
+		}
+
+		indent := leadingSpaces(line)
+		if indent >= 4 {
+			i = r.indentedCode(lines, i)
+			continue
+		}
+
+		text := line[indent:]
+		switch {
+		case isFence(text):
+			i = r.fencedCode(lines, i)
+		case headingLevel(text) > 0:
+			r.heading(text)
+			i++
+		case isThematicBreak(text):
+			r.out.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(text, ">") && depth < maxNesting:
+			i = r.blockquote(lines, i, depth)
+		case isListItem(line) && depth < maxNesting:
+			i = r.list(lines, i, depth)
+		case isTableStart(lines, i):
+			i = r.table(lines, i)
+		default:
+			i = r.paragraph(lines, i, tight)
+		}
+	}
+}
+
+// indentedCode 渲染缩进4个空格的代码块
+func (r *renderer) indentedCode(lines []string, i int) int {
+	var code []string
+	for ; i < len(lines); i++ {
+		if isBlank(lines[i]) {
+			code = append(code, "")
+			continue
+		}
+		if leadingSpaces(lines[i]) < 4 {
+			break
+		}
+		code = append(code, lines[i][4:])
+	}
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+	r.code("", code)
+	return i
+}
+
+// fencedCode 渲染```或~~~包围的代码块，没有结束标记时延续到末尾
+func (r *renderer) fencedCode(lines []string, i int) int {
+	indent := leadingSpaces(lines[i])
+	open := lines[i][indent:]
+	char := open[0]
+	n := runLength(open, 0, char)
+	lang := strings.Fields(open[n:])
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if ind := leadingSpaces(line); ind < 4 {
+			rest := line[ind:]
+			if runLength(rest, 0, char) >= n && isBlank(rest[runLength(rest, 0, char):]) {
+				i++
+				break
+			}
+		}
+		// 去掉与开始标记相同的缩进
+		strip := indent
+		if s := leadingSpaces(line); s < strip {
+			strip = s
+		}
+		code = append(code, line[strip:])
+	}
+
+	language := ""
+	if len(lang) > 0 {
+		language = cleanLanguage(lang[0])
+	}
+	r.code(language, code)
+	return i
+}
+
+func (r *renderer) code(language string, lines []string) {
+	r.out.WriteString("<pre><code")
+	if language != "" {
+		r.out.WriteString(` class="language-`)
+		r.out.WriteString(html.EscapeString(language))
+		r.out.WriteString(`"`)
+	}
+	r.out.WriteString(">")
+	for _, line := range lines {
+		r.out.WriteString(html.EscapeString(line))
+		r.out.WriteString("\n")
+	}
+	r.out.WriteString("</code></pre>\n")
+}
+
+// heading 渲染#开头的标题
+func (r *renderer) heading(text string) {
+	level := headingLevel(text)
+	content := strings.TrimSpace(text[level:])
+	// 去掉可选的结尾#
+	if trimmed := strings.TrimRight(content, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") {
+		content = strings.TrimSpace(trimmed)
+	}
+	r.headingTag(level, content)
+}
+
+func (r *renderer) headingTag(level int, content string) {
+	tag := "h" + strconv.Itoa(level)
+	r.out.WriteString("<" + tag + ">")
+	r.out.WriteString(r.inline(content))
+	r.out.WriteString("</" + tag + ">\n")
+}
+
+// blockquote 渲染>开头的引用，段落的后续行可以省略>
+func (r *renderer) blockquote(lines []string, i int, depth int) int {
+	var inner []string
+	lazy := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		indent := leadingSpaces(line)
+		if indent < 4 && strings.HasPrefix(line[indent:], ">") {
+			rest := line[indent+1:]
+			rest = strings.TrimPrefix(rest, " ")
+			inner = append(inner, rest)
+			lazy = !isBlank(rest)
+			continue
+		}
+		if lazy && !isBlank(line) && !startsBlock(line) {
+			inner = append(inner, line)
+			continue
+		}
+		break
+	}
+	r.out.WriteString("<blockquote>\n")
+	r.blocks(inner, depth+1, false)
+	r.out.WriteString("</blockquote>\n")
+	return i
+}
+
+// list 渲染连续的同类列表项
+func (r *renderer) list(lines []string, i int, depth int) int {
+	first, _ := parseListMarker(lines[i])
+	var items [][]string
+	loose := false
+
+	for i < len(lines) {
+		marker, ok := parseListMarker(lines[i])
+		if !ok || marker.ordered != first.ordered || marker.char != first.char {
+			break
+		}
+
+		item := []string{""}
+		if marker.offset < len(lines[i]) {
+			item[0] = lines[i][marker.offset:]
+		}
+		blank := false
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				item = append(item, "")
+				blank = true
+				continue
+			}
+			if leadingSpaces(line) >= marker.offset {
+				item = append(item, line[marker.offset:])
+				blank = false
+				continue
+			}
+			// 空行之后没有缩进的内容不再属于该列表项，否则作为段落的延续行
+			if blank || startsBlock(line) {
+				break
+			}
+			item = append(item, strings.TrimLeft(line, " "))
+		}
+
+		trailing := 0
+		for len(item) > 0 && isBlank(item[len(item)-1]) {
+			item = item[:len(item)-1]
+			trailing++
+		}
+		if trailing > 0 && i < len(lines) {
+			if next, ok := parseListMarker(lines[i]); ok && next.ordered == first.ordered && next.char == first.char {
+				loose = true
+			}
+		}
+		items = append(items, item)
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	r.out.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		r.out.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	r.out.WriteString(">\n")
+
+	for _, item := range items {
+		checkbox := ""
+		if len(item) > 0 {
+			if rest, checked, ok := taskMarker(item[0]); ok {
+				item[0] = rest
+				checkbox = `<input type="checkbox" disabled>`
+				if checked {
+					checkbox = `<input type="checkbox" checked disabled>`
+				}
+			}
+		}
+		if checkbox != "" {
+			r.out.WriteString(`<li class="task">` + checkbox)
+		} else {
+			r.out.WriteString("<li>")
+		}
+
+		tight := !loose && !hasBlankLine(item)
+		if tight {
+			// 紧凑列表项只有一个段落时不换行，嵌套的块另起一行
+			var sub renderer
+			sub.budget = r.budget
+			sub.blocks(item, depth+1, true)
+			r.budget = sub.budget
+			r.out.WriteString(strings.TrimSuffix(sub.out.String(), "\n"))
+		} else {
+			r.out.WriteString("\n")
+			r.blocks(item, depth+1, false)
+		}
+		r.out.WriteString("</li>\n")
+	}
+	r.out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// table 渲染GFM表格
+func (r *renderer) table(lines []string, i int) int {
+	header := splitRow(lines[i])
+	aligns := parseDelimiterRow(lines[i+1])
+
+	r.out.WriteString("<table>\n<thead>\n")
+	r.tableRow("th", header, aligns)
+	r.out.WriteString("</thead>\n")
+
+	i += 2
+	body := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) || startsBlock(line) || !strings.Contains(line, "|") {
+			break
+		}
+		if !body {
+			r.out.WriteString("<tbody>\n")
+			body = true
+		}
+		r.tableRow("td", splitRow(line), aligns)
+	}
+	if body {
+		r.out.WriteString("</tbody>\n")
+	}
+	r.out.WriteString("</table>\n")
+	return i
+}
+
+func (r *renderer) tableRow(tag string, cells []string, aligns []string) {
+	r.out.WriteString("<tr>")
+	for i, align := range aligns {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		r.out.WriteString("<" + tag)
+		if align != "" {
+			r.out.WriteString(` style="text-align: ` + align + `"`)
+		}
+		r.out.WriteString(">")
+		r.out.WriteString(r.inline(cell))
+		r.out.WriteString("</" + tag + ">")
+	}
+	r.out.WriteString("</tr>\n")
+}
+
+// paragraph 渲染段落，下一行是===或---时作为标题
+func (r *renderer) paragraph(lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+		if len(text) > 0 {
+			if level := setextLevel(line); level > 0 {
+				r.headingTag(level, strings.Join(text, "\n"))
+				return i + 1
+			}
+			if startsBlock(line) || isTableStart(lines, i) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+
+	content := r.inline(strings.TrimRight(strings.Join(text, "\n"), " "))
+	if tight {
+		r.out.WriteString(content)
+		r.out.WriteString("\n")
+		return i
+	}
+	r.out.WriteString("<p>")
+	r.out.WriteString(content)
+	r.out.WriteString("</p>\n")
+	return i
+}
+
+// startsBlock 判断该行是否开始一个新的块，可以打断段落
+func startsBlock(line string) bool {
+	indent := leadingSpaces(line)
+	if indent >= 4 {
+		return false
+	}
+	text := line[indent:]
+	return isFence(text) || headingLevel(text) > 0 || isThematicBreak(text) ||
+		strings.HasPrefix(text, ">") || isListItem(line)
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func leadingSpaces(line string) int {
+	n := 0
+	for n < len(line) && line[n] == ' ' {
+		n++
+	}
+	return n
+}
+
+// expandTabs 把行首的制表符展开为空格，制表位为4
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\t':
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		case ' ':
+			b.WriteByte(' ')
+			col++
+		default:
+			b.WriteString(line[i:])
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isFence(text string) bool {
+	if len(text) < 3 || (text[0] != '`' && text[0] != '~') {
+		return false
+	}
+	n := runLength(text, 0, text[0])
+	if n < 3 {
+		return false
+	}
+	// 反引号围栏的语言标记中不能再有反引号，否则是行内代码
+	return text[0] == '~' || !strings.Contains(text[n:], "`")
+}
+
+// cleanLanguage 只保留语言名中常见的字符
+func cleanLanguage(lang string) string {
+	var b strings.Builder
+	for _, c := range lang {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("+#-_.", c) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// headingLevel 返回#标题的级别，不是标题时返回0
+func headingLevel(text string) int {
+	n := runLength(text, 0, '#')
+	if n == 0 || n > 6 {
+		return 0
+	}
+	if n < len(text) && text[n] != ' ' {
+		return 0
+	}
+	return n
+}
+
+// setextLevel 判断段落下一行是否为===或---标题下划线
+func setextLevel(line string) int {
+	indent := leadingSpaces(line)
+	if indent >= 4 {
+		return 0
+	}
+	text := strings.TrimRight(line[indent:], " ")
+	if text == "" {
+		return 0
+	}
+	if runLength(text, 0, '=') == len(text) {
+		return 1
+	}
+	if runLength(text, 0, '-') == len(text) {
+		return 2
+	}
+	return 0
+}
+
+func isThematicBreak(text string) bool {
+	if text == "" || !strings.ContainsRune("-*_", rune(text[0])) {
+		return false
+	}
+	count := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case text[0]:
+			count++
+		case ' ':
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+type listMarker struct {
+	ordered bool
+	char    byte
+	start   int
+	// offset 列表项内容相对行首的位置，后续行缩进至少这么多才属于该项
+	offset int
+}
+
+func isListItem(line string) bool {
+	_, ok := parseListMarker(line)
+	return ok
+}
+
+// parseListMarker 解析-、*、+或数字加.或)开头的列表项
+func parseListMarker(line string) (listMarker, bool) {
+	indent := leadingSpaces(line)
+	if indent >= 4 {
+		return listMarker{}, false
+	}
+	text := line[indent:]
+	var m listMarker
+	width := 0
+	switch {
+	case text == "":
+		return listMarker{}, false
+	case text[0] == '-' || text[0] == '*' || text[0] == '+':
+		m.char = text[0]
+		width = 1
+	default:
+		digits := 0
+		for digits < len(text) && digits < 9 && text[digits] >= '0' && text[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits >= len(text) || (text[digits] != '.' && text[digits] != ')') {
+			return listMarker{}, false
+		}
+		m.ordered = true
+		m.char = text[digits]
+		m.start, _ = strconv.Atoi(text[:digits])
+		width = digits + 1
+	}
+
+	rest := text[width:]
+	if rest == "" {
+		// 空的列表项
+		m.offset = indent + width + 1
+		return m, true
+	}
+	if rest[0] != ' ' {
+		return listMarker{}, false
+	}
+	spaces := leadingSpaces(rest)
+	if spaces > 4 || spaces == len(rest) {
+		// 内容本身是缩进代码块，或者只有空格
+		spaces = 1
+	}
+	m.offset = indent + width + spaces
+	return m, true
+}
+
+// taskMarker 解析任务列表项开头的[ ]或[x]
+func taskMarker(text string) (rest string, checked bool, ok bool) {
+	if len(text) < 4 || text[0] != '[' || text[2] != ']' || text[3] != ' ' {
+		return text, false, false
+	}
+	switch text[1] {
+	case ' ':
+		return text[4:], false, true
+	case 'x', 'X':
+		return text[4:], true, true
+	}
+	return text, false, false
+}
+
+// hasBlankLine 判断列表项内部（围栏代码块以外）是否有空行
+func hasBlankLine(lines []string) bool {
+	var fence byte
+	fenceLen := 0
+	for _, line := range lines {
+		text := strings.TrimLeft(line, " ")
+		if fence != 0 {
+			if runLength(text, 0, fence) >= fenceLen && isBlank(text[runLength(text, 0, fence):]) {
+				fence = 0
+			}
+			continue
+		}
+		if isFence(text) {
+			fence, fenceLen = text[0], runLength(text, 0, text[0])
+			continue
+		}
+		if isBlank(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTableStart 判断第i行是否为表头，即下一行是分隔行且列数相同
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") || leadingSpaces(lines[i]) >= 4 {
+		return false
+	}
+	aligns := parseDelimiterRow(lines[i+1])
+	return aligns != nil && len(splitRow(lines[i])) == len(aligns)
+}
+
+// parseDelimiterRow 解析表格分隔行，返回每列的对齐方式，不是分隔行时返回nil
+func parseDelimiterRow(line string) []string {
+	if !strings.Contains(line, "-") || leadingSpaces(line) >= 4 {
+		return nil
+	}
+	cells := splitRow(line)
+	aligns := make([]string, len(cells))
+	for i, cell := range cells {
+		left := strings.HasPrefix(cell, ":")
+		right := strings.HasSuffix(cell, ":")
+		dashes := strings.Trim(cell, ":")
+		if dashes == "" || runLength(dashes, 0, '-') != len(dashes) {
+			return nil
+		}
+		switch {
+		case left && right:
+			aligns[i] = "center"
+		case left:
+			aligns[i] = "left"
+		case right:
+			aligns[i] = "right"
+		}
+	}
+	// 没有竖线的单列分隔行与标题下划线无法区分，不当作表格
+	if len(cells) == 1 && !strings.Contains(line, "|") {
+		return nil
+	}
+	return aligns
+}
+
+// splitRow 按未转义的|拆分表格行
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
@@ -0,0 +1,127 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsafeOutput 渲染结果中不应出现的内容：脚本、事件处理器、样式和危险协议的地址
+var unsafeOutput = regexp.MustCompile(`(?i)<script|<iframe|<img|<svg|<style|<[a-z]+(\s+[a-z-]+="[^"]*")*\s+(on[a-z]+|style|src)\s*=|(href|src)="\s*(javascript|vbscript|data):`)
+
+func assertSafe(t *testing.T, out string) {
+	t.Helper()
+	if m := unsafeOutput.FindString(out); m != "" {
+		t.Fatalf("unsafe output %q in %q", m, out)
+	}
+}
+
+func TestRenderSanitize(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		notWant []string
+	}{
+		// 危险协议的链接只保留文字
+		{"javascript link", `[x](javascript:alert(1))`, []string{"<p>x</p>"}, []string{"<a"}},
+		{"vbscript link", `[x](vbscript:msgbox(1))`, []string{"<p>x</p>"}, []string{"<a"}},
+		{"data link", `[x](data:text/html;base64,PHNjcmlwdD4=)`, []string{"<p>x</p>"}, []string{"<a"}},
+		{"mixed case scheme", `[x](JaVaScRiPt:alert(1))`, []string{"<p>x</p>"}, []string{"<a"}},
+		{"decimal entity", `[x](&#106;avascript:alert(1))`, nil, []string{"<a"}},
+		{"hex entity", `[x](&#x6A;&#x61;vascript:alert(1))`, nil, []string{"<a"}},
+		{"named entity colon", `[x](javascript&colon;alert(1))`, nil, []string{"<a"}},
+		{"entity tab in scheme", `[x](java&#x09;script:alert(1))`, nil, []string{"<a"}},
+		{"leading control chars", "[x](\x01 javascript:alert(1))", nil, []string{"<a"}},
+		{"angle bracket destination", `[x](<javascript:alert(1)>)`, nil, []string{"<a"}},
+		{"image with javascript", `![x](javascript:alert(1))`, nil, []string{"<a", "<img"}},
+		{"relative link", `[x](/admin)`, []string{"<p>x</p>"}, []string{"<a"}},
+		{"autolink javascript", `<javascript:alert(1)>`, nil, []string{"<a"}},
+		{"autolink vbscript", `<VBScript:msgbox(1)>`, nil, []string{"<a"}},
+		{"safe link", `[x](https://example.com/a?b=1&c=2 "t")`, []string{`<a href="https://example.com/a?b=1&amp;c=2" title="t" target="_blank" rel="noopener noreferrer nofollow">x</a>`}, nil},
+		{"title injection", `[x](https://example.com 'a" onmouseover="alert(1)')`, []string{`title="a&#34; onmouseover=&#34;alert(1)"`}, nil},
+
+		// 原始HTML只保留白名单中的标签且去掉属性
+		{"script tag", `<script>alert(1)</script>`, []string{"&lt;script&gt;alert(1)&lt;/script&gt;"}, nil},
+		{"img onerror", `<img src=x onerror=alert(1)>`, []string{"&lt;img"}, nil},
+		{"allowed tag attributes", `<b onclick="alert(1)" style="x">bold</b>`, []string{"<b>bold</b>"}, []string{"onclick"}},
+		{"quoted gt in attribute", `<b title="a>b" onclick=alert(1)>x</b>`, []string{"<b>x</b>"}, nil},
+		{"uppercase tag", `<STRONG onclick=alert(1)>x</STRONG>`, []string{"<strong>x</strong>"}, nil},
+		{"unclosed tag", `<em>never closed`, []string{"<em>never closed</em>"}, nil},
+		{"stray closing tag", `text</div></b>`, []string{"<p>text&lt;/div&gt;</p>"}, nil},
+		{"svg onload", `<svg onload=alert(1)>`, []string{"&lt;svg"}, nil},
+		{"html comment", `<!-- <script>alert(1)</script> -->`, []string{"&lt;!--"}, nil},
+		{"html entity decoded then escaped", `&lt;script&gt;`, []string{"&lt;script&gt;"}, nil},
+
+		// 代码块的语言标记只保留常见字符
+		{"fence info quote", "```js\" onmouseover=\"alert(1)\n1\n```", []string{`<pre><code class="language-js">1`}, []string{"onmouseover"}},
+		{"fence info brackets", "~~~<script>x\n1\n~~~", []string{`<code class="language-scriptx">`}, nil},
+		{"fence info only symbols", "```\"><\n1\n```", []string{"<pre><code>1"}, []string{"class"}},
+		{"code content escaped", "```\n<script>alert(1)</script>\n```", []string{"&lt;script&gt;"}, nil},
+		{"code span escaped", "`<b onclick=x>`", []string{"<code>&lt;b onclick=x&gt;</code>"}, nil},
+
+		// 自动链接和嵌套强调
+		{"autolink", `<https://example.com/a_b>`, []string{`<a href="https://example.com/a_b" target="_blank" rel="noopener noreferrer nofollow">https://example.com/a_b</a>`}, []string{"<em>"}},
+		{"email autolink", `<a@example.com>`, []string{`href="mailto:a@example.com"`}, nil},
+		{"bare url", `见https://example.com/x。`, []string{`<a href="https://example.com/x"`, "</a>。"}, nil},
+		{"www url", `visit www.example.com.`, []string{`href="http://www.example.com"`}, nil},
+		{"url with quote", `https://example.com/"onmouseover="alert(1)`, []string{`href="https://example.com/"`}, nil},
+		{"nested emphasis", `***both*** and **bold *em* bold**`, []string{"<em><strong>both</strong></em>", "<strong>bold <em>em</em> bold</strong>"}, nil},
+		{"emphasis in link", `[**a** _b_](https://example.com)`, []string{`<strong>a</strong> <em>b</em></a>`}, nil},
+		{"no link inside link", `[a [b](https://b.example) c](https://a.example)`, nil, []string{`<a href="https://b.example"`}},
+		{"intraword underscore", `snake_case_name`, []string{"snake_case_name"}, []string{"<em>"}},
+		{"strikethrough", `~~gone~~`, []string{"<del>gone</del>"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := Render(tt.src)
+			assertSafe(t, out)
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("Render(%q) = %q, want %q", tt.src, out, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out, notWant) {
+					t.Errorf("Render(%q) = %q, must not contain %q", tt.src, out, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderDeepNesting(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"blockquotes", strings.Repeat(">", 100000) + " x"},
+		{"lists", strings.Repeat("- ", 50000) + "x"},
+		{"indented lists", func() string {
+			var b strings.Builder
+			for i := 0; i < 2000; i++ {
+				b.WriteString(strings.Repeat("  ", i) + "- x\n")
+			}
+			return b.String()
+		}()},
+		{"brackets", strings.Repeat("[", 100000) + "x" + strings.Repeat("](https://e.example)", 1000)},
+		{"unclosed links", strings.Repeat("[a](", 50000)},
+		{"emphasis", strings.Repeat("*a ", 50000) + strings.Repeat("b* ", 50000)},
+		{"nested emphasis", strings.Repeat("**_", 20000) + "x" + strings.Repeat("_**", 20000)},
+		{"underscores", strings.Repeat("_", 200000)},
+		{"backticks", strings.Repeat("`a``", 50000)},
+		{"tags", strings.Repeat("<b><i>", 50000)},
+		{"strikethrough", strings.Repeat("~~a ", 50000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			out := Render(tt.src)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("Render took %v", elapsed)
+			}
+			assertSafe(t, out)
+		})
+	}
+}
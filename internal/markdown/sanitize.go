@@ -0,0 +1,133 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// allowedTags 回复中允许保留的原始HTML标签，其余标签按文字显示
+//
+// 保留的标签一律去掉属性后重新生成，因此不可能带入事件处理器、样式或脚本地址。
+var allowedTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "s": true, "del": true, "ins": true,
+	"sub": true, "sup": true, "kbd": true, "mark": true, "small": true, "br": true,
+}
+
+// voidTags 没有结束标签的元素
+var voidTags = map[string]bool{"br": true}
+
+// allowedSchemes 链接允许的协议
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// parseTag 解析text开头的HTML开始或结束标签，返回小写的标签名和标签长度
+func parseTag(text string) (name string, closing bool, n int, ok bool) {
+	if len(text) < 3 || text[0] != '<' {
+		return "", false, 0, false
+	}
+	i := 1
+	if text[i] == '/' {
+		closing = true
+		i++
+	}
+	start := i
+	for i < len(text) && (isAlnum(text[i])) {
+		i++
+	}
+	if i == start || !(text[start] >= 'a' && text[start] <= 'z' || text[start] >= 'A' && text[start] <= 'Z') {
+		return "", false, 0, false
+	}
+	name = strings.ToLower(text[start:i])
+
+	// 属性部分只需要找到标签结尾，内容会被丢弃；引号中的>不算结尾
+	var quote byte
+	for ; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if closing {
+				return "", false, 0, false
+			}
+			quote = c
+		case c == '>':
+			return name, closing, i + 1, true
+		case c == '<' || c == '\n' && closing:
+			return "", false, 0, false
+		case i == start+len(name) && c != ' ' && c != '\t' && c != '\n' && c != '/':
+			// 标签名后必须是空白、/或>
+			return "", false, 0, false
+		}
+	}
+	return "", false, 0, false
+}
+
+// writeTag 输出不带属性的标签，维护已打开的标签栈
+//
+// 结束标签只有在对应的开始标签仍然打开时才输出，中间未关闭的标签一并关闭；
+// 没有对应开始标签的结束标签直接丢弃。
+func writeTag(b *strings.Builder, open []string, name string, closing bool) []string {
+	if voidTags[name] {
+		if !closing {
+			b.WriteString("<" + name + ">")
+		}
+		return open
+	}
+	if !closing {
+		b.WriteString("<" + name + ">")
+		return append(open, name)
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i] != name {
+			continue
+		}
+		for j := len(open) - 1; j >= i; j-- {
+			b.WriteString("</" + open[j] + ">")
+		}
+		return open[:i]
+	}
+	return open
+}
+
+// safeURL 只允许http、https和mailto的绝对地址
+//
+// 浏览器解析地址时会忽略其中的制表符、换行和首尾的控制字符，
+// 判断协议前同样去掉这些字符，避免java\tscript:之类的写法绕过检查。
+func safeURL(raw string) (string, bool) {
+	var b strings.Builder
+	for _, c := range strings.TrimSpace(raw) {
+		if c < 0x20 || c == 0x7f {
+			continue
+		}
+		b.WriteRune(c)
+	}
+	url := b.String()
+
+	colon := strings.IndexByte(url, ':')
+	if colon <= 0 || !isScheme(url[:colon]) {
+		return "", false
+	}
+	if !allowedSchemes[strings.ToLower(url[:colon])] {
+		return "", false
+	}
+	return strings.ReplaceAll(url, " ", "%20"), true
+}
+
+// isScheme 判断是否为合法的URL协议名
+func isScheme(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			continue
+		}
+		if i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '.' || c == '-') {
+			continue
+		}
+		return false
+	}
+	return true
+}
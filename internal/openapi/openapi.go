@@ -0,0 +1,356 @@
+// Package openapi 生成HTTP接口的OpenAPI 3文档，并按文档校验请求
+//
+// 各个包用Routes()描述自己注册的接口，请求体和响应的Schema由SchemaOf根据Go类型生成，
+// 与实际编解码的结构保持一致。文档在/api/openapi.json提供，页面/api-docs.html据此
+// 展示可交互的接口文档。
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/jsonschema"
+)
+
+const (
+	// Version 接口文档的版本，接口出现不兼容的修改时递增主版本号
+	Version = "1.0.0"
+	// SpecPath 文档的访问路径
+	SpecPath = "/api/openapi.json"
+	// defaultMaxBodySize 校验请求体时读取的默认上限
+	defaultMaxBodySize = 1 << 20
+)
+
+// Route 一个接口，Method和Path与http.HandleFunc注册的一致
+type Route struct {
+	Method string
+	Path   string
+	Operation
+}
+
+// Operation OpenAPI中的操作
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// Security 为nil时使用文档默认的认证方式，Public接口输出空数组
+	Security *[]map[string][]string `json:"security,omitempty"`
+	// Permission 调用需要的权限，以扩展字段x-permission输出
+	Permission string `json:"x-permission,omitempty"`
+
+	// Public 不需要认证即可调用
+	Public bool `json:"-"`
+	// MaxBodySize 校验时读取请求体的上限，0表示使用默认值
+	MaxBodySize int64 `json:"-"`
+}
+
+// Parameter 查询参数
+type Parameter struct {
+	Name        string             `json:"name"`
+	In          string             `json:"in"`
+	Description string             `json:"description,omitempty"`
+	Required    bool               `json:"required,omitempty"`
+	Schema      *jsonschema.Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType 请求体或响应的内容，Schema为*jsonschema.Schema或Ref返回的引用
+type MediaType struct {
+	Schema interface{} `json:"schema,omitempty"`
+}
+
+// Response 响应
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header 响应头
+type Header struct {
+	Description string             `json:"description,omitempty"`
+	Schema      *jsonschema.Schema `json:"schema"`
+}
+
+// Document OpenAPI 3文档
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Tags       []Tag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security,omitempty"`
+}
+
+// Info 文档信息
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Tag 接口分组
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components 可复用的定义
+type Components struct {
+	Schemas         map[string]*jsonschema.Schema `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme     `json:"securitySchemes"`
+}
+
+// SecurityScheme 认证方式
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// tags 接口分组的说明，按此顺序展示
+var tags = []Tag{
+	{Name: "chat", Description: "对话和WebSocket通道"},
+	{Name: "sessions", Description: "历史会话的查询、导出和导入"},
+	{Name: "tasks", Description: "浏览器自动化任务"},
+	{Name: "auth", Description: "登录和当前用户"},
+	{Name: "admin", Description: "权限策略、审计和用量"},
+	{Name: "gateway", Description: "OpenAI兼容的LLM网关"},
+	{Name: "meta", Description: "接口文档"},
+}
+
+// Spec 接口文档及按文档校验请求的中间件
+type Spec struct {
+	cookieName string
+
+	mu     sync.RWMutex
+	routes map[string]map[string]*Operation // 路径 -> 请求方法 -> 操作
+	data   []byte
+}
+
+// New 创建接口文档，cookieName为登录会话Cookie的名称
+func New(cookieName string) *Spec {
+	s := &Spec{
+		cookieName: cookieName,
+		routes:     make(map[string]map[string]*Operation),
+	}
+	s.Add(Route{
+		Method: http.MethodGet,
+		Path:   SpecPath,
+		Operation: Operation{
+			Tags:        []string{"meta"},
+			Summary:     "OpenAPI文档",
+			Description: "返回本文档，不需要认证。",
+			OperationID: "getOpenAPI",
+			Public:      true,
+			Responses:   map[string]*Response{"200": {Description: "OpenAPI 3文档", Content: jsonContent(&jsonschema.Schema{Type: jsonschema.TypeList{"object"}})}},
+		},
+	})
+	return s
+}
+
+// Add 添加接口
+func (s *Spec) Add(routes ...Route) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, route := range routes {
+		op := route.Operation
+		if s.routes[route.Path] == nil {
+			s.routes[route.Path] = make(map[string]*Operation)
+		}
+		s.routes[route.Path][route.Method] = &op
+	}
+	s.data = nil
+}
+
+// RegisterHandlers 注册文档的HTTP处理程序
+func (s *Spec) RegisterHandlers() {
+	http.HandleFunc(SpecPath, s.handleSpec)
+}
+
+// Document 生成OpenAPI文档
+func (s *Spec) Document() *Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title: "GoBrowserAgent API",
+			Description: "GoBrowserAgent的HTTP接口。出错时统一返回{\"error\": {...}}结构，见components.schemas.Error；" +
+				"/v1/*网关接口使用OpenAI兼容的错误格式。请求带?lang=en或Accept-Language: en时错误提示为英文。",
+			Version: Version,
+		},
+		Tags:  tags,
+		Paths: make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: map[string]*jsonschema.Schema{
+				"Error":       errorSchema(),
+				"OpenAIError": openAIErrorSchema(),
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", Description: "配置文件auth.tokens中的API令牌"},
+				"cookieAuth": {Type: "apiKey", In: "cookie", Name: s.cookieName, Description: "网页登录后的会话Cookie"},
+			},
+		},
+		Security: []map[string][]string{{"bearerAuth": {}}, {"cookieAuth": {}}},
+	}
+
+	for path, methods := range s.routes {
+		item := make(map[string]*Operation, len(methods))
+		for method, op := range methods {
+			out := *op
+			out.Responses = withErrorResponses(path, op)
+			if op.Public {
+				out.Security = &[]map[string][]string{}
+			}
+			item[strings.ToLower(method)] = &out
+		}
+		doc.Paths[path] = item
+	}
+	return doc
+}
+
+// handleSpec 返回OpenAPI文档
+func (s *Spec) handleSpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+
+	s.mu.RLock()
+	data := s.data
+	s.mu.RUnlock()
+	if data == nil {
+		var err error
+		data, err = json.MarshalIndent(s.Document(), "", "  ")
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("生成OpenAPI文档失败: %v", err))
+			return
+		}
+		s.mu.Lock()
+		s.data = data
+		s.mu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// lookup 查找请求对应的操作
+func (s *Spec) lookup(r *http.Request) *Operation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.routes[r.URL.Path][r.Method]
+}
+
+// withErrorResponses 为操作补充通用的错误响应
+func withErrorResponses(path string, op *Operation) map[string]*Response {
+	ref := Ref("Error")
+	if strings.HasPrefix(path, "/v1/") {
+		ref = Ref("OpenAIError")
+	}
+	responses := make(map[string]*Response, len(op.Responses)+4)
+	for code, resp := range op.Responses {
+		responses[code] = resp
+	}
+	add := func(code, description string) {
+		if _, ok := responses[code]; !ok {
+			responses[code] = &Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: ref}}}
+		}
+	}
+	if op.Parameters != nil || op.RequestBody != nil {
+		add("400", "请求参数或请求体校验失败")
+	}
+	if !op.Public {
+		add("401", "未认证或登录已过期")
+	}
+	if op.Permission != "" {
+		add("403", "没有权限"+op.Permission)
+	}
+	if !op.Public {
+		add("429", "超出限流、并发或每日配额，Retry-After头给出建议的等待秒数")
+	}
+	add("default", "其他错误")
+	return responses
+}
+
+// Ref 引用components.schemas中的定义
+func Ref(name string) map[string]string {
+	return map[string]string{"$ref": "#/components/schemas/" + name}
+}
+
+// JSONBody 必填的JSON请求体
+func JSONBody(description string, schema *jsonschema.Schema) *RequestBody {
+	return &RequestBody{Description: description, Required: true, Content: jsonContent(schema)}
+}
+
+// JSONResponse JSON响应
+func JSONResponse(description string, schema interface{}) *Response {
+	return &Response{Description: description, Content: jsonContent(schema)}
+}
+
+// Query 查询参数
+func Query(name, description string, required bool, schema *jsonschema.Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+}
+
+func jsonContent(schema interface{}) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+// errorSchema 统一的错误响应结构，与apierror.Body一致
+func errorSchema() *jsonschema.Schema {
+	detail := SchemaOf(struct {
+		Code       string      `json:"code"`
+		Message    string      `json:"message"`
+		Details    interface{} `json:"details,omitempty"`
+		RetryAfter int         `json:"retry_after,omitempty"`
+		RequestID  string      `json:"request_id,omitempty"`
+	}{})
+	detail.Required = []string{"code", "message"}
+	detail.Properties["code"].Description = "稳定的错误码，例如permission_denied、rate_limited"
+	detail.Properties["message"].Description = "按请求语言给出的提示"
+	detail.Properties["details"].Description = "补充信息，例如校验失败的字段"
+	detail.Properties["retry_after"].Description = "建议的重试等待秒数"
+	detail.Properties["request_id"].Description = "请求ID，与X-Request-ID响应头相同"
+	return &jsonschema.Schema{
+		Type:       jsonschema.TypeList{"object"},
+		Properties: map[string]*jsonschema.Schema{"error": detail},
+		Required:   []string{"error"},
+	}
+}
+
+// openAIErrorSchema /v1/*使用的OpenAI兼容错误结构
+func openAIErrorSchema() *jsonschema.Schema {
+	detail := SchemaOf(struct {
+		Message   string      `json:"message"`
+		Type      string      `json:"type"`
+		Param     interface{} `json:"param"`
+		Code      string      `json:"code"`
+		RequestID string      `json:"request_id,omitempty"`
+	}{})
+	detail.Required = []string{"message", "type", "code"}
+	return &jsonschema.Schema{
+		Type:       jsonschema.TypeList{"object"},
+		Properties: map[string]*jsonschema.Schema{"error": detail},
+		Required:   []string{"error"},
+	}
+}
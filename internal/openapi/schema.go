@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/jsonschema"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// SchemaOf 根据Go类型生成JSON Schema，字段名和可省略的字段取自json标签
+//
+// 生成的Schema不包含required和取值范围，调用方按接口的实际要求补充。
+// 递归引用自身的类型在第二次出现时生成不限类型的Schema。
+func SchemaOf(v interface{}) *jsonschema.Schema {
+	return schemaOf(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) *jsonschema.Schema {
+	if t == nil {
+		return &jsonschema.Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &jsonschema.Schema{Type: jsonschema.TypeList{"string"}, Format: "date-time"}
+	case t == rawJSONType:
+		return &jsonschema.Schema{}
+	case t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType):
+		// 自定义编码的类型无法推断结构
+		return &jsonschema.Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return typed("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return typed("integer")
+	case reflect.Float32, reflect.Float64:
+		return typed("number")
+	case reflect.String:
+		return typed("string")
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte按base64编码为字符串
+			return typed("string")
+		}
+		s := typed("array")
+		s.Items = schemaOf(t.Elem(), visiting)
+		return s
+	case reflect.Map:
+		s := typed("object")
+		s.AdditionalProperties = &jsonschema.Additional{Allowed: true, Schema: schemaOf(t.Elem(), visiting)}
+		return s
+	case reflect.Struct:
+		if visiting[t] {
+			return typed("object")
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := typed("object")
+		s.Properties = make(map[string]*jsonschema.Schema)
+		addFields(s, t, visiting)
+		return s
+	}
+	// interface{}等任意类型
+	return &jsonschema.Schema{}
+}
+
+// addFields 添加结构体字段，匿名嵌入的结构体字段提升到外层，外层同名字段优先
+func addFields(s *jsonschema.Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = schemaOf(field.Type, visiting)
+	}
+
+	for _, et := range embedded {
+		if visiting[et] {
+			continue
+		}
+		visiting[et] = true
+		inner := &jsonschema.Schema{Properties: make(map[string]*jsonschema.Schema)}
+		addFields(inner, et, visiting)
+		delete(visiting, et)
+		for name, prop := range inner.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = prop
+			}
+		}
+	}
+}
+
+func typed(name string) *jsonschema.Schema {
+	return &jsonschema.Schema{Type: jsonschema.TypeList{name}}
+}
+
+// String 字符串Schema
+func String(description string) *jsonschema.Schema {
+	s := typed("string")
+	s.Description = description
+	return s
+}
+
+// Integer 整数Schema，min和max为nil时不限制
+func Integer(description string, min, max *float64) *jsonschema.Schema {
+	s := typed("integer")
+	s.Description = description
+	s.Minimum = min
+	s.Maximum = max
+	return s
+}
+
+// Enum 只能取给定值的字符串Schema
+func Enum(description string, values ...string) *jsonschema.Schema {
+	s := String(description)
+	for _, v := range values {
+		s.Enum = append(s.Enum, v)
+	}
+	return s
+}
+
+// Bound 返回数值的指针，用于Minimum、Maximum
+func Bound(v float64) *float64 {
+	return &v
+}
+
+// Length 返回长度的指针，用于MinLength、MinItems等
+func Length(n int) *int {
+	return &n
+}
@@ -0,0 +1,168 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/jsonschema"
+)
+
+// Middleware 按文档校验请求的查询参数和JSON请求体，未在文档中描述的请求直接放行
+//
+// 校验失败时返回validation_failed，details中逐条给出出错的位置和原因，
+// 位置形如query.limit、body.messages[0].role。校验通过后请求体原样交给后续的处理程序。
+func (s *Spec) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := s.lookup(r)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		errs := validateQuery(op, r)
+		if op.RequestBody != nil {
+			bodyErrs, err := validateBody(op, w, r)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+			errs = append(errs, bodyErrs...)
+		}
+		if len(errs) > 0 {
+			apierror.Write(w, r, apierror.New(apierror.CodeValidationFailed).WithDetails(errs))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validateQuery 校验查询参数，参数值按Schema的类型转换后再校验
+func validateQuery(op *Operation, r *http.Request) jsonschema.Errors {
+	var errs jsonschema.Errors
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		if param.In != "query" {
+			continue
+		}
+		path := "query." + param.Name
+		raw, ok := query[param.Name]
+		if !ok || len(raw) == 0 || raw[0] == "" {
+			if param.Required {
+				errs = append(errs, jsonschema.ValidationError{Path: path, Message: "缺少必填参数"})
+			}
+			continue
+		}
+
+		value, err := convertParam(param.Schema, raw[0])
+		if err != nil {
+			errs = append(errs, jsonschema.ValidationError{Path: path, Message: err.Error()})
+			continue
+		}
+		errs = append(errs, prefixErrors(param.Schema.Validate(value), path)...)
+	}
+	return errs
+}
+
+// convertParam 把查询参数的文字转换为Schema声明的类型
+func convertParam(schema *jsonschema.Schema, raw string) (interface{}, error) {
+	if schema == nil || len(schema.Type) == 0 {
+		return raw, nil
+	}
+	switch schema.Type[0] {
+	case "integer", "number":
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errors.New("应为数字")
+		}
+		return f, nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("应为true或false")
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+// validateBody 读取并校验JSON请求体，返回的error表示请求体无法读取
+func validateBody(op *Operation, w http.ResponseWriter, r *http.Request) (jsonschema.Errors, error) {
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil, nil
+	}
+
+	limit := op.MaxBodySize
+	if limit <= 0 {
+		limit = defaultMaxBodySize
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	r.Body.Close()
+	if err != nil {
+		return nil, apierror.FromDecode(err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if op.RequestBody.Required {
+			return jsonschema.Errors{{Path: "body", Message: "缺少请求体"}}, nil
+		}
+		return nil, nil
+	}
+	schema, ok := media.Schema.(*jsonschema.Schema)
+	if !ok {
+		return nil, nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return jsonschema.Errors{{Path: "body", Message: "不是有效的JSON"}}, nil
+	}
+	return prefixErrors(schema.Validate(dropNulls(value)), "body"), nil
+}
+
+// dropNulls 去掉对象中值为null的字段
+//
+// encoding/json解码时null与未提供等同，OpenAI的SDK等客户端也常对可选字段传null，
+// 校验时同样按未提供处理。
+func dropNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = dropNulls(item)
+		}
+	}
+	return value
+}
+
+// prefixErrors 把校验错误位置开头的$替换为prefix
+func prefixErrors(err error, prefix string) jsonschema.Errors {
+	var errs jsonschema.Errors
+	if !errors.As(err, &errs) {
+		if err != nil {
+			return jsonschema.Errors{{Path: prefix, Message: err.Error()}}
+		}
+		return nil
+	}
+	out := make(jsonschema.Errors, len(errs))
+	for i, e := range errs {
+		out[i] = jsonschema.ValidationError{Path: prefix + strings.TrimPrefix(e.Path, "$"), Message: e.Message}
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Limits 单个客户端的限额，0表示不限制
+type Limits struct {
+	// RequestsPerMinute 令牌桶的填充速率
+	RequestsPerMinute float64 `json:"requests_per_minute"`
+	// Burst 令牌桶容量，即允许的突发请求数
+	Burst int `json:"burst"`
+	// MaxConcurrent 同时处理的请求数上限
+	MaxConcurrent int `json:"max_concurrent"`
+	// DailyQuota 每天（按服务器本地时间）的请求数上限
+	DailyQuota int `json:"daily_quota"`
+}
+
+// Config 存储限流配置
+type Config struct {
+	// Enabled 是否启用限流
+	Enabled bool `json:"enabled"`
+	// Default 未单独配置的客户端使用的限额
+	Default Limits `json:"default"`
+	// Clients 按带认证方式前缀的调用方标识（例如token:ci）单独配置的限额，只需填写要覆盖的字段
+	Clients map[string]Limits `json:"clients"`
+	// MaxBodyBytes 请求体的最大字节数
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// TrustProxy 为true时按X-Forwarded-For识别匿名客户端的IP，仅在部署于反向代理之后时开启
+	TrustProxy bool `json:"trust_proxy"`
+}
+
+// LoadConfig 从配置文件加载限流配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		RateLimit *Config `json:"ratelimit"`
+	}{
+		RateLimit: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.RateLimit, nil
+}
+
+// GetDefaultConfig 获取默认配置
+func GetDefaultConfig() *Config {
+	return &Config{
+		Enabled: true,
+		Default: Limits{
+			RequestsPerMinute: 60,
+			Burst:             20,
+			MaxConcurrent:     4,
+		},
+		MaxBodyBytes: 10 << 20,
+	}
+}
+
+// limitsFor 返回客户端的限额
+func (c *Config) limitsFor(name string) Limits {
+	limits := c.Default
+	override, ok := c.Clients[name]
+	if !ok || name == "" {
+		return limits
+	}
+	if override.RequestsPerMinute != 0 {
+		limits.RequestsPerMinute = override.RequestsPerMinute
+	}
+	if override.Burst != 0 {
+		limits.Burst = override.Burst
+	}
+	if override.MaxConcurrent != 0 {
+		limits.MaxConcurrent = override.MaxConcurrent
+	}
+	if override.DailyQuota != 0 {
+		limits.DailyQuota = override.DailyQuota
+	}
+	return limits
+}
@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+)
+
+const (
+	// sweepInterval 清理空闲客户端的间隔
+	sweepInterval = time.Minute
+	// idleTimeout 客户端空闲多久后回收其状态
+	idleTimeout = 10 * time.Minute
+)
+
+// clientState 单个客户端的令牌桶、并发数和当日用量
+type clientState struct {
+	limits   Limits
+	tokens   float64
+	updated  time.Time
+	inflight int
+	day      string
+	used     int
+}
+
+// Decision 一次限流判断的结果，用于生成响应头
+type Decision struct {
+	Allowed bool
+	// Reason 拒绝原因，用于日志
+	Reason string
+	// Code 拒绝时返回给调用方的错误码
+	Code string
+	// MaxConcurrent 并发上限，因并发过多被拒绝时用于提示
+	MaxConcurrent int
+	// RetryAfter 建议的重试等待时间
+	RetryAfter time.Duration
+
+	// Limit 令牌桶容量，0表示未限制速率
+	Limit     int
+	Remaining int
+	// Reset 令牌桶完全恢复所需的时间
+	Reset time.Duration
+
+	// QuotaLimit 每日配额，0表示未限制
+	QuotaLimit     int
+	QuotaRemaining int
+	// QuotaReset 距离配额重置的时间
+	QuotaReset time.Duration
+}
+
+// Limiter 按客户端限制请求速率、并发数和每日请求数
+//
+// 状态只保存在内存中，服务重启后重新计数。
+type Limiter struct {
+	config *Config
+
+	mu        sync.Mutex
+	clients   map[string]*clientState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter 创建限流器
+func NewLimiter(config *Config) *Limiter {
+	if config == nil {
+		config = GetDefaultConfig()
+	}
+	return &Limiter{
+		config:  config,
+		clients: make(map[string]*clientState),
+		now:     time.Now,
+	}
+}
+
+// Acquire 为客户端申请处理一个请求，key区分客户端，name用于查找单独配置的限额
+//
+// 允许时返回的release必须在请求结束后调用，以释放并发名额。
+func (l *Limiter) Acquire(key, name string) (Decision, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	state, ok := l.clients[key]
+	if !ok {
+		limits := l.config.limitsFor(name)
+		state = &clientState{
+			limits:  limits,
+			tokens:  float64(burstOf(limits)),
+			updated: now,
+		}
+		l.clients[key] = state
+	}
+	limits := state.limits
+
+	// 按经过的时间补充令牌
+	burst := burstOf(limits)
+	if limits.RequestsPerMinute > 0 {
+		elapsed := now.Sub(state.updated).Seconds()
+		state.tokens = math.Min(float64(burst), state.tokens+elapsed*limits.RequestsPerMinute/60)
+	}
+	state.updated = now
+
+	// 跨天时重置配额
+	today := now.Format("2006-01-02")
+	if state.day != today {
+		state.day = today
+		state.used = 0
+	}
+
+	decision := Decision{Allowed: true}
+	switch {
+	case limits.DailyQuota > 0 && state.used >= limits.DailyQuota:
+		decision.Allowed = false
+		decision.Reason = "今日请求配额已用完"
+		decision.Code = apierror.CodeQuotaExceeded
+		decision.RetryAfter = untilMidnight(now)
+	case limits.MaxConcurrent > 0 && state.inflight >= limits.MaxConcurrent:
+		decision.Allowed = false
+		decision.Reason = fmt.Sprintf("同时进行的请求过多（上限%d个）", limits.MaxConcurrent)
+		decision.Code = apierror.CodeConcurrencyLimited
+		decision.MaxConcurrent = limits.MaxConcurrent
+		decision.RetryAfter = time.Second
+	case limits.RequestsPerMinute > 0 && state.tokens < 1:
+		decision.Allowed = false
+		decision.Reason = "请求过于频繁"
+		decision.Code = apierror.CodeRateLimited
+		decision.RetryAfter = time.Duration((1 - state.tokens) * 60 / limits.RequestsPerMinute * float64(time.Second))
+	}
+
+	if decision.Allowed {
+		if limits.RequestsPerMinute > 0 {
+			state.tokens--
+		}
+		state.used++
+		state.inflight++
+	}
+
+	if limits.RequestsPerMinute > 0 {
+		decision.Limit = burst
+		decision.Remaining = int(math.Max(0, math.Floor(state.tokens)))
+		decision.Reset = time.Duration((float64(burst) - state.tokens) * 60 / limits.RequestsPerMinute * float64(time.Second))
+	}
+	if limits.DailyQuota > 0 {
+		decision.QuotaLimit = limits.DailyQuota
+		decision.QuotaRemaining = limits.DailyQuota - state.used
+		if decision.QuotaRemaining < 0 {
+			decision.QuotaRemaining = 0
+		}
+		decision.QuotaReset = untilMidnight(now)
+	}
+
+	if !decision.Allowed {
+		return decision, func() {}
+	}
+
+	var once sync.Once
+	return decision, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			state.inflight--
+			l.mu.Unlock()
+		})
+	}
+}
+
+// sweep 回收长时间空闲的客户端状态，调用方需持有l.mu
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	today := now.Format("2006-01-02")
+	for key, state := range l.clients {
+		// 当天用过配额的客户端需要保留计数
+		if state.inflight == 0 && now.Sub(state.updated) > idleTimeout &&
+			(state.limits.DailyQuota == 0 || state.day != today) {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// burstOf 返回令牌桶容量，至少为1
+func burstOf(limits Limits) int {
+	if limits.Burst > 0 {
+		return limits.Burst
+	}
+	return 1
+}
+
+// untilMidnight 返回距离下一个本地零点的时间
+func untilMidnight(now time.Time) time.Duration {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
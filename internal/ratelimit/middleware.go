@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+	"GoBrowserAgent/internal/websocket"
+
+	"github.com/sirupsen/logrus"
+)
+
+// limitedPrefixes 需要限流的路径前缀，静态页面不限流
+var limitedPrefixes = []string{"/api/", "/v1/"}
+
+// Middleware 对API请求限流并限制请求体大小，需要放在认证中间件之后以便按用户识别客户端
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limited(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if l.config.MaxBodyBytes > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, l.config.MaxBodyBytes)
+		}
+		if !l.config.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decision, release := l.AcquireRequest(r)
+		// WebSocket连接会保持很久，只计入速率和配额，连接上的每条消息再单独申请
+		if websocket.IsUpgrade(r) {
+			release()
+		}
+		defer release()
+
+		writeHeaders(w.Header(), decision)
+		if !decision.Allowed {
+			key, _ := l.clientOf(r)
+			logrus.Warnf("限流: %s %s %s: %s", key, r.Method, r.URL.Path, decision.Reason)
+			apierror.Write(w, r, Error(decision))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AcquireRequest 按请求的调用方申请处理一个请求，未启用限流时总是允许
+//
+// 允许时返回的release必须在处理结束后调用。
+func (l *Limiter) AcquireRequest(r *http.Request) (Decision, func()) {
+	if !l.config.Enabled {
+		return Decision{Allowed: true}, func() {}
+	}
+	key, name := l.clientOf(r)
+	return l.Acquire(key, name)
+}
+
+// Error 把拒绝的判断结果转换为接口错误
+func Error(d Decision) *apierror.Error {
+	retryAfter := int(math.Ceil(d.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	e := apierror.New(d.Code).With("limit", strconv.Itoa(d.MaxConcurrent))
+	e.RetryAfter = retryAfter
+	return e
+}
+
+// clientOf 返回客户端标识和用于查找单独限额的名称，已认证的请求按身份识别，否则按IP识别
+func (l *Limiter) clientOf(r *http.Request) (string, string) {
+	if identity, ok := auth.FromContext(r.Context()); ok && identity != nil {
+		return identity.Subject, identity.Subject
+	}
+	return "ip:" + l.clientIP(r), ""
+}
+
+// clientIP 返回请求来源IP
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.config.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeHeaders 写入X-RateLimit-*和X-Quota-*响应头
+func writeHeaders(header http.Header, d Decision) {
+	if d.Limit > 0 {
+		header.Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	}
+	if d.QuotaLimit > 0 {
+		header.Set("X-Quota-Limit", strconv.Itoa(d.QuotaLimit))
+		header.Set("X-Quota-Remaining", strconv.Itoa(d.QuotaRemaining))
+		header.Set("X-Quota-Reset", strconv.Itoa(ceilSeconds(d.QuotaReset)))
+	}
+}
+
+// limited 判断路径是否需要限流
+func limited(path string) bool {
+	for _, prefix := range limitedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,138 @@
+package rbac
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AuditEvent 审计日志中的一条记录
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	// Subject 带认证方式前缀的调用方标识，未启用认证时为空
+	Subject string `json:"subject,omitempty"`
+	// Method 认证方式
+	Method string `json:"method,omitempty"`
+	// Action 请求的权限或执行的管理操作
+	Action string `json:"action"`
+	// Resource 访问的接口、站点等
+	Resource string `json:"resource,omitempty"`
+	Allowed  bool   `json:"allowed"`
+	Reason   string `json:"reason,omitempty"`
+	Remote   string `json:"remote,omitempty"`
+}
+
+// AuditLog 追加写入的审计日志
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenAuditLog 打开审计日志，path为空时只输出到日志
+func OpenAuditLog(path string) (*AuditLog, error) {
+	log := &AuditLog{path: path}
+	if path == "" {
+		return log, nil
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("创建审计日志目录失败: %v", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %v", err)
+	}
+	log.file = file
+	return log, nil
+}
+
+// Record 写入一条审计记录
+func (l *AuditLog) Record(event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Allowed {
+		logrus.Infof("审计: %s执行%s %s", event.Subject, event.Action, event.Resource)
+	} else {
+		logrus.Warnf("审计: 拒绝%s执行%s %s: %s", event.Subject, event.Action, event.Resource, event.Reason)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("编码审计记录失败: %v", err)
+		return
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		logrus.Errorf("写入审计日志失败: %v", err)
+	}
+}
+
+// Recent 返回最近的limit条记录，按时间倒序
+func (l *AuditLog) Recent(limit int) ([]AuditEvent, error) {
+	if l.path == "" || limit <= 0 {
+		return []AuditEvent{}, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return []AuditEvent{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %v", err)
+	}
+	defer file.Close()
+
+	// 只保留最后limit条，避免日志很大时占用过多内存
+	ring := make([]AuditEvent, 0, limit)
+	start := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event AuditEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			continue
+		}
+		if len(ring) < limit {
+			ring = append(ring, event)
+			continue
+		}
+		ring[start] = event
+		start = (start + 1) % limit
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %v", err)
+	}
+
+	events := make([]AuditEvent, 0, len(ring))
+	for i := len(ring) - 1; i >= 0; i-- {
+		events = append(events, ring[(start+i)%len(ring)])
+	}
+	return events, nil
+}
+
+// Close 关闭审计日志
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,46 @@
+package rbac
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config 存储权限控制配置
+type Config struct {
+	// PolicyFile 权限策略文件路径，为空时使用内置的默认策略
+	PolicyFile string `json:"policy_file"`
+	// AuditLog 审计日志路径（JSONL），为空时只输出到日志
+	AuditLog string `json:"audit_log"`
+}
+
+// LoadConfig 从配置文件加载权限控制配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		RBAC *Config `json:"rbac"`
+	}{
+		RBAC: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.RBAC, nil
+}
+
+// GetDefaultConfig 获取默认配置
+func GetDefaultConfig() *Config {
+	return &Config{
+		AuditLog: filepath.Join("data", "audit.jsonl"),
+	}
+}
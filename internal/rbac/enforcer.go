@@ -0,0 +1,268 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeniedError 权限不足
+type DeniedError struct {
+	Subject  string
+	Action   string
+	Resource string
+	Reason   string
+	// Code 返回给调用方的错误码
+	Code string
+	// Host 被拒绝访问的站点，仅site_not_allowed时有
+	Host string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("无权执行%s: %s", e.Action, e.Reason)
+}
+
+// APIError 转换为接口错误
+func (e *DeniedError) APIError() *apierror.Error {
+	err := apierror.New(e.Code).With("permission", e.Action)
+	if e.Host != "" {
+		err.With("host", e.Host)
+	}
+	return err
+}
+
+// Enforcer 按权限策略校验调用方的权限，并审计被拒绝的请求
+//
+// Enforcer为nil时放行所有请求，便于在没有配置权限控制的场景（例如命令行）中复用同一套代码。
+type Enforcer struct {
+	mu          sync.RWMutex
+	policy      *Policy
+	policyFile  string
+	audit       *AuditLog
+	authEnabled bool
+}
+
+// NewEnforcer 根据配置加载权限策略并打开审计日志
+//
+// authEnabled为false时所有调用方都按策略中的anonymous_role授权；
+// 为true时没有身份的调用方不拥有任何权限。
+func NewEnforcer(config *Config, authEnabled bool) (*Enforcer, error) {
+	if config == nil {
+		config = GetDefaultConfig()
+	}
+
+	policy := DefaultPolicy()
+	if config.PolicyFile != "" {
+		loaded, err := LoadPolicy(config.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		policy = loaded
+		logrus.Infof("已加载权限策略: %s, 共%d个角色", config.PolicyFile, len(policy.Roles))
+	}
+
+	audit, err := OpenAuditLog(config.AuditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Enforcer{
+		policy:      policy,
+		policyFile:  config.PolicyFile,
+		audit:       audit,
+		authEnabled: authEnabled,
+	}, nil
+}
+
+// Check 校验context中的调用方是否拥有权限，resource仅用于审计
+func (e *Enforcer) Check(ctx context.Context, perm Permission, resource string) error {
+	return e.check(ctx, perm, resource, "")
+}
+
+// CheckSite 校验调用方能否运行访问目标站点的浏览器任务，target为URL或主机名
+func (e *Enforcer) CheckSite(ctx context.Context, target string) error {
+	if err := e.Check(ctx, PermTaskRun, target); err != nil {
+		return err
+	}
+	if e == nil {
+		return nil
+	}
+
+	identity, _ := auth.FromContext(ctx)
+	host := hostOf(target)
+	e.mu.RLock()
+	allowed := e.policy.allowsSite(e.rolesLocked(ctx), host)
+	e.mu.RUnlock()
+	if allowed {
+		return nil
+	}
+	err := e.deny(identity, string(PermTaskRun), target, fmt.Sprintf("不允许访问站点%s", host), "")
+	err.Code = apierror.CodeSiteNotAllowed
+	err.Host = host
+	return err
+}
+
+// Allowed 判断调用方是否拥有权限，不记录审计，用于决定数据的可见范围
+func (e *Enforcer) Allowed(ctx context.Context, perm Permission) bool {
+	if e == nil {
+		return true
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policy.allows(e.rolesLocked(ctx), perm)
+}
+
+// Require 包装HTTP处理程序，没有权限时返回403
+func (e *Enforcer) Require(perm Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := e.check(r.Context(), perm, r.URL.Path, r.RemoteAddr); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Permissions 返回调用方拥有的全部权限
+func (e *Enforcer) Permissions(ctx context.Context) []Permission {
+	if e == nil {
+		return Permissions
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policy.permissionsFor(e.rolesLocked(ctx))
+}
+
+// Roles 返回调用方的角色
+func (e *Enforcer) Roles(ctx context.Context) []string {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rolesLocked(ctx)
+}
+
+// Policy 返回当前策略的副本
+func (e *Enforcer) Policy() *Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	data, _ := json.Marshal(e.policy)
+	var policy Policy
+	json.Unmarshal(data, &policy)
+	return &policy
+}
+
+// UpdatePolicy 校验并替换当前策略，配置了策略文件时同时写回文件
+func (e *Enforcer) UpdatePolicy(ctx context.Context, policy *Policy) error {
+	if err := policy.Validate(); err != nil {
+		return apierror.Wrap(apierror.CodeInvalidPolicy, err).WithDetails(err.Error())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.policyFile != "" {
+		data, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			return fmt.Errorf("编码权限策略失败: %v", err)
+		}
+		tmpPath := e.policyFile + ".tmp"
+		if err := os.WriteFile(tmpPath, append(data, '\n'), 0600); err != nil {
+			return fmt.Errorf("写入权限策略失败: %v", err)
+		}
+		if err := os.Rename(tmpPath, e.policyFile); err != nil {
+			return fmt.Errorf("写入权限策略失败: %v", err)
+		}
+	}
+	e.policy = policy
+
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		identity = &auth.Identity{}
+	}
+	e.audit.Record(AuditEvent{
+		Subject:  identity.Subject,
+		Method:   identity.Method,
+		Action:   "policy.update",
+		Resource: e.policyFile,
+		Allowed:  true,
+	})
+	return nil
+}
+
+// Audit 返回审计日志
+func (e *Enforcer) Audit() *AuditLog {
+	return e.audit
+}
+
+// Close 关闭审计日志
+func (e *Enforcer) Close() error {
+	if e == nil {
+		return nil
+	}
+	return e.audit.Close()
+}
+
+// check 校验权限，remote为请求来源地址，仅用于审计
+func (e *Enforcer) check(ctx context.Context, perm Permission, resource, remote string) error {
+	if e == nil {
+		return nil
+	}
+
+	identity, _ := auth.FromContext(ctx)
+	e.mu.RLock()
+	roles := e.rolesLocked(ctx)
+	allowed := e.policy.allows(roles, perm)
+	e.mu.RUnlock()
+	if allowed {
+		return nil
+	}
+
+	reason, code := "角色没有该权限", apierror.CodePermissionDenied
+	switch {
+	case identity == nil && e.authEnabled:
+		reason, code = "未登录", apierror.CodeUnauthenticated
+	case len(roles) == 0:
+		reason, code = "没有绑定任何角色", apierror.CodeNoRole
+	}
+	err := e.deny(identity, string(perm), resource, reason, remote)
+	err.Code = code
+	return err
+}
+
+// rolesLocked 返回调用方的角色，调用方需持有e.mu
+func (e *Enforcer) rolesLocked(ctx context.Context) []string {
+	identity, ok := auth.FromContext(ctx)
+	if !ok || identity == nil {
+		if e.authEnabled {
+			return nil
+		}
+		return e.policy.rolesFor("", true)
+	}
+	return e.policy.rolesFor(identity.Subject, false)
+}
+
+// deny 记录审计并返回权限错误
+func (e *Enforcer) deny(identity *auth.Identity, action, resource, reason, remote string) *DeniedError {
+	if identity == nil {
+		identity = &auth.Identity{}
+	}
+	e.audit.Record(AuditEvent{
+		Subject:  identity.Subject,
+		Method:   identity.Method,
+		Action:   action,
+		Resource: resource,
+		Allowed:  false,
+		Reason:   reason,
+		Remote:   remote,
+	})
+	return &DeniedError{Subject: identity.Subject, Action: action, Resource: resource, Reason: reason}
+}
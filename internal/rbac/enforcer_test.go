@@ -0,0 +1,155 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+)
+
+func newTestEnforcer(t *testing.T, authEnabled bool) *Enforcer {
+	t.Helper()
+	e, err := NewEnforcer(&Config{AuditLog: filepath.Join(t.TempDir(), "audit.jsonl")}, authEnabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+
+	policy := DefaultPolicy()
+	policy.Roles[RoleOperator] = Role{
+		Permissions: []Permission{PermChat, PermTaskRun, PermArtifactView},
+		Sites:       []string{"*.example.com"},
+	}
+	policy.Bindings["password:alice"] = []string{RoleOperator}
+	policy.Bindings["password:root"] = []string{RoleAdmin}
+	e.policy = policy
+	return e
+}
+
+func withSubject(method, id string) context.Context {
+	identity := auth.NewIdentity(method, id, id)
+	return auth.WithIdentity(context.Background(), &identity)
+}
+
+func TestEnforcerDenyAudit(t *testing.T) {
+	e := newTestEnforcer(t, true)
+	noRole := newTestEnforcer(t, true)
+	noRole.policy.DefaultRole = ""
+
+	tests := []struct {
+		name     string
+		enforcer *Enforcer
+		ctx      context.Context
+		perm     Permission
+		code     string
+		subject  string
+	}{
+		{"unauthenticated", e, context.Background(), PermChat, apierror.CodeUnauthenticated, ""},
+		{"missing permission", e, withSubject(auth.MethodPassword, "alice"), PermSecretManage, apierror.CodePermissionDenied, "password:alice"},
+		{"default role", e, withSubject(auth.MethodToken, "alice"), PermChat, apierror.CodePermissionDenied, "token:alice"},
+		{"no role", noRole, withSubject(auth.MethodOIDC, "42"), PermArtifactView, apierror.CodeNoRole, "oidc:42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.enforcer.Check(tt.ctx, tt.perm, "/api/test")
+			var denied *DeniedError
+			if !errors.As(err, &denied) {
+				t.Fatalf("Check = %v, want DeniedError", err)
+			}
+			if denied.Code != tt.code {
+				t.Errorf("Code = %s, want %s", denied.Code, tt.code)
+			}
+			if got := denied.APIError().Params["permission"]; got != string(tt.perm) {
+				t.Errorf("permission param = %q, want %s", got, tt.perm)
+			}
+
+			events, err := tt.enforcer.Audit().Recent(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d audit events, want 1", len(events))
+			}
+			event := events[0]
+			if event.Allowed || event.Subject != tt.subject || event.Action != string(tt.perm) || event.Resource != "/api/test" || event.Reason == "" {
+				t.Errorf("audit event = %+v", event)
+			}
+		})
+	}
+}
+
+func TestEnforcerAllowedNotAudited(t *testing.T) {
+	e := newTestEnforcer(t, true)
+	ctx := withSubject(auth.MethodPassword, "alice")
+	if err := e.Check(ctx, PermChat, "/api/chat"); err != nil {
+		t.Fatalf("Check chat: %v", err)
+	}
+	if e.Allowed(ctx, PermConfigEdit) {
+		t.Error("operator allowed config.edit")
+	}
+	events, err := e.Audit().Recent(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("got audit events %+v, want none", events)
+	}
+}
+
+func TestEnforcerCheckSite(t *testing.T) {
+	e := newTestEnforcer(t, true)
+	alice := withSubject(auth.MethodPassword, "alice")
+
+	if err := e.CheckSite(alice, "https://wiki.example.com/page"); err != nil {
+		t.Fatalf("CheckSite allowed host: %v", err)
+	}
+	if err := e.CheckSite(withSubject(auth.MethodPassword, "root"), "https://www.baidu.com"); err != nil {
+		t.Fatalf("CheckSite admin: %v", err)
+	}
+
+	err := e.CheckSite(alice, "https://www.baidu.com/s?wd=go")
+	var denied *DeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("CheckSite = %v, want DeniedError", err)
+	}
+	if denied.Code != apierror.CodeSiteNotAllowed || denied.Host != "www.baidu.com" {
+		t.Errorf("denied = %+v", denied)
+	}
+	if got := denied.APIError().Params["host"]; got != "www.baidu.com" {
+		t.Errorf("host param = %q", got)
+	}
+	events, _ := e.Audit().Recent(1)
+	if len(events) != 1 || events[0].Allowed || events[0].Action != string(PermTaskRun) || events[0].Resource != "https://www.baidu.com/s?wd=go" {
+		t.Errorf("audit events = %+v", events)
+	}
+
+	// 没有task.run权限时按权限不足处理，不检查站点
+	err = e.CheckSite(withSubject(auth.MethodToken, "ci"), "https://wiki.example.com")
+	if !errors.As(err, &denied) || denied.Code != apierror.CodePermissionDenied {
+		t.Errorf("CheckSite without task.run = %v", err)
+	}
+}
+
+func TestEnforcerAnonymous(t *testing.T) {
+	e := newTestEnforcer(t, false)
+	ctx := context.Background()
+	for _, perm := range Permissions {
+		if err := e.Check(ctx, perm, ""); err != nil {
+			t.Errorf("anonymous Check(%s) = %v", perm, err)
+		}
+	}
+	if err := e.CheckSite(ctx, "https://www.baidu.com"); err != nil {
+		t.Errorf("anonymous CheckSite = %v", err)
+	}
+
+	var nilEnforcer *Enforcer
+	if err := nilEnforcer.CheckSite(ctx, "https://www.baidu.com"); err != nil {
+		t.Errorf("nil Enforcer CheckSite = %v", err)
+	}
+	if !nilEnforcer.Allowed(ctx, PermSecretManage) {
+		t.Error("nil Enforcer denied secret.manage")
+	}
+}
@@ -0,0 +1,96 @@
+package rbac
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"GoBrowserAgent/internal/apierror"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxPolicySize 权限策略请求体的最大字节数
+const maxPolicySize = 1 << 20
+
+// defaultAuditLimit 默认返回的审计记录数
+const defaultAuditLimit = 100
+
+// PermissionsResponse 当前调用方的角色和权限
+type PermissionsResponse struct {
+	Roles       []string     `json:"roles"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// RegisterHandlers 注册权限相关的HTTP处理程序
+func (e *Enforcer) RegisterHandlers() {
+	http.HandleFunc("/api/permissions", e.handlePermissions)
+	http.HandleFunc("/api/admin/policy", e.Require(PermConfigEdit, e.handlePolicy))
+	http.HandleFunc("/api/admin/audit", e.Require(PermAuditView, e.handleAudit))
+}
+
+// handlePermissions 返回当前调用方的角色和权限，页面据此隐藏无权使用的功能
+func (e *Enforcer) handlePermissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+	writeJSON(w, PermissionsResponse{
+		Roles:       e.Roles(r.Context()),
+		Permissions: e.Permissions(r.Context()),
+	})
+}
+
+// handlePolicy GET返回当前权限策略，PUT替换权限策略
+func (e *Enforcer) handlePolicy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, e.Policy())
+	case http.MethodPut:
+		var policy Policy
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPolicySize)).Decode(&policy); err != nil {
+			apierror.Write(w, r, apierror.FromDecode(err))
+			return
+		}
+		if err := e.UpdatePolicy(r.Context(), &policy); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		writeJSON(w, e.Policy())
+	default:
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet, http.MethodPut))
+	}
+}
+
+// handleAudit 返回最近的审计记录
+func (e *Enforcer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+
+	limit := defaultAuditLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			apierror.Write(w, r, apierror.New(apierror.CodeInvalidParameter).With("name", "limit").WithDetails(map[string]int{"min": 1, "max": 1000}))
+			return
+		}
+		limit = n
+	}
+
+	events, err := e.audit.Recent(limit)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	writeJSON(w, events)
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Errorf("编码响应失败: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package rbac
+
+import (
+	"net/http"
+
+	"GoBrowserAgent/internal/openapi"
+)
+
+// Routes 返回RegisterHandlers注册的接口，用于生成OpenAPI文档和校验请求
+func (e *Enforcer) Routes() []openapi.Route {
+	permission := openapi.String("权限，*表示全部权限")
+	permission.Enum = []interface{}{string(PermAll)}
+	for _, perm := range Permissions {
+		permission.Enum = append(permission.Enum, string(perm))
+	}
+
+	policy := openapi.SchemaOf(Policy{})
+	policy.Required = []string{"roles"}
+	role := policy.Properties["roles"].AdditionalProperties.Schema
+	role.Properties["permissions"].Items = permission
+	role.Properties["sites"].Description = "允许浏览器任务访问的主机，支持*.example.com匹配子域名，*匹配所有站点"
+	policy.Properties["anonymous_role"].Description = "未启用认证时调用方的角色"
+	policy.Properties["default_role"].Description = "已认证但没有绑定角色的用户的角色，为空表示没有任何权限"
+	policy.Properties["bindings"].Description = "带认证方式前缀的调用方标识（password:用户名、token:令牌名称或oidc:<sub>）到角色列表的映射"
+
+	permissions := openapi.SchemaOf(PermissionsResponse{})
+	permissions.Properties["permissions"].Items = permission
+
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/api/permissions", Operation: openapi.Operation{
+			Tags:        []string{"auth"},
+			Summary:     "当前调用方的权限",
+			Description: "页面据此隐藏无权使用的功能。",
+			OperationID: "permissions",
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("角色和权限", permissions),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/admin/policy", Operation: openapi.Operation{
+			Tags:        []string{"admin"},
+			Summary:     "获取权限策略",
+			OperationID: "getPolicy",
+			Permission:  string(PermConfigEdit),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("当前的权限策略", policy),
+			},
+		}},
+		{Method: http.MethodPut, Path: "/api/admin/policy", Operation: openapi.Operation{
+			Tags:        []string{"admin"},
+			Summary:     "替换权限策略",
+			Description: "校验通过后立即生效，并写入配置的策略文件。",
+			OperationID: "updatePolicy",
+			Permission:  string(PermConfigEdit),
+			MaxBodySize: maxPolicySize,
+			RequestBody: openapi.JSONBody("新的权限策略", policy),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("替换后的权限策略", policy),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/admin/audit", Operation: openapi.Operation{
+			Tags:        []string{"admin"},
+			Summary:     "审计记录",
+			Description: "按时间倒序返回最近的权限检查和管理操作。",
+			OperationID: "audit",
+			Permission:  string(PermAuditView),
+			Parameters: []openapi.Parameter{
+				openapi.Query("limit", "最多返回的记录数，默认100", false, openapi.Integer("", openapi.Bound(1), openapi.Bound(1000))),
+			},
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("审计记录", openapi.SchemaOf([]AuditEvent{})),
+			},
+		}},
+	}
+}
@@ -0,0 +1,262 @@
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"GoBrowserAgent/internal/auth"
+)
+
+// Permission 一项可授权的能力
+type Permission string
+
+// 内置权限
+const (
+	// PermChat 与LLM对话、导入和删除自己的对话
+	PermChat Permission = "chat"
+	// PermTaskRun 运行浏览器任务，还需要目标站点在角色的sites中
+	PermTaskRun Permission = "task.run"
+	// PermArtifactView 查看自己的对话记录、导出文件、截图等产物
+	PermArtifactView Permission = "artifact.view"
+	// PermArtifactAdmin 查看、导出和删除所有调用方的对话
+	PermArtifactAdmin Permission = "artifact.admin"
+	// PermConfigEdit 修改配置和权限策略
+	PermConfigEdit Permission = "config.edit"
+	// PermSecretManage 读取和修改凭据保险库中的站点配置和登录状态
+	PermSecretManage Permission = "secret.manage"
+	// PermAuditView 查看审计日志
+	PermAuditView Permission = "audit.view"
+	// PermAll 匹配所有权限
+	PermAll Permission = "*"
+)
+
+// Permissions 所有内置权限，用于校验策略文件
+var Permissions = []Permission{
+	PermChat, PermTaskRun, PermArtifactView, PermArtifactAdmin, PermConfigEdit, PermSecretManage, PermAuditView,
+}
+
+// 内置角色
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Role 角色拥有的权限和可访问的站点
+type Role struct {
+	Permissions []Permission `json:"permissions"`
+	// Sites 允许浏览器任务访问的主机，支持"*.example.com"匹配子域名，"*"匹配所有站点
+	Sites []string `json:"sites,omitempty"`
+}
+
+// Policy 权限策略
+type Policy struct {
+	// AnonymousRole 未启用认证时调用方的角色
+	AnonymousRole string `json:"anonymous_role"`
+	// DefaultRole 已认证但没有绑定角色的用户的角色，为空表示没有任何权限
+	DefaultRole string `json:"default_role"`
+	// Roles 角色定义
+	Roles map[string]Role `json:"roles"`
+	// Bindings 带认证方式前缀的调用方标识 -> 角色列表，例如password:alice、token:ci、oidc:<sub>
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// DefaultPolicy 内置的默认策略
+//
+// 未启用认证时保持原有行为，调用方拥有全部权限；启用认证后未绑定角色的用户只能查看产物。
+func DefaultPolicy() *Policy {
+	return &Policy{
+		AnonymousRole: RoleAdmin,
+		DefaultRole:   RoleViewer,
+		Roles: map[string]Role{
+			RoleViewer: {
+				Permissions: []Permission{PermArtifactView},
+			},
+			RoleOperator: {
+				Permissions: []Permission{PermChat, PermTaskRun, PermArtifactView},
+				Sites:       []string{"*"},
+			},
+			RoleAdmin: {
+				Permissions: []Permission{PermAll},
+				Sites:       []string{"*"},
+			},
+		},
+		Bindings: map[string][]string{},
+	}
+}
+
+// LoadPolicy 从文件加载权限策略
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取权限策略失败: %v", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy 解析并校验权限策略
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("解析权限策略失败: %v", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate 检查策略中引用的角色和权限是否存在
+func (p *Policy) Validate() error {
+	if len(p.Roles) == 0 {
+		return fmt.Errorf("权限策略中没有定义角色")
+	}
+
+	known := make(map[Permission]bool, len(Permissions)+1)
+	known[PermAll] = true
+	for _, perm := range Permissions {
+		known[perm] = true
+	}
+	for name, role := range p.Roles {
+		for _, perm := range role.Permissions {
+			if !known[perm] {
+				return fmt.Errorf("角色%s中的权限%q不存在", name, perm)
+			}
+		}
+		for _, pattern := range role.Sites {
+			if !validSite(pattern) {
+				return fmt.Errorf("角色%s中的站点%q无效，请写成主机名、*.example.com或*", name, pattern)
+			}
+		}
+	}
+
+	for _, name := range []string{p.AnonymousRole, p.DefaultRole} {
+		if _, ok := p.Roles[name]; name != "" && !ok {
+			return fmt.Errorf("角色%s不存在", name)
+		}
+	}
+	for subject, roles := range p.Bindings {
+		if !validSubject(subject) {
+			return fmt.Errorf("绑定的调用方%q缺少认证方式前缀，请写成password:用户名、token:令牌名称或oidc:<sub>", subject)
+		}
+		for _, name := range roles {
+			if _, ok := p.Roles[name]; !ok {
+				return fmt.Errorf("%s绑定的角色%s不存在", subject, name)
+			}
+		}
+	}
+	return nil
+}
+
+// rolesFor 返回调用方的角色，anonymous表示未启用认证
+func (p *Policy) rolesFor(subject string, anonymous bool) []string {
+	if anonymous {
+		if p.AnonymousRole == "" {
+			return nil
+		}
+		return []string{p.AnonymousRole}
+	}
+	if roles, ok := p.Bindings[subject]; ok {
+		return roles
+	}
+	if p.DefaultRole == "" {
+		return nil
+	}
+	return []string{p.DefaultRole}
+}
+
+// validSubject 判断调用方标识是否带有认证方式前缀
+func validSubject(subject string) bool {
+	method, id, ok := strings.Cut(subject, ":")
+	if !ok || id == "" {
+		return false
+	}
+	switch method {
+	case auth.MethodPassword, auth.MethodToken, auth.MethodOIDC:
+		return true
+	}
+	return false
+}
+
+// allows 判断角色列表是否拥有权限
+func (p *Policy) allows(roles []string, perm Permission) bool {
+	for _, name := range roles {
+		for _, granted := range p.Roles[name].Permissions {
+			if granted == PermAll || granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsSite 判断角色列表是否可以访问主机
+func (p *Policy) allowsSite(roles []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, name := range roles {
+		for _, pattern := range p.Roles[name].Sites {
+			if matchSite(strings.ToLower(pattern), host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// permissionsFor 返回角色列表拥有的全部权限，用于页面按权限显示功能
+func (p *Policy) permissionsFor(roles []string) []Permission {
+	set := make(map[Permission]bool)
+	for _, name := range roles {
+		for _, perm := range p.Roles[name].Permissions {
+			if perm == PermAll {
+				for _, all := range Permissions {
+					set[all] = true
+				}
+				continue
+			}
+			set[perm] = true
+		}
+	}
+	perms := make([]Permission, 0, len(set))
+	for perm := range set {
+		perms = append(perms, perm)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+// validSite 判断站点模式是否是主机名、*.主机名或*
+func validSite(pattern string) bool {
+	host := strings.TrimPrefix(pattern, "*.")
+	if pattern == "*" {
+		return true
+	}
+	return host != "" && !strings.ContainsAny(host, "*/:?#@ ")
+}
+
+// matchSite 判断主机是否匹配站点模式
+func matchSite(pattern, host string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:]) || host == pattern[2:]
+	default:
+		return pattern == host
+	}
+}
+
+// hostOf 从URL或主机名中取出主机部分
+func hostOf(target string) string {
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	if u, err := url.Parse("//" + target); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return strings.ToLower(target)
+}
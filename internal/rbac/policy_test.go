@@ -0,0 +1,129 @@
+package rbac
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		err    string
+	}{
+		{"default", `{"anonymous_role":"admin","default_role":"viewer","roles":{"admin":{"permissions":["*"],"sites":["*"]},"viewer":{"permissions":["artifact.view"]}}}`, ""},
+		{"all permissions", `{"roles":{"r":{"permissions":["chat","task.run","artifact.view","artifact.admin","config.edit","secret.manage","audit.view"]}}}`, ""},
+		{"no roles", `{"roles":{}}`, "没有定义角色"},
+		{"unknown permission", `{"roles":{"r":{"permissions":["task.delete"]}}}`, `"task.delete"不存在`},
+		{"unknown anonymous role", `{"anonymous_role":"root","roles":{"r":{"permissions":[]}}}`, "角色root不存在"},
+		{"unknown default role", `{"default_role":"root","roles":{"r":{"permissions":[]}}}`, "角色root不存在"},
+		{"binding without method", `{"roles":{"r":{"permissions":[]}},"bindings":{"alice":["r"]}}`, "缺少认证方式前缀"},
+		{"binding with unknown method", `{"roles":{"r":{"permissions":[]}},"bindings":{"ldap:alice":["r"]}}`, "缺少认证方式前缀"},
+		{"binding without id", `{"roles":{"r":{"permissions":[]}},"bindings":{"token:":["r"]}}`, "缺少认证方式前缀"},
+		{"binding to unknown role", `{"roles":{"r":{"permissions":[]}},"bindings":{"oidc:123":["root"]}}`, "oidc:123绑定的角色root不存在"},
+		{"sites", `{"roles":{"r":{"permissions":["task.run"],"sites":["example.com","*.example.org","*"]}}}`, ""},
+		{"site with scheme", `{"roles":{"r":{"permissions":["task.run"],"sites":["https://example.com"]}}}`, "站点"},
+		{"site with inner wildcard", `{"roles":{"r":{"permissions":["task.run"],"sites":["a.*.com"]}}}`, "站点"},
+		{"empty site", `{"roles":{"r":{"permissions":["task.run"],"sites":[""]}}}`, "站点"},
+		{"malformed", `{"roles":[]}`, "解析权限策略失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.policy))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("ParsePolicy: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("ParsePolicy error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPolicyAllows(t *testing.T) {
+	policy := &Policy{Roles: map[string]Role{
+		"viewer":   {Permissions: []Permission{PermArtifactView}},
+		"operator": {Permissions: []Permission{PermChat, PermTaskRun}},
+		"admin":    {Permissions: []Permission{PermAll}},
+	}}
+	tests := []struct {
+		roles []string
+		perm  Permission
+		want  bool
+	}{
+		{[]string{"viewer"}, PermArtifactView, true},
+		{[]string{"viewer"}, PermChat, false},
+		{[]string{"viewer", "operator"}, PermTaskRun, true},
+		{[]string{"operator"}, PermSecretManage, false},
+		{[]string{"admin"}, PermSecretManage, true},
+		{[]string{"admin"}, PermAuditView, true},
+		{[]string{"missing"}, PermChat, false},
+		{nil, PermArtifactView, false},
+	}
+	for _, tt := range tests {
+		if got := policy.allows(tt.roles, tt.perm); got != tt.want {
+			t.Errorf("allows(%v, %s) = %v, want %v", tt.roles, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyAllowsSite(t *testing.T) {
+	policy := &Policy{Roles: map[string]Role{
+		"intranet": {Sites: []string{"*.Example.com", "www.baidu.com"}},
+		"all":      {Sites: []string{"*"}},
+		"none":     {},
+	}}
+	tests := []struct {
+		roles  []string
+		target string
+		want   bool
+	}{
+		{[]string{"intranet"}, "https://example.com/login", true},
+		{[]string{"intranet"}, "https://wiki.EXAMPLE.com:8443/a", true},
+		{[]string{"intranet"}, "https://a.b.example.com", true},
+		{[]string{"intranet"}, "https://badexample.com", false},
+		{[]string{"intranet"}, "https://example.com.evil.org", false},
+		{[]string{"intranet"}, "www.baidu.com", true},
+		{[]string{"intranet"}, "https://baidu.com", false},
+		{[]string{"intranet"}, "http://user@www.baidu.com/", true},
+		{[]string{"none", "all"}, "http://127.0.0.1:8080", true},
+		{[]string{"none"}, "https://example.com", false},
+		{nil, "https://example.com", false},
+	}
+	for _, tt := range tests {
+		if got := policy.allowsSite(tt.roles, hostOf(tt.target)); got != tt.want {
+			t.Errorf("allowsSite(%v, %s) = %v, want %v", tt.roles, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyRolesFor(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.Bindings["password:alice"] = []string{RoleOperator}
+
+	tests := []struct {
+		subject   string
+		anonymous bool
+		want      string
+	}{
+		{"", true, RoleAdmin},
+		{"password:alice", false, RoleOperator},
+		// 同名但认证方式不同的用户不共享绑定
+		{"token:alice", false, RoleViewer},
+		{"oidc:unknown", false, RoleViewer},
+	}
+	for _, tt := range tests {
+		roles := policy.rolesFor(tt.subject, tt.anonymous)
+		if len(roles) != 1 || roles[0] != tt.want {
+			t.Errorf("rolesFor(%q, %v) = %v, want [%s]", tt.subject, tt.anonymous, roles, tt.want)
+		}
+	}
+
+	policy.DefaultRole = ""
+	if roles := policy.rolesFor("token:ci", false); len(roles) != 0 {
+		t.Errorf("rolesFor without default role = %v, want none", roles)
+	}
+}
@@ -0,0 +1,260 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/pkg/parser"
+)
+
+// pageQueryTimeout 补全时查询页面元素的最长时间，避免页面卡住时Tab没有响应
+const pageQueryTimeout = time.Second
+
+// selectorPrefixes 选择器的前缀，语法见pkg/browser/selector.go
+var selectorPrefixes = []string{"css:", "xpath:", "text:", "role:", "label:", "placeholder:"}
+
+// newCompleter 返回按命令定义补全的函数：
+// 第一个词补全命令名，之后补全还没有使用的参数名，选择器参数的值补全选择器的前缀和当前页面的元素，
+// wait命令补全for、until等关键字，login命令的第一个参数补全站点名称，state命令补全操作和名称。
+// 浏览器还没有启动时不查询页面。
+func newCompleter(executor *command.Executor) completer {
+	return func(line []rune) (int, []string) {
+		start := wordStart(line)
+		word := string(line[start:])
+		words := strings.Fields(string(line[:start]))
+
+		if len(words) == 0 {
+			return start, filterPrefix(executor.Registry.Names(), word)
+		}
+		spec, ok := executor.Registry.Lookup(words[0])
+		if !ok {
+			return start, nil
+		}
+		if spec.Name == "help" {
+			if len(words) > 1 {
+				return start, nil
+			}
+			return start, filterPrefix(executor.Registry.Names(), word)
+		}
+
+		if spec.Name == "wait" && !strings.Contains(word, "=") {
+			if candidates := waitWords(executor, words[1:]); candidates != nil {
+				return start, filterPrefix(candidates, word)
+			}
+		}
+
+		if spec.Name == "login" && len(words) == 1 && !strings.Contains(word, "=") {
+			return start, filterPrefix(profileNames(executor), word)
+		}
+		if spec.Name == "state" && !strings.Contains(word, "=") {
+			switch len(words) {
+			case 1:
+				return start, filterPrefix([]string{"save", "load"}, word)
+			case 2:
+				return start, filterPrefix(stateNames(executor), word)
+			}
+		}
+
+		// 正在输入参数值
+		if i := strings.IndexByte(word, '='); i > 0 {
+			key, value := word[:i], word[i+1:]
+			arg := findArg(spec, key)
+			if arg == nil || arg.Type != parser.Selector {
+				return start, nil
+			}
+			valueStart := start + len([]rune(key)) + 1
+			return valueStart, filterPrefix(append(pageSelectors(executor), selectorPrefixes...), value)
+		}
+
+		used := make(map[string]bool, len(words))
+		for _, w := range words[1:] {
+			if i := strings.IndexByte(w, '='); i > 0 {
+				used[w[:i]] = true
+			}
+		}
+		var keys []string
+		for _, arg := range spec.Args {
+			switch {
+			case arg.Prefix:
+				keys = append(keys, arg.Name)
+				if spec.Name == "form" {
+					for _, name := range pageFieldNames(executor) {
+						if !used[arg.Name+name] {
+							keys = append(keys, arg.Name+name+"=")
+						}
+					}
+				}
+			case arg.Positional || used[arg.Name]:
+			default:
+				keys = append(keys, arg.Name+"=")
+			}
+		}
+		return start, filterPrefix(keys, word)
+	}
+}
+
+// waitWords 按wait命令已经输入的条件补全下一个词，没有可补全的关键字时返回nil
+func waitWords(executor *command.Executor, args []string) []string {
+	switch {
+	case len(args) == 0:
+		return []string{"for", "until"}
+	case args[0] != "for":
+		return nil
+	case len(args) == 1:
+		return append([]string{"url", "text", "network"}, append(pageSelectors(executor), selectorPrefixes...)...)
+	case len(args) == 2 && args[1] == "url":
+		return []string{"~"}
+	case len(args) == 2 && args[1] == "network":
+		return []string{"idle"}
+	case len(args) == 2 && args[1] != "text":
+		return []string{"visible", "hidden", "attached", "timeout="}
+	}
+	return nil
+}
+
+// profileNames 返回凭据保险库中的站点名称，保险库打不开时不补全
+func profileNames(executor *command.Executor) []string {
+	if executor.Vault == nil {
+		return nil
+	}
+	profiles, err := executor.Vault.Profiles()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// stateNames 返回保存的状态和站点配置的名称，state save可以保存到站点名称下供login复用
+func stateNames(executor *command.Executor) []string {
+	names := profileNames(executor)
+	if executor.Vault == nil {
+		return names
+	}
+	states, err := executor.Vault.States()
+	if err != nil {
+		return names
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, s := range states {
+		if !seen[s.Name] {
+			names = append(names, s.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// wordStart 返回光标所在词的起始位置，引号中的空白不分隔词
+func wordStart(line []rune) int {
+	start := 0
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ' ' || r == '\t':
+			start = i + 1
+		}
+	}
+	return start
+}
+
+func findArg(spec *parser.Spec, key string) *parser.Arg {
+	for i := range spec.Args {
+		arg := &spec.Args[i]
+		if arg.Name == key || arg.Prefix && strings.HasPrefix(key, arg.Name) {
+			return arg
+		}
+	}
+	return nil
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var out []string
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) && !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// pageSelectors 返回当前页面上带id或name属性的元素对应的选择器
+func pageSelectors(executor *command.Executor) []string {
+	page := executor.CurrentPage()
+	if page == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pageQueryTimeout)
+	defer cancel()
+
+	var selectors []string
+	if elements, err := page.Query(ctx, "[id]"); err == nil {
+		for _, e := range elements {
+			if id := e.Attributes["id"]; id != "" && isFieldName(id) && (id[0] < '0' || id[0] > '9') {
+				selectors = append(selectors, "#"+id)
+			}
+		}
+	}
+	if elements, err := page.Query(ctx, "[name]"); err == nil {
+		for _, e := range elements {
+			if name := e.Attributes["name"]; name != "" && isFieldName(name) {
+				selectors = append(selectors, fmt.Sprintf("[name=%s]", name))
+			}
+		}
+	}
+	return selectors
+}
+
+// pageFieldNames 返回当前页面上表单控件的name属性
+func pageFieldNames(executor *command.Executor) []string {
+	page := executor.CurrentPage()
+	if page == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pageQueryTimeout)
+	defer cancel()
+
+	elements, err := page.Query(ctx, "input[name], select[name], textarea[name]")
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range elements {
+		if e.Attributes["type"] == "hidden" || e.Attributes["type"] == "submit" {
+			continue
+		}
+		if name := e.Attributes["name"]; name != "" && isFieldName(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isFieldName 判断name能否直接写在field_后面作为参数名，或者不加引号写进选择器
+func isFieldName(name string) bool {
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,340 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted 输入时按下了Ctrl+C
+var errInterrupted = errors.New("已取消输入")
+
+// completer 根据光标前的文字返回补全候选，start是被替换部分的起始位置（按字符计算）
+type completer func(line []rune) (start int, candidates []string)
+
+// editor 终端行编辑器，支持光标移动、历史导航和Tab补全
+//
+// 终端不支持原始模式时退化为按行读取。
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	history  *History
+	complete completer
+
+	// 当前行的编辑状态
+	prompt    string
+	buf       []rune
+	pos       int
+	cursorRow int
+	lastTab   bool
+}
+
+func newEditor(in io.Reader, out io.Writer, fd int, history *History, complete completer) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, fd: fd, history: history, complete: complete}
+}
+
+// ReadLine 显示提示符并读取一行；Ctrl+C返回errInterrupted，空行上的Ctrl+D返回io.EOF
+func (e *editor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readCooked(prompt)
+	}
+	defer restore()
+
+	e.prompt, e.buf, e.pos, e.cursorRow, e.lastTab = prompt, nil, 0, 0, false
+	entries := e.history.Entries()
+	historyIndex := len(entries)
+	draft := ""
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		tab := false
+		switch r {
+		case '\r', '\n':
+			e.pos = len(e.buf)
+			e.refresh()
+			fmt.Fprint(e.out, "\r\n")
+			return string(e.buf), nil
+		case 3: // Ctrl+C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl+D
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case 127, 8: // Backspace
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case 1: // Ctrl+A
+			e.pos = 0
+		case 5: // Ctrl+E
+			e.pos = len(e.buf)
+		case 2: // Ctrl+B
+			e.move(-1)
+		case 6: // Ctrl+F
+			e.move(1)
+		case 11: // Ctrl+K
+			e.buf = e.buf[:e.pos]
+		case 21: // Ctrl+U
+			e.buf = append([]rune{}, e.buf[e.pos:]...)
+			e.pos = 0
+		case 23: // Ctrl+W
+			e.deleteWord()
+		case 12: // Ctrl+L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			e.cursorRow = 0
+		case 16, 14: // Ctrl+P、Ctrl+N
+			historyIndex, draft = e.navigate(entries, historyIndex, draft, r == 16)
+		case '\t':
+			tab = true
+			e.completeWord()
+		case 27:
+			switch e.readEscape() {
+			case "A":
+				historyIndex, draft = e.navigate(entries, historyIndex, draft, true)
+			case "B":
+				historyIndex, draft = e.navigate(entries, historyIndex, draft, false)
+			case "C":
+				e.move(1)
+			case "D":
+				e.move(-1)
+			case "H", "1~", "7~":
+				e.pos = 0
+			case "F", "4~", "8~":
+				e.pos = len(e.buf)
+			case "3~":
+				e.deleteAt(e.pos)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		e.lastTab = tab
+		e.refresh()
+	}
+}
+
+// readCooked 不支持原始模式时按行读取
+func (e *editor) readCooked(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readEscape 读取ESC之后的控制序列，返回[或O之后的部分，例如"A"、"3~"
+func (e *editor) readEscape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != '[' && r != 'O' {
+		return ""
+	}
+	var seq strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq.WriteRune(r)
+		if r >= 0x40 && r <= 0x7e {
+			return seq.String()
+		}
+	}
+}
+
+func (e *editor) insert(text []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(text))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, text...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(text)
+}
+
+func (e *editor) deleteAt(i int) {
+	if i < len(e.buf) {
+		e.buf = append(e.buf[:i], e.buf[i+1:]...)
+	}
+}
+
+func (e *editor) move(delta int) {
+	e.pos = max(0, min(len(e.buf), e.pos+delta))
+}
+
+// deleteWord 删除光标前的一个词
+func (e *editor) deleteWord() {
+	start := e.pos
+	for start > 0 && e.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && e.buf[start-1] != ' ' {
+		start--
+	}
+	e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+	e.pos = start
+}
+
+// navigate 在历史中向前或向后移动，离开最新位置时保存正在编辑的内容
+func (e *editor) navigate(entries []string, index int, draft string, back bool) (int, string) {
+	if back {
+		if index == 0 {
+			return index, draft
+		}
+		if index == len(entries) {
+			draft = string(e.buf)
+		}
+		index--
+	} else {
+		if index >= len(entries) {
+			return index, draft
+		}
+		index++
+	}
+	if index == len(entries) {
+		e.buf = []rune(draft)
+	} else {
+		e.buf = []rune(entries[index])
+	}
+	e.pos = len(e.buf)
+	return index, draft
+}
+
+// completeWord 补全光标前的词：唯一候选直接补全，多个候选先补全公共前缀，再按一次Tab列出所有候选
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	start, candidates := e.complete(e.buf[:e.pos])
+	if len(candidates) == 0 {
+		return
+	}
+	typed := string(e.buf[start:e.pos])
+	prefix := commonPrefix(candidates)
+	if len(candidates) == 1 && !strings.HasSuffix(prefix, "=") {
+		prefix += " "
+	}
+	if len([]rune(prefix)) > len([]rune(typed)) {
+		e.buf = append(e.buf[:start], append([]rune(prefix), e.buf[e.pos:]...)...)
+		e.pos = start + len([]rune(prefix))
+		return
+	}
+	if !e.lastTab {
+		return
+	}
+	e.listCandidates(candidates)
+}
+
+// listCandidates 在当前行下方分列显示候选
+func (e *editor) listCandidates(candidates []string) {
+	sort.Strings(candidates)
+	width := 0
+	for _, c := range candidates {
+		width = max(width, displayWidth(c))
+	}
+	width += 2
+	columns := max(1, terminalWidth(e.fd)/width)
+
+	e.pos = len(e.buf)
+	e.refresh()
+	var b strings.Builder
+	b.WriteString("\r\n")
+	for i, c := range candidates {
+		b.WriteString(c)
+		if (i+1)%columns == 0 || i == len(candidates)-1 {
+			b.WriteString("\r\n")
+		} else {
+			b.WriteString(strings.Repeat(" ", width-displayWidth(c)))
+		}
+	}
+	fmt.Fprint(e.out, b.String())
+	e.cursorRow = 0
+}
+
+// refresh 重新绘制提示符和输入内容，并把光标移到正确位置；输入超过一行时按终端宽度计算折行
+func (e *editor) refresh() {
+	columns := terminalWidth(e.fd)
+	var b strings.Builder
+	if e.cursorRow > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", e.cursorRow)
+	}
+	b.WriteString("\r\x1b[J")
+	b.WriteString(e.prompt)
+	b.WriteString(string(e.buf))
+
+	promptWidth := displayWidth(e.prompt)
+	total := promptWidth + runesWidth(e.buf)
+	if total > 0 && total%columns == 0 {
+		// 恰好写满一行时终端不会立即换行，手动换到下一行
+		b.WriteString("\r\n")
+	}
+	endRow := total / columns
+	target := promptWidth + runesWidth(e.buf[:e.pos])
+	targetRow, targetColumn := target/columns, target%columns
+	if endRow > targetRow {
+		fmt.Fprintf(&b, "\x1b[%dA", endRow-targetRow)
+	}
+	b.WriteString("\r")
+	if targetColumn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", targetColumn)
+	}
+	e.cursorRow = targetRow
+	fmt.Fprint(e.out, b.String())
+}
+
+// ansiPattern 匹配颜色等控制序列
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// displayWidth 返回文字在终端中占用的列数，忽略控制序列
+func displayWidth(s string) int {
+	return runesWidth([]rune(ansiPattern.ReplaceAllString(s, "")))
+}
+
+func runesWidth(rs []rune) int {
+	width := 0
+	for _, r := range rs {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth 中日韩文字和全角符号占两列
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115F, r >= 0x2E80 && r <= 0xA4CF, r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF, r >= 0xFE30 && r <= 0xFE4F, r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6, r >= 0x1F300 && r <= 0x1FAFF, r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
+
+// commonPrefix 返回所有候选的最长公共前缀
+func commonPrefix(candidates []string) string {
+	prefix := []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		rs := []rune(c)
+		n := 0
+		for n < len(prefix) && n < len(rs) && prefix[n] == rs[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
@@ -0,0 +1,98 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxHistory 保留的历史命令条数
+const maxHistory = 1000
+
+// secretPattern 包含密码等敏感参数的命令只保存在内存中，不写入历史文件
+var secretPattern = regexp.MustCompile(`(?i)\b(password|passwd|secret|token)[a-z_]*=`)
+
+// History 命令历史，每条命令在文件中占一行
+type History struct {
+	path    string
+	entries []string
+}
+
+// LoadHistory 读取历史文件，path为空时只在内存中保存
+func LoadHistory(path string) *History {
+	h := &History{path: path}
+	if path == "" {
+		return h
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Warnf("读取命令历史失败: %v", err)
+		}
+		return h
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		h.rewrite()
+	}
+	return h
+}
+
+// Entries 按时间顺序返回历史命令
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Add 追加一条命令，多行命令合并为一行；与上一条相同的命令不重复记录
+func (h *History) Add(line string) {
+	line = strings.TrimSpace(strings.ReplaceAll(line, "\n", " "))
+	if line == "" || len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	if h.path == "" || secretPattern.MatchString(line) {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		logrus.Warnf("保存命令历史失败: %v", err)
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logrus.Warnf("保存命令历史失败: %v", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		logrus.Warnf("保存命令历史失败: %v", err)
+	}
+}
+
+// rewrite 用内存中的历史覆盖文件，去掉超出上限的旧命令
+func (h *History) rewrite() {
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(h.entries, "\n")+"\n"), 0600); err != nil {
+		logrus.Warnf("整理命令历史失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		logrus.Warnf("整理命令历史失败: %v", err)
+	}
+}
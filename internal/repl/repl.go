@@ -0,0 +1,173 @@
+// Package repl 提供命令行交互模式：逐行读取浏览器命令并执行
+//
+// 在Linux终端中支持光标移动、持久化的命令历史（上下方向键）、Tab补全命令名、参数名和页面上的选择器，
+// 以及引号未闭合或以\结尾时的多行输入。
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/pkg/parser"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+)
+
+// 终端颜色
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+	colorDim   = "\x1b[2m"
+)
+
+// REPL 交互式命令循环
+type REPL struct {
+	executor *command.Executor
+	history  *History
+	editor   *editor
+	out      io.Writer
+	color    bool
+}
+
+// New 创建交互模式，从标准输入读取命令，historyPath为空时不保存历史
+//
+// 设置了NO_COLOR环境变量或标准输出不是终端时不使用颜色。
+func New(executor *command.Executor, historyPath string) *REPL {
+	history := LoadHistory(historyPath)
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &REPL{
+		executor: executor,
+		history:  history,
+		editor:   newEditor(os.Stdin, os.Stdout, int(os.Stdin.Fd()), history, newCompleter(executor)),
+		out:      os.Stdout,
+		color:    !noColor && IsTerminal(int(os.Stdout.Fd())),
+	}
+}
+
+// Run 循环读取并执行命令，直到输入exit或Ctrl+D，返回时关闭浏览器
+func (r *REPL) Run() error {
+	defer r.executor.Close()
+
+	fmt.Fprintln(r.out, r.paint(colorCyan, "GoBrowserAgent 交互模式"))
+	fmt.Fprintln(r.out, r.paint(colorDim, "输入 help 查看可用命令，Tab补全，上下方向键浏览历史，exit或Ctrl+D退出"))
+
+	for {
+		line, err := r.readCommand()
+		if errors.Is(err, errInterrupted) {
+			fmt.Fprintln(r.out, r.paint(colorDim, "输入 exit 或按Ctrl+D退出"))
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取输入失败: %v", err)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		r.history.Add(line)
+		if !r.execute(line) {
+			return nil
+		}
+	}
+}
+
+// readCommand 读取一条命令，引号没有闭合或行尾是\时继续读取下一行
+func (r *REPL) readCommand() (string, error) {
+	line, err := r.editor.ReadLine(r.paint(colorCyan, prompt))
+	if err != nil {
+		return "", err
+	}
+	for parser.Incomplete(line) {
+		// 行尾的\只表示续行，不属于命令内容
+		if strings.HasSuffix(line, "\\") {
+			line = strings.TrimSuffix(line, "\\")
+		}
+		next, err := r.editor.ReadLine(r.paint(colorDim, continuationPrompt))
+		if err == io.EOF {
+			return "", errInterrupted
+		}
+		if err != nil {
+			return "", err
+		}
+		line += "\n" + next
+	}
+	return line, nil
+}
+
+// execute 解析并执行一条命令，返回false表示退出
+func (r *REPL) execute(line string) bool {
+	cmd, err := r.executor.Registry.Parse(line)
+	if err != nil {
+		r.printError(err)
+		return true
+	}
+	if cmd == nil {
+		return true
+	}
+
+	switch cmd.Name {
+	case "exit":
+		return false
+	case "help":
+		help, err := r.executor.Registry.Help(cmd.String("command"))
+		if err != nil {
+			r.printError(err)
+			return true
+		}
+		fmt.Fprintln(r.out, help)
+		return true
+	}
+
+	// 执行期间按Ctrl+C只取消当前命令
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	start := time.Now()
+	result, err := r.executor.Execute(ctx, cmd)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintln(r.out, r.paint(colorRed, "命令已取消"))
+			return true
+		}
+		r.printError(err)
+		return true
+	}
+
+	fmt.Fprintln(r.out, r.paint(colorGreen, result.Message))
+	if len(result.Data) > 0 {
+		fmt.Fprintln(r.out, string(result.Data))
+	}
+	fmt.Fprintln(r.out, r.paint(colorDim, fmt.Sprintf("(%s)", elapsed)))
+	return true
+}
+
+// printError 输出错误，解析错误附带原始命令和指示出错位置的^
+func (r *REPL) printError(err error) {
+	var parseErr *parser.Error
+	if errors.As(err, &parseErr) {
+		fmt.Fprintln(r.out, r.paint(colorRed, parseErr.Pretty()))
+		return
+	}
+	fmt.Fprintln(r.out, r.paint(colorRed, fmt.Sprintf("错误: %v", err)))
+}
+
+func (r *REPL) paint(color, text string) string {
+	if !r.color {
+		return text
+	}
+	return color + text + colorReset
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+
+package repl
+
+import (
+	"errors"
+	"os"
+)
+
+// IsTerminal 判断标准输入、输出或错误输出是否连接到终端，其他文件描述符返回false
+//
+// 不能用os.NewFile包装fd：返回的*os.File被回收时会关闭这个文件描述符。
+func IsTerminal(fd int) bool {
+	var file *os.File
+	switch uintptr(fd) {
+	case os.Stdin.Fd():
+		file = os.Stdin
+	case os.Stdout.Fd():
+		file = os.Stdout
+	case os.Stderr.Fd():
+		file = os.Stderr
+	default:
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// makeRaw 其他系统不切换原始模式，按行读取，没有历史导航和补全
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("当前系统不支持终端原始模式")
+}
+
+func terminalWidth(fd int) int {
+	return 80
+}
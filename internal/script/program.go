@@ -0,0 +1,513 @@
+package script
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/pkg/parser"
+)
+
+// maxCallDepth 过程调用的最大嵌套层数，防止无限递归
+const maxCallDepth = 100
+
+// defaultRetryDelay retry块两次尝试之间的默认等待时间
+const defaultRetryDelay = time.Second
+
+// Program 编译后的脚本
+type Program struct {
+	nodes []node
+	procs map[string]*procedure
+}
+
+// node 脚本中的一条语句
+type node interface {
+	pos() Line
+}
+
+// commandNode 一条命令，执行时先替换变量再解析
+type commandNode struct {
+	at Line
+}
+
+// ifNode if/else if/else/end
+type ifNode struct {
+	branches []branch
+	elseBody []node
+}
+
+type branch struct {
+	at   Line
+	cond *condition
+	body []node
+}
+
+// forNode for each <变量> in ...，来源是列表、文件中的行或页面上的元素
+type forNode struct {
+	at       Line
+	variable string
+	source   string
+	// values 列表项，source为file时是文件路径，为elements时是选择器
+	values []string
+	attr   string
+	body   []node
+}
+
+const (
+	sourceItems    = "items"
+	sourceFile     = "file"
+	sourceElements = "elements"
+)
+
+// retryNode retry <次数>，块中任何一步失败时从头重新执行整个块
+type retryNode struct {
+	at       Line
+	attempts int
+	delay    time.Duration
+	body     []node
+}
+
+// procedure proc <名称> [参数...]定义的过程
+type procedure struct {
+	at     Line
+	name   string
+	params []string
+	body   []node
+}
+
+// callNode call <过程> [参数...]，参数可以按位置或按名称传入
+type callNode struct {
+	at     Line
+	name   string
+	column int
+	args   []parser.Token
+}
+
+func (n *commandNode) pos() Line { return n.at }
+func (n *ifNode) pos() Line      { return n.branches[0].at }
+func (n *forNode) pos() Line     { return n.at }
+func (n *retryNode) pos() Line   { return n.at }
+func (n *callNode) pos() Line    { return n.at }
+
+// condition if的条件
+//
+//	[not] exists <选择器>
+//	[not] visible <选择器>
+//	[not] text <选择器> <运算符> <值>
+//	[not] url|title <运算符> <值>
+//	[not] <值> <运算符> <值>
+type condition struct {
+	negate   bool
+	kind     string
+	selector string
+	left     string
+	op       string
+	right    string
+}
+
+// operators 条件中支持的比较运算符
+var operators = map[string]bool{
+	"==": true, "!=": true, "contains": true, "~": true, "!~": true,
+	">": true, "<": true, ">=": true, "<=": true,
+}
+
+// compiler 把按行读取的命令组织为语句块
+type compiler struct {
+	lines    []Line
+	next     int
+	registry *parser.Registry
+	procs    map[string]*procedure
+	calls    []*callNode
+	errs     []*LineError
+}
+
+// Compile 检查脚本并组织为语句块，返回所有错误
+func Compile(lines []Line, registry *parser.Registry) (*Program, []*LineError) {
+	c := &compiler{lines: lines, registry: registry, procs: make(map[string]*procedure)}
+	nodes := c.block(0)
+	for c.next < len(c.lines) {
+		// 顶层多余的end或else
+		line := c.lines[c.next]
+		c.next++
+		c.errorAt(line, 1, "%s前面没有对应的if、for、retry或proc", firstWord(line.Text))
+		nodes = append(nodes, c.block(0)...)
+	}
+	for _, call := range c.calls {
+		c.checkCall(call)
+	}
+	// 过程调用在最后检查，按在脚本中的位置重新排列错误
+	index := make(map[Line]int, len(lines))
+	for i, line := range lines {
+		index[line] = i
+	}
+	sort.SliceStable(c.errs, func(i, j int) bool { return index[c.errs[i].Line] < index[c.errs[j].Line] })
+	return &Program{nodes: nodes, procs: c.procs}, c.errs
+}
+
+func (c *compiler) errorAt(line Line, column int, format string, args ...interface{}) {
+	c.errs = append(c.errs, &LineError{Line: line, Err: &parser.Error{Line: line.Text, Column: column, Message: fmt.Sprintf(format, args...)}})
+}
+
+// block 读取语句直到遇到end或else（不消耗），depth是块的嵌套层数，顶层为0
+func (c *compiler) block(depth int) []node {
+	var nodes []node
+	for c.next < len(c.lines) {
+		line := c.lines[c.next]
+		tokens, err := parser.Tokenize(line.Text)
+		if err != nil {
+			c.next++
+			c.errs = append(c.errs, &LineError{Line: line, Err: err})
+			continue
+		}
+		keyword := ""
+		if tokens[0].Key == "" && !tokens[0].Quoted {
+			keyword = tokens[0].Value
+		}
+		if keyword == "end" || keyword == "else" {
+			return nodes
+		}
+		c.next++
+
+		switch keyword {
+		case "if":
+			if n := c.ifStatement(line, tokens, depth); n != nil {
+				nodes = append(nodes, n)
+			}
+		case "for":
+			if n := c.forStatement(line, tokens, depth); n != nil {
+				nodes = append(nodes, n)
+			}
+		case "retry":
+			if n := c.retryStatement(line, tokens, depth); n != nil {
+				nodes = append(nodes, n)
+			}
+		case "proc":
+			c.procStatement(line, tokens, depth)
+		case "call":
+			if len(tokens) < 2 || tokens[1].Key != "" {
+				c.errorAt(line, len([]rune(line.Text))+1, "缺少过程名，用法: call <过程> [参数...]")
+				continue
+			}
+			call := &callNode{at: line, name: tokens[1].Value, column: tokens[1].Column, args: tokens[2:]}
+			c.calls = append(c.calls, call)
+			nodes = append(nodes, call)
+		default:
+			c.checkCommand(line)
+			nodes = append(nodes, &commandNode{at: line})
+		}
+	}
+	return nodes
+}
+
+// end 读取块的结束行，没有时报告在块的开始行
+func (c *compiler) end(opener Line, keyword string) {
+	if c.next < len(c.lines) && firstWord(c.lines[c.next].Text) == "end" {
+		line := c.lines[c.next]
+		c.next++
+		if tokens, _ := parser.Tokenize(line.Text); len(tokens) > 1 {
+			c.errorAt(line, tokens[1].Column, "end后面不能有其他内容")
+		}
+		return
+	}
+	if c.next < len(c.lines) {
+		// 遇到了不属于该块的else
+		line := c.lines[c.next]
+		c.next++
+		c.errorAt(line, 1, "else前面没有对应的if")
+		c.block(1)
+		c.end(opener, keyword)
+		return
+	}
+	c.errorAt(opener, 1, "%s没有对应的end", keyword)
+}
+
+func (c *compiler) ifStatement(line Line, tokens []parser.Token, depth int) node {
+	n := &ifNode{}
+	cond := c.condition(line, tokens[1:])
+	body := c.block(depth + 1)
+	n.branches = append(n.branches, branch{at: line, cond: cond, body: body})
+	hasElse := false
+
+	for c.next < len(c.lines) && firstWord(c.lines[c.next].Text) == "else" {
+		elseLine := c.lines[c.next]
+		c.next++
+		elseTokens, _ := parser.Tokenize(elseLine.Text)
+		if len(elseTokens) > 1 && elseTokens[1].Value == "if" && elseTokens[1].Key == "" {
+			cond := c.condition(elseLine, elseTokens[2:])
+			n.branches = append(n.branches, branch{at: elseLine, cond: cond, body: c.block(depth + 1)})
+			continue
+		}
+		if len(elseTokens) > 1 {
+			c.errorAt(elseLine, elseTokens[1].Column, "else后面只能是if")
+		}
+		if hasElse {
+			c.errorAt(elseLine, 1, "if只能有一个else")
+		}
+		hasElse = true
+		n.elseBody = c.block(depth + 1)
+	}
+	c.end(line, "if")
+	return n
+}
+
+// condition 解析if和else if后面的条件
+func (c *compiler) condition(line Line, tokens []parser.Token) *condition {
+	end := len([]rune(line.Text)) + 1
+	const usage = "条件应为exists <选择器>、visible <选择器>、text <选择器> <运算符> <值>、url|title <运算符> <值>或<值> <运算符> <值>"
+	cond := &condition{}
+	if len(tokens) > 0 && tokenText(tokens[0]) == "not" {
+		cond.negate = true
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		c.errorAt(line, end, "缺少条件，%s", usage)
+		return nil
+	}
+
+	var rest []parser.Token
+	switch kind := tokenText(tokens[0]); kind {
+	case "exists", "visible":
+		if len(tokens) != 2 {
+			c.errorAt(line, end, "%s后面应为一个选择器", kind)
+			return nil
+		}
+		cond.kind, cond.selector = kind, tokenText(tokens[1])
+		return cond
+	case "text":
+		if len(tokens) < 2 {
+			c.errorAt(line, end, "text后面应为选择器、运算符和值")
+			return nil
+		}
+		cond.kind, cond.selector = kind, tokenText(tokens[1])
+		rest = tokens[2:]
+	case "url", "title":
+		cond.kind = kind
+		rest = tokens[1:]
+	default:
+		cond.kind, cond.left = "value", tokenText(tokens[0])
+		rest = tokens[1:]
+	}
+
+	if len(rest) != 2 {
+		column := end
+		if len(rest) > 2 {
+			column = rest[2].Column
+		}
+		c.errorAt(line, column, "%s", usage)
+		return nil
+	}
+	cond.op, cond.right = tokenText(rest[0]), tokenText(rest[1])
+	if !operators[cond.op] {
+		c.errorAt(line, rest[0].Column, "不支持的运算符%q，可用: ==、!=、contains、~、!~、>、<、>=、<=", cond.op)
+		return nil
+	}
+	if cond.op == "~" || cond.op == "!~" {
+		if refs, err := references(cond.right); err == nil && len(refs) == 0 {
+			if _, err := regexp.Compile(cond.right); err != nil {
+				c.errorAt(line, rest[1].ValueColumn, "无效的正则表达式: %v", err)
+				return nil
+			}
+		}
+	}
+	return cond
+}
+
+func (c *compiler) forStatement(line Line, tokens []parser.Token, depth int) node {
+	const usage = "用法: for each <变量> in <项...> | file <路径> | elements <选择器> [attr=<属性>]"
+	end := len([]rune(line.Text)) + 1
+	args := tokens[1:]
+	if len(args) > 0 && tokenText(args[0]) == "each" {
+		args = args[1:]
+	}
+	n := &forNode{at: line, source: sourceItems}
+	ok := true
+	switch {
+	case len(args) < 3 || tokenText(args[1]) != "in":
+		c.errorAt(line, end, "%s", usage)
+		ok = false
+	case !isVariableName(tokenText(args[0])):
+		c.errorAt(line, args[0].Column, "无效的变量名%q", tokenText(args[0]))
+		ok = false
+	default:
+		n.variable = tokenText(args[0])
+		items := args[2:]
+		switch tokenText(items[0]) {
+		case sourceFile:
+			if len(items) != 2 {
+				c.errorAt(line, end, "%s", usage)
+				ok = false
+				break
+			}
+			n.source, n.values = sourceFile, []string{tokenText(items[1])}
+		case sourceElements:
+			if len(items) < 2 || len(items) > 3 || len(items) == 3 && items[2].Key != "attr" {
+				c.errorAt(line, end, "%s", usage)
+				ok = false
+				break
+			}
+			n.source, n.values = sourceElements, []string{tokenText(items[1])}
+			if len(items) == 3 {
+				n.attr = items[2].Value
+			}
+		default:
+			for _, t := range items {
+				n.values = append(n.values, tokenText(t))
+			}
+		}
+	}
+	n.body = c.block(depth + 1)
+	c.end(line, "for")
+	if !ok {
+		return nil
+	}
+	return n
+}
+
+func (c *compiler) retryStatement(line Line, tokens []parser.Token, depth int) node {
+	n := &retryNode{at: line, delay: defaultRetryDelay}
+	ok := true
+	if len(tokens) < 2 || tokens[1].Key != "" {
+		c.errorAt(line, len([]rune(line.Text))+1, "缺少重试次数，用法: retry <次数> [delay=<时长>]")
+		ok = false
+	} else if attempts, err := strconv.Atoi(tokens[1].Value); err != nil || attempts < 1 {
+		c.errorAt(line, tokens[1].Column, "重试次数应为正整数")
+		ok = false
+	} else {
+		n.attempts = attempts
+	}
+	for _, t := range tokens[min(2, len(tokens)):] {
+		if t.Key != "delay" {
+			c.errorAt(line, t.Column, "retry只支持delay参数，用法: retry <次数> [delay=<时长>]")
+			ok = false
+			continue
+		}
+		delay, err := parser.ParseDuration(t.Value)
+		if err != nil {
+			c.errorAt(line, t.ValueColumn, "参数delay: %v", err)
+			ok = false
+			continue
+		}
+		n.delay = delay
+	}
+	n.body = c.block(depth + 1)
+	c.end(line, "retry")
+	if !ok {
+		return nil
+	}
+	return n
+}
+
+func (c *compiler) procStatement(line Line, tokens []parser.Token, depth int) {
+	p := &procedure{at: line}
+	ok := true
+	switch {
+	case depth > 0:
+		c.errorAt(line, 1, "proc只能在脚本顶层定义")
+		ok = false
+	case len(tokens) < 2 || !isVariableName(tokenText(tokens[1])):
+		c.errorAt(line, len([]rune(line.Text))+1, "缺少过程名，用法: proc <名称> [参数...]")
+		ok = false
+	default:
+		p.name = tokens[1].Value
+		if prev, exists := c.procs[p.name]; exists {
+			c.errorAt(line, tokens[1].Column, "过程%s已经在%s定义", p.name, prev.at)
+			ok = false
+		}
+		seen := make(map[string]bool)
+		for _, t := range tokens[2:] {
+			name := tokenText(t)
+			if !isVariableName(name) || seen[name] {
+				c.errorAt(line, t.Column, "无效或重复的参数名%q", name)
+				ok = false
+			}
+			seen[name] = true
+			p.params = append(p.params, name)
+		}
+	}
+	p.body = c.block(depth + 1)
+	c.end(line, "proc")
+	if ok {
+		c.procs[p.name] = p
+	}
+}
+
+// checkCall 检查调用的过程是否存在以及参数是否匹配
+func (c *compiler) checkCall(call *callNode) {
+	p, ok := c.procs[call.name]
+	if !ok {
+		c.errorAt(call.at, call.column, "过程%s没有定义", call.name)
+		return
+	}
+	bound := make(map[string]bool)
+	positional := 0
+	for _, t := range call.args {
+		if t.Key == "" {
+			if positional >= len(p.params) {
+				c.errorAt(call.at, t.Column, "过程%s只有%d个参数", p.name, len(p.params))
+				return
+			}
+			bound[p.params[positional]] = true
+			positional++
+			continue
+		}
+		if !contains(p.params, t.Key) {
+			c.errorAt(call.at, t.Column, "过程%s没有参数%s", p.name, t.Key)
+			return
+		}
+		if bound[t.Key] {
+			c.errorAt(call.at, t.Column, "参数%s重复", t.Key)
+			return
+		}
+		bound[t.Key] = true
+	}
+	for _, param := range p.params {
+		if !bound[param] {
+			c.errorAt(call.at, len([]rune(call.at.Text))+1, "缺少参数%s，用法: call %s %s", param, p.name, strings.Join(p.params, " "))
+			return
+		}
+	}
+}
+
+// checkCommand 检查普通命令，含变量的参数值到执行时再检查类型
+func (c *compiler) checkCommand(line Line) {
+	cmd, err := c.registry.ParseWith(line.Text, checkExpander)
+	if err != nil {
+		c.errs = append(c.errs, &LineError{Line: line, Err: err})
+		return
+	}
+	if cmd.Name == "set" || cmd.Name == "extract" {
+		if name := cmd.String("name"); cmd.Has("name") && name != "" && !isVariableName(name) {
+			c.errorAt(line, len(cmd.Name)+2, "无效的变量名%q，变量名由字母、数字和_组成", name)
+		}
+	}
+	if cmd.Name == "extract" && cmd.Has("selector") == cmd.Has("js") {
+		c.errorAt(line, len([]rune(line.Text))+1, "extract需要选择器或js参数中的一个")
+	}
+}
+
+// tokenText 返回词的文字，key=value形式的词还原为原样
+func tokenText(t parser.Token) string {
+	if t.Key != "" {
+		return t.Key + "=" + t.Value
+	}
+	return t.Value
+}
+
+func firstWord(text string) string {
+	if fields := strings.Fields(text); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
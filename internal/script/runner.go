@@ -0,0 +1,660 @@
+package script
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/pkg/browser"
+	"GoBrowserAgent/pkg/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Status 步骤的执行结果
+type Status string
+
+const (
+	StatusSucceeded Status = "成功"
+	StatusFailed    Status = "失败"
+	// StatusRetried 在retry块中失败、随后重新执行的步骤，不计为失败
+	StatusRetried Status = "重试"
+	StatusSkipped Status = "跳过"
+)
+
+// Options 脚本运行参数
+type Options struct {
+	// ContinueOnError 为true时某一步失败后继续执行后面的命令，否则跳过剩余的命令
+	ContinueOnError bool
+	// Verbose 为true时输出每一步的命令和结果
+	Verbose bool
+}
+
+// Step 一条命令的执行记录
+type Step struct {
+	Line     Line
+	Status   Status
+	Duration time.Duration
+	// Message 成功时是任务的结果说明，失败时是错误信息
+	Message string
+}
+
+// Summary 脚本运行统计
+type Summary struct {
+	Steps     []Step
+	Succeeded int
+	Failed    int
+	Retried   int
+	Skipped   int
+	Duration  time.Duration
+}
+
+var (
+	// errStepFailed 有一步失败，停止执行当前的块
+	errStepFailed = errors.New("步骤失败")
+	// errExit 执行到exit命令
+	errExit = errors.New("脚本结束")
+)
+
+// Runner 在执行器的页面上执行脚本
+type Runner struct {
+	executor *command.Executor
+	registry *parser.Registry
+	options  Options
+	out      io.Writer
+
+	// 执行状态
+	program    *Program
+	vars       *Variables
+	summary    *Summary
+	retryDepth int
+	callDepth  int
+}
+
+// NewRunner 创建脚本执行器，命令的输出写入out
+func NewRunner(executor *command.Executor, options Options, out io.Writer) *Runner {
+	registry := parser.NewDefaultRegistry()
+	for _, spec := range statementSpecs() {
+		registry.MustRegister(spec)
+	}
+	return &Runner{executor: executor, registry: registry, options: options, out: out}
+}
+
+// statementSpecs 只在脚本中使用的命令
+func statementSpecs() []*parser.Spec {
+	return []*parser.Spec{
+		{
+			Name:    "set",
+			Summary: "设置变量",
+			Args: []parser.Arg{
+				{Name: "name", Type: parser.String, Positional: true, Required: true, Help: "变量名"},
+				{Name: "value", Type: parser.String, Positional: true, Required: true, Help: "变量的值，可以引用其他变量"},
+			},
+			Examples: []string{`set keyword "golang 教程"`, "set greeting 你好${name}"},
+		},
+		{
+			Name:        "extract",
+			Summary:     "把页面上的内容保存到变量",
+			Description: "默认取第一个匹配元素的文字；all=true时把所有匹配元素保存为列表，可以在for each中遍历。",
+			Args: []parser.Arg{
+				{Name: "name", Type: parser.String, Positional: true, Required: true, Help: "变量名"},
+				{Name: "selector", Type: parser.Selector, Positional: true, Help: "元素选择器"},
+				{Name: "attr", Type: parser.String, Placeholder: "attribute", Help: "取属性而不是文字，例如href、value"},
+				{Name: "all", Type: parser.Bool, Help: "保存所有匹配的元素"},
+				{Name: "js", Type: parser.String, Placeholder: "expression", Help: "改为保存JavaScript表达式的值"},
+			},
+			Examples: []string{"extract title h1", "extract links a.result attr=href all=true", `extract count js="document.querySelectorAll('tr').length"`},
+		},
+		{
+			Name:    "print",
+			Summary: "输出一行文字",
+			Args: []parser.Arg{
+				{Name: "message", Type: parser.String, Positional: true, Required: true, Help: "要输出的文字，可以引用变量"},
+			},
+			Examples: []string{`print "客户${id}: ${status}"`},
+		},
+	}
+}
+
+// Check 编译脚本，返回所有语法错误
+func (r *Runner) Check(lines []Line) (*Program, []*LineError) {
+	return Compile(lines, r.registry)
+}
+
+// Run 按顺序执行脚本
+//
+// 执行失败不作为错误返回，记录在Summary中。ctx取消后剩余的命令记为跳过。
+func (r *Runner) Run(ctx context.Context, program *Program) *Summary {
+	r.program = program
+	r.vars = NewVariables()
+	r.summary = &Summary{}
+
+	start := time.Now()
+	if err := r.exec(ctx, program.nodes); err == errExit {
+		logrus.Info("脚本在exit处结束")
+	}
+	r.summary.Duration = time.Since(start)
+	r.summary.count()
+	return r.summary
+}
+
+// exec 执行一个语句块；返回errStepFailed时块中剩余的语句没有执行
+func (r *Runner) exec(ctx context.Context, nodes []node) error {
+	for i, n := range nodes {
+		if ctx.Err() != nil {
+			r.skip(nodes[i:])
+			return errStepFailed
+		}
+		err := r.execNode(ctx, n)
+		if err == nil {
+			continue
+		}
+		if err == errStepFailed && r.options.ContinueOnError && r.retryDepth == 0 && ctx.Err() == nil {
+			continue
+		}
+		if err == errStepFailed {
+			r.skip(nodes[i+1:])
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Runner) execNode(ctx context.Context, n node) error {
+	switch n := n.(type) {
+	case *commandNode:
+		return r.execCommand(ctx, n.at)
+	case *ifNode:
+		for _, b := range n.branches {
+			ok, err := r.evaluate(ctx, b.cond)
+			if err != nil {
+				return r.fail(b.at, time.Now(), err)
+			}
+			if ok {
+				return r.exec(ctx, b.body)
+			}
+		}
+		return r.exec(ctx, n.elseBody)
+	case *forNode:
+		return r.execFor(ctx, n)
+	case *retryNode:
+		return r.execRetry(ctx, n)
+	case *callNode:
+		return r.execCall(ctx, n)
+	}
+	return fmt.Errorf("未知的语句%T", n)
+}
+
+// execCommand 替换变量后解析并执行一条命令
+func (r *Runner) execCommand(ctx context.Context, line Line) error {
+	start := time.Now()
+	cmd, err := r.registry.ParseWith(line.Text, r.expander(ctx))
+	if err != nil {
+		return r.fail(line, start, err)
+	}
+	if r.options.Verbose {
+		fmt.Fprintf(r.out, "%s: %s\n", line, oneLine(line.Text))
+	}
+
+	var message string
+	var data json.RawMessage
+	switch cmd.Name {
+	case "exit":
+		return errExit
+	case "help":
+		help, err := r.registry.Help(cmd.String("command"))
+		if err != nil {
+			return r.fail(line, start, err)
+		}
+		fmt.Fprintln(r.out, help)
+	case "set":
+		name := cmd.String("name")
+		if !isVariableName(name) {
+			return r.fail(line, start, fmt.Errorf("无效的变量名%q", name))
+		}
+		r.vars.Set(name, cmd.String("value"))
+		message = fmt.Sprintf("已设置变量%s", name)
+	case "extract":
+		if message, err = r.extract(ctx, cmd); err != nil {
+			return r.fail(line, start, err)
+		}
+	case "print":
+		fmt.Fprintln(r.out, cmd.String("message"))
+	default:
+		result, err := r.executor.Execute(ctx, cmd)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				err = fmt.Errorf("已中断: %v", err)
+			}
+			return r.fail(line, start, err)
+		}
+		message, data = result.Message, result.Data
+	}
+
+	step := Step{Line: line, Status: StatusSucceeded, Duration: time.Since(start), Message: message}
+	r.summary.Steps = append(r.summary.Steps, step)
+	if r.options.Verbose && message != "" {
+		fmt.Fprintf(r.out, "  %s (%s)\n", message, step.Duration.Round(time.Millisecond))
+		if len(data) > 0 {
+			fmt.Fprintf(r.out, "  %s\n", data)
+		}
+	}
+	return nil
+}
+
+// fail 记录失败的步骤
+func (r *Runner) fail(line Line, start time.Time, err error) error {
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) {
+		lineErr = &LineError{Line: line, Err: err}
+	}
+	r.summary.Steps = append(r.summary.Steps, Step{Line: line, Status: StatusFailed, Duration: time.Since(start), Message: err.Error()})
+	if r.retryDepth > 0 {
+		logrus.Warn(lineErr.Error())
+	} else {
+		logrus.Error(lineErr.Error())
+	}
+	return errStepFailed
+}
+
+// skip 把没有执行的命令记为跳过，retry块中的命令还会重新执行，不记录
+func (r *Runner) skip(nodes []node) {
+	if r.retryDepth > 0 {
+		return
+	}
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ifNode:
+			for _, b := range n.branches {
+				r.skip(b.body)
+			}
+			r.skip(n.elseBody)
+		case *forNode:
+			r.skip(n.body)
+		case *retryNode:
+			r.skip(n.body)
+		default:
+			r.summary.Steps = append(r.summary.Steps, Step{Line: n.pos(), Status: StatusSkipped})
+		}
+	}
+}
+
+// extract 执行extract命令
+func (r *Runner) extract(ctx context.Context, cmd *parser.Command) (string, error) {
+	name := cmd.String("name")
+	if !isVariableName(name) {
+		return "", fmt.Errorf("无效的变量名%q", name)
+	}
+	page, err := r.executor.Page(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if cmd.Has("js") {
+		raw, err := page.Evaluate(ctx, cmd.String("js"))
+		if err != nil {
+			return "", fmt.Errorf("执行JavaScript: %v", err)
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", fmt.Errorf("解析JavaScript结果: %v", err)
+		}
+		switch v := value.(type) {
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = stringify(item)
+			}
+			r.vars.SetList(name, items)
+			return fmt.Sprintf("已保存%d项到变量%s", len(items), name), nil
+		default:
+			r.vars.Set(name, stringify(v))
+			return fmt.Sprintf("已保存变量%s", name), nil
+		}
+	}
+
+	selector := cmd.String("selector")
+	elements, err := page.Query(ctx, selector)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", selector, err)
+	}
+	values := make([]string, 0, len(elements))
+	for _, e := range elements {
+		values = append(values, elementValue(e, cmd.String("attr")))
+	}
+	if cmd.Bool("all") {
+		r.vars.SetList(name, values)
+		return fmt.Sprintf("已保存%d项到变量%s", len(values), name), nil
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("%s: 未找到匹配的元素", selector)
+	}
+	r.vars.Set(name, values[0])
+	return fmt.Sprintf("已保存变量%s", name), nil
+}
+
+// elementValue 返回元素的文字，attr不为空时返回属性值
+func elementValue(e browser.Element, attr string) string {
+	if attr != "" {
+		return e.Attributes[attr]
+	}
+	return strings.TrimSpace(e.Text)
+}
+
+// stringify 把JavaScript的值转换为文字，字符串不带引号
+func stringify(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// evaluate 计算if的条件
+func (r *Runner) evaluate(ctx context.Context, cond *condition) (bool, error) {
+	ok, err := r.test(ctx, cond)
+	if err != nil {
+		return false, err
+	}
+	return ok != cond.negate, nil
+}
+
+func (r *Runner) test(ctx context.Context, cond *condition) (bool, error) {
+	var left string
+	switch cond.kind {
+	case "exists", "visible", "text":
+		selector, err := r.expand(ctx, cond.selector)
+		if err != nil {
+			return false, err
+		}
+		page, err := r.executor.Page(ctx)
+		if err != nil {
+			return false, err
+		}
+		elements, err := page.Query(ctx, selector)
+		if err != nil {
+			return false, fmt.Errorf("%s: %v", selector, err)
+		}
+		switch cond.kind {
+		case "exists":
+			return len(elements) > 0, nil
+		case "visible":
+			for _, e := range elements {
+				if e.Visible {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		if len(elements) == 0 {
+			// 元素不存在时文字条件不成立
+			return false, nil
+		}
+		left = strings.TrimSpace(elements[0].Text)
+	case "url", "title":
+		value, err := r.resolve(ctx, "page."+cond.kind)
+		if err != nil {
+			return false, err
+		}
+		left = value
+	default:
+		value, err := r.expand(ctx, cond.left)
+		if err != nil {
+			return false, err
+		}
+		left = value
+	}
+
+	right, err := r.expand(ctx, cond.right)
+	if err != nil {
+		return false, err
+	}
+	return compare(left, cond.op, right)
+}
+
+// compare 按运算符比较两个值，>、<、>=、<=按数字比较
+func compare(left, op, right string) (bool, error) {
+	switch op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "contains":
+		return strings.Contains(left, right), nil
+	case "~", "!~":
+		re, err := regexp.Compile(right)
+		if err != nil {
+			return false, fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		return re.MatchString(left) == (op == "~"), nil
+	}
+
+	a, err := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	if err != nil {
+		return false, fmt.Errorf("%q不是数字，不能用%s比较", left, op)
+	}
+	b, err := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	if err != nil {
+		return false, fmt.Errorf("%q不是数字，不能用%s比较", right, op)
+	}
+	switch op {
+	case ">":
+		return a > b, nil
+	case "<":
+		return a < b, nil
+	case ">=":
+		return a >= b, nil
+	}
+	return a <= b, nil
+}
+
+// execFor 执行for each循环，循环变量<名称>_index是从1开始的序号
+func (r *Runner) execFor(ctx context.Context, n *forNode) error {
+	start := time.Now()
+	items, err := r.loopItems(ctx, n)
+	if err != nil {
+		return r.fail(n.at, start, err)
+	}
+	if r.options.Verbose {
+		fmt.Fprintf(r.out, "%s: 循环%d次\n", n.at, len(items))
+	}
+
+	indexName := n.variable + "_index"
+	defer r.vars.save(n.variable, indexName)()
+	for i, item := range items {
+		r.vars.Set(n.variable, item)
+		r.vars.Set(indexName, strconv.Itoa(i+1))
+		if err := r.exec(ctx, n.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loopItems 返回循环的各项
+func (r *Runner) loopItems(ctx context.Context, n *forNode) ([]string, error) {
+	switch n.source {
+	case sourceFile:
+		path, err := r.expand(ctx, n.values[0])
+		if err != nil {
+			return nil, err
+		}
+		return readItems(resolvePath(n.at.File, path))
+	case sourceElements:
+		selector, err := r.expand(ctx, n.values[0])
+		if err != nil {
+			return nil, err
+		}
+		page, err := r.executor.Page(ctx)
+		if err != nil {
+			return nil, err
+		}
+		elements, err := page.Query(ctx, selector)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", selector, err)
+		}
+		items := make([]string, len(elements))
+		for i, e := range elements {
+			items[i] = elementValue(e, n.attr)
+		}
+		return items, nil
+	}
+	return r.expandList(ctx, n.values)
+}
+
+// readItems 读取文件中的非空行，忽略#开头的注释
+func readItems(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var items []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			items = append(items, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	return items, nil
+}
+
+// execRetry 执行retry块，失败时等待delay后从头重新执行，最多执行attempts次
+func (r *Runner) execRetry(ctx context.Context, n *retryNode) error {
+	for attempt := 1; ; attempt++ {
+		// 最后一次尝试与普通的块相同：失败时记录跳过的命令，-continue-on-error时继续执行
+		mark := len(r.summary.Steps)
+		final := attempt >= n.attempts
+		if !final {
+			r.retryDepth++
+		}
+		err := r.exec(ctx, n.body)
+		if !final {
+			r.retryDepth--
+		}
+		if err != errStepFailed || ctx.Err() != nil {
+			return err
+		}
+		if final {
+			logrus.Errorf("%s: 重试%d次后仍然失败", n.at, n.attempts)
+			return err
+		}
+		for i := range r.summary.Steps[mark:] {
+			if step := &r.summary.Steps[mark+i]; step.Status == StatusFailed {
+				step.Status = StatusRetried
+			}
+		}
+		logrus.Warnf("%s: 第%d次尝试失败，%s后重试", n.at, attempt, n.delay)
+		select {
+		case <-time.After(n.delay):
+		case <-ctx.Done():
+			return errStepFailed
+		}
+	}
+}
+
+// execCall 调用过程，参数在过程中作为变量使用，返回后恢复同名变量原来的值
+func (r *Runner) execCall(ctx context.Context, n *callNode) error {
+	start := time.Now()
+	p := r.program.procs[n.name]
+	if r.callDepth >= maxCallDepth {
+		return r.fail(n.at, start, fmt.Errorf("过程调用超过%d层，可能存在无限递归", maxCallDepth))
+	}
+
+	values := make(map[string]string, len(p.params))
+	positional := 0
+	for _, t := range n.args {
+		value, err := r.expand(ctx, t.Value)
+		if err != nil {
+			return r.fail(n.at, start, err)
+		}
+		name := t.Key
+		if name == "" {
+			name = p.params[positional]
+			positional++
+		}
+		values[name] = value
+	}
+	if r.options.Verbose {
+		fmt.Fprintf(r.out, "%s: 调用%s\n", n.at, p.name)
+	}
+
+	defer r.vars.save(p.params...)()
+	for name, value := range values {
+		r.vars.Set(name, value)
+	}
+	r.callDepth++
+	defer func() { r.callDepth-- }()
+	return r.exec(ctx, p.body)
+}
+
+// count 统计各状态的步骤数
+func (s *Summary) count() {
+	s.Succeeded, s.Failed, s.Retried, s.Skipped = 0, 0, 0, 0
+	for _, step := range s.Steps {
+		switch step.Status {
+		case StatusSucceeded:
+			s.Succeeded++
+		case StatusFailed:
+			s.Failed++
+		case StatusRetried:
+			s.Retried++
+		case StatusSkipped:
+			s.Skipped++
+		}
+	}
+}
+
+// Print 输出每一步的耗时和结果
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintln(w, "步骤  位置                      结果  耗时        命令")
+	for i, step := range s.Steps {
+		duration := "-"
+		if step.Status != StatusSkipped {
+			duration = step.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%-4d  %-24s  %s  %-10s  %s\n", i+1, step.Line, step.Status, duration, truncate(oneLine(step.Line.Text), 60))
+	}
+	fmt.Fprintf(w, "共%d步, 成功%d步, 失败%d步, 重试%d步, 跳过%d步, 总耗时%s\n",
+		len(s.Steps), s.Succeeded, s.Failed, s.Retried, s.Skipped, s.Duration.Round(time.Millisecond))
+}
+
+// resolvePath 相对路径按所在脚本文件的目录解析
+func resolvePath(scriptFile, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(scriptFile), path)
+}
+
+// oneLine 把多行命令显示为一行
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func truncate(s string, n int) string {
+	rs := []rune(s)
+	if len(rs) <= n {
+		return s
+	}
+	return string(rs[:n-3]) + "..."
+}
@@ -0,0 +1,333 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/pkg/browser"
+)
+
+// fakePage 记录导航的地址，failures中的地址在剩余次数用完前导航失败
+type fakePage struct {
+	mu       sync.Mutex
+	url      string
+	visits   []string
+	failures map[string]int
+	elements map[string][]browser.Element
+}
+
+func (p *fakePage) Navigate(ctx context.Context, url string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.visits = append(p.visits, url)
+	if n := p.failures[url]; n != 0 {
+		p.failures[url] = n - 1
+		return fmt.Errorf("无法打开%s", url)
+	}
+	p.url = url
+	return nil
+}
+
+func (p *fakePage) URL(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.url, nil
+}
+
+func (p *fakePage) Title(ctx context.Context) (string, error) {
+	url, _ := p.URL(ctx)
+	return "title of " + url, nil
+}
+
+func (p *fakePage) Query(ctx context.Context, selector string) ([]browser.Element, error) {
+	return p.elements[selector], nil
+}
+
+func (p *fakePage) Evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	return json.RawMessage(expression), nil
+}
+
+func (p *fakePage) Click(ctx context.Context, selector string) error             { return nil }
+func (p *fakePage) Type(ctx context.Context, selector, text string) error        { return nil }
+func (p *fakePage) Fill(ctx context.Context, selector, value string) error       { return nil }
+func (p *fakePage) Submit(ctx context.Context, selector string) error            { return nil }
+func (p *fakePage) WaitForFunction(ctx context.Context, expression string) error { return nil }
+func (p *fakePage) WaitForNetworkIdle(ctx context.Context) error                 { return nil }
+func (p *fakePage) Screenshot(ctx context.Context) ([]byte, error)               { return nil, nil }
+func (p *fakePage) Cookies(ctx context.Context) ([]browser.Cookie, error)        { return nil, nil }
+func (p *fakePage) Close() error                                                 { return nil }
+func (p *fakePage) WaitForSelector(ctx context.Context, selector string, state browser.WaitState) error {
+	return nil
+}
+func (p *fakePage) WaitForURL(ctx context.Context, pattern *regexp.Regexp) error { return nil }
+func (p *fakePage) SetCookies(ctx context.Context, cookies []browser.Cookie) error {
+	return nil
+}
+func (p *fakePage) StorageState(ctx context.Context) (*browser.StorageState, error) {
+	return &browser.StorageState{}, nil
+}
+func (p *fakePage) SetStorageState(ctx context.Context, state *browser.StorageState) error {
+	return nil
+}
+
+// fakeBrowser 总是返回同一个页面
+type fakeBrowser struct{ page *fakePage }
+
+func (b *fakeBrowser) NewPage(ctx context.Context) (browser.Page, error) { return b.page, nil }
+func (b *fakeBrowser) Close() error                                      { return nil }
+
+// runScript 编译并在假页面上执行脚本，返回统计、输出和页面
+func runScript(t *testing.T, src string, options Options, failures map[string]int) (*Summary, string, *fakePage) {
+	t.Helper()
+	path := writeScript(t, t.TempDir(), "test.gba", src)
+	lines, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := &fakePage{failures: failures, elements: map[string][]browser.Element{
+		"li": {{Tag: "li", Text: " one "}, {Tag: "li", Text: "two", Attributes: map[string]string{"id": "second"}}},
+	}}
+	var out bytes.Buffer
+	r := NewRunner(command.NewExecutorWithBrowser(&fakeBrowser{page: page}), options, &out)
+	program, errs := r.Check(lines)
+	if len(errs) > 0 {
+		t.Fatalf("compile errors: %v", errs)
+	}
+	return r.Run(context.Background(), program), out.String(), page
+}
+
+// statuses 按行号列出每一步的结果，例如"2:成功"
+func statuses(s *Summary) string {
+	var parts []string
+	for _, step := range s.Steps {
+		parts = append(parts, fmt.Sprintf("%d:%s", step.Line.Number, step.Status))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRunnerRetry(t *testing.T) {
+	src := strings.Join([]string{
+		"retry 3 delay=1ms",
+		"  go https://a.example",
+		"  go https://flaky.example",
+		"end",
+		"go https://done.example",
+	}, "\n")
+	summary, _, page := runScript(t, src, Options{}, map[string]int{"https://flaky.example": 2})
+
+	// 前两次尝试中失败的步骤记为重试，同一块中成功的步骤每次都记录
+	want := "2:成功 3:重试 2:成功 3:重试 2:成功 3:成功 5:成功"
+	if got := statuses(summary); got != want {
+		t.Fatalf("steps = %s, want %s", got, want)
+	}
+	if summary.Succeeded != 5 || summary.Retried != 2 || summary.Failed != 0 || summary.Skipped != 0 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if len(page.visits) != 7 {
+		t.Fatalf("visits = %v", page.visits)
+	}
+}
+
+func TestRunnerRetryExhausted(t *testing.T) {
+	src := strings.Join([]string{
+		"retry 2 delay=1ms",
+		"  go https://broken.example",
+		"  go https://after.example",
+		"end",
+		"go https://next.example",
+	}, "\n")
+
+	// 没有-continue-on-error时，最后一次失败后跳过块中和之后的命令
+	summary, _, _ := runScript(t, src, Options{}, map[string]int{"https://broken.example": -1})
+	if got, want := statuses(summary), "2:重试 2:失败 3:跳过 5:跳过"; got != want {
+		t.Fatalf("steps = %s, want %s", got, want)
+	}
+
+	// -continue-on-error在重试中的尝试里不生效，失败后立即重新开始；只有最后一次尝试继续执行
+	summary, _, page := runScript(t, src, Options{ContinueOnError: true}, map[string]int{"https://broken.example": -1})
+	if got, want := statuses(summary), "2:重试 2:失败 3:成功 5:成功"; got != want {
+		t.Fatalf("steps with continue-on-error = %s, want %s", got, want)
+	}
+	if want := "https://broken.example https://broken.example https://after.example https://next.example"; strings.Join(page.visits, " ") != want {
+		t.Fatalf("visits = %v", page.visits)
+	}
+	if summary.Failed != 1 || summary.Retried != 1 || summary.Succeeded != 2 {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
+
+func TestRunnerSkip(t *testing.T) {
+	src := strings.Join([]string{
+		"go https://a.example",
+		"go https://broken.example",
+		"print after",
+		"if exists li",
+		"  print yes",
+		"else",
+		"  print no",
+		"end",
+		"for each i in 1 2 3",
+		"  print ${i}",
+		"end",
+		"retry 2",
+		"  print retried",
+		"end",
+		"proc p",
+		`  print "in proc"`,
+		"end",
+		"call p",
+	}, "\n")
+	summary, out, _ := runScript(t, src, Options{}, map[string]int{"https://broken.example": -1})
+
+	// 跳过的块中每条命令记录一次，过程调用记为一步，过程定义不是步骤
+	want := "1:成功 2:失败 3:跳过 5:跳过 7:跳过 10:跳过 13:跳过 18:跳过"
+	if got := statuses(summary); got != want {
+		t.Fatalf("steps = %s, want %s", got, want)
+	}
+	if summary.Succeeded != 1 || summary.Failed != 1 || summary.Skipped != 6 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if out != "" {
+		t.Fatalf("output = %q", out)
+	}
+
+	summary, out, _ = runScript(t, src, Options{ContinueOnError: true}, map[string]int{"https://broken.example": -1})
+	if summary.Failed != 1 || summary.Skipped != 0 {
+		t.Fatalf("summary with continue-on-error = %+v", summary)
+	}
+	if want := "after\nyes\n1\n2\n3\nretried\nin proc\n"; out != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	path := writeScript(t, t.TempDir(), "test.gba", "print a\nprint b\nif exists li\n  print c\nend")
+	lines, _ := Load(path)
+	r := NewRunner(command.NewExecutorWithBrowser(&fakeBrowser{page: &fakePage{}}), Options{ContinueOnError: true}, &bytes.Buffer{})
+	program, _ := r.Check(lines)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	summary := r.Run(ctx, program)
+	if got, want := statuses(summary), "1:跳过 2:跳过 4:跳过"; got != want {
+		t.Fatalf("steps = %s, want %s", got, want)
+	}
+}
+
+func TestRunnerVariables(t *testing.T) {
+	t.Setenv("GBA_SCRIPT_TEST", "from env")
+	src := strings.Join([]string{
+		`set name "张三"`,
+		`print "$${name} = ${name}, $$${name}"`,
+		`set template "$${name}"`,
+		`print ${template}`,
+		`print ${env.GBA_SCRIPT_TEST}`,
+		`go https://example.com/${name}`,
+		`print "${page.url} | ${page.title}"`,
+		`extract items li all=true`,
+		`print "${items}"`,
+		`extract id li attr=id all=true`,
+		`print "[${id}]"`,
+		`extract first li`,
+		`print ${first}`,
+		`extract n js="[1, \"x\", null, {\"a\": true}]"`,
+		`print "${n}"`,
+	}, "\n")
+	summary, out, _ := runScript(t, src, Options{}, nil)
+	if summary.Failed != 0 {
+		t.Fatalf("steps = %+v", summary.Steps)
+	}
+	want := strings.Join([]string{
+		"${name} = 张三, $${name}",
+		"${name}",
+		"from env",
+		"https://example.com/张三 | title of https://example.com/张三",
+		"one, two",
+		"[, second]",
+		"one",
+		`1, x, , {"a":true}`,
+	}, "\n") + "\n"
+	if out != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+}
+
+func TestRunnerScopes(t *testing.T) {
+	src := strings.Join([]string{
+		"proc greet who",
+		"  set who changed",
+		`  print "hi ${who}"`,
+		"  for each item in a b",
+		"    print ${who}/${item}/${item_index}",
+		"  end",
+		"end",
+		"set who outer",
+		"set item kept",
+		"for each item in x ${who}",
+		"  call greet ${item}",
+		"end",
+		`print "${who} ${item}"`,
+		"print ${item_index}",
+	}, "\n")
+	summary, out, _ := runScript(t, src, Options{}, nil)
+
+	// 过程参数和循环变量在结束后恢复原值，原来没有的变量被删除
+	want := strings.Join([]string{
+		"hi changed", "changed/a/1", "changed/b/2",
+		"hi changed", "changed/a/1", "changed/b/2",
+		"outer kept",
+	}, "\n") + "\n"
+	if out != want {
+		t.Fatalf("output = %q, want %q", out, want)
+	}
+	last := summary.Steps[len(summary.Steps)-1]
+	if last.Status != StatusFailed || !strings.Contains(last.Message, "变量item_index没有定义") {
+		t.Fatalf("last step = %+v", last)
+	}
+}
+
+func TestRunnerRecursion(t *testing.T) {
+	src := strings.Join([]string{
+		"proc down n",
+		"  print ${n}",
+		"  call down x${n}",
+		"end",
+		"call down 0",
+		"print unreachable",
+	}, "\n")
+	summary, out, _ := runScript(t, src, Options{}, nil)
+
+	if lines := strings.Count(out, "\n"); lines != maxCallDepth {
+		t.Fatalf("printed %d lines, want %d", lines, maxCallDepth)
+	}
+	if summary.Failed != 1 || summary.Succeeded != maxCallDepth {
+		t.Fatalf("summary = %d succeeded, %d failed", summary.Succeeded, summary.Failed)
+	}
+	var failed Step
+	for _, step := range summary.Steps {
+		if step.Status == StatusFailed {
+			failed = step
+		}
+	}
+	if failed.Line.Number != 3 || !strings.Contains(failed.Message, fmt.Sprintf("超过%d层", maxCallDepth)) {
+		t.Fatalf("failed step = %+v", failed)
+	}
+	if last := summary.Steps[len(summary.Steps)-1]; last.Line.Number != 6 || last.Status != StatusSkipped {
+		t.Fatalf("last step = %+v", last)
+	}
+}
+
+func TestRunnerExit(t *testing.T) {
+	summary, out, _ := runScript(t, "print a\nif 1 < 2\n  exit\nend\nprint b", Options{}, nil)
+	if out != "a\n" || summary.Failed != 0 || summary.Skipped != 0 {
+		t.Fatalf("output = %q, summary = %+v", out, summary)
+	}
+}
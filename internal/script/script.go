@@ -0,0 +1,146 @@
+// Package script 执行脚本文件：每行一条命令，在同一个浏览器页面上按顺序执行
+//
+// 以#或//开头的行是注释，空行被忽略；引号没有闭合或行尾是\时与下一行合并为一条命令，
+// 规则与交互模式相同。执行前先解析整个文件，有语法错误时不启动浏览器。
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"GoBrowserAgent/pkg/parser"
+)
+
+// Line 脚本中的一条命令
+type Line struct {
+	// File 脚本文件路径
+	File string
+	// Number 命令开始的行号（从1开始）
+	Number int
+	// Text 命令文本，多行命令用\n连接
+	Text string
+}
+
+func (l Line) String() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Number)
+}
+
+// LineError 脚本中某一行的错误
+type LineError struct {
+	Line Line
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	if parseErr, ok := e.Err.(*parser.Error); ok {
+		return fmt.Sprintf("%s:%d: %s", e.Line, parseErr.Column, parseErr.Message)
+	}
+	return fmt.Sprintf("%s: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Pretty 返回带出错位置的多行说明，不是解析错误时与Error相同
+func (e *LineError) Pretty() string {
+	if parseErr, ok := e.Err.(*parser.Error); ok {
+		return fmt.Sprintf("%s:\n%s", e.Line, parseErr.Pretty())
+	}
+	return e.Error()
+}
+
+// Load 读取脚本文件，返回去掉注释和空行后的命令
+//
+// include <路径>在读取时替换为被包含文件中的命令，相对路径按当前脚本所在的目录解析。
+func Load(path string) ([]Line, error) {
+	return load(path, nil)
+}
+
+// load 读取一个脚本文件，stack是正在读取的文件，用于发现循环包含
+func load(path string, stack []string) ([]Line, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开脚本文件失败: %v", err)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("循环包含: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开脚本文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var lines []Line
+	var current *Line
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if current == nil {
+			trimmed := strings.TrimSpace(text)
+			if trimmed == "" || isComment(trimmed) {
+				continue
+			}
+			current = &Line{File: path, Number: number, Text: trimmed}
+		} else {
+			current.Text += "\n" + text
+		}
+
+		if parser.Incomplete(current.Text) {
+			// 行尾的\只表示续行，不属于命令内容
+			current.Text = strings.TrimSuffix(current.Text, "\\")
+			continue
+		}
+		included, err := include(*current, stack)
+		if err != nil {
+			return nil, err
+		}
+		if included != nil {
+			lines = append(lines, included...)
+		} else {
+			lines = append(lines, *current)
+		}
+		current = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取脚本文件失败: %v", err)
+	}
+	if current != nil {
+		// 文件在续行中结束，交给解析器报告未闭合的引号
+		lines = append(lines, *current)
+	}
+	return lines, nil
+}
+
+// include 处理include命令，不是include时返回nil
+func include(line Line, stack []string) ([]Line, error) {
+	tokens, err := parser.Tokenize(line.Text)
+	if err != nil || len(tokens) == 0 || tokens[0].Value != "include" || tokens[0].Key != "" || tokens[0].Quoted {
+		return nil, nil
+	}
+	if len(tokens) != 2 || tokens[1].Key != "" {
+		return nil, &LineError{Line: line, Err: &parser.Error{Line: line.Text, Column: len([]rune(line.Text)) + 1, Message: "用法: include <文件>"}}
+	}
+	lines, err := load(resolvePath(line.File, tokens[1].Value), stack)
+	if err != nil {
+		return nil, &LineError{Line: line, Err: err}
+	}
+	// 空文件也要返回非nil，与不是include的情况区分
+	if lines == nil {
+		lines = []Line{}
+	}
+	return lines, nil
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//")
+}
@@ -0,0 +1,195 @@
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScript 在临时目录中写入脚本文件，返回路径
+func writeScript(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "common.gba", "# 公共步骤\nset site https://example.com\n")
+	path := writeScript(t, dir, "main.gba", strings.Join([]string{
+		"# 注释",
+		"// 另一种注释",
+		"",
+		"include common.gba",
+		`print "第一行`,
+		`第二行"`,
+		"go \\",
+		"  ${site}",
+		"  wait 1s  ",
+	}, "\n"))
+
+	lines, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		file   string
+		number int
+		text   string
+	}{
+		{"common.gba", 2, "set site https://example.com"},
+		{"main.gba", 5, "print \"第一行\n第二行\""},
+		{"main.gba", 7, "go \n  ${site}"},
+		{"main.gba", 9, "wait 1s"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("lines = %+v", lines)
+	}
+	for i, w := range want {
+		if filepath.Base(lines[i].File) != w.file || lines[i].Number != w.number || lines[i].Text != w.text {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], w)
+		}
+	}
+}
+
+func TestLoadInclude(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "lib"), 0700)
+	writeScript(t, dir, "lib/a.gba", "include b.gba\n")
+	writeScript(t, dir, "lib/b.gba", "print b\n")
+	writeScript(t, dir, "empty.gba", "# 只有注释\n")
+	writeScript(t, dir, "self.gba", "include self.gba\n")
+	writeScript(t, dir, "x.gba", "print x\ninclude y.gba\n")
+	writeScript(t, dir, "y.gba", "\n\ninclude x.gba\n")
+
+	tests := []struct {
+		name    string
+		content string
+		lines   int
+		err     string
+	}{
+		{"nested relative path", "include lib/a.gba\n", 1, ""},
+		{"empty file", "include empty.gba\nprint done\n", 1, ""},
+		{"same file twice", "include lib/b.gba\ninclude lib/b.gba\n", 2, ""},
+		{"self include", "include self.gba\n", 0, "main.gba:1: self.gba:1: 循环包含"},
+		{"indirect cycle", "print start\ninclude x.gba\n", 0, "main.gba:2: x.gba:2: y.gba:3: 循环包含"},
+		{"missing file", "\ninclude missing.gba\n", 0, "main.gba:2: 打开脚本文件失败"},
+		{"no path", "include\n", 0, "main.gba:1:8: 用法: include <文件>"},
+		{"two paths", "include a b\n", 0, "main.gba:1:12: 用法: include <文件>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeScript(t, dir, "main.gba", tt.content)
+			lines, err := Load(path)
+			if tt.err == "" {
+				if err != nil || len(lines) != tt.lines {
+					t.Fatalf("Load = %d lines, %v", len(lines), err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Load succeeded with %d lines", len(lines))
+			}
+			// 错误中的路径是绝对路径，只比较文件名
+			if msg := strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), ""); !strings.Contains(msg, tt.err) {
+				t.Fatalf("error = %q, want %q", msg, tt.err)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		errs []string
+	}{
+		{"unknown command", "print ok\n\nfly away", []string{"s.gba:3:1: "}},
+		{"unclosed quote at end", "print ok\nprint \"open", []string{"s.gba:2:7: "}},
+		{"bad argument after continuation", "print ok\ngo \\\n  https://example.com bogus=1", []string{"s.gba:2:"}},
+		{"if without end", "print ok\nif exists #a\n  print a", []string{"s.gba:2:1: if没有对应的end"}},
+		{"stray end", "end", []string{"s.gba:1:1: end前面没有对应的if、for、retry或proc"}},
+		{"text after end", "if exists #a\nend now", []string{"s.gba:2:5: end后面不能有其他内容"}},
+		{"else after else", "if exists #a\nelse\nelse\nend", []string{"s.gba:3:1: if只能有一个else"}},
+		{"bad operator", "if ${a} === b\nend", []string{"s.gba:1:9: 不支持的运算符"}},
+		{"bad regexp", "if url ~ \"(\"\nend", []string{"s.gba:1:10: 无效的正则表达式"}},
+		{"missing condition", "if\nend", []string{"s.gba:1:3: 缺少条件"}},
+		{"retry count", "retry 0\nend", []string{"s.gba:1:7: 重试次数应为正整数"}},
+		{"retry delay", "retry 2 delay=soon\nend", []string{"s.gba:1:15: 参数delay"}},
+		{"bad loop variable", "for each 1x in a b\nend", []string{"s.gba:1:10: 无效的变量名"}},
+		{"nested proc", "if exists #a\nproc inner\nend\nend", []string{"s.gba:2:1: proc只能在脚本顶层定义"}},
+		{"duplicate proc", "proc p\nend\nproc p\nend", []string{"s.gba:3:6: 过程p已经在"}},
+		{"undefined proc", "print ok\ncall nope", []string{"s.gba:2:6: 过程nope没有定义"}},
+		{"too many arguments", "proc p a\nend\ncall p 1 2", []string{"s.gba:3:10: 过程p只有1个参数"}},
+		{"unknown named argument", "proc p a\nend\ncall p b=1", []string{"s.gba:3:8: 过程p没有参数b"}},
+		{"missing argument", "proc p a b\nend\ncall p 1", []string{"s.gba:3:9: 缺少参数b"}},
+		{"bad variable reference", "print ${a b}", []string{"s.gba:1:"}},
+		{"bad variable name in set", "set 1x value", []string{"s.gba:1:5: 无效的变量名"}},
+		{"extract without source", "extract title", []string{"s.gba:1:14: extract需要选择器或js参数中的一个"}},
+		{
+			// 过程调用最后检查，错误仍按行号排列
+			"errors in line order", "call nope\nfly\nif\nend",
+			[]string{"s.gba:1:6: ", "s.gba:2:1: ", "s.gba:3:3: "},
+		},
+	}
+	r := NewRunner(nil, Options{}, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeScript(t, dir, "s.gba", tt.src)
+			lines, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, errs := r.Check(lines)
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("errors = %v, want %v", errs, tt.errs)
+			}
+			for i, e := range errs {
+				msg := strings.ReplaceAll(e.Error(), dir+string(filepath.Separator), "")
+				if !strings.HasPrefix(msg, tt.errs[i]) {
+					t.Errorf("error %d = %q, want prefix %q", i, msg, tt.errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompileValid(t *testing.T) {
+	src := strings.Join([]string{
+		"proc open page",
+		"  go ${page}",
+		"end",
+		"set base https://example.com",
+		"for each p in /a /b",
+		"  call open ${base}${p}",
+		"  if title contains Example",
+		"    print ok",
+		"  else if not exists #missing",
+		"    print missing",
+		"  else",
+		"    exit",
+		"  end",
+		"end",
+		"retry 3 delay=10ms",
+		"  call open page=${base}",
+		"end",
+		`print "$${not_a_variable}"`,
+	}, "\n")
+	path := writeScript(t, t.TempDir(), "ok.gba", src)
+	lines, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, errs := NewRunner(nil, Options{}, nil).Check(lines)
+	if len(errs) > 0 {
+		t.Fatalf("errors = %v", errs)
+	}
+	if len(program.nodes) != 4 || len(program.procs) != 1 {
+		t.Fatalf("program = %d nodes, %d procs", len(program.nodes), len(program.procs))
+	}
+}
@@ -0,0 +1,210 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"GoBrowserAgent/pkg/parser"
+)
+
+// listSeparator 列表变量在文字中展开时的分隔符
+const listSeparator = ", "
+
+// Variables 脚本变量，值是一个或多个字符串；多个值的列表变量可以在for each中逐项遍历
+type Variables struct {
+	values map[string][]string
+}
+
+// NewVariables 创建空的变量表
+func NewVariables() *Variables {
+	return &Variables{values: make(map[string][]string)}
+}
+
+// Set 设置单值变量
+func (v *Variables) Set(name, value string) {
+	v.values[name] = []string{value}
+}
+
+// SetList 设置列表变量
+func (v *Variables) SetList(name string, items []string) {
+	v.values[name] = append([]string(nil), items...)
+}
+
+// Get 返回变量的值，列表变量的各项用", "连接
+func (v *Variables) Get(name string) (string, bool) {
+	items, ok := v.values[name]
+	return strings.Join(items, listSeparator), ok
+}
+
+// List 返回变量的所有项
+func (v *Variables) List(name string) ([]string, bool) {
+	items, ok := v.values[name]
+	return items, ok
+}
+
+// save 记录变量当前的值，返回恢复的函数；用于循环变量和过程参数
+func (v *Variables) save(names ...string) func() {
+	type saved struct {
+		items []string
+		ok    bool
+	}
+	old := make(map[string]saved, len(names))
+	for _, name := range names {
+		items, ok := v.values[name]
+		old[name] = saved{items, ok}
+	}
+	return func() {
+		for name, s := range old {
+			if s.ok {
+				v.values[name] = s.items
+			} else {
+				delete(v.values, name)
+			}
+		}
+	}
+}
+
+// isVariableName 变量名由字母、数字和_组成，不以数字开头
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case i > 0 && c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// reference 文字中的一个${...}引用
+type reference struct {
+	start, end int
+	name       string
+}
+
+// references 找出文字中的所有变量引用，$${表示字面的${
+func references(s string) ([]reference, error) {
+	var refs []reference
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) || s[i+1] != '{' {
+			continue
+		}
+		if i > 0 && s[i-1] == '$' {
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("变量引用${没有结束")
+		}
+		name := s[i+2 : i+end]
+		if !isReferenceName(name) {
+			return nil, fmt.Errorf("无效的变量名%q", name)
+		}
+		refs = append(refs, reference{start: i, end: i + end + 1, name: name})
+		i += end
+	}
+	return refs, nil
+}
+
+// isReferenceName 引用可以是变量名，也可以是env.<环境变量>或page.url、page.title
+func isReferenceName(name string) bool {
+	if rest, ok := strings.CutPrefix(name, "env."); ok {
+		return rest != ""
+	}
+	if name == "page.url" || name == "page.title" {
+		return true
+	}
+	return isVariableName(name)
+}
+
+// checkExpander 执行前检查脚本时使用：引用格式正确但值未知的参数跳过类型检查
+func checkExpander(value string) (string, error) {
+	refs, err := references(value)
+	if err != nil {
+		return "", err
+	}
+	if len(refs) > 0 {
+		return value, parser.SkipValue
+	}
+	return strings.ReplaceAll(value, "$${", "${"), nil
+}
+
+// expand 替换文字中的变量引用
+func (r *Runner) expand(ctx context.Context, value string) (string, error) {
+	refs, err := references(value)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	last := 0
+	for _, ref := range refs {
+		b.WriteString(strings.ReplaceAll(value[last:ref.start], "$${", "${"))
+		resolved, err := r.resolve(ctx, ref.name)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(resolved)
+		last = ref.end
+	}
+	b.WriteString(strings.ReplaceAll(value[last:], "$${", "${"))
+	return b.String(), nil
+}
+
+// expander 返回在ctx中替换变量的Expander
+func (r *Runner) expander(ctx context.Context) parser.Expander {
+	return func(value string) (string, error) {
+		return r.expand(ctx, value)
+	}
+}
+
+// resolve 返回一个引用的值
+func (r *Runner) resolve(ctx context.Context, name string) (string, error) {
+	if env, ok := strings.CutPrefix(name, "env."); ok {
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("环境变量%s没有设置", env)
+		}
+		return value, nil
+	}
+	switch name {
+	case "page.url", "page.title":
+		page, err := r.executor.Page(ctx)
+		if err != nil {
+			return "", err
+		}
+		if name == "page.url" {
+			return page.URL(ctx)
+		}
+		return page.Title(ctx)
+	}
+	value, ok := r.vars.Get(name)
+	if !ok {
+		return "", fmt.Errorf("变量%s没有定义", name)
+	}
+	return value, nil
+}
+
+// expandList 展开for each的列表项：恰好是${list}的项展开为列表的每一项，其他项按文字替换
+func (r *Runner) expandList(ctx context.Context, values []string) ([]string, error) {
+	var items []string
+	for _, value := range values {
+		if refs, err := references(value); err == nil && len(refs) == 1 && refs[0].start == 0 && refs[0].end == len(value) {
+			if list, ok := r.vars.List(refs[0].name); ok {
+				items = append(items, list...)
+				continue
+			}
+		}
+		item, err := r.expand(ctx, value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -27,9 +27,11 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, err
 	}
 
-	// 解析JSON配置
-	var configData struct {
-		LLM Config `json:"llm"`
+	// 解析JSON配置，未设置的字段使用默认值
+	configData := struct {
+		LLM *Config `json:"llm"`
+	}{
+		LLM: GetDefaultConfig(),
 	}
 
 	if err := json.Unmarshal(data, &configData); err != nil {
@@ -44,7 +46,7 @@ func LoadConfig(configPath string) (*Config, error) {
 		config.APIKey = apiKey
 	}
 
-	return &config, nil
+	return config, nil
 }
 
 // GetConfigPath 获取配置文件路径
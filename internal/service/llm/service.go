@@ -2,11 +2,14 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
+	"GoBrowserAgent/internal/httpclient"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -14,104 +17,208 @@ import (
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Name 区分同一角色的不同参与者，为空时不发送
+	Name string `json:"name,omitempty"`
+	// Parts 数组形式的内容片段（文字、图片等），不为空时代替Content原样发送给上游，Content只保存其中的文字
+	Parts []json.RawMessage `json:"-"`
 }
 
 // ChatRequest 定义聊天请求结构
 type ChatRequest struct {
-	Model       string        `json:"model"`
-	Messages    []ChatMessage `json:"messages"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	TopP        float64       `json:"top_p,omitempty"`
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	// MaxTokens、Temperature、TopP 为nil时使用配置中的默认值，显式的0会原样发送
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	// Stream 由CompleteStream设置，调用方无需填写
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+
+	// 以下参数为空时不发送，由上游使用自己的默认值；网关按OpenAI的写法原样转发
+	// Stop 停止序列，字符串或字符串数组
+	Stop             json.RawMessage `json:"stop,omitempty"`
+	N                *int            `json:"n,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	Seed             *int64          `json:"seed,omitempty"`
+	// ResponseFormat 输出格式，例如{"type":"json_object"}
+	ResponseFormat json.RawMessage `json:"response_format,omitempty"`
+	// User 终端用户标识，供上游识别滥用
+	User string `json:"user,omitempty"`
+}
+
+// wireRequest 发送给上游的请求，消息带内容片段时content为数组
+type wireRequest struct {
+	ChatRequest
+	Messages []wireMessage `json:"messages"`
+}
+
+// wireMessage 发送给上游的消息
+type wireMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+	Name    string      `json:"name,omitempty"`
+}
+
+// StreamOptions 流式请求选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Usage 定义一次请求的token用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatChoice 定义LLM返回的单个候选结果
+type ChatChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
 }
 
 // ChatResponse 定义LLM API响应结构
 type ChatResponse struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	Choices []struct {
-		Index        int         `json:"index"`
-		Message      ChatMessage `json:"message"`
-		FinishReason string      `json:"finish_reason"`
-	} `json:"choices"`
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   Usage        `json:"usage"`
+}
+
+// APIError LLM接口返回的非200响应
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("LLM API返回错误: %s", e.Body)
 }
 
 // Service LLM服务
 type Service struct {
-	Config *Config
+	Config     *Config
+	HTTPClient *httpclient.Client
 }
 
 // NewService 创建新的LLM服务
-func NewService(config *Config) *Service {
+func NewService(config *Config, httpClient *httpclient.Client) *Service {
 	return &Service{
-		Config: config,
+		Config:     config,
+		HTTPClient: httpClient,
 	}
 }
 
 // Chat 处理与LLM的聊天
 func (s *Service) Chat(userMessage string) (string, error) {
-	if s.Config.APIKey == "" {
-		return "", fmt.Errorf("未配置API密钥，请在配置文件中设置api_key或通过环境变量LLM_API_KEY设置")
-	}
-
-	// 构建请求
-	chatReq := ChatRequest{
-		Model: s.Config.Model,
+	chatResp, err := s.Complete(context.Background(), ChatRequest{
 		Messages: []ChatMessage{
 			{
 				Role:    "user",
 				Content: userMessage,
 			},
 		},
-		MaxTokens:   s.Config.MaxTokens,
-		Temperature: s.Config.Temperature,
-		TopP:        s.Config.TopP,
-	}
-
-	reqBody, err := json.Marshal(chatReq)
-	if err != nil {
-		return "", fmt.Errorf("无法序列化聊天请求: %v", err)
-	}
-
-	// 发送请求到LLM API
-	req, err := http.NewRequest("POST", s.Config.APIEndpoint, bytes.NewBuffer(reqBody))
+	})
 	if err != nil {
-		return "", fmt.Errorf("创建HTTP请求失败: %v", err)
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.Config.APIKey)
-
-	logrus.Debugf("发送请求到LLM API: %s, 模型: %s", s.Config.APIEndpoint, s.Config.Model)
+	// 返回响应
+	return chatResp.Choices[0].Message.Content, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Complete 发送完整的聊天请求，请求中未设置的模型参数使用配置中的默认值
+func (s *Service) Complete(ctx context.Context, chatReq ChatRequest) (*ChatResponse, error) {
+	chatReq.Stream = false
+	chatReq.StreamOptions = nil
+	resp, err := s.send(ctx, chatReq)
 	if err != nil {
-		return "", fmt.Errorf("发送请求到LLM API失败: %v", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("LLM API返回错误: %s", string(respBody))
+		return nil, fmt.Errorf("读取响应失败: %v", err)
 	}
 
 	// 解析响应
 	var chatResp ChatResponse
 	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", fmt.Errorf("解析LLM响应失败: %v", err)
+		return nil, fmt.Errorf("解析LLM响应失败: %v", err)
 	}
 
 	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("LLM未返回任何响应")
+		return nil, fmt.Errorf("LLM未返回任何响应")
 	}
 
-	// 返回响应
-	return chatResp.Choices[0].Message.Content, nil
+	return &chatResp, nil
+}
+
+// send 补全默认参数后发送请求，返回状态码为200的响应
+func (s *Service) send(ctx context.Context, chatReq ChatRequest) (*http.Response, error) {
+	if s.Config.APIKey == "" {
+		return nil, fmt.Errorf("未配置API密钥，请在配置文件中设置api_key或通过环境变量LLM_API_KEY设置")
+	}
+
+	// 补全默认参数
+	if chatReq.Model == "" {
+		chatReq.Model = s.Config.Model
+	}
+	if chatReq.MaxTokens == nil && s.Config.MaxTokens > 0 {
+		maxTokens := s.Config.MaxTokens
+		chatReq.MaxTokens = &maxTokens
+	}
+	if chatReq.Temperature == nil {
+		temperature := s.Config.Temperature
+		chatReq.Temperature = &temperature
+	}
+	if chatReq.TopP == nil {
+		topP := s.Config.TopP
+		chatReq.TopP = &topP
+	}
+
+	wire := wireRequest{ChatRequest: chatReq, Messages: make([]wireMessage, len(chatReq.Messages))}
+	for i, m := range chatReq.Messages {
+		wire.Messages[i] = wireMessage{Role: m.Role, Content: m.Content, Name: m.Name}
+		if len(m.Parts) > 0 {
+			wire.Messages[i].Content = m.Parts
+		}
+	}
+	reqBody, err := json.Marshal(wire)
+	if err != nil {
+		return nil, fmt.Errorf("无法序列化聊天请求: %v", err)
+	}
+
+	// 发送请求到LLM API
+	req, err := http.NewRequestWithContext(ctx, "POST", s.Config.APIEndpoint, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.Config.APIKey)
+	if chatReq.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	logrus.Debugf("发送请求到LLM API: %s, 模型: %s", s.Config.APIEndpoint, chatReq.Model)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求到LLM API失败: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return resp, nil
 }
@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"GoBrowserAgent/internal/httpclient"
+)
+
+func TestCompleteSamplingDefaults(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		bodies <- body
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	client, err := httpclient.New(httpclient.GetDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := GetDefaultConfig()
+	config.APIEndpoint = server.URL
+	config.APIKey = "test"
+	s := NewService(config, client)
+	messages := []ChatMessage{{Role: "user", Content: "hi"}}
+
+	// 省略时使用配置中的默认值
+	if _, err := s.Complete(context.Background(), ChatRequest{Messages: messages}); err != nil {
+		t.Fatal(err)
+	}
+	body := <-bodies
+	if body["temperature"] != config.Temperature || body["top_p"] != config.TopP || body["max_tokens"] != float64(config.MaxTokens) {
+		t.Fatalf("defaults not applied: %v", body)
+	}
+
+	// 显式的0原样发送
+	zero, zeroTokens := 0.0, 0
+	if _, err := s.Complete(context.Background(), ChatRequest{Messages: messages, Temperature: &zero, TopP: &zero, MaxTokens: &zeroTokens}); err != nil {
+		t.Fatal(err)
+	}
+	body = <-bodies
+	for _, key := range []string{"temperature", "top_p", "max_tokens"} {
+		if v, ok := body[key]; !ok || v != 0.0 {
+			t.Errorf("%s = %v, want explicit 0", key, v)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChatDelta 流式响应中的增量消息
+type ChatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ChunkChoice 流式响应中单个候选结果的增量
+type ChunkChoice struct {
+	Index        int       `json:"index"`
+	Delta        ChatDelta `json:"delta"`
+	FinishReason *string   `json:"finish_reason"`
+}
+
+// ChatChunk 流式响应中的一个数据块
+type ChatChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// maxStreamChoices 流式响应中允许的候选结果数，超出时视为上游响应有误，避免按任意的index分配内存
+const maxStreamChoices = 16
+
+// CompleteStream 以流式方式发送聊天请求，每收到一个数据块调用一次onChunk
+//
+// 返回值是把所有增量拼接后的完整响应，包含上游在最后一个数据块中给出的用量。
+// onChunk返回错误时停止读取并返回该错误。
+func (s *Service) CompleteStream(ctx context.Context, chatReq ChatRequest, onChunk func(*ChatChunk) error) (*ChatResponse, error) {
+	chatReq.Stream = true
+	chatReq.StreamOptions = &StreamOptions{IncludeUsage: true}
+	resp, err := s.send(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ChatResponse{Object: "chat.completion"}
+	var contents []*strings.Builder
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		// 忽略空行、注释和event等其他字段
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[len("data:"):])
+		if string(data) == "[DONE]" {
+			break
+		}
+
+		var chunk ChatChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil, fmt.Errorf("解析LLM流式响应失败: %v", err)
+		}
+
+		if result.ID == "" {
+			result.ID = chunk.ID
+			result.Created = chunk.Created
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index < 0 || choice.Index >= maxStreamChoices {
+				return nil, fmt.Errorf("LLM流式响应的候选序号%d无效，最多支持%d个候选", choice.Index, maxStreamChoices)
+			}
+			for len(result.Choices) <= choice.Index {
+				result.Choices = append(result.Choices, ChatChoice{Index: len(result.Choices), Message: ChatMessage{Role: "assistant"}})
+				contents = append(contents, &strings.Builder{})
+			}
+			contents[choice.Index].WriteString(choice.Delta.Content)
+			if choice.Delta.Role != "" {
+				result.Choices[choice.Index].Message.Role = choice.Delta.Role
+			}
+			if choice.FinishReason != nil {
+				result.Choices[choice.Index].FinishReason = *choice.FinishReason
+			}
+		}
+
+		if onChunk != nil {
+			if err := onChunk(&chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取LLM流式响应失败: %v", err)
+	}
+
+	for i := range result.Choices {
+		result.Choices[i].Message.Content = contents[i].String()
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("LLM未返回任何响应")
+	}
+	return result, nil
+}
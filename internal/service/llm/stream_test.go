@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"GoBrowserAgent/internal/httpclient"
+)
+
+// newStreamService 返回请求发往fake上游的服务，上游按顺序返回lines中的SSE数据
+func newStreamService(t *testing.T, lines []string) *Service {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, line := range lines {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := httpclient.New(httpclient.GetDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := GetDefaultConfig()
+	config.APIEndpoint = server.URL
+	config.APIKey = "test"
+	return NewService(config, client)
+}
+
+func TestCompleteStreamInterleavedChoices(t *testing.T) {
+	s := newStreamService(t, []string{
+		`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":"你"}}]}`,
+		`{"id":"c1","choices":[{"index":1,"delta":{"content":"甲"}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"content":"好"}}]}`,
+		`{"id":"c1","choices":[{"index":2,"delta":{"content":"丙"}}]}`,
+		`{"id":"c1","choices":[{"index":1,"delta":{"content":"乙"}}]}`,
+		`[DONE]`,
+	})
+	resp, err := s.CompleteStream(context.Background(), ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"你好", "甲乙", "丙"}
+	if len(resp.Choices) != len(want) {
+		t.Fatalf("got %d choices, want %d", len(resp.Choices), len(want))
+	}
+	for i, w := range want {
+		if got := resp.Choices[i].Message.Content; got != w {
+			t.Errorf("choice %d = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestCompleteStreamRejectsLargeIndex(t *testing.T) {
+	s := newStreamService(t, []string{
+		`{"id":"c1","choices":[{"index":100000000,"delta":{"content":"x"}}]}`,
+		`[DONE]`,
+	})
+	_, err := s.CompleteStream(context.Background(), ChatRequest{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "候选序号") {
+		t.Fatalf("err = %v, want invalid index error", err)
+	}
+}
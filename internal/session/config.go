@@ -0,0 +1,59 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config 存储会话持久化配置
+type Config struct {
+	// Path 存储文件路径，为空时会话只保存在内存中
+	Path string `json:"path"`
+	// RetentionDays 会话最后更新后保留的天数，0表示永久保留
+	RetentionDays int `json:"retention_days"`
+	// MaxSessions 最多保留的会话数，超出时删除最久未更新的会话，0表示不限制
+	MaxSessions int `json:"max_sessions"`
+}
+
+// LoadConfig 从配置文件加载会话存储配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		Store *Config `json:"store"`
+	}{
+		Store: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.Store, nil
+}
+
+// GetDefaultConfig 获取默认配置
+func GetDefaultConfig() *Config {
+	return &Config{
+		Path:          filepath.Join("data", "sessions.jsonl"),
+		RetentionDays: 90,
+		MaxSessions:   1000,
+	}
+}
+
+// Open 根据配置打开会话存储
+func Open(config *Config) (Store, error) {
+	if config.Path == "" {
+		logrus.Warn("未配置会话存储路径，会话仅保存在内存中")
+		return NewMemoryStore(), nil
+	}
+	return OpenFileStore(config)
+}
@@ -0,0 +1,212 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/markdown"
+)
+
+const (
+	// ExportFormatName JSON导出文件的格式标识
+	ExportFormatName = "gobrowseragent.session"
+	// ExportVersion JSON导出文件的版本
+	ExportVersion = 1
+)
+
+// Export JSON导出文件结构
+type Export struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Session    *Session  `json:"session"`
+}
+
+// ExportJSON 导出为JSON，包含完整的消息列表和元数据
+//
+// 导出文件可能被分享给其他人，不包含创建者的身份；导入时会话归属于导入的调用方。
+func ExportJSON(s *Session) ([]byte, error) {
+	c := s.Clone()
+	c.Owner = ""
+	return json.MarshalIndent(Export{
+		Format:     ExportFormatName,
+		Version:    ExportVersion,
+		ExportedAt: time.Now(),
+		Session:    c,
+	}, "", "  ")
+}
+
+// ImportJSON 解析JSON导出文件，返回分配了新ID的会话
+func ImportJSON(data []byte) (*Session, error) {
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("解析导出文件失败: %v", err)
+	}
+	if export.Format != ExportFormatName {
+		return nil, fmt.Errorf("不支持的导出格式: %q", export.Format)
+	}
+	if export.Version > ExportVersion {
+		return nil, fmt.Errorf("导出文件版本%d高于当前支持的版本%d", export.Version, ExportVersion)
+	}
+	if export.Session == nil {
+		return nil, fmt.Errorf("导出文件中没有会话")
+	}
+
+	for i, m := range export.Session.Messages {
+		switch m.Role {
+		case "system", "user", "assistant":
+		default:
+			return nil, fmt.Errorf("第%d条消息的角色无效: %q", i+1, m.Role)
+		}
+	}
+
+	s := export.Session.Clone()
+	originalID := s.ID
+	s.ID = NewID()
+	// 旧版本导出的文件中可能带有创建者，由调用方重新设置
+	s.Owner = ""
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	s.UpdatedAt = time.Now()
+	if s.Title == "" {
+		for _, m := range s.Messages {
+			if m.Role == "user" {
+				s.Title = makeTitle(m.Content)
+				break
+			}
+		}
+	}
+	if originalID != "" {
+		s.Metadata["imported_from"] = originalID
+	}
+	return s, nil
+}
+
+// ExportMarkdown 导出为Markdown
+func ExportMarkdown(s *Session) []byte {
+	var b strings.Builder
+
+	title := s.Title
+	if title == "" {
+		title = "未命名对话"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- 创建时间: %s\n", s.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "- 更新时间: %s\n", s.UpdatedAt.Format("2006-01-02 15:04:05"))
+	if s.Model != "" {
+		fmt.Fprintf(&b, "- 模型: %s\n", s.Model)
+	}
+	if s.Usage.TotalTokens > 0 {
+		fmt.Fprintf(&b, "- Token用量: %d\n", s.Usage.TotalTokens)
+	}
+	b.WriteString("\n---\n")
+
+	for _, m := range s.Messages {
+		fmt.Fprintf(&b, "\n### %s\n\n%s\n", roleName(m.Role), strings.TrimSpace(m.Content))
+	}
+
+	return []byte(b.String())
+}
+
+// htmlTemplate 自包含的HTML导出模板，助手回复经markdown包渲染和清理，其余内容经html/template转义
+var htmlTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Title}}</title>
+<style>
+body { font-family: 'PingFang SC', 'Microsoft YaHei', sans-serif; background: #f5f7fb; color: #333; line-height: 1.6; margin: 0; padding: 20px; }
+.container { max-width: 900px; margin: 0 auto; background: #fff; border-radius: 12px; box-shadow: 0 8px 30px rgba(0,0,0,0.12); overflow: hidden; }
+header { background: linear-gradient(135deg, #4b6cb7 0%, #182848 100%); color: #fff; padding: 18px 20px; }
+header h1 { font-size: 1.3rem; margin: 0; }
+header p { font-size: 0.8rem; margin: 4px 0 0; opacity: 0.8; }
+.messages { padding: 20px; }
+.message { margin-bottom: 16px; max-width: 80%; }
+.message .role { font-size: 0.75rem; color: #a0a0a0; margin-bottom: 4px; }
+.message .content { padding: 12px 18px; border-radius: 18px; white-space: pre-wrap; word-wrap: break-word; }
+.user { margin-left: auto; }
+.user .content { background: #4b6cb7; color: #fff; border-bottom-right-radius: 4px; }
+.assistant .content, .system .content { background: #fff; border: 1px solid #e1e5f0; border-bottom-left-radius: 4px; }
+.system .content { background: #f9fafc; font-style: italic; }
+.content.markdown { white-space: normal; }
+.markdown > :first-child { margin-top: 0; }
+.markdown > :last-child { margin-bottom: 0; }
+.markdown pre { background: #f5f7fa; border: 1px solid #e1e5f0; border-radius: 8px; padding: 12px; overflow-x: auto; }
+.markdown code { font-family: 'SFMono-Regular', Consolas, 'Liberation Mono', Menlo, monospace; font-size: 0.9em; }
+.markdown table { border-collapse: collapse; }
+.markdown th, .markdown td { border: 1px solid #e1e5f0; padding: 4px 10px; }
+.markdown blockquote { margin: 8px 0; padding-left: 12px; border-left: 3px solid #c9d1e6; color: #666; }
+</style>
+</head>
+<body>
+<div class="container">
+<header>
+<h1>{{.Title}}</h1>
+<p>导出时间 {{.ExportedAt}}{{if .Model}} · 模型 {{.Model}}{{end}}</p>
+</header>
+<div class="messages">
+{{range .Messages}}<div class="message {{.Role}}">
+<div class="role">{{.Name}}</div>
+{{if .HTML}}<div class="content markdown">{{.HTML}}</div>{{else}}<div class="content">{{.Content}}</div>{{end}}
+</div>
+{{end}}</div>
+</div>
+</body>
+</html>
+`))
+
+// ExportHTML 导出为不依赖外部资源的HTML页面
+func ExportHTML(s *Session) ([]byte, error) {
+	type message struct {
+		Role    string
+		Name    string
+		Content string
+		HTML    template.HTML
+	}
+	data := struct {
+		Title      string
+		Model      string
+		ExportedAt string
+		Messages   []message
+	}{
+		Title:      s.Title,
+		Model:      s.Model,
+		ExportedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if data.Title == "" {
+		data.Title = "未命名对话"
+	}
+	for _, m := range s.Messages {
+		msg := message{Role: m.Role, Name: roleName(m.Role), Content: m.Content}
+		if m.Role == "assistant" {
+			// markdown.Render的输出已经过清理，可以直接插入页面
+			msg.HTML = template.HTML(markdown.Render(m.Content))
+		}
+		data.Messages = append(data.Messages, msg)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("渲染HTML失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// roleName 返回角色的显示名称
+func roleName(role string) string {
+	switch role {
+	case "user":
+		return "用户"
+	case "assistant":
+		return "助手"
+	case "system":
+		return "系统"
+	}
+	return role
+}
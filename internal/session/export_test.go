@@ -0,0 +1,43 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"GoBrowserAgent/internal/service/llm"
+)
+
+func TestExportJSONOmitsOwner(t *testing.T) {
+	s := New()
+	s.Owner = "password:alice"
+	s.Append(llm.ChatMessage{Role: "user", Content: "你好"})
+
+	data, err := ExportJSON(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "owner") || strings.Contains(string(data), "alice") {
+		t.Fatalf("export contains the owner: %s", data)
+	}
+	if s.Owner != "password:alice" {
+		t.Fatalf("export changed the session owner to %q", s.Owner)
+	}
+
+	imported, err := ImportJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Owner != "" || imported.ID == s.ID || imported.Metadata["imported_from"] != s.ID {
+		t.Fatalf("imported = %+v", imported)
+	}
+
+	// 旧版本导出的文件中的创建者被忽略
+	legacy := strings.Replace(string(data), `"title"`, `"owner": "password:mallory", "title"`, 1)
+	imported, err = ImportJSON([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Owner != "" {
+		t.Fatalf("imported owner = %q", imported.Owner)
+	}
+}
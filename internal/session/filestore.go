@@ -0,0 +1,343 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// retentionInterval 定期执行保留策略的间隔
+const retentionInterval = time.Hour
+
+// record 存储文件中的一条日志记录
+type record struct {
+	Op      string   `json:"op"`
+	ID      string   `json:"id,omitempty"`
+	Session *Session `json:"session,omitempty"`
+}
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// FileStore 基于单个本地文件的会话存储
+//
+// 文件是追加写入的JSONL日志，每次保存或删除追加一条记录并立即落盘；
+// 启动时回放日志重建内存数据和搜索索引，日志中的过期记录过多时重写为紧凑的快照。
+type FileStore struct {
+	*MemoryStore
+
+	config  Config
+	mu      sync.Mutex
+	file    *os.File
+	records int
+	closed  bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// OpenFileStore 打开或创建文件会话存储
+func OpenFileStore(config *Config) (*FileStore, error) {
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("创建会话存储目录失败: %v", err)
+		}
+	}
+
+	fs := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		config:      *config,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+
+	fs.applyRetention()
+
+	// 启动时重写一次，清理已删除的记录和中断写入留下的半行
+	fs.mu.Lock()
+	err := fs.compact()
+	fs.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go fs.retentionLoop()
+
+	logrus.Infof("已加载会话存储: %s, 共%d个会话", config.Path, len(fs.sessions))
+	return fs, nil
+}
+
+// load 回放日志文件
+func (fs *FileStore) load() error {
+	file, err := os.Open(fs.config.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开会话存储失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			logrus.Warnf("会话存储第%d行已损坏，已跳过: %v", lineNo, err)
+			continue
+		}
+		switch rec.Op {
+		case opPut:
+			if rec.Session != nil {
+				fs.MemoryStore.Save(rec.Session)
+			}
+		case opDelete:
+			fs.MemoryStore.Delete(rec.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取会话存储失败: %v", err)
+	}
+	return nil
+}
+
+// Save 实现Store，先写日志再更新内存
+func (fs *FileStore) Save(s *Session) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.append(record{Op: opPut, Session: s}); err != nil {
+		return err
+	}
+	if err := fs.MemoryStore.Save(s); err != nil {
+		return err
+	}
+	fs.maybeCompact()
+	return nil
+}
+
+// Delete 实现Store
+func (fs *FileStore) Delete(id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, err := fs.MemoryStore.Get(id); err != nil {
+		return err
+	}
+	if err := fs.append(record{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+	if err := fs.MemoryStore.Delete(id); err != nil {
+		return err
+	}
+	fs.maybeCompact()
+	return nil
+}
+
+// Close 实现Store，停止后台任务并关闭文件，可以重复调用
+func (fs *FileStore) Close() error {
+	fs.stopOnce.Do(func() { close(fs.stop) })
+	<-fs.done
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.closed = true
+	if fs.file == nil {
+		return nil
+	}
+	err := fs.file.Sync()
+	if closeErr := fs.file.Close(); err == nil {
+		err = closeErr
+	}
+	fs.file = nil
+	return err
+}
+
+// append 追加一条记录并落盘，调用方需持有fs.mu
+//
+// 之前的重写没能重新打开日志时在这里重试，避免之后的保存全部失败。
+func (fs *FileStore) append(rec record) error {
+	if fs.closed {
+		return fmt.Errorf("会话存储已关闭")
+	}
+	if fs.file == nil {
+		if err := fs.open(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("编码会话失败: %v", err)
+	}
+	if _, err := fs.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入会话存储失败: %v", err)
+	}
+	if err := fs.file.Sync(); err != nil {
+		return fmt.Errorf("写入会话存储失败: %v", err)
+	}
+	fs.records++
+	return nil
+}
+
+// maybeCompact 日志中的记录数远多于现存会话时重写，调用方需持有fs.mu
+func (fs *FileStore) maybeCompact() {
+	fs.MemoryStore.mu.RLock()
+	live := len(fs.sessions)
+	fs.MemoryStore.mu.RUnlock()
+
+	if fs.records > 2*live+100 {
+		if err := fs.compact(); err != nil {
+			logrus.Errorf("重写会话存储失败: %v", err)
+		}
+	}
+}
+
+// compact 将当前所有会话写入临时文件后原子替换日志，调用方需持有fs.mu
+func (fs *FileStore) compact() error {
+	tmpPath := fs.config.Path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+
+	fs.MemoryStore.mu.RLock()
+	sessions := make([]*Session, 0, len(fs.sessions))
+	for _, s := range fs.sessions {
+		sessions = append(sessions, s)
+	}
+	fs.MemoryStore.mu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.Before(sessions[j].UpdatedAt)
+	})
+
+	writer := bufio.NewWriter(tmp)
+	for _, s := range sessions {
+		line, err := json.Marshal(record{Op: opPut, Session: s})
+		if err == nil {
+			_, err = writer.Write(append(line, '\n'))
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("写入临时文件失败: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	tmp.Close()
+
+	// Windows上不能替换仍然打开的文件，先关闭日志；替换失败时重新打开原来的日志继续追加
+	if fs.file != nil {
+		fs.file.Close()
+		fs.file = nil
+	}
+	if err := os.Rename(tmpPath, fs.config.Path); err != nil {
+		os.Remove(tmpPath)
+		if openErr := fs.open(); openErr != nil {
+			logrus.Errorf("%v", openErr)
+		}
+		return fmt.Errorf("替换会话存储失败: %v", err)
+	}
+	if err := fs.open(); err != nil {
+		return err
+	}
+	fs.records = len(sessions)
+	return nil
+}
+
+// open 以追加方式打开日志文件，调用方需持有fs.mu
+func (fs *FileStore) open() error {
+	file, err := os.OpenFile(fs.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开会话存储失败: %v", err)
+	}
+	fs.file = file
+	return nil
+}
+
+// retentionLoop 定期执行保留策略
+func (fs *FileStore) retentionLoop() {
+	defer close(fs.done)
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fs.stop:
+			return
+		case <-ticker.C:
+			fs.applyRetention()
+		}
+	}
+}
+
+// applyRetention 删除超过保留期限或超出数量上限的会话
+//
+// 整个过程持有fs.mu，挑选和删除之间不会有Save更新会话，避免删掉刚刚保存的会话。
+func (fs *FileStore) applyRetention() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	summaries, _ := fs.MemoryStore.List("")
+
+	var expired []string
+	if fs.config.RetentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -fs.config.RetentionDays)
+		kept := summaries[:0]
+		for _, s := range summaries {
+			if s.UpdatedAt.Before(cutoff) {
+				expired = append(expired, s.ID)
+			} else {
+				kept = append(kept, s)
+			}
+		}
+		summaries = kept
+	}
+	// List按更新时间倒序，超出上限的部分是最久未更新的会话
+	if fs.config.MaxSessions > 0 && len(summaries) > fs.config.MaxSessions {
+		for _, s := range summaries[fs.config.MaxSessions:] {
+			expired = append(expired, s.ID)
+		}
+	}
+
+	if len(expired) == 0 {
+		return
+	}
+
+	// 先写删除记录再删内存中的会话，写入失败时保留会话，下次重试
+	for _, id := range expired {
+		if err := fs.append(record{Op: opDelete, ID: id}); err != nil {
+			logrus.Errorf("删除过期会话失败: %v", err)
+			return
+		}
+		fs.MemoryStore.Delete(id)
+	}
+	fs.maybeCompact()
+	logrus.Infof("按保留策略删除了%d个会话", len(expired))
+}
@@ -0,0 +1,158 @@
+package session
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"GoBrowserAgent/internal/service/llm"
+)
+
+func openTestStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	fs, err := OpenFileStore(&Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestFileStoreCloseTwice(t *testing.T) {
+	fs := openTestStore(t, filepath.Join(t.TempDir(), "sessions.jsonl"))
+	if err := fs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if err := fs.Save(New()); err == nil {
+		t.Fatal("Save after Close succeeded")
+	}
+}
+
+func TestFileStoreReopensLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.jsonl")
+	fs := openTestStore(t, path)
+	defer fs.Close()
+
+	s := New()
+	s.Append(llm.ChatMessage{Role: "user", Content: "hello"})
+	if err := fs.Save(s); err != nil {
+		t.Fatal(err)
+	}
+
+	// 日志替换失败且没能重新打开时，之后的保存应当重新打开日志
+	fs.mu.Lock()
+	fs.file.Close()
+	fs.file = nil
+	fs.mu.Unlock()
+
+	other := New()
+	if err := fs.Save(other); err != nil {
+		t.Fatalf("Save with the log closed: %v", err)
+	}
+	fs.Close()
+
+	reopened := openTestStore(t, path)
+	defer reopened.Close()
+	for _, id := range []string{s.ID, other.ID} {
+		if _, err := reopened.Get(id); err != nil {
+			t.Errorf("session %s lost: %v", id, err)
+		}
+	}
+}
+
+func TestFileStoreRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.jsonl")
+	fs := openTestStore(t, path)
+
+	old := New()
+	old.UpdatedAt = time.Now().AddDate(0, 0, -10)
+	recent := make([]*Session, 3)
+	for i := range recent {
+		recent[i] = New()
+		recent[i].UpdatedAt = time.Now().Add(time.Duration(i) * time.Minute)
+	}
+	for _, s := range append([]*Session{old}, recent...) {
+		if err := fs.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 日志没有打开时，删除记录也必须写入日志，否则重新打开后会话会复活
+	fs.mu.Lock()
+	fs.file.Close()
+	fs.file = nil
+	fs.mu.Unlock()
+	fs.config.RetentionDays = 7
+	fs.config.MaxSessions = 2
+	fs.applyRetention()
+	fs.Close()
+
+	reopened := openTestStore(t, path)
+	defer reopened.Close()
+	summaries, _ := reopened.List("")
+	if len(summaries) != 2 || summaries[0].ID != recent[2].ID || summaries[1].ID != recent[1].ID {
+		t.Fatalf("sessions after retention = %+v", summaries)
+	}
+}
+
+func TestSearchCJK(t *testing.T) {
+	store := NewMemoryStore()
+	sessions := map[string]string{
+		"login":   "登录失败，提示验证码错误",
+		"browser": "用浏览器打开GitHub页面后超时了",
+		"mixed":   "Chrome浏览器的CDP timeout设置",
+		"japan":   "ログインできません",
+		"english": "the quick brown fox",
+	}
+	ids := map[string]string{}
+	for name, content := range sessions {
+		s := New()
+		s.Append(llm.ChatMessage{Role: "user", Content: content})
+		store.Save(s)
+		ids[s.ID] = name
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"登录", []string{"login"}},
+		{"验证码错误", []string{"login"}},
+		{"录失", []string{"login"}},
+		{"浏览器", []string{"browser", "mixed"}},
+		{"浏览器 timeout", []string{"mixed"}},
+		{"TIMEOUT 设置", []string{"mixed"}},
+		{"github页面", []string{"browser"}},
+		{"chrome浏览器", []string{"mixed"}},
+		{"ログイン", []string{"japan"}},
+		{"页", []string{"browser"}},
+		{"登录成功", nil},
+		{"浏览 fox", nil},
+		{"quick fox", []string{"english"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := store.Search(tt.query, "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, ids[r.SessionID])
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for _, r := range results {
+				if r.Snippet == "" {
+					t.Errorf("Search(%q) returned an empty snippet", tt.query)
+				}
+			}
+		})
+	}
+}
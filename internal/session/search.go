@@ -0,0 +1,221 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchResult 搜索命中的会话
+type SearchResult struct {
+	SessionID string    `json:"session_id"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// MessageIndex 命中片段所在的消息下标，标题命中时为-1
+	MessageIndex int    `json:"message_index"`
+	Role         string `json:"role,omitempty"`
+	Snippet      string `json:"snippet"`
+	Hits         int    `json:"hits"`
+}
+
+// index 基于分词的倒排索引
+//
+// 中日韩文字按相邻两个字切分（bigram），单个汉字作为补充词条，其他文字按单词切分并转为小写，
+// 因此无需中文分词词典也能检索任意中文子串。
+type index struct {
+	postings map[string]map[string]struct{}
+	terms    map[string][]string
+}
+
+func newIndex() *index {
+	return &index{
+		postings: make(map[string]map[string]struct{}),
+		terms:    make(map[string][]string),
+	}
+}
+
+// add 重新索引会话
+func (idx *index) add(s *Session) {
+	idx.remove(s.ID)
+
+	seen := map[string]struct{}{}
+	for _, token := range tokenize(s.Title) {
+		seen[token] = struct{}{}
+	}
+	for _, m := range s.Messages {
+		for _, token := range tokenize(m.Content) {
+			seen[token] = struct{}{}
+		}
+	}
+
+	terms := make([]string, 0, len(seen))
+	for token := range seen {
+		ids, ok := idx.postings[token]
+		if !ok {
+			ids = make(map[string]struct{})
+			idx.postings[token] = ids
+		}
+		ids[s.ID] = struct{}{}
+		terms = append(terms, token)
+	}
+	idx.terms[s.ID] = terms
+}
+
+// remove 从索引中移除会话
+func (idx *index) remove(id string) {
+	for _, token := range idx.terms[id] {
+		delete(idx.postings[token], id)
+		if len(idx.postings[token]) == 0 {
+			delete(idx.postings, token)
+		}
+	}
+	delete(idx.terms, id)
+}
+
+// candidates 返回包含查询全部词条的会话ID
+func (idx *index) candidates(query string) []string {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	// 从最短的倒排列表开始求交集
+	sort.Slice(tokens, func(i, j int) bool {
+		return len(idx.postings[tokens[i]]) < len(idx.postings[tokens[j]])
+	})
+
+	var result []string
+	for id := range idx.postings[tokens[0]] {
+		matched := true
+		for _, token := range tokens[1:] {
+			if _, ok := idx.postings[token][id]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// tokenize 将文本切分为索引词条
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var prevCJK rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			tokens = append(tokens, string(r))
+			if prevCJK != 0 {
+				tokens = append(tokens, string([]rune{prevCJK, r}))
+			}
+			prevCJK = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			prevCJK = 0
+			word = append(word, r)
+		default:
+			prevCJK = 0
+			flushWord()
+		}
+	}
+	flushWord()
+	return tokens
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// queryTerms 按空白拆分查询，每一项都必须作为子串出现
+func queryTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// matchSession 在会话中逐条确认查询词并生成片段，未命中全部查询词时返回false
+func matchSession(s *Session, terms []string) (SearchResult, bool) {
+	result := SearchResult{
+		SessionID:    s.ID,
+		Title:        s.Title,
+		UpdatedAt:    s.UpdatedAt,
+		MessageIndex: -1,
+	}
+
+	found := make(map[string]bool, len(terms))
+	check := func(text string) int {
+		lower := strings.ToLower(text)
+		hits := 0
+		for _, term := range terms {
+			if n := strings.Count(lower, term); n > 0 {
+				found[term] = true
+				hits += n
+			}
+		}
+		return hits
+	}
+
+	bestHits := check(s.Title)
+	result.Hits = bestHits
+	if bestHits > 0 {
+		result.Snippet = s.Title
+	}
+	for i, m := range s.Messages {
+		hits := check(m.Content)
+		result.Hits += hits
+		if hits > bestHits {
+			bestHits = hits
+			result.MessageIndex = i
+			result.Role = m.Role
+			result.Snippet = snippet(m.Content, terms)
+		}
+	}
+
+	return result, len(found) == len(terms)
+}
+
+// snippet 截取第一个命中词附近的文本
+func snippet(text string, terms []string) string {
+	const radius = 30
+
+	lower := strings.ToLower(text)
+	pos := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (pos < 0 || i < pos) {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		pos = 0
+	}
+	// ToLower可能改变字节长度，按字符位置换算回原文
+	runePos := utf8.RuneCountInString(lower[:pos])
+
+	runes := []rune(text)
+	if runePos > len(runes) {
+		runePos = len(runes)
+	}
+	start, end := runePos-radius, runePos+radius*2
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(runes) {
+		end, suffix = len(runes), ""
+	}
+	return prefix + strings.Join(strings.Fields(string(runes[start:end])), " ") + suffix
+}
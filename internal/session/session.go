@@ -0,0 +1,235 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"GoBrowserAgent/internal/service/llm"
+)
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("会话不存在")
+
+// Session 一次对话及其元数据
+type Session struct {
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	Model     string            `json:"model,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Messages  []llm.ChatMessage `json:"messages"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Usage     llm.Usage         `json:"usage"`
+	// Owner 创建会话的调用方标识，例如password:alice；未启用认证时为空
+	Owner string `json:"owner,omitempty"`
+}
+
+// Summary 会话列表中展示的摘要信息
+type Summary struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	MessageCount int       `json:"message_count"`
+}
+
+// Store 会话存储
+type Store interface {
+	// Get 返回会话副本，不存在时返回ErrNotFound
+	Get(id string) (*Session, error)
+	// Save 创建或覆盖会话
+	Save(s *Session) error
+	// Delete 删除会话
+	Delete(id string) error
+	// List 按更新时间倒序返回会话摘要，owner不为空时只返回该调用方的会话
+	List(owner string) ([]Summary, error)
+	// Search 全文搜索会话标题和消息内容，按命中次数和更新时间排序，owner不为空时只搜索该调用方的会话
+	Search(query, owner string, limit int) ([]SearchResult, error)
+	// Close 将未落盘的数据写入存储并释放资源
+	Close() error
+}
+
+// New 创建一个空会话
+func New() *Session {
+	now := time.Now()
+	return &Session{
+		ID:        NewID(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Metadata:  map[string]string{},
+	}
+}
+
+// NewID 生成随机会话ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand在受支持的平台上不会失败，退化为时间戳保证可用
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))[:32]
+	}
+	return hex.EncodeToString(b)
+}
+
+// Append 追加消息并更新时间，首条用户消息作为标题
+func (s *Session) Append(messages ...llm.ChatMessage) {
+	for _, m := range messages {
+		if s.Title == "" && m.Role == "user" {
+			s.Title = makeTitle(m.Content)
+		}
+		s.Messages = append(s.Messages, m)
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// AddUsage 累加token用量
+func (s *Session) AddUsage(usage llm.Usage) {
+	s.Usage.PromptTokens += usage.PromptTokens
+	s.Usage.CompletionTokens += usage.CompletionTokens
+	s.Usage.TotalTokens += usage.TotalTokens
+}
+
+// Summary 返回会话摘要
+func (s *Session) Summary() Summary {
+	return Summary{
+		ID:           s.ID,
+		Title:        s.Title,
+		CreatedAt:    s.CreatedAt,
+		UpdatedAt:    s.UpdatedAt,
+		MessageCount: len(s.Messages),
+	}
+}
+
+// Clone 深拷贝会话
+func (s *Session) Clone() *Session {
+	c := *s
+	c.Messages = append([]llm.ChatMessage(nil), s.Messages...)
+	c.Metadata = make(map[string]string, len(s.Metadata))
+	for k, v := range s.Metadata {
+		c.Metadata[k] = v
+	}
+	return &c
+}
+
+// makeTitle 截取消息开头作为标题
+func makeTitle(content string) string {
+	title := strings.Join(strings.Fields(content), " ")
+	const maxRunes = 30
+	if utf8.RuneCountInString(title) > maxRunes {
+		runes := []rune(title)
+		title = string(runes[:maxRunes]) + "…"
+	}
+	return title
+}
+
+// MemoryStore 内存中的会话存储，进程退出后数据丢失
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+	index    *index
+}
+
+// NewMemoryStore 创建内存会话存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]*Session),
+		index:    newIndex(),
+	}
+}
+
+// Get 实现Store
+func (m *MemoryStore) Get(id string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.Clone(), nil
+}
+
+// Save 实现Store
+func (m *MemoryStore) Save(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := s.Clone()
+	m.sessions[s.ID] = c
+	m.index.add(c)
+	return nil
+}
+
+// Delete 实现Store
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.sessions, id)
+	m.index.remove(id)
+	return nil
+}
+
+// List 实现Store
+func (m *MemoryStore) List(owner string) ([]Summary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	summaries := make([]Summary, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if owner != "" && s.Owner != owner {
+			continue
+		}
+		summaries = append(summaries, s.Summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+	return summaries, nil
+}
+
+// Search 实现Store
+func (m *MemoryStore) Search(query, owner string, limit int) ([]SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	results := []SearchResult{}
+	for _, id := range m.index.candidates(query) {
+		s := m.sessions[id]
+		if owner != "" && s.Owner != owner {
+			continue
+		}
+		if result, ok := matchSession(s, terms); ok {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Hits != results[j].Hits {
+			return results[i].Hits > results[j].Hits
+		}
+		return results[i].UpdatedAt.After(results[j].UpdatedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Close 实现Store，内存存储无需释放资源
+func (m *MemoryStore) Close() error {
+	return nil
+}
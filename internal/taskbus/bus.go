@@ -0,0 +1,300 @@
+// Package taskbus 在后台任务和界面之间传递任务事件、审批请求、中途补充的指令和取消操作
+//
+// 任务通过Start登记后获得Task，用它发布进度、请求用户审批和接收用户输入；
+// 界面（例如WebSocket连接）通过Subscribe接收同一用户的任务事件，并通过Resolve、SendInput和Cancel回应。
+package taskbus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 事件类型
+const (
+	EventStarted         = "started"
+	EventProgress        = "progress"
+	EventApprovalRequest = "approval_request"
+	EventApprovalResult  = "approval_result"
+	EventInput           = "input"
+	EventFinished        = "finished"
+	EventFailed          = "failed"
+	EventCancelled       = "cancelled"
+)
+
+// subscriberBuffer 每个订阅者缓存的事件数，订阅者处理不过来时丢弃事件而不阻塞任务
+const subscriberBuffer = 256
+
+var (
+	// ErrNotFound 任务不存在，或者不属于当前用户
+	ErrNotFound = errors.New("任务不存在或已结束")
+	// ErrApprovalNotFound 审批请求不存在、已处理或者不属于当前用户
+	ErrApprovalNotFound = errors.New("审批请求不存在或已处理")
+	// ErrInputBusy 任务还没有取走之前补充的指令
+	ErrInputBusy = errors.New("任务尚未处理之前的指令，请稍后再试")
+)
+
+// Event 任务事件
+type Event struct {
+	TaskID string `json:"task_id"`
+	// Owner 发起任务的用户，只推送给同一用户的订阅者
+	Owner string `json:"-"`
+	Type  string `json:"type"`
+	// Message 展示给用户的说明
+	Message string `json:"message,omitempty"`
+	// ApprovalID 审批请求的ID，仅approval_request和approval_result事件有
+	ApprovalID string                 `json:"approval_id,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Time       time.Time              `json:"time"`
+}
+
+// ApprovalResult 用户对审批请求的答复
+type ApprovalResult struct {
+	Approved bool   `json:"approved"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Bus 任务事件总线
+type Bus struct {
+	mu          sync.Mutex
+	tasks       map[string]*Task
+	approvals   map[string]*pendingApproval
+	subscribers map[int]*subscriber
+	nextSubID   int
+}
+
+type subscriber struct {
+	owner  string
+	events chan Event
+}
+
+type pendingApproval struct {
+	task   *Task
+	result chan ApprovalResult
+}
+
+// New 创建任务事件总线
+func New() *Bus {
+	return &Bus{
+		tasks:       make(map[string]*Task),
+		approvals:   make(map[string]*pendingApproval),
+		subscribers: make(map[int]*subscriber),
+	}
+}
+
+// Task 已登记的任务
+type Task struct {
+	ID    string
+	Owner string
+
+	bus    *Bus
+	ctx    context.Context
+	cancel context.CancelFunc
+	inputs chan string
+	once   sync.Once
+}
+
+// Start 登记任务并发布started事件，任务结束时必须调用Finish
+//
+// 返回的任务context在用户取消或ctx取消时被取消。
+func (b *Bus) Start(ctx context.Context, owner, message string) *Task {
+	taskCtx, cancel := context.WithCancel(ctx)
+	t := &Task{
+		ID:     newID(),
+		Owner:  owner,
+		bus:    b,
+		ctx:    taskCtx,
+		cancel: cancel,
+		inputs: make(chan string, 16),
+	}
+
+	b.mu.Lock()
+	b.tasks[t.ID] = t
+	b.mu.Unlock()
+
+	t.Publish(EventStarted, message, nil)
+	return t
+}
+
+// Context 返回任务的context
+func (t *Task) Context() context.Context {
+	return t.ctx
+}
+
+// Inputs 返回用户在任务进行中补充的指令
+func (t *Task) Inputs() <-chan string {
+	return t.inputs
+}
+
+// Publish 发布任务事件
+func (t *Task) Publish(eventType, message string, data map[string]interface{}) {
+	t.bus.publish(Event{
+		TaskID:  t.ID,
+		Owner:   t.Owner,
+		Type:    eventType,
+		Message: message,
+		Data:    data,
+	})
+}
+
+// RequestApproval 请求用户批准一个操作，阻塞到用户答复或任务被取消
+func (t *Task) RequestApproval(action, detail string) (ApprovalResult, error) {
+	pending := &pendingApproval{task: t, result: make(chan ApprovalResult, 1)}
+	id := newID()
+
+	t.bus.mu.Lock()
+	t.bus.approvals[id] = pending
+	t.bus.mu.Unlock()
+	defer func() {
+		t.bus.mu.Lock()
+		delete(t.bus.approvals, id)
+		t.bus.mu.Unlock()
+	}()
+
+	t.bus.publish(Event{
+		TaskID:     t.ID,
+		Owner:      t.Owner,
+		Type:       EventApprovalRequest,
+		Message:    action,
+		ApprovalID: id,
+		Data:       map[string]interface{}{"detail": detail},
+	})
+
+	select {
+	case result := <-pending.result:
+		return result, nil
+	case <-t.ctx.Done():
+		return ApprovalResult{}, t.ctx.Err()
+	}
+}
+
+// Finish 结束任务并发布finished、failed或cancelled事件
+func (t *Task) Finish(err error) {
+	t.once.Do(func() {
+		t.bus.mu.Lock()
+		delete(t.bus.tasks, t.ID)
+		t.bus.mu.Unlock()
+
+		switch {
+		case err == nil:
+			t.Publish(EventFinished, "", nil)
+		case errors.Is(err, context.Canceled):
+			t.Publish(EventCancelled, "", nil)
+		default:
+			t.Publish(EventFailed, err.Error(), nil)
+		}
+		t.cancel()
+	})
+}
+
+// Subscribe 订阅某个用户的任务事件，返回的函数用于取消订阅
+func (b *Bus) Subscribe(owner string) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextSubID
+	b.nextSubID++
+	sub := &subscriber{owner: owner, events: make(chan Event, subscriberBuffer)}
+	b.subscribers[id] = sub
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, id)
+			b.mu.Unlock()
+			close(sub.events)
+		})
+	}
+}
+
+// Cancel 取消用户的任务
+func (b *Bus) Cancel(owner, taskID string) error {
+	t, err := b.task(owner, taskID)
+	if err != nil {
+		return err
+	}
+	t.cancel()
+	return nil
+}
+
+// SendInput 向进行中的任务补充指令
+func (b *Bus) SendInput(owner, taskID, text string) error {
+	t, err := b.task(owner, taskID)
+	if err != nil {
+		return err
+	}
+	select {
+	case t.inputs <- text:
+		t.Publish(EventInput, text, nil)
+		return nil
+	default:
+		return ErrInputBusy
+	}
+}
+
+// Resolve 答复审批请求
+func (b *Bus) Resolve(owner, approvalID string, result ApprovalResult) error {
+	b.mu.Lock()
+	pending, ok := b.approvals[approvalID]
+	if ok && pending.task.Owner == owner {
+		delete(b.approvals, approvalID)
+	}
+	b.mu.Unlock()
+	if !ok || pending.task.Owner != owner {
+		return ErrApprovalNotFound
+	}
+
+	pending.result <- result
+	b.publish(Event{
+		TaskID:     pending.task.ID,
+		Owner:      owner,
+		Type:       EventApprovalResult,
+		ApprovalID: approvalID,
+		Data:       map[string]interface{}{"approved": result.Approved, "comment": result.Comment},
+	})
+	return nil
+}
+
+// task 查找属于owner的任务
+func (b *Bus) task(owner, taskID string) (*Task, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t, ok := b.tasks[taskID]
+	if !ok || t.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// publish 把事件推送给同一用户的订阅者
+func (b *Bus) publish(e Event) {
+	e.Time = time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subscribers {
+		if sub.owner != e.Owner {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			logrus.Warnf("任务事件订阅者处理过慢，丢弃事件: %s %s", e.TaskID, e.Type)
+		}
+	}
+}
+
+// newID 生成随机ID
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
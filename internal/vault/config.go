@@ -0,0 +1,56 @@
+package vault
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// KeyEnv 保存密钥的环境变量，值为32字节密钥的base64编码；设置后不读取密钥文件
+const KeyEnv = "GOBROWSERAGENT_VAULT_KEY"
+
+// Config 存储凭据保险库配置
+type Config struct {
+	// Path 加密保存站点配置的文件
+	Path string `json:"path"`
+	// KeyFile 密钥文件，第一次保存时自动生成，权限为0600；设置了GOBROWSERAGENT_VAULT_KEY时不使用
+	KeyFile string `json:"key_file"`
+	// StateDir 加密保存登录状态和state save保存的浏览器状态的目录，每个名称一个文件
+	StateDir string `json:"state_dir"`
+	// SessionHours 站点配置没有设置有效期时，登录状态保留的小时数
+	SessionHours int `json:"session_hours"`
+}
+
+// LoadConfig 从配置文件加载凭据保险库配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		Vault *Config `json:"vault"`
+	}{
+		Vault: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.Vault, nil
+}
+
+// GetDefaultConfig 获取默认配置
+func GetDefaultConfig() *Config {
+	return &Config{
+		Path:         filepath.Join("data", "vault.enc"),
+		KeyFile:      filepath.Join("data", "vault.key"),
+		StateDir:     filepath.Join("data", "states"),
+		SessionHours: 12,
+	}
+}
@@ -0,0 +1,177 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fileMagic 加密文件的开头，同时是GCM附加数据的前缀，防止把其他文件当作保险库解密
+const fileMagic = "GBAVAULT1\n"
+
+// profilesContext 站点配置文件的附加数据，状态文件使用stateContext
+const profilesContext = "profiles"
+
+// stateContext 返回名为name的状态文件的附加数据，复制或改名后的状态文件无法解密
+func stateContext(name string) string {
+	return "state:" + name
+}
+
+const keySize = 32
+
+// ErrNoKey 还没有生成密钥，说明保险库中还没有保存过任何内容
+var ErrNoKey = errors.New("没有找到保险库密钥")
+
+// loadKey 读取密钥，优先使用环境变量；create为true且密钥文件不存在时生成新的密钥文件
+//
+// 密钥文件中是base64编码的密钥，内容可以直接作为GOBROWSERAGENT_VAULT_KEY的值，
+// 把它发给定时任务所在的机器即可解密同一份保险库。
+func (v *Vault) loadKey(create bool) ([]byte, error) {
+	v.keyMu.Lock()
+	defer v.keyMu.Unlock()
+	if v.key != nil {
+		return v.key, nil
+	}
+
+	if encoded := os.Getenv(KeyEnv); encoded != "" {
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("环境变量%s无效: %v", KeyEnv, err)
+		}
+		v.key = key
+		return key, nil
+	}
+
+	if v.config.KeyFile == "" {
+		return nil, fmt.Errorf("%w: 未配置key_file，也没有设置环境变量%s", ErrNoKey, KeyEnv)
+	}
+	data, err := os.ReadFile(v.config.KeyFile)
+	switch {
+	case err == nil:
+		key, err := decodeKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("密钥文件%s无效: %v", v.config.KeyFile, err)
+		}
+		v.key = key
+		return key, nil
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("读取密钥文件失败: %v", err)
+	case !create:
+		return nil, fmt.Errorf("%w: %s不存在，也没有设置环境变量%s", ErrNoKey, v.config.KeyFile, KeyEnv)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(v.config.KeyFile), 0700); err != nil {
+		return nil, fmt.Errorf("创建密钥目录失败: %v", err)
+	}
+	// O_EXCL避免两个进程同时生成不同的密钥
+	f, err := os.OpenFile(v.config.KeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("创建密钥文件失败: %v", err)
+	}
+	_, err = f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	logrus.Infof("已生成保险库密钥: %s，请妥善备份，丢失后无法解密保存的密码和登录状态", v.config.KeyFile)
+	v.key = key
+	return key, nil
+}
+
+// decodeKey 解码base64编码的密钥
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的base64: %v", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("密钥应为%d字节，实际为%d字节", keySize, len(key))
+	}
+	return key, nil
+}
+
+// seal 用AES-256-GCM加密，结果为fileMagic、随机nonce和密文
+//
+// context标明文件的用途，与fileMagic一起作为附加数据，解密时必须给出相同的context。
+func seal(key, plaintext []byte, context string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %v", err)
+	}
+	out := append([]byte(fileMagic), nonce...)
+	return gcm.Seal(out, nonce, plaintext, []byte(fileMagic+context)), nil
+}
+
+// unseal 解密seal的结果，context必须与加密时相同
+func unseal(key, data []byte, context string) ([]byte, error) {
+	if !strings.HasPrefix(string(data), fileMagic) {
+		return nil, fmt.Errorf("不是保险库加密的文件")
+	}
+	data = data[len(fileMagic):]
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("文件已损坏")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(fileMagic+context))
+	if err != nil {
+		return nil, fmt.Errorf("解密失败，密钥不正确或文件已损坏")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFile 先写临时文件再重命名，写入中断时不会留下半个文件
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入%s失败: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/pkg/browser"
+	"GoBrowserAgent/pkg/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stateExt 状态文件的扩展名
+const stateExt = ".state"
+
+var (
+	// ErrStateNotFound 没有保存过该名称的状态
+	ErrStateNotFound = errors.New("没有保存的状态")
+	// ErrStateExpired 保存的状态已经过期
+	ErrStateExpired = errors.New("保存的状态已过期")
+)
+
+// stateFile 状态文件解密后的内容
+type stateFile struct {
+	Name      string                `json:"name"`
+	SavedAt   time.Time             `json:"saved_at"`
+	ExpiresAt time.Time             `json:"expires_at"`
+	State     *browser.StorageState `json:"state"`
+}
+
+// StateInfo 保存的状态的概要，不包含Cookie和存储的值
+type StateInfo struct {
+	Name      string
+	SavedAt   time.Time
+	ExpiresAt time.Time
+	Cookies   int
+	// Origins 保存了localStorage或sessionStorage的来源
+	Origins []string
+}
+
+// Expired 判断状态在now时是否已经过期
+func (i *StateInfo) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+func (f *stateFile) info() *StateInfo {
+	info := &StateInfo{Name: f.Name, SavedAt: f.SavedAt, ExpiresAt: f.ExpiresAt}
+	if f.State != nil {
+		info.Cookies = len(f.State.Cookies)
+		for _, o := range f.State.Origins {
+			info.Origins = append(info.Origins, o.Origin)
+		}
+	}
+	return info
+}
+
+// statePath 返回状态文件的路径，名称只能是站点名称的格式，防止写到StateDir之外
+func (v *Vault) statePath(name string) (string, error) {
+	if !parser.IsProfileName(name) {
+		return "", fmt.Errorf("无效的名称%q，只能包含字母、数字、_和-", name)
+	}
+	if v.config.StateDir == "" {
+		return "", fmt.Errorf("未配置状态目录state_dir")
+	}
+	return filepath.Join(v.config.StateDir, name+stateExt), nil
+}
+
+// SaveState 加密保存状态，返回过期时间
+//
+// ttl不大于0时使用配置中的session_hours。Cookie都有过期时间时，状态最晚在最后一个Cookie过期时过期。
+func (v *Vault) SaveState(name string, state *browser.StorageState, ttl time.Duration) (time.Time, error) {
+	path, err := v.statePath(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ttl <= 0 {
+		ttl = time.Duration(v.config.SessionHours) * time.Hour
+	}
+	now := time.Now()
+	expires := now.Add(ttl)
+	if cookiesExpire := state.CookiesExpire(); !cookiesExpire.IsZero() && cookiesExpire.Before(expires) {
+		logrus.Infof("%s的Cookie将在%s全部过期，状态的有效期随之缩短", name, cookiesExpire.Format("2006-01-02 15:04:05"))
+		expires = cookiesExpire
+	}
+
+	key, err := v.loadKey(true)
+	if err != nil {
+		return time.Time{}, err
+	}
+	plaintext, err := json.Marshal(stateFile{Name: name, SavedAt: now, ExpiresAt: expires, State: state})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("序列化状态失败: %v", err)
+	}
+	data, err := seal(key, plaintext, stateContext(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := writeFile(path, data); err != nil {
+		return time.Time{}, fmt.Errorf("保存状态失败: %v", err)
+	}
+	logrus.Debugf("已保存%s的状态: %d个Cookie，%d个来源的存储", name, len(state.Cookies), len(state.Origins))
+	return expires, nil
+}
+
+// readState 读取并解密名为name的状态文件，不检查是否过期
+func (v *Vault) readState(name string) (*stateFile, error) {
+	path, err := v.statePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := v.loadKey(false)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := unseal(key, data, stateContext(name))
+	if err != nil {
+		return nil, fmt.Errorf("打开状态文件%s失败: %v", path, err)
+	}
+	var file stateFile
+	if err := json.Unmarshal(plaintext, &file); err != nil {
+		return nil, fmt.Errorf("解析状态文件%s失败: %v", path, err)
+	}
+	if file.Name != name {
+		return nil, fmt.Errorf("状态文件%s保存的是%s的状态", path, file.Name)
+	}
+	if file.State == nil {
+		return nil, fmt.Errorf("状态文件%s中没有状态", path)
+	}
+	return &file, nil
+}
+
+// OpenState 读取状态和过期时间，不存在时返回ErrStateNotFound，已经过期时返回ErrStateExpired
+func (v *Vault) OpenState(name string) (*browser.StorageState, time.Time, error) {
+	file, err := v.readState(name)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, fmt.Errorf("%w: %s", ErrStateNotFound, name)
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if file.info().Expired(time.Now()) {
+		return nil, file.ExpiresAt, fmt.Errorf("%w: %s已于%s过期", ErrStateExpired, name, file.ExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+	return file.State, file.ExpiresAt, nil
+}
+
+// LoadState 读取登录状态，没有保存过或者已经过期时返回nil，过期的文件会被删除
+func (v *Vault) LoadState(name string) (*browser.StorageState, error) {
+	state, _, err := v.OpenState(name)
+	switch {
+	case errors.Is(err, ErrStateNotFound):
+		return nil, nil
+	case errors.Is(err, ErrStateExpired):
+		logrus.Infof("%v", err)
+		return nil, v.DeleteState(name)
+	}
+	return state, err
+}
+
+// DeleteState 删除保存的状态，不存在时不返回错误
+func (v *Vault) DeleteState(name string) error {
+	path, err := v.statePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除状态失败: %v", err)
+	}
+	return nil
+}
+
+// States 返回按名称排序的所有状态的概要，包括已经过期的状态；无法解密的文件跳过并记录警告
+func (v *Vault) States() ([]*StateInfo, error) {
+	if v.config.StateDir == "" {
+		return nil, fmt.Errorf("未配置状态目录state_dir")
+	}
+	entries, err := os.ReadDir(v.config.StateDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取状态目录失败: %v", err)
+	}
+	var infos []*StateInfo
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), stateExt)
+		if !ok || entry.IsDir() || !parser.IsProfileName(name) {
+			continue
+		}
+		file, err := v.readState(name)
+		if err != nil {
+			logrus.Warnf("%v", err)
+			continue
+		}
+		info := file.info()
+		info.Name = name
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
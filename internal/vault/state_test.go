@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"GoBrowserAgent/pkg/browser"
+)
+
+func openTestVault(t *testing.T) *Vault {
+	t.Helper()
+	t.Setenv(KeyEnv, "")
+	dir := t.TempDir()
+	v, err := Open(&Config{
+		Path:     filepath.Join(dir, "vault.enc"),
+		KeyFile:  filepath.Join(dir, "vault.key"),
+		StateDir: filepath.Join(dir, "states"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestStateBoundToName(t *testing.T) {
+	v := openTestVault(t)
+	state := &browser.StorageState{Cookies: []browser.Cookie{{Name: "sid", Value: "admin", Domain: "example.com"}}}
+	if _, err := v.SaveState("admin", state, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := v.OpenState("admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 把admin的状态文件复制为crm的状态文件后不能被当作crm的状态读取
+	data, err := os.ReadFile(filepath.Join(v.config.StateDir, "admin"+stateExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(v.config.StateDir, "crm"+stateExt), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := v.OpenState("crm"); err == nil {
+		t.Fatal("copied state file was accepted under another name")
+	}
+	infos, err := v.States()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name != "admin" {
+		t.Fatalf("States() = %+v, want only admin", infos)
+	}
+
+	// 状态文件也不能冒充站点配置文件
+	if err := os.WriteFile(v.config.Path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Profiles(); err == nil {
+		t.Fatal("state file was accepted as the profile vault")
+	}
+}
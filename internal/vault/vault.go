@@ -0,0 +1,266 @@
+// Package vault 加密保存登录网站用的站点配置（地址、用户名、密码和输入框）以及登录后的浏览器状态
+//
+// 站点配置保存在一个文件中，登录状态每个站点一个文件，都使用AES-256-GCM加密。
+// 密钥来自环境变量GOBROWSERAGENT_VAULT_KEY或密钥文件，脚本和命令中只需要写站点名称，不再出现明文密码。
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/pkg/parser"
+)
+
+// ErrNotFound 没有匹配的站点配置
+var ErrNotFound = errors.New("没有找到站点配置")
+
+// Profile 一个网站的登录配置
+type Profile struct {
+	// Name 站点名称，login命令和保存的登录状态都使用这个名称
+	Name string `json:"name"`
+	// URL 登录页地址，login命令给出地址时按主机名匹配站点
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// UsernameSelector、PasswordSelector、SubmitSelector 输入框和登录按钮，为空时自动查找
+	UsernameSelector string `json:"username_selector,omitempty"`
+	PasswordSelector string `json:"password_selector,omitempty"`
+	SubmitSelector   string `json:"submit_selector,omitempty"`
+	// Success 登录成功的条件，写法与wait命令相同，例如"for url ~ /home"
+	Success string `json:"success,omitempty"`
+	// SessionTTL 登录状态的有效期，为0时使用配置中的session_hours
+	SessionTTL time.Duration `json:"session_ttl,omitempty"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// Validate 检查名称、地址和成功条件
+func (p *Profile) Validate() error {
+	if !parser.IsProfileName(p.Name) {
+		return fmt.Errorf("无效的站点名称%q，只能包含字母、数字、_和-", p.Name)
+	}
+	if p.URL == "" {
+		return fmt.Errorf("站点%s缺少登录页地址", p.Name)
+	}
+	normalized, err := parser.NormalizeURL(p.URL)
+	if err != nil {
+		return err
+	}
+	p.URL = normalized
+	if p.Success != "" {
+		if _, err := parser.ParseSuccessCondition(p.Success); err != nil {
+			return err
+		}
+	}
+	if p.SessionTTL < 0 {
+		return fmt.Errorf("登录状态的有效期不能为负数")
+	}
+	return nil
+}
+
+// profileFile 站点配置文件解密后的内容
+type profileFile struct {
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+// Vault 凭据保险库
+//
+// 每次读取都重新解密文件，交互模式中也能看到另一个进程用vault set保存的修改。
+type Vault struct {
+	config Config
+
+	// mu 保护站点配置文件的读改写
+	mu sync.Mutex
+
+	keyMu sync.Mutex
+	key   []byte
+}
+
+// Open 根据配置打开保险库，文件和密钥在第一次保存时才创建
+func Open(config *Config) (*Vault, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("未配置保险库文件路径")
+	}
+	if config.SessionHours <= 0 {
+		config.SessionHours = GetDefaultConfig().SessionHours
+	}
+	return &Vault{config: *config}, nil
+}
+
+// SessionTTL 返回站点登录状态的有效期
+func (v *Vault) SessionTTL(p *Profile) time.Duration {
+	if p.SessionTTL > 0 {
+		return p.SessionTTL
+	}
+	return time.Duration(v.config.SessionHours) * time.Hour
+}
+
+// load 读取并解密站点配置，文件不存在时返回空的配置
+func (v *Vault) load() (map[string]*Profile, error) {
+	data, err := os.ReadFile(v.config.Path)
+	if os.IsNotExist(err) {
+		return map[string]*Profile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取保险库失败: %v", err)
+	}
+	key, err := v.loadKey(false)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := unseal(key, data, profilesContext)
+	if err != nil {
+		return nil, fmt.Errorf("打开保险库%s失败: %v", v.config.Path, err)
+	}
+	var file profileFile
+	if err := json.Unmarshal(plaintext, &file); err != nil {
+		return nil, fmt.Errorf("解析保险库失败: %v", err)
+	}
+	if file.Profiles == nil {
+		file.Profiles = map[string]*Profile{}
+	}
+	return file.Profiles, nil
+}
+
+// save 加密并写入站点配置
+func (v *Vault) save(profiles map[string]*Profile) error {
+	key, err := v.loadKey(true)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(profileFile{Profiles: profiles})
+	if err != nil {
+		return fmt.Errorf("序列化站点配置失败: %v", err)
+	}
+	data, err := seal(key, plaintext, profilesContext)
+	if err != nil {
+		return err
+	}
+	return writeFile(v.config.Path, data)
+}
+
+// Profiles 返回按名称排序的所有站点配置
+func (v *Vault) Profiles() ([]*Profile, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	profiles, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Profile, 0, len(profiles))
+	for _, p := range profiles {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Profile 按名称返回站点配置
+func (v *Vault) Profile(name string) (*Profile, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	profiles, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return p, nil
+}
+
+// Put 添加或替换站点配置
+func (v *Vault) Put(p *Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	profiles, err := v.load()
+	if err != nil {
+		return err
+	}
+	saved := *p
+	saved.UpdatedAt = time.Now()
+	profiles[p.Name] = &saved
+	return v.save(profiles)
+}
+
+// Delete 删除站点配置和保存的登录状态
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	profiles, err := v.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := profiles[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(profiles, name)
+	if err := v.save(profiles); err != nil {
+		return err
+	}
+	return v.DeleteState(name)
+}
+
+// Lookup 按名称或登录页地址查找站点配置
+//
+// 给出地址时匹配主机名和端口相同的站点，有多个时选登录页路径是地址路径前缀且最长的一个，
+// 仍然无法区分时返回错误，需要改用站点名称。
+func (v *Vault) Lookup(site string) (*Profile, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	profiles, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+	if parser.IsProfileName(site) {
+		if p, ok := profiles[site]; ok {
+			return p, nil
+		}
+		return nil, fmt.Errorf("%w: %s，可以用vault set添加", ErrNotFound, site)
+	}
+
+	target, err := url.Parse(site)
+	if err != nil {
+		return nil, fmt.Errorf("%q不是有效的地址", site)
+	}
+	var best []*Profile
+	bestScore := -1
+	for _, p := range profiles {
+		u, err := url.Parse(p.URL)
+		if err != nil || !strings.EqualFold(u.Host, target.Host) {
+			continue
+		}
+		score := 0
+		if strings.HasPrefix(target.Path, u.Path) {
+			score = len(u.Path) + 1
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore = []*Profile{p}, score
+		case score == bestScore:
+			best = append(best, p)
+		}
+	}
+	switch len(best) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, target.Host)
+	case 1:
+		return best[0], nil
+	}
+	names := make([]string, len(best))
+	for i, p := range best {
+		names[i] = p.Name
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("地址%s匹配多个站点配置: %s，请改用站点名称", site, strings.Join(names, "、"))
+}
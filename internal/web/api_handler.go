@@ -4,80 +4,132 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/internal/markdown"
+	"GoBrowserAgent/internal/ratelimit"
+	"GoBrowserAgent/internal/rbac"
 	"GoBrowserAgent/internal/service/llm"
+	"GoBrowserAgent/internal/session"
 
 	"github.com/sirupsen/logrus"
 )
 
+// maxChatBodySize 聊天请求体的最大字节数
+const maxChatBodySize = 1 << 20
+
 // UserChatRequest 定义用户请求结构
 type UserChatRequest struct {
-	Message string `json:"message"`
+	Message   string `json:"message"`
+	SessionID string `json:"session_id,omitempty"`
 }
 
 // UserChatResponse 定义响应给用户的结构
 type UserChatResponse struct {
 	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
+	// HTML 回复渲染后的安全HTML，页面直接显示，不应再自行解析Markdown
+	HTML      string `json:"html"`
+	SessionID string `json:"session_id,omitempty"`
 }
 
 // APIHandler 处理API请求
 type APIHandler struct {
 	LLMService *llm.Service
+	Sessions   session.Store
+	// Access 权限校验，为nil时不做限制
+	Access *rbac.Enforcer
+	// Limiter WebSocket上每条聊天消息的限流，为nil时不限制
+	Limiter *ratelimit.Limiter
+	// Tasks 运行WebSocket客户端提交的浏览器任务，客户端通过它的事件总线接收任务事件、答复审批和补充命令
+	Tasks *command.Runner
+
+	ws *wsHub
 }
 
 // NewAPIHandler 创建新的API处理程序
-func NewAPIHandler(llmService *llm.Service) *APIHandler {
+func NewAPIHandler(llmService *llm.Service, sessions session.Store, access *rbac.Enforcer, limiter *ratelimit.Limiter, tasks *command.Runner) *APIHandler {
 	return &APIHandler{
 		LLMService: llmService,
+		Sessions:   sessions,
+		Access:     access,
+		Limiter:    limiter,
+		Tasks:      tasks,
+		ws:         newWSHub(tasks.Bus),
 	}
 }
 
 // RegisterHandlers 注册HTTP处理程序
 func (h *APIHandler) RegisterHandlers() {
-	http.HandleFunc("/api/chat", h.handleChat)
+	http.HandleFunc("/api/chat", h.Access.Require(rbac.PermChat, h.handleChat))
+	http.HandleFunc("/api/sessions", h.Access.Require(rbac.PermArtifactView, h.handleSessionList))
+	http.HandleFunc("/api/sessions/search", h.Access.Require(rbac.PermArtifactView, h.handleSessionSearch))
+	http.HandleFunc("/api/sessions/get", h.Access.Require(rbac.PermArtifactView, h.handleSessionGet))
+	http.HandleFunc("/api/sessions/delete", h.Access.Require(rbac.PermChat, h.handleSessionDelete))
+	http.HandleFunc("/api/sessions/export", h.Access.Require(rbac.PermArtifactView, h.handleSessionExport))
+	http.HandleFunc("/api/sessions/import", h.Access.Require(rbac.PermChat, h.handleSessionImport))
+	http.HandleFunc("/api/ws", h.handleWebSocket)
 }
 
 // handleChat 处理聊天请求
 func (h *APIHandler) handleChat(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodPost))
 		return
 	}
 
 	var req UserChatRequest
-	decoder := json.NewDecoder(r.Body)
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxChatBodySize))
 	if err := decoder.Decode(&req); err != nil {
-		logrus.Errorf("解析请求体失败: %v", err)
-		http.Error(w, "无效的请求格式", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.FromDecode(err))
 		return
 	}
 
 	if req.Message == "" {
-		http.Error(w, "消息不能为空", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.New(apierror.CodeMessageEmpty))
+		return
+	}
+
+	// 加载或创建会话
+	sess, err := h.loadOrCreateSession(r.Context(), req.SessionID)
+	if err != nil {
+		apierror.Write(w, r, sessionError(err))
 		return
 	}
 
-	// 处理聊天请求
-	message, err := h.LLMService.Chat(req.Message)
-	var resp UserChatResponse
+	// 处理聊天请求，携带会话中的历史消息
+	sess.Append(llm.ChatMessage{Role: "user", Content: req.Message})
+	chatResp, err := h.LLMService.Complete(r.Context(), llm.ChatRequest{Messages: sess.Messages})
+	if err == nil {
+		h.recordReply(sess, chatResp.Choices[0].Message.Content, chatResp)
+	}
+
+	// 调用失败时也保存用户消息，页面据此切换到新建的会话
+	if err := h.Sessions.Save(sess); err != nil {
+		logrus.Errorf("保存会话失败: %v", err)
+	}
 
 	if err != nil {
-		logrus.Errorf("处理聊天请求失败: %v", err)
-		resp = UserChatResponse{
-			Error: err.Error(),
-		}
-	} else {
-		resp = UserChatResponse{
-			Message: message,
-		}
+		e := apierror.Upstream(err)
+		e.Details = map[string]string{"session_id": sess.ID}
+		apierror.Write(w, r, e)
+		return
 	}
 
 	// 返回响应
 	w.Header().Set("Content-Type", "application/json")
 	encoder := json.NewEncoder(w)
+	reply := chatResp.Choices[0].Message.Content
+	resp := UserChatResponse{Message: reply, HTML: markdown.Render(reply), SessionID: sess.ID}
 	if err := encoder.Encode(resp); err != nil {
 		logrus.Errorf("编码响应失败: %v", err)
-		http.Error(w, "服务器内部错误", http.StatusInternalServerError)
-		return
+	}
+}
+
+// recordReply 把模型回复和用量记入会话
+func (h *APIHandler) recordReply(sess *session.Session, reply string, chatResp *llm.ChatResponse) {
+	sess.Append(llm.ChatMessage{Role: "assistant", Content: reply})
+	sess.AddUsage(chatResp.Usage)
+	if chatResp.Model != "" {
+		sess.Model = chatResp.Model
 	}
 }
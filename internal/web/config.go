@@ -0,0 +1,80 @@
+package web
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config 存储Web服务器配置
+type Config struct {
+	// Host 监听地址，为空时监听所有网卡
+	Host string `json:"host"`
+	// Port 监听端口
+	Port int `json:"port"`
+	// StaticDir 自定义静态文件目录，其中的文件优先于内置页面，可用于替换界面皮肤
+	StaticDir string `json:"static_dir"`
+	// ReadTimeout 读取整个请求的超时时间（秒）
+	ReadTimeout int `json:"read_timeout"`
+	// WriteTimeout 写入响应的超时时间（秒），需要大于LLM的最长响应时间
+	WriteTimeout int `json:"write_timeout"`
+	// IdleTimeout keep-alive连接的空闲超时时间（秒）
+	IdleTimeout int `json:"idle_timeout"`
+	// ShutdownTimeout 收到退出信号后等待进行中请求完成的时间（秒）
+	ShutdownTimeout int `json:"shutdown_timeout"`
+}
+
+// LoadConfig 从配置文件加载Web服务器配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		Web *Config `json:"web"`
+	}{
+		Web: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.Web, nil
+}
+
+// GetDefaultConfig 获取默认配置
+func GetDefaultConfig() *Config {
+	return &Config{
+		Port:            8080,
+		ReadTimeout:     30,
+		WriteTimeout:    300,
+		IdleTimeout:     120,
+		ShutdownTimeout: 30,
+	}
+}
+
+// Addr 返回监听地址
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// NewServer 按配置创建HTTP服务器
+func NewServer(config *Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              config.Addr(),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Duration(config.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(config.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(config.IdleTimeout) * time.Second,
+	}
+}
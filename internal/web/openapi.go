@@ -0,0 +1,136 @@
+package web
+
+import (
+	"net/http"
+
+	"GoBrowserAgent/internal/jsonschema"
+	"GoBrowserAgent/internal/openapi"
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/session"
+)
+
+// Routes 返回RegisterHandlers注册的接口，用于生成OpenAPI文档和校验请求
+func (h *APIHandler) Routes() []openapi.Route {
+	chatRequest := openapi.SchemaOf(UserChatRequest{})
+	chatRequest.Required = []string{"message"}
+	chatRequest.Properties["message"].Description = "用户消息"
+	chatRequest.Properties["message"].MinLength = openapi.Length(1)
+	chatRequest.Properties["session_id"].Description = "继续已有的会话，为空时创建新会话"
+
+	importRequest := openapi.SchemaOf(session.Export{})
+	importRequest.Required = []string{"format", "session"}
+	importRequest.Properties["format"].Enum = []interface{}{session.ExportFormatName}
+	importRequest.Properties["version"].Minimum = openapi.Bound(1)
+	importRequest.Properties["version"].Maximum = openapi.Bound(session.ExportVersion)
+	importSession := importRequest.Properties["session"]
+	importSession.Properties["messages"].Items.Required = []string{"role", "content"}
+	importSession.Properties["messages"].Items.Properties["role"].Enum = []interface{}{"system", "user", "assistant"}
+
+	sessionID := openapi.Query("id", "会话ID", true, openapi.String(""))
+	sessionView := openapi.JSONResponse("会话内容，助手回复附带渲染后的html", openapi.SchemaOf(SessionView{}))
+
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: "/api/chat", Operation: openapi.Operation{
+			Tags:        []string{"chat"},
+			Summary:     "发送消息",
+			Description: "发送一条消息并等待完整回复，需要流式输出时使用/api/ws。",
+			OperationID: "chat",
+			Permission:  string(rbac.PermChat),
+			MaxBodySize: maxChatBodySize,
+			RequestBody: openapi.JSONBody("", chatRequest),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("回复内容和渲染后的html", openapi.SchemaOf(UserChatResponse{})),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/ws", Operation: openapi.Operation{
+			Tags:    []string{"chat", "tasks"},
+			Summary: "WebSocket通道",
+			Description: "升级为WebSocket连接，用于流式对话、取消生成以及运行浏览器任务、答复审批和补充命令。" +
+				"连接后先发送hello，服务端回复welcome；之后客户端可发送chat、task、cancel、approval、input和ping，" +
+				"服务端推送delta、done、cancelled、task_created、task_event、pong和error。每条消息的结构见101响应。" +
+				"chat需要chat权限，task、approval和input需要task.run权限，任务中的每条命令还按角色的sites校验目标站点，" +
+				"无权限时回复error消息而不断开连接。",
+			OperationID: "websocket",
+			Responses: map[string]*openapi.Response{
+				"101": openapi.JSONResponse("切换为WebSocket协议，双向的消息均为如下结构的JSON文本帧", openapi.SchemaOf(WSMessage{})),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/sessions", Operation: openapi.Operation{
+			Tags:        []string{"sessions"},
+			Summary:     "会话列表",
+			Description: "按更新时间倒序返回调用方的会话摘要，拥有artifact.admin权限时返回所有会话。",
+			OperationID: "listSessions",
+			Permission:  string(rbac.PermArtifactView),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("会话摘要", openapi.SchemaOf([]session.Summary{})),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/sessions/search", Operation: openapi.Operation{
+			Tags:        []string{"sessions"},
+			Summary:     "搜索会话",
+			Description: "在调用方的会话标题和消息中全文搜索，返回匹配的会话和摘录；拥有artifact.admin权限时搜索所有会话。",
+			OperationID: "searchSessions",
+			Permission:  string(rbac.PermArtifactView),
+			Parameters: []openapi.Parameter{
+				openapi.Query("q", "搜索词", true, &jsonschema.Schema{Type: jsonschema.TypeList{"string"}, MinLength: openapi.Length(1)}),
+				openapi.Query("limit", "最多返回的会话数，默认20", false, openapi.Integer("", openapi.Bound(1), nil)),
+			},
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("搜索结果", openapi.SchemaOf([]session.SearchResult{})),
+			},
+		}},
+		{Method: http.MethodGet, Path: "/api/sessions/get", Operation: openapi.Operation{
+			Tags:        []string{"sessions"},
+			Summary:     "获取会话",
+			OperationID: "getSession",
+			Permission:  string(rbac.PermArtifactView),
+			Parameters:  []openapi.Parameter{sessionID},
+			Responses:   map[string]*openapi.Response{"200": sessionView},
+		}},
+		{Method: http.MethodPost, Path: "/api/sessions/delete", Operation: openapi.Operation{
+			Tags:        []string{"sessions"},
+			Summary:     "删除会话",
+			Description: "也可以使用DELETE请求。",
+			OperationID: "deleteSession",
+			Permission:  string(rbac.PermChat),
+			Parameters:  []openapi.Parameter{sessionID},
+			Responses:   map[string]*openapi.Response{"204": {Description: "已删除"}},
+		}},
+		{Method: http.MethodDelete, Path: "/api/sessions/delete", Operation: openapi.Operation{
+			Tags:        []string{"sessions"},
+			Summary:     "删除会话",
+			OperationID: "deleteSessionByDelete",
+			Permission:  string(rbac.PermChat),
+			Parameters:  []openapi.Parameter{sessionID},
+			Responses:   map[string]*openapi.Response{"204": {Description: "已删除"}},
+		}},
+		{Method: http.MethodGet, Path: "/api/sessions/export", Operation: openapi.Operation{
+			Tags:        []string{"sessions"},
+			Summary:     "导出会话",
+			Description: "以附件形式下载会话，JSON格式可以再通过/api/sessions/import导入。",
+			OperationID: "exportSession",
+			Permission:  string(rbac.PermArtifactView),
+			Parameters: []openapi.Parameter{
+				sessionID,
+				openapi.Query("format", "导出格式，默认markdown", false, openapi.Enum("", "markdown", "md", "json", "html")),
+			},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "导出文件", Content: map[string]openapi.MediaType{
+					"text/markdown":    {Schema: openapi.String("")},
+					"application/json": {Schema: openapi.SchemaOf(session.Export{})},
+					"text/html":        {Schema: openapi.String("")},
+				}},
+			},
+		}},
+		{Method: http.MethodPost, Path: "/api/sessions/import", Operation: openapi.Operation{
+			Tags:        []string{"sessions"},
+			Summary:     "导入会话",
+			Description: "导入JSON导出文件，会话分配新的ID并归导入者所有，原ID记录在metadata.imported_from中。",
+			OperationID: "importSession",
+			Permission:  string(rbac.PermChat),
+			MaxBodySize: maxImportSize,
+			RequestBody: openapi.JSONBody("/api/sessions/export?format=json导出的文件", importRequest),
+			Responses:   map[string]*openapi.Response{"200": sessionView},
+		}},
+	}
+}
@@ -0,0 +1,288 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+	"GoBrowserAgent/internal/markdown"
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/service/llm"
+	"GoBrowserAgent/internal/session"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxImportSize 导入文件的最大字节数
+const maxImportSize = 10 << 20
+
+// defaultSearchLimit 搜索默认返回的会话数
+const defaultSearchLimit = 20
+
+// MessageView 返回给页面的消息
+type MessageView struct {
+	llm.ChatMessage
+	// HTML 助手回复渲染后的安全HTML，用户消息按原文显示
+	HTML string `json:"html,omitempty"`
+}
+
+// SessionView 返回给页面的会话，助手回复附带渲染结果
+type SessionView struct {
+	*session.Session
+	Messages []MessageView `json:"messages"`
+}
+
+// renderSession 渲染会话中的助手回复
+func renderSession(sess *session.Session) SessionView {
+	view := SessionView{Session: sess, Messages: make([]MessageView, len(sess.Messages))}
+	for i, m := range sess.Messages {
+		view.Messages[i] = MessageView{ChatMessage: m}
+		if m.Role == "assistant" {
+			view.Messages[i].HTML = markdown.Render(m.Content)
+		}
+	}
+	return view
+}
+
+// sessionOwner 返回调用方作为会话归属的标识，未启用认证时为空
+func sessionOwner(ctx context.Context) string {
+	if identity, ok := auth.FromContext(ctx); ok && identity != nil {
+		return identity.Subject
+	}
+	return ""
+}
+
+// sessionScope 返回调用方可以访问的会话归属，为空表示可以访问所有会话
+func (h *APIHandler) sessionScope(ctx context.Context) string {
+	if h.Access.Allowed(ctx, rbac.PermArtifactAdmin) {
+		return ""
+	}
+	return sessionOwner(ctx)
+}
+
+// loadSession 按ID加载调用方可以访问的会话，属于其他调用方的会话按不存在处理，不暴露会话是否存在
+func (h *APIHandler) loadSession(ctx context.Context, id string) (*session.Session, error) {
+	sess, err := h.Sessions.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if scope := h.sessionScope(ctx); scope != "" && sess.Owner != scope {
+		return nil, session.ErrNotFound
+	}
+	return sess, nil
+}
+
+// loadOrCreateSession 按ID加载会话，ID为空时为调用方创建新会话
+func (h *APIHandler) loadOrCreateSession(ctx context.Context, id string) (*session.Session, error) {
+	if id == "" {
+		sess := session.New()
+		sess.Owner = sessionOwner(ctx)
+		return sess, nil
+	}
+	return h.loadSession(ctx, id)
+}
+
+// handleSessionList 返回按更新时间倒序排列的会话列表
+func (h *APIHandler) handleSessionList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+
+	summaries, err := h.Sessions.List(h.sessionScope(r.Context()))
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("获取会话列表失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summaries); err != nil {
+		logrus.Errorf("编码响应失败: %v", err)
+	}
+}
+
+// handleSessionSearch 全文搜索历史会话
+func (h *APIHandler) handleSessionSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		apierror.Write(w, r, apierror.New(apierror.CodeMissingParameter).With("name", "q"))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			apierror.Write(w, r, apierror.New(apierror.CodeInvalidParameter).With("name", "limit"))
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.Sessions.Search(query, h.sessionScope(r.Context()), limit)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("搜索会话失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		logrus.Errorf("编码响应失败: %v", err)
+	}
+}
+
+// handleSessionDelete 删除会话
+func (h *APIHandler) handleSessionDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodPost, http.MethodDelete))
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		apierror.Write(w, r, apierror.New(apierror.CodeMissingParameter).With("name", "id"))
+		return
+	}
+
+	if _, err := h.loadSession(r.Context(), id); err != nil {
+		apierror.Write(w, r, sessionError(err))
+		return
+	}
+	if err := h.Sessions.Delete(id); err != nil {
+		apierror.Write(w, r, sessionError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSessionGet 返回会话的完整内容
+func (h *APIHandler) handleSessionGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+
+	sess, ok := h.getSession(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(renderSession(sess)); err != nil {
+		logrus.Errorf("编码响应失败: %v", err)
+	}
+}
+
+// handleSessionExport 将会话导出为Markdown、JSON或HTML文件
+func (h *APIHandler) handleSessionExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+
+	sess, ok := h.getSession(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		data        []byte
+		err         error
+		contentType string
+		ext         string
+	)
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "markdown", "md":
+		data, contentType, ext = session.ExportMarkdown(sess), "text/markdown; charset=utf-8", "md"
+	case "json":
+		data, err = session.ExportJSON(sess)
+		contentType, ext = "application/json", "json"
+	case "html":
+		data, err = session.ExportHTML(sess)
+		contentType, ext = "text/html; charset=utf-8", "html"
+	default:
+		apierror.Write(w, r, apierror.New(apierror.CodeUnsupportedFormat).With("format", format))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("导出会话失败: %v", err))
+		return
+	}
+
+	filename := fmt.Sprintf("chat-%s.%s", sess.CreatedAt.Format("20060102-150405"), ext)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", filename, url.PathEscape(filename)))
+	w.Write(data)
+}
+
+// handleSessionImport 导入JSON导出文件为新会话
+func (h *APIHandler) handleSessionImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodPost))
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDecode(err))
+		return
+	}
+
+	sess, err := session.ImportJSON(data)
+	if err != nil {
+		// 导入文件的校验信息可以安全地返回给调用方
+		apierror.Write(w, r, apierror.Wrap(apierror.CodeInvalidImport, err).WithDetails(err.Error()))
+		return
+	}
+	// 导入的会话归导入者所有，不沿用导出文件中的归属
+	sess.Owner = sessionOwner(r.Context())
+
+	if err := h.Sessions.Save(sess); err != nil {
+		apierror.Write(w, r, fmt.Errorf("保存会话失败: %v", err))
+		return
+	}
+
+	logrus.Infof("已导入会话: %s (%d条消息)", sess.ID, len(sess.Messages))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(renderSession(sess)); err != nil {
+		logrus.Errorf("编码响应失败: %v", err)
+	}
+}
+
+// getSession 按查询参数id加载会话，失败时写入错误响应
+func (h *APIHandler) getSession(w http.ResponseWriter, r *http.Request) (*session.Session, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		apierror.Write(w, r, apierror.New(apierror.CodeMissingParameter).With("name", "id"))
+		return nil, false
+	}
+
+	sess, err := h.loadSession(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, sessionError(err))
+		return nil, false
+	}
+	return sess, true
+}
+
+// sessionError 把会话存储的错误转换为接口错误
+func sessionError(err error) *apierror.Error {
+	if errors.Is(err, session.ErrNotFound) {
+		return apierror.New(apierror.CodeSessionNotFound)
+	}
+	return apierror.Wrap(apierror.CodeInternal, fmt.Errorf("访问会话存储失败: %v", err))
+}
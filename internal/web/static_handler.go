@@ -0,0 +1,237 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed static
+var embeddedStatic embed.FS
+
+// minGzipSize 小于该大小的文件不压缩
+const minGzipSize = 1024
+
+// staticAsset 缓存的静态文件内容
+type staticAsset struct {
+	content     []byte
+	gzipped     []byte
+	etag        string
+	contentType string
+	modTime     time.Time
+}
+
+// StaticHandler 提供静态页面，优先使用自定义目录中的文件，否则使用编译进二进制的内置页面
+//
+// 响应带有强ETag和Cache-Control，支持If-None-Match条件请求，客户端接受时返回gzip压缩内容。
+type StaticHandler struct {
+	overrideDir string
+	embedded    fs.FS
+
+	mu    sync.RWMutex
+	cache map[string]*staticAsset
+}
+
+// NewStaticHandler 创建静态文件处理程序，overrideDir为空时只使用内置页面
+func NewStaticHandler(overrideDir string) *StaticHandler {
+	sub, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		// embed目录在编译期确定，不会出错
+		panic(err)
+	}
+	if overrideDir != "" {
+		logrus.Infof("使用自定义静态文件目录: %s", overrideDir)
+	}
+	return &StaticHandler{
+		overrideDir: overrideDir,
+		embedded:    sub,
+		cache:       make(map[string]*staticAsset),
+	}
+}
+
+// ServeHTTP 实现http.Handler
+func (h *StaticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet, http.MethodHead))
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+
+	asset, err := h.load(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set("ETag", asset.etag)
+	header.Set("Vary", "Accept-Encoding")
+	if strings.HasSuffix(name, ".html") {
+		// 页面每次都重新验证，保证升级后立即生效
+		header.Set("Cache-Control", "no-cache")
+	} else {
+		header.Set("Cache-Control", "public, max-age=3600")
+	}
+	if !asset.modTime.IsZero() {
+		header.Set("Last-Modified", asset.modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), asset.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", asset.contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	body := asset.content
+	if asset.gzipped != nil && acceptsGzip(r) {
+		header.Set("Content-Encoding", "gzip")
+		body = asset.gzipped
+	}
+	header.Set("Content-Length", fmt.Sprint(len(body)))
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+// load 读取静态文件，内置文件只读取一次，自定义目录中的文件在修改后重新读取
+func (h *StaticHandler) load(name string) (*staticAsset, error) {
+	if h.overrideDir != "" {
+		fullPath := filepath.Join(h.overrideDir, filepath.FromSlash(name))
+		if info, err := os.Stat(fullPath); err == nil && !info.IsDir() {
+			cacheKey := "override:" + name
+			h.mu.RLock()
+			asset, ok := h.cache[cacheKey]
+			h.mu.RUnlock()
+			if ok && asset.modTime.Equal(info.ModTime()) && int64(len(asset.content)) == info.Size() {
+				return asset, nil
+			}
+
+			content, err := os.ReadFile(fullPath)
+			if err != nil {
+				return nil, err
+			}
+			asset = newStaticAsset(name, content, info.ModTime())
+			h.mu.Lock()
+			h.cache[cacheKey] = asset
+			h.mu.Unlock()
+			return asset, nil
+		}
+	}
+
+	cacheKey := "embedded:" + name
+	h.mu.RLock()
+	asset, ok := h.cache[cacheKey]
+	h.mu.RUnlock()
+	if ok {
+		return asset, nil
+	}
+
+	file, err := h.embedded.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// embed.FS不记录修改时间，依靠内容摘要作为ETag
+	asset = newStaticAsset(name, content, time.Time{})
+	h.mu.Lock()
+	h.cache[cacheKey] = asset
+	h.mu.Unlock()
+	return asset, nil
+}
+
+// newStaticAsset 计算ETag、内容类型并按需预先压缩
+func newStaticAsset(name string, content []byte, modTime time.Time) *staticAsset {
+	sum := sha256.Sum256(content)
+	asset := &staticAsset{
+		content: content,
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+		modTime: modTime,
+	}
+
+	asset.contentType = mime.TypeByExtension(path.Ext(name))
+	if asset.contentType == "" {
+		asset.contentType = http.DetectContentType(content)
+	}
+
+	if len(content) >= minGzipSize && compressible(asset.contentType) {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := gz.Write(content); err == nil && gz.Close() == nil && buf.Len() < len(content) {
+			asset.gzipped = buf.Bytes()
+		}
+	}
+	return asset
+}
+
+// compressible 判断内容类型是否值得压缩
+func compressible(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "svg") ||
+		strings.Contains(contentType, "xml")
+}
+
+// acceptsGzip 判断客户端是否接受gzip编码
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		// gzip;q=0表示明确拒绝
+		if len(fields) > 1 && strings.ReplaceAll(strings.TrimSpace(fields[1]), " ", "") == "q=0" {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// etagMatches 判断If-None-Match是否命中
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
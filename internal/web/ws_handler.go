@@ -0,0 +1,367 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/internal/markdown"
+	"GoBrowserAgent/internal/ratelimit"
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/service/llm"
+	"GoBrowserAgent/internal/taskbus"
+	"GoBrowserAgent/internal/websocket"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// wsPingInterval 服务端发送心跳的间隔
+	wsPingInterval = 30 * time.Second
+	// wsReadTimeout 超过该时间没有收到任何数据（包括pong）就认为连接已断开
+	wsReadTimeout = 2*wsPingInterval + 10*time.Second
+	// wsHelloTimeout 连接建立后等待hello消息的时间
+	wsHelloTimeout = 10 * time.Second
+)
+
+// handleWebSocket 处理/api/ws，建立双向的聊天和任务控制通道
+func (h *APIHandler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// 浏览器发起WebSocket时不受同源策略限制且会带上Cookie，必须自己校验来源
+	if !auth.SameOrigin(r) {
+		apierror.Write(w, r, apierror.New(apierror.CodeCrossSiteRequest))
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		apierror.Write(w, r, apierror.MethodNotAllowed(http.MethodGet))
+		return
+	}
+	if !websocket.IsUpgrade(r) {
+		apierror.Write(w, r, apierror.New(apierror.CodeBadRequest))
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		logrus.Debugf("%v", err)
+		// 连接已被接管后的失败无法再写入响应
+		var handshakeErr *websocket.HandshakeError
+		if errors.As(err, &handshakeErr) {
+			apierror.Write(w, r, handshakeError(handshakeErr))
+		}
+		return
+	}
+	conn.MaxMessageSize = maxChatBodySize
+	defer conn.Close(websocket.CloseNormal, "")
+
+	// 第一条消息必须是hello，用于新建或恢复会话
+	conn.SetReadDeadline(time.Now().Add(wsHelloTimeout))
+	var hello WSMessage
+	if _, data, err := conn.ReadMessage(); err != nil || json.Unmarshal(data, &hello) != nil || hello.Type != "hello" {
+		conn.Close(websocket.ClosePolicyViolation, "第一条消息必须是hello")
+		return
+	}
+
+	identity, _ := auth.FromContext(r.Context())
+	s, resumed := h.ws.open(hello.ResumeToken, identity)
+	if s == nil {
+		conn.Close(websocket.CloseGoingAway, "服务器正在关闭")
+		return
+	}
+	s.attach(conn, hello.LastSeq, resumed, apierror.Language(r))
+	defer s.detach(conn)
+	if resumed {
+		logrus.Debugf("WebSocket会话已恢复: %s", r.RemoteAddr)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	conn.SetPongHandler(func([]byte) {
+		conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteControl(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				logrus.Debugf("读取WebSocket消息失败: %v", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+
+		var msg WSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.sendEphemeral(s.errorMessage(WSMessage{}, apierror.New(apierror.CodeInvalidMessage)))
+			continue
+		}
+		h.dispatchWS(r, s, msg)
+	}
+}
+
+// dispatchWS 按类型处理客户端消息
+func (h *APIHandler) dispatchWS(r *http.Request, s *wsSession, msg WSMessage) {
+	switch msg.Type {
+	case "ping":
+		s.sendEphemeral(WSMessage{Type: "pong", ID: msg.ID})
+	case "chat":
+		h.wsChat(r, s, msg)
+	case "task":
+		h.wsTask(s, msg)
+	case "cancel":
+		h.wsCancel(s, msg)
+	case "approval":
+		h.wsApproval(s, msg)
+	case "input":
+		h.wsInput(s, msg)
+	default:
+		s.sendEphemeral(s.errorMessage(WSMessage{ID: msg.ID}, apierror.New(apierror.CodeUnknownMessage).With("type", msg.Type)))
+	}
+}
+
+// wsChat 开始一次流式生成，增量内容以delta消息推送
+func (h *APIHandler) wsChat(r *http.Request, s *wsSession, msg WSMessage) {
+	if msg.ID == "" || strings.TrimSpace(msg.Message) == "" {
+		s.sendEphemeral(s.errorMessage(WSMessage{ID: msg.ID}, apierror.New(apierror.CodeInvalidMessage).WithDetails(requiredFields("id", "message"))))
+		return
+	}
+	if err := h.Access.Check(s.ctx, rbac.PermChat, r.URL.Path); err != nil {
+		s.send(s.errorMessage(WSMessage{ID: msg.ID}, apierror.From(err)))
+		return
+	}
+
+	release := func() {}
+	if h.Limiter != nil {
+		decision, rel := h.Limiter.AcquireRequest(r)
+		if !decision.Allowed {
+			s.send(s.errorMessage(WSMessage{ID: msg.ID}, ratelimit.Error(decision)))
+			return
+		}
+		release = rel
+	}
+
+	sess, err := h.loadOrCreateSession(s.ctx, msg.SessionID)
+	if err != nil {
+		release()
+		s.send(s.errorMessage(WSMessage{ID: msg.ID, SessionID: msg.SessionID}, sessionError(err)))
+		return
+	}
+	ctx, ok := s.startChat(msg.ID, msg.SessionID)
+	if ok && !h.ws.track() {
+		s.endChat(msg.ID)
+		release()
+		s.send(s.errorMessage(WSMessage{ID: msg.ID, SessionID: sess.ID}, apierror.New(apierror.CodeShuttingDown)))
+		return
+	}
+	if !ok {
+		release()
+		s.send(s.errorMessage(WSMessage{ID: msg.ID, SessionID: sess.ID}, apierror.New(apierror.CodeChatBusy)))
+		return
+	}
+	s.setChatSession(msg.ID, sess.ID)
+
+	go func() {
+		defer h.ws.running.Done()
+		defer release()
+		defer s.endChat(msg.ID)
+		// 上游响应或渲染中的意外错误只结束这一次生成，不能让整个服务退出
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.Errorf("处理聊天请求时发生panic: %v\n%s", r, debug.Stack())
+				s.send(s.errorMessage(WSMessage{ID: msg.ID, SessionID: sess.ID}, apierror.New(apierror.CodeInternal)))
+			}
+		}()
+
+		sess.Append(llm.ChatMessage{Role: "user", Content: msg.Message})
+		var partial strings.Builder
+		chatResp, err := h.LLMService.CompleteStream(ctx, llm.ChatRequest{Messages: sess.Messages}, func(chunk *llm.ChatChunk) error {
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content == "" {
+					continue
+				}
+				partial.WriteString(choice.Delta.Content)
+				s.send(WSMessage{Type: "delta", ID: msg.ID, SessionID: sess.ID, Content: choice.Delta.Content})
+			}
+			return nil
+		})
+
+		switch {
+		case err == nil:
+			reply := partial.String()
+			if len(chatResp.Choices) > 0 {
+				reply = chatResp.Choices[0].Message.Content
+			}
+			h.recordReply(sess, reply, chatResp)
+			s.send(WSMessage{Type: "done", ID: msg.ID, SessionID: sess.ID, Message: reply, HTML: markdown.Render(reply), Usage: &chatResp.Usage})
+		case ctx.Err() != nil:
+			// 用户停止生成时保留已经生成的部分
+			if partial.Len() > 0 {
+				sess.Append(llm.ChatMessage{Role: "assistant", Content: partial.String()})
+			}
+			s.send(WSMessage{Type: "cancelled", ID: msg.ID, SessionID: sess.ID, Message: partial.String(), HTML: markdown.Render(partial.String())})
+		default:
+			logrus.Errorf("处理聊天请求失败: %v", err)
+			s.send(s.errorMessage(WSMessage{ID: msg.ID, SessionID: sess.ID}, apierror.Upstream(err)))
+		}
+
+		if err := h.Sessions.Save(sess); err != nil {
+			logrus.Errorf("保存会话失败: %v", err)
+		}
+	}()
+}
+
+// wsTask 在后台运行一组浏览器命令，任务的进度和审批请求以task_event推送
+func (h *APIHandler) wsTask(s *wsSession, msg WSMessage) {
+	if msg.ID == "" || len(msg.Commands) == 0 {
+		s.sendEphemeral(s.errorMessage(WSMessage{ID: msg.ID}, apierror.New(apierror.CodeInvalidMessage).WithDetails(requiredFields("id", "commands"))))
+		return
+	}
+	if err := h.requireTaskRun(s, msg); err != nil {
+		return
+	}
+	cmds, err := h.Tasks.Parse(msg.Commands)
+	if err != nil {
+		s.send(s.errorMessage(WSMessage{ID: msg.ID}, apierror.Wrap(apierror.CodeInvalidMessage, err).WithDetails(err.Error())))
+		return
+	}
+	t, err := h.Tasks.Start(s.ctx, s.owner(), cmds)
+	if err != nil {
+		s.send(s.errorMessage(WSMessage{ID: msg.ID}, taskError(err)))
+		return
+	}
+	s.send(WSMessage{Type: "task_created", ID: msg.ID, TaskID: t.ID})
+}
+
+// wsCancel 取消进行中的生成（id）或任务（task_id）
+func (h *APIHandler) wsCancel(s *wsSession, msg WSMessage) {
+	if msg.TaskID != "" {
+		if err := h.requireTaskRun(s, msg); err != nil {
+			return
+		}
+		if err := h.Tasks.Bus.Cancel(s.owner(), msg.TaskID); err != nil {
+			s.send(s.errorMessage(WSMessage{TaskID: msg.TaskID}, taskError(err)))
+		}
+		return
+	}
+	if !s.cancelChat(msg.ID) {
+		s.sendEphemeral(s.errorMessage(WSMessage{ID: msg.ID}, apierror.New(apierror.CodeNotRunning)))
+	}
+}
+
+// wsApproval 答复任务的审批请求
+func (h *APIHandler) wsApproval(s *wsSession, msg WSMessage) {
+	if msg.ApprovalID == "" || msg.Approved == nil {
+		s.sendEphemeral(s.errorMessage(WSMessage{}, apierror.New(apierror.CodeInvalidMessage).WithDetails(requiredFields("approval_id", "approved"))))
+		return
+	}
+	if err := h.requireTaskRun(s, msg); err != nil {
+		return
+	}
+	result := taskbus.ApprovalResult{Approved: *msg.Approved, Comment: msg.Comment}
+	if err := h.Tasks.Bus.Resolve(s.owner(), msg.ApprovalID, result); err != nil {
+		s.send(s.errorMessage(WSMessage{ApprovalID: msg.ApprovalID}, taskError(err)))
+	}
+}
+
+// wsInput 向进行中的任务补充命令，排在剩余的命令之后执行
+func (h *APIHandler) wsInput(s *wsSession, msg WSMessage) {
+	if msg.TaskID == "" || strings.TrimSpace(msg.Message) == "" {
+		s.sendEphemeral(s.errorMessage(WSMessage{}, apierror.New(apierror.CodeInvalidMessage).WithDetails(requiredFields("task_id", "message"))))
+		return
+	}
+	if err := h.requireTaskRun(s, msg); err != nil {
+		return
+	}
+	if _, err := h.Tasks.Parse([]string{msg.Message}); err != nil {
+		s.send(s.errorMessage(WSMessage{TaskID: msg.TaskID}, apierror.Wrap(apierror.CodeInvalidMessage, err).WithDetails(err.Error())))
+		return
+	}
+	if err := h.Tasks.Bus.SendInput(s.owner(), msg.TaskID, msg.Message); err != nil {
+		s.send(s.errorMessage(WSMessage{TaskID: msg.TaskID}, taskError(err)))
+	}
+}
+
+// requireTaskRun 校验任务控制权限，拒绝时向客户端发送错误
+func (h *APIHandler) requireTaskRun(s *wsSession, msg WSMessage) error {
+	err := h.Access.Check(s.ctx, rbac.PermTaskRun, "/api/ws")
+	if err != nil {
+		s.send(s.errorMessage(WSMessage{ID: msg.ID, TaskID: msg.TaskID, ApprovalID: msg.ApprovalID}, apierror.From(err)))
+	}
+	return err
+}
+
+// taskError 把任务运行器和事件总线的错误转换为接口错误
+func taskError(err error) *apierror.Error {
+	switch {
+	case errors.Is(err, taskbus.ErrNotFound):
+		return apierror.New(apierror.CodeTaskNotFound)
+	case errors.Is(err, taskbus.ErrApprovalNotFound):
+		return apierror.New(apierror.CodeApprovalNotFound)
+	case errors.Is(err, taskbus.ErrInputBusy):
+		return apierror.New(apierror.CodeTaskInputBusy)
+	case errors.Is(err, command.ErrRunnerClosed):
+		return apierror.New(apierror.CodeShuttingDown)
+	}
+	return apierror.From(err)
+}
+
+// handshakeError 把WebSocket握手错误转换为接口错误
+func handshakeError(err *websocket.HandshakeError) *apierror.Error {
+	switch err.Status {
+	case http.StatusMethodNotAllowed:
+		return apierror.MethodNotAllowed(http.MethodGet)
+	case http.StatusUpgradeRequired:
+		return apierror.Wrap(apierror.CodeWebSocketVersion, err)
+	case http.StatusBadRequest:
+		return apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Message)
+	}
+	return apierror.Wrap(apierror.CodeInternal, err)
+}
+
+// requiredFields 消息缺少必填字段时的补充信息
+func requiredFields(fields ...string) map[string][]string {
+	return map[string][]string{"required": fields}
+}
+
+// CloseWebSockets 关闭所有WebSocket连接，取消其中进行中的生成并等待已生成的内容保存
+func (h *APIHandler) CloseWebSockets(ctx context.Context) error {
+	h.ws.close()
+
+	saved := make(chan struct{})
+	go func() {
+		h.ws.running.Wait()
+		close(saved)
+	}()
+	select {
+	case <-saved:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待WebSocket生成结束超时: %v", ctx.Err())
+	}
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/internal/session"
+	"GoBrowserAgent/internal/taskbus"
+	"GoBrowserAgent/internal/websocket"
+	"GoBrowserAgent/pkg/browser"
+)
+
+// wsClient 测试用的WebSocket客户端
+type wsClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialTestWS(t *testing.T) (*wsClient, string) {
+	t.Helper()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>target</title></head><body></body></html>"))
+	}))
+	t.Cleanup(site.Close)
+
+	config := browser.GetDefaultConfig()
+	config.Backend = browser.BackendHTTP
+	runner := command.NewRunner(config, nil, taskbus.New())
+	runner.Confirm = map[string]bool{"wait": true}
+	h := NewAPIHandler(nil, session.NewMemoryStore(), nil, nil, runner)
+	server := httptest.NewServer(http.HandlerFunc(h.handleWebSocket))
+	t.Cleanup(func() {
+		h.CloseWebSockets(context.Background())
+		runner.Close(context.Background())
+		server.Close()
+	})
+
+	conn, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &wsClient{t: t, conn: conn}
+	c.send(WSMessage{Type: "hello"})
+	if msg := c.read(); msg.Type != "welcome" {
+		t.Fatalf("first message = %+v, want welcome", msg)
+	}
+	return c, site.URL
+}
+
+func (c *wsClient) send(msg WSMessage) {
+	c.t.Helper()
+	data, _ := json.Marshal(msg)
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsClient) read() WSMessage {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var msg WSMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// readEvent 读取下一条task_event，跳过task_created
+func (c *wsClient) readEvent() *taskbus.Event {
+	c.t.Helper()
+	for {
+		msg := c.read()
+		if msg.Type == "task_created" {
+			continue
+		}
+		if msg.Type != "task_event" || msg.Event == nil {
+			c.t.Fatalf("message = %+v, want task_event", msg)
+		}
+		return msg.Event
+	}
+}
+
+func TestWSTask(t *testing.T) {
+	c, site := dialTestWS(t)
+
+	c.send(WSMessage{Type: "task", ID: "t1", Commands: []string{"go " + site, "wait 1ms"}})
+	var taskID string
+	for taskID == "" {
+		msg := c.read()
+		switch msg.Type {
+		case "task_created":
+			if msg.ID != "t1" {
+				t.Fatalf("task_created = %+v", msg)
+			}
+			taskID = msg.TaskID
+		case "task_event":
+		default:
+			t.Fatalf("message = %+v", msg)
+		}
+	}
+
+	var approvalID string
+	for approvalID == "" {
+		e := c.readEvent()
+		if e.TaskID != taskID {
+			t.Fatalf("event for task %s, want %s", e.TaskID, taskID)
+		}
+		switch e.Type {
+		case taskbus.EventStarted, taskbus.EventProgress:
+		case taskbus.EventApprovalRequest:
+			approvalID = e.ApprovalID
+		default:
+			t.Fatalf("event = %+v", e)
+		}
+	}
+
+	approved := true
+	c.send(WSMessage{Type: "approval", ApprovalID: approvalID, Approved: &approved})
+	for _, want := range []string{taskbus.EventApprovalResult, taskbus.EventProgress, taskbus.EventFinished} {
+		if e := c.readEvent(); e.Type != want {
+			t.Fatalf("event = %+v, want %s", e, want)
+		}
+	}
+}
+
+func TestWSTaskCancel(t *testing.T) {
+	c, _ := dialTestWS(t)
+
+	c.send(WSMessage{Type: "task", ID: "t1", Commands: []string{"wait 1ms"}})
+	var taskID string
+	for {
+		msg := c.read()
+		if msg.Type == "task_created" {
+			taskID = msg.TaskID
+		}
+		if msg.Type == "task_event" && msg.Event.Type == taskbus.EventApprovalRequest {
+			break
+		}
+	}
+
+	c.send(WSMessage{Type: "cancel", TaskID: taskID})
+	if e := c.readEvent(); e.Type != taskbus.EventCancelled {
+		t.Fatalf("event = %+v, want cancelled", e)
+	}
+	c.send(WSMessage{Type: "cancel", TaskID: taskID})
+	if msg := c.read(); msg.Type != "error" || msg.Code != apierror.CodeTaskNotFound || msg.TaskID != taskID {
+		t.Fatalf("message = %+v, want task_not_found", msg)
+	}
+}
+
+func TestWSTaskErrors(t *testing.T) {
+	c, _ := dialTestWS(t)
+	approved := false
+
+	tests := []struct {
+		name string
+		msg  WSMessage
+		code string
+	}{
+		{"task without commands", WSMessage{Type: "task", ID: "t1"}, apierror.CodeInvalidMessage},
+		{"task with non-page command", WSMessage{Type: "task", ID: "t2", Commands: []string{"help"}}, apierror.CodeInvalidMessage},
+		{"approval without answer", WSMessage{Type: "approval", ApprovalID: "a1"}, apierror.CodeInvalidMessage},
+		{"unknown approval", WSMessage{Type: "approval", ApprovalID: "a1", Approved: &approved}, apierror.CodeApprovalNotFound},
+		{"input without task", WSMessage{Type: "input", Message: "wait 1ms"}, apierror.CodeInvalidMessage},
+		{"input with bad command", WSMessage{Type: "input", TaskID: "x", Message: "nope"}, apierror.CodeInvalidMessage},
+		{"input to unknown task", WSMessage{Type: "input", TaskID: "x", Message: "wait 1ms"}, apierror.CodeTaskNotFound},
+		{"unknown type", WSMessage{Type: "launch"}, apierror.CodeUnknownMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.t = t
+			c.send(tt.msg)
+			if msg := c.read(); msg.Type != "error" || msg.Code != tt.code {
+				t.Fatalf("message = %+v, want error %s", msg, tt.code)
+			}
+		})
+	}
+}
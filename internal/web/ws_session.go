@@ -0,0 +1,425 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+	"GoBrowserAgent/internal/service/llm"
+	"GoBrowserAgent/internal/session"
+	"GoBrowserAgent/internal/taskbus"
+	"GoBrowserAgent/internal/websocket"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// wsResumeWindow 连接断开后保留状态的时间，在此期间可以凭resume_token恢复
+	wsResumeWindow = 2 * time.Minute
+	// wsReplayBuffer 为恢复连接保留的最近消息数
+	wsReplayBuffer = 512
+	// wsWriteTimeout 单条消息的写超时
+	wsWriteTimeout = 10 * time.Second
+	// wsSendQueue 每条连接的发送队列长度，能容纳恢复时补发的全部消息；队列满时断开连接，由客户端恢复
+	wsSendQueue = wsReplayBuffer + 16
+)
+
+// WSMessage WebSocket上收发的JSON消息，type决定其余字段的含义
+//
+// 客户端发送：hello、chat、task、cancel、approval、input、ping；
+// 服务端发送：welcome、delta、done、cancelled、task_created、task_event、error、pong。
+// 服务端发送的delta、done、cancelled、task_created、task_event和error带有递增的seq，断线重连时据此补发。
+type WSMessage struct {
+	Type string `json:"type"`
+	Seq  int64  `json:"seq,omitempty"`
+
+	// ID 客户端为chat和task消息生成的ID，相关的delta、done、task_created等消息会带上同一ID
+	ID        string `json:"id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	// Message chat的用户消息、done的完整回复、input补充的命令
+	Message string `json:"message,omitempty"`
+	// HTML done和cancelled中回复渲染后的安全HTML
+	HTML string `json:"html,omitempty"`
+	// Content delta中新增的内容
+	Content string     `json:"content,omitempty"`
+	Usage   *llm.Usage `json:"usage,omitempty"`
+
+	// Code、Error和Details 错误码、按连接语言给出的提示和补充信息，与HTTP接口的错误响应一致
+	Code    string      `json:"code,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+
+	// Commands task中依次执行的命令，写法与交互模式相同
+	Commands []string `json:"commands,omitempty"`
+	// TaskID task_created分配的任务ID，cancel、input和task_event通过它指明任务
+	TaskID string         `json:"task_id,omitempty"`
+	Event  *taskbus.Event `json:"event,omitempty"`
+	// ApprovalID、Approved和Comment approval答复task_event中的审批请求
+	ApprovalID string `json:"approval_id,omitempty"`
+	Approved   *bool  `json:"approved,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+
+	// ResumeToken和LastSeq hello中用于恢复之前的连接，welcome中返回新的或原有的令牌
+	ResumeToken string `json:"resume_token,omitempty"`
+	LastSeq     int64  `json:"last_seq,omitempty"`
+	Resumed     bool   `json:"resumed,omitempty"`
+	// Heartbeat 服务端发送心跳的间隔（秒）
+	Heartbeat int `json:"heartbeat,omitempty"`
+}
+
+// wsSession 一个可恢复的WebSocket会话，底层连接断开后在wsResumeWindow内保留，
+// 进行中的生成继续执行，新连接恢复后补发期间的消息
+type wsSession struct {
+	token    string
+	identity *auth.Identity
+	// ctx 携带调用方身份，会话过期或服务关闭时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	// queue 当前连接的发送队列，由writeLoop写入连接，持有mu时不进行网络写入
+	queue chan []byte
+	// lang 错误提示的语言，取自最近一次连接的请求
+	lang       string
+	detachedAt time.Time
+	seq        int64
+	buffer     []WSMessage
+	// chats 进行中的生成，chat消息ID -> 对话会话ID和取消函数
+	chats map[string]*wsChat
+	// unsubscribe 停止接收任务事件
+	unsubscribe func()
+}
+
+type wsChat struct {
+	sessionID string
+	cancel    context.CancelFunc
+}
+
+// owner 返回任务事件总线中使用的调用方标识
+func (s *wsSession) owner() string {
+	if s.identity == nil {
+		return ""
+	}
+	return s.identity.Subject
+}
+
+// send 为消息分配序号、保存到补发缓冲区，并在连接存在时立即发送
+func (s *wsSession) send(msg WSMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	msg.Seq = s.seq
+	s.buffer = append(s.buffer, msg)
+	if len(s.buffer) > wsReplayBuffer {
+		s.buffer = s.buffer[len(s.buffer)-wsReplayBuffer:]
+	}
+	s.enqueueLocked(msg)
+}
+
+// sendEphemeral 发送不需要补发的消息，例如pong
+func (s *wsSession) sendEphemeral(msg WSMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enqueueLocked(msg)
+}
+
+// errorMessage 在msg上填写错误码和按连接语言给出的提示
+func (s *wsSession) errorMessage(msg WSMessage, e *apierror.Error) WSMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errorMessageLocked(msg, e)
+}
+
+// errorMessageLocked 同errorMessage，调用方需持有mu
+func (s *wsSession) errorMessageLocked(msg WSMessage, e *apierror.Error) WSMessage {
+	msg.Type = "error"
+	msg.Code = e.Code
+	msg.Error = e.Message(s.lang)
+	msg.Details = e.Details
+	return msg
+}
+
+// enqueueLocked 把消息放进当前连接的发送队列，队列已满时断开连接，调用方需持有mu
+func (s *wsSession) enqueueLocked(msg WSMessage) {
+	if s.conn == nil {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logrus.Errorf("编码WebSocket消息失败: %v", err)
+		return
+	}
+	select {
+	case s.queue <- data:
+	default:
+		logrus.Debugf("WebSocket发送队列已满，断开连接等待客户端重连")
+		conn := s.conn
+		s.detachLocked()
+		go conn.Close(websocket.CloseGoingAway, "")
+	}
+}
+
+// writeLoop 依次把队列中的消息写入连接，队列关闭（连接已解除绑定）时退出
+func (s *wsSession) writeLoop(conn *websocket.Conn, queue <-chan []byte) {
+	for data := range queue {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			logrus.Debugf("写入WebSocket消息失败，等待客户端重连: %v", err)
+			s.detach(conn)
+			conn.Close(websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+// attach 把新连接绑定到会话，先发送welcome，再补发序号大于lastSeq的消息
+func (s *wsSession) attach(conn *websocket.Conn, lastSeq int64, resumed bool, lang string) {
+	s.mu.Lock()
+	previous := s.conn
+	s.detachLocked()
+	s.conn = conn
+	s.queue = make(chan []byte, wsSendQueue)
+	go s.writeLoop(conn, s.queue)
+	s.lang = lang
+	s.enqueueLocked(WSMessage{
+		Type:        "welcome",
+		ResumeToken: s.token,
+		Resumed:     resumed,
+		LastSeq:     s.seq,
+		Heartbeat:   int(wsPingInterval / time.Second),
+	})
+
+	if resumed {
+		// 缓冲区已经丢弃了部分消息时提示客户端重新加载对话
+		if len(s.buffer) > 0 && s.buffer[0].Seq > lastSeq+1 {
+			s.enqueueLocked(s.errorMessageLocked(WSMessage{}, apierror.New(apierror.CodeResumeIncomplete)))
+		}
+		for _, msg := range s.buffer {
+			if msg.Seq > lastSeq {
+				s.enqueueLocked(msg)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if previous != nil && previous != conn {
+		previous.Close(websocket.CloseNormal, "已在新的连接上恢复")
+	}
+}
+
+// detach 连接断开时解除绑定，开始计算恢复期限
+func (s *wsSession) detach(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == conn {
+		s.detachLocked()
+	}
+}
+
+// detachLocked 解除当前连接的绑定并关闭发送队列，调用方需持有mu，连接由调用方关闭
+func (s *wsSession) detachLocked() {
+	if s.conn == nil {
+		return
+	}
+	close(s.queue)
+	s.conn = nil
+	s.queue = nil
+	s.detachedAt = time.Now()
+}
+
+// expired 判断断开的会话是否已经超过恢复期限
+func (s *wsSession) expired(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn == nil && now.Sub(s.detachedAt) > wsResumeWindow
+}
+
+// startChat 登记一次生成，同一对话同时只能有一个生成
+func (s *wsSession) startChat(id, sessionID string) (context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chats[id]; ok {
+		return nil, false
+	}
+	for _, chat := range s.chats {
+		if sessionID != "" && chat.sessionID == sessionID {
+			return nil, false
+		}
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.chats[id] = &wsChat{sessionID: sessionID, cancel: cancel}
+	return ctx, true
+}
+
+// setChatSession 新建对话后记录分配的会话ID
+func (s *wsSession) setChatSession(id, sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if chat, ok := s.chats[id]; ok {
+		chat.sessionID = sessionID
+	}
+}
+
+// endChat 生成结束后注销
+func (s *wsSession) endChat(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if chat, ok := s.chats[id]; ok {
+		chat.cancel()
+		delete(s.chats, id)
+	}
+}
+
+// cancelChat 取消进行中的生成
+func (s *wsSession) cancelChat(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chat, ok := s.chats[id]
+	if ok {
+		chat.cancel()
+	}
+	return ok
+}
+
+// close 取消会话中的生成、停止接收任务事件并关闭连接
+func (s *wsSession) close(code int, reason string) {
+	s.cancel()
+	s.unsubscribe()
+	s.mu.Lock()
+	conn := s.conn
+	s.detachLocked()
+	s.mu.Unlock()
+	if conn != nil {
+		conn.Close(code, reason)
+	}
+}
+
+// wsHub 管理所有WebSocket会话
+type wsHub struct {
+	tasks *taskbus.Bus
+
+	mu       sync.Mutex
+	sessions map[string]*wsSession
+	closed   bool
+	stop     chan struct{}
+	// running 进行中的生成，关闭时等待它们保存会话
+	running sync.WaitGroup
+}
+
+func newWSHub(tasks *taskbus.Bus) *wsHub {
+	hub := &wsHub{
+		tasks:    tasks,
+		sessions: make(map[string]*wsSession),
+		stop:     make(chan struct{}),
+	}
+	go hub.sweepLoop()
+	return hub
+}
+
+// open 凭resume_token恢复会话，令牌无效、已过期或属于其他用户时创建新会话
+func (hub *wsHub) open(token string, identity *auth.Identity) (*wsSession, bool) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return nil, false
+	}
+
+	if s, ok := hub.sessions[token]; ok && token != "" && sameIdentity(s.identity, identity) {
+		return s, true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if identity != nil {
+		ctx = auth.WithIdentity(ctx, identity)
+	}
+	s := &wsSession{
+		token:    session.NewID(),
+		identity: identity,
+		ctx:      ctx,
+		cancel:   cancel,
+		chats:    make(map[string]*wsChat),
+	}
+
+	// 同一用户的任务事件推送到该用户的每个会话，断开期间的事件在恢复时补发
+	events, unsubscribe := hub.tasks.Subscribe(s.owner())
+	s.unsubscribe = unsubscribe
+	go func() {
+		for event := range events {
+			event := event
+			s.send(WSMessage{Type: "task_event", TaskID: event.TaskID, ApprovalID: event.ApprovalID, Event: &event})
+		}
+	}()
+
+	hub.sessions[s.token] = s
+	return s, false
+}
+
+// track 登记一次进行中的生成，服务关闭后返回false
+func (hub *wsHub) track() bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return false
+	}
+	hub.running.Add(1)
+	return true
+}
+
+// sweepLoop 定期清理超过恢复期限的会话
+func (hub *wsHub) sweepLoop() {
+	ticker := time.NewTicker(wsResumeWindow / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hub.stop:
+			return
+		case now := <-ticker.C:
+			var expired []*wsSession
+			hub.mu.Lock()
+			for token, s := range hub.sessions {
+				if s.expired(now) {
+					delete(hub.sessions, token)
+					expired = append(expired, s)
+				}
+			}
+			hub.mu.Unlock()
+			for _, s := range expired {
+				s.close(websocket.CloseGoingAway, "")
+			}
+		}
+	}
+}
+
+// close 关闭所有会话，不再接受新连接
+func (hub *wsHub) close() {
+	hub.mu.Lock()
+	if hub.closed {
+		hub.mu.Unlock()
+		return
+	}
+	hub.closed = true
+	close(hub.stop)
+	sessions := make([]*wsSession, 0, len(hub.sessions))
+	for token, s := range hub.sessions {
+		delete(hub.sessions, token)
+		sessions = append(sessions, s)
+	}
+	hub.mu.Unlock()
+
+	for _, s := range sessions {
+		s.close(websocket.CloseGoingAway, "服务器正在关闭")
+	}
+}
+
+// sameIdentity 判断两个身份是否为同一调用方
+func sameIdentity(a, b *auth.Identity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Name == b.Name && a.Method == b.Method
+}
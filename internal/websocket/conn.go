@@ -0,0 +1,341 @@
+// Package websocket 实现RFC 6455 WebSocket协议的服务端和客户端
+//
+// 只实现项目需要的部分：文本/二进制消息、分片、ping/pong和关闭握手，不支持扩展（例如压缩）。
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型（操作码）
+const (
+	continuationFrame = 0x0
+	TextMessage       = 0x1
+	BinaryMessage     = 0x2
+	CloseMessage      = 0x8
+	PingMessage       = 0x9
+	PongMessage       = 0xA
+)
+
+// 关闭状态码
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// maxControlPayload 控制帧负载的最大长度
+const maxControlPayload = 125
+
+// ErrClosed 连接已关闭
+var ErrClosed = errors.New("websocket连接已关闭")
+
+// CloseError 对端发送了关闭帧
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket连接已关闭: %d %s", e.Code, e.Reason)
+}
+
+// Conn WebSocket连接
+//
+// 同一时间只能有一个goroutine调用ReadMessage，写方法可以并发调用。
+type Conn struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	isClient bool
+
+	// MaxMessageSize 单条消息的最大字节数，0表示不限制
+	MaxMessageSize int64
+
+	writeMu sync.Mutex
+	closed  bool
+
+	handlerMu   sync.Mutex
+	pongHandler func(data []byte)
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, isClient bool) *Conn {
+	if reader == nil {
+		reader = bufio.NewReader(conn)
+	}
+	return &Conn{conn: conn, reader: reader, isClient: isClient}
+}
+
+// SetPongHandler 设置收到pong时的回调，通常用于延长读超时
+func (c *Conn) SetPongHandler(handler func(data []byte)) {
+	c.handlerMu.Lock()
+	defer c.handlerMu.Unlock()
+	c.pongHandler = handler
+}
+
+// SetReadDeadline 设置读超时
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// RemoteAddr 返回对端地址
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage 读取一条完整的数据消息，自动回复ping并处理关闭握手
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		messageType int
+		message     []byte
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteControl(PongMessage, payload); err != nil && !errors.Is(err, ErrClosed) {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			c.handlerMu.Lock()
+			handler := c.pongHandler
+			c.handlerMu.Unlock()
+			if handler != nil {
+				handler(payload)
+			}
+			continue
+		case CloseMessage:
+			closeErr := &CloseError{Code: CloseNoStatus}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			// 回复关闭帧后关闭底层连接
+			c.writeClose(closeErr.Code, "")
+			c.conn.Close()
+			return 0, nil, closeErr
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				c.fail(CloseProtocolError, "上一条分片消息尚未结束")
+				return 0, nil, fmt.Errorf("websocket协议错误: 分片消息中出现新消息")
+			}
+			messageType = opcode
+		case continuationFrame:
+			if messageType == 0 {
+				c.fail(CloseProtocolError, "意外的延续帧")
+				return 0, nil, fmt.Errorf("websocket协议错误: 意外的延续帧")
+			}
+		default:
+			c.fail(CloseProtocolError, "未知的操作码")
+			return 0, nil, fmt.Errorf("websocket协议错误: 未知的操作码%d", opcode)
+		}
+
+		if c.MaxMessageSize > 0 && int64(len(message)+len(payload)) > c.MaxMessageSize {
+			c.fail(CloseMessageTooBig, "消息过大")
+			return 0, nil, fmt.Errorf("websocket消息超过%d字节", c.MaxMessageSize)
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				c.fail(CloseInvalidPayload, "文本消息不是有效的UTF-8")
+				return 0, nil, fmt.Errorf("websocket文本消息不是有效的UTF-8")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// readFrame 读取一帧
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		c.fail(CloseProtocolError, "不支持扩展")
+		return false, 0, nil, fmt.Errorf("websocket协议错误: 设置了保留位")
+	}
+	opcode := int(header[0] & 0x0F)
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+
+	// 客户端发送的帧必须带掩码，服务端发送的帧不能带掩码
+	if masked == c.isClient {
+		c.fail(CloseProtocolError, "掩码设置错误")
+		return false, 0, nil, fmt.Errorf("websocket协议错误: 掩码设置错误")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			c.fail(CloseProtocolError, "帧长度无效")
+			return false, 0, nil, fmt.Errorf("websocket协议错误: 帧长度无效")
+		}
+	}
+
+	if opcode >= CloseMessage && (length > maxControlPayload || !fin) {
+		c.fail(CloseProtocolError, "控制帧无效")
+		return false, 0, nil, fmt.Errorf("websocket协议错误: 控制帧无效")
+	}
+	if c.MaxMessageSize > 0 && length > c.MaxMessageSize {
+		c.fail(CloseMessageTooBig, "消息过大")
+		return false, 0, nil, fmt.Errorf("websocket消息超过%d字节", c.MaxMessageSize)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage 发送一条数据消息
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("不支持的消息类型: %d", messageType)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// WriteControl 发送控制帧
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("不支持的控制帧类型: %d", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("控制帧负载不能超过%d字节", maxControlPayload)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// Close 发送关闭帧并关闭连接
+func (c *Conn) Close(code int, reason string) error {
+	c.writeClose(code, reason)
+	return c.conn.Close()
+}
+
+// writeClose 发送关闭帧，之后不能再写入
+func (c *Conn) writeClose(code int, reason string) {
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeFrameLocked(CloseMessage, payload)
+	c.closed = true
+}
+
+// fail 因协议错误关闭连接
+func (c *Conn) fail(code int, reason string) {
+	c.writeClose(code, reason)
+	c.conn.Close()
+}
+
+// writeFrame 写入一个完整帧
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+// writeFrameLocked 写入一个完整帧，调用方需持有writeMu
+func (c *Conn) writeFrameLocked(opcode int, payload []byte) error {
+	header := make([]byte, 0, 14)
+	header = append(header, 0x80|byte(opcode))
+
+	var maskBit byte
+	if c.isClient {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, maskBit|byte(length))
+	case length <= 0xFFFF:
+		header = append(header, maskBit|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, maskBit|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	data := payload
+	if c.isClient {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		header = append(header, mask[:]...)
+		data = make([]byte, len(payload))
+		copy(data, payload)
+		maskBytes(mask, data)
+	}
+
+	frame := append(header, data...)
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// SetWriteDeadline 设置写超时
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// maskBytes 按RFC 6455对负载进行掩码运算
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
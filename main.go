@@ -1,45 +1,279 @@
 package main
 
 import (
+	"GoBrowserAgent/internal/apierror"
+	"GoBrowserAgent/internal/auth"
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/internal/gateway"
+	"GoBrowserAgent/internal/httpclient"
+	"GoBrowserAgent/internal/lifecycle"
+	"GoBrowserAgent/internal/openapi"
+	"GoBrowserAgent/internal/ratelimit"
+	"GoBrowserAgent/internal/rbac"
+	"GoBrowserAgent/internal/repl"
+	"GoBrowserAgent/internal/script"
 	"GoBrowserAgent/internal/service/llm"
+	"GoBrowserAgent/internal/session"
+	"GoBrowserAgent/internal/taskbus"
 	"GoBrowserAgent/internal/web"
+	"context"
+	"flag"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "batch":
+			os.Exit(runBatch(os.Args[2:]))
+		case "eval":
+			os.Exit(runEval(os.Args[2:]))
+		case "hash-password":
+			os.Exit(runHashPassword(os.Args[2:]))
+		case "vault":
+			os.Exit(runVault(os.Args[2:]))
+		case "state":
+			os.Exit(runState(os.Args[2:]))
+		}
+	}
+
+	configPath := flag.String("config", llm.GetConfigPath(), "配置文件路径")
+	webMode := flag.Bool("web", false, "启动Web服务；不指定时，在终端中运行进入交互模式")
+	scriptPath := flag.String("script", "", "要执行的脚本文件路径")
+	verbose := flag.Bool("verbose", false, "显示详细的执行信息")
+	continueOnError := flag.Bool("continue-on-error", false, "脚本中的命令失败后继续执行后面的命令")
+	flag.Parse()
+
+	if *scriptPath != "" {
+		os.Exit(runScript(*configPath, *scriptPath, script.Options{Verbose: *verbose, ContinueOnError: *continueOnError}))
+	}
+
+	// 在终端中直接运行时进入交互模式，作为服务运行（标准输入不是终端）时仍然启动Web服务
+	if !*webMode && repl.IsTerminal(int(os.Stdin.Fd())) {
+		os.Exit(runREPL(*configPath))
+	}
+
 	logrus.Info("开始启动web服务")
 
-	// 加载LLM配置
-	configPath := llm.GetConfigPath()
-	llmConfig, err := llm.LoadConfig(configPath)
+	llmService, llmConfig, err := newLLMService(*configPath, nil)
 	if err != nil {
-		logrus.Warnf("加载LLM配置失败: %v, 将使用默认配置", err)
-		// 使用默认配置
-		llmConfig = llm.GetDefaultConfig()
+		logrus.Errorf("%v", err)
+		os.Exit(1)
 	}
 
-	// 创建LLM服务
-	llmService := llm.NewService(llmConfig)
+	// 打开会话存储
+	storeConfig, err := session.LoadConfig(*configPath)
+	if err != nil {
+		logrus.Warnf("加载会话存储配置失败: %v, 将使用默认配置", err)
+		storeConfig = session.GetDefaultConfig()
+	}
+	sessions, err := session.Open(storeConfig)
+	if err != nil {
+		logrus.Errorf("打开会话存储失败: %v", err)
+		os.Exit(1)
+	}
+
+	// 退出时的清理按注册的逆序执行，存储最先打开、最后关闭
+	lc := lifecycle.New()
+	lc.OnShutdown("会话存储", func(ctx context.Context) error {
+		return sessions.Close()
+	})
+
+	// 加载认证配置
+	authConfig, err := auth.LoadConfig(*configPath)
+	if err != nil {
+		logrus.Warnf("加载认证配置失败: %v, 将使用默认配置", err)
+		authConfig = auth.GetDefaultConfig()
+	}
+	authenticator, err := auth.NewAuthenticator(authConfig, llmService.HTTPClient)
+	if err != nil {
+		logrus.Errorf("初始化认证失败: %v", err)
+		os.Exit(1)
+	}
+	if !authenticator.Enabled() {
+		logrus.Warn("未启用认证，任何能访问该端口的人都可以使用API")
+	}
+	authenticator.RegisterHandlers()
+
+	// 接口文档，各模块注册处理程序时一并添加接口描述
+	spec := openapi.New(authConfig.CookieName)
+	spec.Add(authenticator.Routes()...)
+
+	// 加载权限策略
+	rbacConfig, err := rbac.LoadConfig(*configPath)
+	if err != nil {
+		logrus.Warnf("加载权限控制配置失败: %v, 将使用默认配置", err)
+		rbacConfig = rbac.GetDefaultConfig()
+	}
+	access, err := rbac.NewEnforcer(rbacConfig, authenticator.Enabled())
+	if err != nil {
+		logrus.Errorf("初始化权限控制失败: %v", err)
+		os.Exit(1)
+	}
+	lc.OnShutdown("审计日志", func(ctx context.Context) error {
+		return access.Close()
+	})
+	access.RegisterHandlers()
+	spec.Add(access.Routes()...)
+
+	// 开放OpenAI兼容的LLM网关
+	gatewayConfig, err := gateway.LoadConfig(*configPath)
+	if err != nil {
+		logrus.Warnf("加载LLM网关配置失败: %v, 将使用默认配置", err)
+		gatewayConfig = gateway.GetDefaultConfig()
+	}
+	if gatewayConfig.Enabled {
+		llmGateway, err := gateway.NewGateway(gatewayConfig, llmService, access, authenticator.Enabled())
+		if err != nil {
+			logrus.Errorf("初始化LLM网关失败: %v", err)
+			os.Exit(1)
+		}
+		lc.OnShutdown("网关用量记录", func(ctx context.Context) error {
+			return llmGateway.Close()
+		})
+		llmGateway.RegisterHandlers()
+		spec.Add(llmGateway.Routes()...)
+	}
+
+	// 加载限流配置
+	rateConfig, err := ratelimit.LoadConfig(*configPath)
+	if err != nil {
+		logrus.Warnf("加载限流配置失败: %v, 将使用默认配置", err)
+		rateConfig = ratelimit.GetDefaultConfig()
+	}
+	limiter := ratelimit.NewLimiter(rateConfig)
+
+	// 运行网页提交的浏览器任务，与交互模式使用相同的浏览器和凭据保险库配置
+	browserConfig, transport, taskVault, err := loadBrowserSetup(*configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		os.Exit(1)
+	}
+	runner := command.NewRunner(browserConfig, transport, taskbus.New())
+	runner.Vault = taskVault
+	runner.Access = access
+	lc.OnShutdown("浏览器任务", runner.Close)
 
 	// 创建API处理程序
-	apiHandler := web.NewAPIHandler(llmService)
+	apiHandler := web.NewAPIHandler(llmService, sessions, access, limiter, runner)
 	apiHandler.RegisterHandlers()
+	spec.Add(apiHandler.Routes()...)
+	spec.RegisterHandlers()
+	// 服务器不跟踪已升级的WebSocket连接，等普通请求处理完后再关闭
+	lc.OnShutdown("WebSocket连接", apiHandler.CloseWebSockets)
+
+	// 加载Web服务器配置
+	webConfig, err := web.LoadConfig(*configPath)
+	if err != nil {
+		logrus.Warnf("加载Web配置失败: %v, 将使用默认配置", err)
+		webConfig = web.GetDefaultConfig()
+	}
 
-	// 设置静态文件服务
-	fs := http.FileServer(http.Dir(filepath.Join("internal", "web", "static")))
-	http.Handle("/", fs)
+	// 设置静态文件服务，页面已编译进二进制
+	http.Handle("/", web.NewStaticHandler(webConfig.StaticDir))
 
 	// 启动HTTP服务器
-	port := "8080"
-	logrus.Infof("Web服务器正在运行: http://localhost:%s", port)
+	// 最外层分配请求ID，之后先认证再限流，限流按用户或API令牌计数，最后按接口文档校验请求
+	server := web.NewServer(webConfig, apierror.Middleware(authenticator.Middleware(limiter.Middleware(spec.Middleware(http.DefaultServeMux)))))
+	// 请求的context在宽限期结束后取消，让仍在等待LLM的请求尽快返回
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	server.BaseContext = func(net.Listener) context.Context { return requestCtx }
+
+	lc.OnShutdown("出站HTTP连接", func(ctx context.Context) error {
+		llmService.HTTPClient.CloseIdleConnections()
+		return nil
+	})
+
+	displayHost := webConfig.Host
+	if displayHost == "" || displayHost == "0.0.0.0" {
+		displayHost = "localhost"
+	}
+	logrus.Infof("Web服务器正在运行: http://%s", net.JoinHostPort(displayHost, strconv.Itoa(webConfig.Port)))
 	logrus.Infof("配置的LLM模型: %s", llmConfig.Model)
-	err = http.ListenAndServe(":"+port, nil)
-	if err != nil {
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
 		logrus.Errorf("HTTP服务器启动失败: %v", err)
-		os.Exit(1)
+		exitCode = 1
+	case <-signalCtx.Done():
+		// 恢复默认信号处理，再次按Ctrl+C可立即退出
+		stop()
+		grace := time.Duration(webConfig.ShutdownTimeout) * time.Second
+		logrus.Infof("收到退出信号，等待进行中的请求完成（最长%s）", grace)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logrus.Warnf("等待请求完成超时，强制关闭剩余连接: %v", err)
+			cancelRequests()
+			server.Close()
+		}
+		cancel()
+	}
+
+	// 取消后台任务并执行清理，给清理留出与宽限期相同的时间
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Duration(webConfig.ShutdownTimeout)*time.Second)
+	if err := lc.Shutdown(cleanupCtx); err != nil {
+		exitCode = 1
+	}
+	cancel()
+
+	logrus.Info("Web服务已退出")
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
 }
+
+// newLLMService 加载配置并创建LLM服务，replay不为空时覆盖配置中的录制/回放设置
+func newLLMService(configPath string, replay *httpclient.ReplayConfig) (*llm.Service, *llm.Config, error) {
+	// 加载LLM配置
+	llmConfig, err := llm.LoadConfig(configPath)
+	if err != nil {
+		logrus.Warnf("加载LLM配置失败: %v, 将使用默认配置", err)
+		// 使用默认配置
+		llmConfig = llm.GetDefaultConfig()
+	}
+
+	// 加载出站HTTP配置
+	httpConfig, err := httpclient.LoadConfig(configPath)
+	if err != nil {
+		logrus.Warnf("加载出站HTTP配置失败: %v, 将使用默认配置", err)
+		httpConfig = httpclient.GetDefaultConfig()
+	}
+	if replay != nil {
+		httpConfig.Replay = replay
+	}
+
+	// 回放模式不访问真实接口，允许不配置API密钥
+	if httpConfig.Replay != nil && httpConfig.Replay.Mode == httpclient.ReplayModeReplay && llmConfig.APIKey == "" {
+		llmConfig.APIKey = "replay"
+	}
+
+	// 创建共享的出站HTTP客户端
+	httpClient, err := httpclient.New(httpConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建出站HTTP客户端失败: %v", err)
+	}
+
+	// 创建LLM服务
+	return llm.NewService(llmConfig, httpClient), llmConfig, nil
+}
@@ -0,0 +1,153 @@
+// Package browser 定义浏览器控制接口，并提供两种实现：基于Chrome DevTools Protocol的CDPBrowser，
+// 以及只发送HTTP请求、不执行JavaScript的HTTPBrowser
+//
+// 任务和命令解析只依赖Browser和Page接口，具体的浏览器由New按配置的后端启动或连接。
+// 所有操作都接受context，未设置截止时间时使用配置中的timeout；操作元素和WaitFor系列方法在超时前
+// 反复检查，页面还在加载或动画还没结束时不需要额外的固定等待。
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrElementNotFound 选择器没有匹配到元素
+var ErrElementNotFound = errors.New("未找到匹配的元素")
+
+// ErrElementNotVisible 元素存在但没有可点击的区域，例如被隐藏或尺寸为0
+var ErrElementNotVisible = errors.New("元素不可见")
+
+// ErrElementDisabled 元素已禁用，例如带disabled属性的按钮
+var ErrElementDisabled = errors.New("元素已禁用")
+
+// ErrElementNotStable 元素的位置或大小还在变化，例如正在播放动画
+var ErrElementNotStable = errors.New("元素位置不稳定")
+
+// ErrNotInForm Submit的元素既不是表单也不在表单中，例如没有<form>的单页应用登录框
+var ErrNotInForm = errors.New("元素不在表单中")
+
+// ErrConditionNotMet 等待的条件在超时前没有满足
+var ErrConditionNotMet = errors.New("条件没有满足")
+
+// ErrUnsupported 当前后端不支持该操作，例如HTTP后端执行JavaScript或截图
+var ErrUnsupported = errors.New("当前浏览器后端不支持此操作")
+
+// 可选的浏览器后端
+const (
+	BackendChrome = "chrome"
+	BackendHTTP   = "http"
+)
+
+// Browser 一个浏览器实例
+type Browser interface {
+	// NewPage 打开新的空白页面
+	NewPage(ctx context.Context) (Page, error)
+	// Close 关闭浏览器打开的所有页面；浏览器由本程序启动时同时退出浏览器
+	Close() error
+}
+
+// Page 浏览器中的一个页面（标签页）
+type Page interface {
+	// Navigate 打开地址并等待页面加载完成
+	Navigate(ctx context.Context, url string) error
+	// URL 返回当前地址
+	URL(ctx context.Context) (string, error)
+	// Title 返回页面标题
+	Title(ctx context.Context) (string, error)
+	// Query 返回选择器匹配的元素，没有匹配时返回空列表；选择器可以是CSS、XPath、文字、角色等，
+	// 语法见selector.go
+	Query(ctx context.Context, selector string) ([]Element, error)
+	// Click 滚动到第一个匹配的元素并用鼠标点击其中心
+	//
+	// Click、Type和Fill先等待元素可以操作：可见、没有禁用，并且位置不再变化；Submit等待元素出现且没有禁用。
+	// 超时后返回最后一次检查的原因，例如ErrElementNotFound。
+	Click(ctx context.Context, selector string) error
+	// Type 聚焦第一个匹配的元素并输入文字，输入追加在已有内容之后
+	Type(ctx context.Context, selector, text string) error
+	// Fill 清空第一个匹配的输入框后输入文字；下拉框选择值或文字相同的选项，
+	// 复选框和单选框按value是否为true、on、1等设置选中状态
+	Fill(ctx context.Context, selector, value string) error
+	// Submit 提交第一个匹配的元素所在的表单并等待新页面加载；元素是提交按钮时以它作为提交者
+	Submit(ctx context.Context, selector string) error
+	// WaitForSelector 等待选择器匹配的元素达到指定状态，state为空时等待元素可见
+	WaitForSelector(ctx context.Context, selector string, state WaitState) error
+	// WaitForURL 等待页面地址匹配正则表达式
+	WaitForURL(ctx context.Context, pattern *regexp.Regexp) error
+	// WaitForFunction 等待JavaScript表达式的结果为真值，表达式抛出异常时立即返回错误
+	WaitForFunction(ctx context.Context, expression string) error
+	// WaitForNetworkIdle 等待网络空闲：没有进行中的请求，并且持续NetworkIdleTime
+	WaitForNetworkIdle(ctx context.Context) error
+	// Evaluate 执行JavaScript表达式，返回JSON编码的结果；结果为Promise时等待其完成
+	Evaluate(ctx context.Context, expression string) (json.RawMessage, error)
+	// Screenshot 截取当前视口，返回PNG图片
+	Screenshot(ctx context.Context) ([]byte, error)
+	// Cookies 返回当前页面可见的Cookie
+	Cookies(ctx context.Context) ([]Cookie, error)
+	// SetCookies 设置Cookie，Cookie需要带URL或Domain
+	SetCookies(ctx context.Context, cookies []Cookie) error
+	// StorageState 返回浏览器中的所有Cookie，以及当前页面和其中iframe所在来源的localStorage和sessionStorage
+	StorageState(ctx context.Context) (*StorageState, error)
+	// SetStorageState 恢复StorageState保存的Cookie和各来源的存储，已过期的Cookie会被跳过；
+	// 页面停留在about:blank，之后再导航到需要的地址
+	SetStorageState(ctx context.Context, state *StorageState) error
+	// Close 关闭页面
+	Close() error
+}
+
+// Element Query返回的元素信息
+type Element struct {
+	Tag        string            `json:"tag"`
+	Text       string            `json:"text"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Visible 元素是否有可见的区域
+	Visible bool `json:"visible"`
+	// X、Y、Width、Height 元素在视口中的位置和大小（CSS像素）
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Cookie 浏览器Cookie，字段与CDP的Network.Cookie一致
+type Cookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// URL 设置Cookie时用于推断Domain和Path，读取时为空
+	URL string `json:"url,omitempty"`
+	// Expires 过期时间（Unix秒），会话Cookie为-1或0
+	Expires  float64 `json:"expires,omitempty"`
+	HTTPOnly bool    `json:"httpOnly,omitempty"`
+	Secure   bool    `json:"secure,omitempty"`
+	// SameSite Strict、Lax或None
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+// New 按配置的后端创建浏览器：chrome后端连接已经运行的浏览器或启动新的浏览器，
+// http后端使用默认的HTTP传输，需要代理等设置时改用NewHTTPBrowser
+func New(ctx context.Context, config *Config) (Browser, error) {
+	switch config.Backend {
+	case "", BackendChrome:
+		if config.RemoteURL != "" {
+			return Connect(ctx, config)
+		}
+		return Launch(ctx, config)
+	case BackendHTTP:
+		return NewHTTPBrowser(config, nil), nil
+	}
+	return nil, fmt.Errorf("未知的浏览器后端: %s", config.Backend)
+}
+
+// checkedValue 判断Fill复选框和单选框时的值是否表示选中
+func checkedValue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "on", "1", "yes", "checked":
+		return true
+	}
+	return false
+}
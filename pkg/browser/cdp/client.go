@@ -0,0 +1,284 @@
+// Package cdp 实现Chrome DevTools Protocol的消息层
+//
+// Client在一条WebSocket连接上收发CDP命令和事件，使用Target.attachToTarget的flatten模式，
+// 所有页面共用浏览器级别的连接，按sessionId区分。只负责协议本身，不关心具体的域和命令，
+// 因此可以用任何实现了同样消息格式的WebSocket服务端测试。
+package cdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"GoBrowserAgent/internal/websocket"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxMessageSize 单条CDP消息的上限，截图等响应可能有几十MB
+const maxMessageSize = 256 << 20
+
+// ErrClosed 连接已关闭
+var ErrClosed = errors.New("CDP连接已关闭")
+
+// Error 浏览器返回的命令错误
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Data != "" {
+		return fmt.Sprintf("CDP错误%d: %s (%s)", e.Code, e.Message, e.Data)
+	}
+	return fmt.Sprintf("CDP错误%d: %s", e.Code, e.Message)
+}
+
+// Event 浏览器推送的事件
+type Event struct {
+	// SessionID 事件所属的会话，浏览器级别的事件为空
+	SessionID string
+	Method    string
+	Params    json.RawMessage
+}
+
+// command 发送的CDP命令
+type command struct {
+	ID        int64       `json:"id"`
+	SessionID string      `json:"sessionId,omitempty"`
+	Method    string      `json:"method"`
+	Params    interface{} `json:"params,omitempty"`
+}
+
+// incoming 收到的响应或事件，Params和Result保持原始JSON
+type incoming struct {
+	ID        int64           `json:"id"`
+	SessionID string          `json:"sessionId"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+	Result    json.RawMessage `json:"result"`
+	Error     *Error          `json:"error"`
+}
+
+// Client CDP连接，可以并发调用
+type Client struct {
+	conn   *websocket.Conn
+	nextID atomic.Int64
+
+	mu        sync.Mutex
+	pending   map[int64]chan incoming
+	listeners map[int64]func(Event)
+	nextSub   int64
+	err       error
+
+	done chan struct{}
+}
+
+// Dial 连接浏览器的DevTools WebSocket地址
+func Dial(ctx context.Context, wsURL string) (*Client, error) {
+	conn, err := websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("连接DevTools失败: %v", err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient 在已建立的WebSocket连接上创建客户端，开始读取消息
+func NewClient(conn *websocket.Conn) *Client {
+	conn.MaxMessageSize = maxMessageSize
+	c := &Client{
+		conn:      conn,
+		pending:   make(map[int64]chan incoming),
+		listeners: make(map[int64]func(Event)),
+		done:      make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Call 发送命令并等待响应，sessionID为空时发给浏览器本身
+//
+// params为nil时不发送参数，result为nil时忽略返回值。ctx取消后不再等待，
+// 但命令可能已经在浏览器中执行。
+func (c *Client) Call(ctx context.Context, sessionID, method string, params, result interface{}) error {
+	id := c.nextID.Add(1)
+	ch := make(chan incoming, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(command{ID: id, SessionID: sessionID, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("编码CDP命令%s失败: %v", method, err)
+	}
+	logrus.Tracef("CDP -> %s", data)
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return fmt.Errorf("发送CDP命令%s失败: %v", method, err)
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %w", method, resp.Error)
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("解析%s的返回值失败: %v", method, err)
+			}
+		}
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// Listen 注册事件处理函数，返回取消注册的函数
+//
+// 处理函数在读取消息的goroutine中按顺序调用，不能阻塞，也不能在其中调用Call。
+func (c *Client) Listen(handler func(Event)) func() {
+	c.mu.Lock()
+	c.nextSub++
+	id := c.nextSub
+	c.listeners[id] = handler
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		delete(c.listeners, id)
+		c.mu.Unlock()
+	}
+}
+
+// WaitEvent 注册对指定会话上满足条件的事件的等待，match为nil时匹配第一个同名事件
+//
+// 应在发送触发事件的命令之前调用，避免错过事件。wait阻塞到事件到达或ctx结束，
+// 不再等待时调用stop取消注册，stop可以重复调用。
+func (c *Client) WaitEvent(sessionID, method string, match func(json.RawMessage) bool) (wait func(ctx context.Context) (json.RawMessage, error), stop func()) {
+	ch := make(chan json.RawMessage, 1)
+	remove := c.Listen(func(ev Event) {
+		if ev.SessionID != sessionID || ev.Method != method {
+			return
+		}
+		if match != nil && !match(ev.Params) {
+			return
+		}
+		select {
+		case ch <- ev.Params:
+		default:
+		}
+	})
+	var once sync.Once
+	stop = func() { once.Do(remove) }
+	wait = func(ctx context.Context) (json.RawMessage, error) {
+		defer stop()
+		select {
+		case params := <-ch:
+			return params, nil
+		case <-c.done:
+			return nil, c.Err()
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待%s: %w", method, ctx.Err())
+		}
+	}
+	return wait, stop
+}
+
+// Done 连接断开后关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err 连接断开的原因，连接正常时返回nil
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClosed
+	}
+	c.mu.Unlock()
+	err := c.conn.Close(websocket.CloseNormal, "")
+	<-c.done
+	return err
+}
+
+// readLoop 读取消息，把响应交给等待的调用方，把事件分发给监听者
+func (c *Client) readLoop() {
+	var readErr error
+	defer func() {
+		c.mu.Lock()
+		switch {
+		case c.err != nil:
+			// 主动关闭
+		case readErr == nil:
+			c.err = ErrClosed
+		default:
+			c.err = fmt.Errorf("%w: %v", ErrClosed, readErr)
+		}
+		c.mu.Unlock()
+		close(c.done)
+	}()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				readErr = err
+			}
+			return
+		}
+		logrus.Tracef("CDP <- %.512s", data)
+
+		var msg incoming
+		if err := json.Unmarshal(data, &msg); err != nil {
+			logrus.Warnf("忽略无法解析的CDP消息: %v", err)
+			continue
+		}
+
+		if msg.ID != 0 {
+			c.mu.Lock()
+			ch := c.pending[msg.ID]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+			continue
+		}
+		if msg.Method == "" {
+			continue
+		}
+
+		c.mu.Lock()
+		handlers := make([]func(Event), 0, len(c.listeners))
+		for _, h := range c.listeners {
+			handlers = append(handlers, h)
+		}
+		c.mu.Unlock()
+		ev := Event{SessionID: msg.SessionID, Method: msg.Method, Params: msg.Params}
+		for _, h := range handlers {
+			h(ev)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package cdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"GoBrowserAgent/internal/websocket"
+)
+
+// fakeBrowser 模拟DevTools端点，每条连接交给serve处理
+func fakeBrowser(t *testing.T, serve func(conn *websocket.Conn)) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		serve(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// readCommand 读取客户端发送的一条命令
+func readCommand(t *testing.T, conn *websocket.Conn) (command, json.RawMessage, bool) {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return command{}, nil, false
+	}
+	var cmd struct {
+		command
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		t.Errorf("decode command: %v", err)
+		return command{}, nil, false
+	}
+	return cmd.command, cmd.Params, true
+}
+
+// send 向客户端发送一条JSON消息
+func send(t *testing.T, conn *websocket.Conn, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		t.Errorf("write: %v", err)
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestCallMatchesResponsesByID(t *testing.T) {
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		// 收齐两条命令后倒序回复，回复内容为命令的方法名和会话
+		var cmds []command
+		for len(cmds) < 2 {
+			cmd, _, ok := readCommand(t, conn)
+			if !ok {
+				return
+			}
+			cmds = append(cmds, cmd)
+		}
+		for i := len(cmds) - 1; i >= 0; i-- {
+			send(t, conn, map[string]interface{}{
+				"id":     cmds[i].ID,
+				"result": map[string]string{"method": cmds[i].Method, "session": cmds[i].SessionID},
+			})
+		}
+	})
+
+	ctx := testContext(t)
+	var wg sync.WaitGroup
+	for _, call := range []struct{ session, method string }{{"", "Browser.getVersion"}, {"S1", "Page.navigate"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result struct{ Method, Session string }
+			if err := client.Call(ctx, call.session, call.method, nil, &result); err != nil {
+				t.Errorf("%s: %v", call.method, err)
+				return
+			}
+			if result.Method != call.method || result.Session != call.session {
+				t.Errorf("%s got response for %s/%q", call.method, result.Method, result.Session)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCallSendsParams(t *testing.T) {
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		cmd, params, ok := readCommand(t, conn)
+		if !ok {
+			return
+		}
+		send(t, conn, map[string]interface{}{"id": cmd.ID, "result": map[string]json.RawMessage{"echo": params}})
+	})
+
+	var result struct {
+		Echo struct {
+			URL string `json:"url"`
+		} `json:"echo"`
+	}
+	err := client.Call(testContext(t), "S1", "Page.navigate", map[string]string{"url": "https://example.com"}, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Echo.URL != "https://example.com" {
+		t.Fatalf("params = %+v", result.Echo)
+	}
+}
+
+func TestCallErrorReply(t *testing.T) {
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		cmd, _, ok := readCommand(t, conn)
+		if !ok {
+			return
+		}
+		send(t, conn, map[string]interface{}{
+			"id":    cmd.ID,
+			"error": map[string]interface{}{"code": -32000, "message": "No node with given id", "data": "nodeId=7"},
+		})
+	})
+
+	err := client.Call(testContext(t), "S1", "DOM.focus", nil, nil)
+	var cdpErr *Error
+	if !errors.As(err, &cdpErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if cdpErr.Code != -32000 || cdpErr.Message != "No node with given id" || cdpErr.Data != "nodeId=7" {
+		t.Fatalf("error = %+v", cdpErr)
+	}
+	if !strings.HasPrefix(err.Error(), "DOM.focus: ") {
+		t.Fatalf("error %q does not name the method", err)
+	}
+}
+
+func TestEventFanOut(t *testing.T) {
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		// 收到触发命令后推送事件再回复，事件一定先于响应到达
+		cmd, _, ok := readCommand(t, conn)
+		if !ok {
+			return
+		}
+		send(t, conn, map[string]interface{}{"method": "Target.targetCreated", "params": map[string]string{"targetId": "T1"}})
+		send(t, conn, map[string]interface{}{"sessionId": "S1", "method": "Page.loadEventFired", "params": map[string]float64{"timestamp": 1}})
+		send(t, conn, map[string]interface{}{"id": cmd.ID, "result": map[string]string{}})
+	})
+
+	var mu sync.Mutex
+	var first, second []Event
+	stopFirst := client.Listen(func(ev Event) {
+		mu.Lock()
+		first = append(first, ev)
+		mu.Unlock()
+	})
+	defer stopFirst()
+	stopSecond := client.Listen(func(ev Event) {
+		mu.Lock()
+		second = append(second, ev)
+		mu.Unlock()
+	})
+	defer stopSecond()
+
+	if err := client.Call(testContext(t), "", "Target.setDiscoverTargets", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for name, events := range map[string][]Event{"first": first, "second": second} {
+		if len(events) != 2 {
+			t.Fatalf("%s listener got %d events, want 2", name, len(events))
+		}
+		if events[0].Method != "Target.targetCreated" || events[0].SessionID != "" {
+			t.Errorf("%s listener event 0 = %+v", name, events[0])
+		}
+		if events[1].Method != "Page.loadEventFired" || events[1].SessionID != "S1" {
+			t.Errorf("%s listener event 1 = %+v", name, events[1])
+		}
+	}
+}
+
+func TestListenStop(t *testing.T) {
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		for {
+			cmd, _, ok := readCommand(t, conn)
+			if !ok {
+				return
+			}
+			send(t, conn, map[string]interface{}{"method": "Runtime.consoleAPICalled"})
+			send(t, conn, map[string]interface{}{"id": cmd.ID, "result": map[string]string{}})
+		}
+	})
+
+	var mu sync.Mutex
+	count := 0
+	stop := client.Listen(func(Event) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+	})
+	ctx := testContext(t)
+	if err := client.Call(ctx, "", "Runtime.enable", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if err := client.Call(ctx, "", "Runtime.enable", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if count != 1 {
+		t.Fatalf("listener called %d times after stop, want 1", count)
+	}
+}
+
+func TestWaitEvent(t *testing.T) {
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		cmd, _, ok := readCommand(t, conn)
+		if !ok {
+			return
+		}
+		// 其他会话、其他事件和不满足条件的事件都应被忽略
+		send(t, conn, map[string]interface{}{"sessionId": "S2", "method": "Page.frameNavigated", "params": map[string]string{"url": "https://other.example/"}})
+		send(t, conn, map[string]interface{}{"sessionId": "S1", "method": "Page.loadEventFired", "params": map[string]string{}})
+		send(t, conn, map[string]interface{}{"sessionId": "S1", "method": "Page.frameNavigated", "params": map[string]string{"url": "about:blank"}})
+		send(t, conn, map[string]interface{}{"sessionId": "S1", "method": "Page.frameNavigated", "params": map[string]string{"url": "https://example.com/"}})
+		send(t, conn, map[string]interface{}{"id": cmd.ID, "result": map[string]string{}})
+	})
+
+	wait, stop := client.WaitEvent("S1", "Page.frameNavigated", func(params json.RawMessage) bool {
+		return !strings.Contains(string(params), "about:blank")
+	})
+	defer stop()
+	ctx := testContext(t)
+	if err := client.Call(ctx, "S1", "Page.navigate", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	params, err := wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(params), "https://example.com/") {
+		t.Fatalf("params = %s", params)
+	}
+}
+
+func TestWaitEventContextDone(t *testing.T) {
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		readCommand(t, conn)
+	})
+
+	wait, stop := client.WaitEvent("S1", "Page.loadEventFired", nil)
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestConnectionLossFailsPendingCalls(t *testing.T) {
+	received := make(chan struct{})
+	client := fakeBrowser(t, func(conn *websocket.Conn) {
+		// 收到命令后不回复，直接断开
+		if _, _, ok := readCommand(t, conn); !ok {
+			return
+		}
+		close(received)
+		conn.Close(websocket.CloseGoingAway, "browser exited")
+	})
+
+	wait, stop := client.WaitEvent("S1", "Page.loadEventFired", nil)
+	defer stop()
+	ctx := testContext(t)
+	errs := make(chan error, 1)
+	go func() { errs <- client.Call(ctx, "S1", "Page.navigate", nil, nil) }()
+
+	<-received
+	if err := <-errs; !errors.Is(err, ErrClosed) {
+		t.Fatalf("pending call err = %v, want ErrClosed", err)
+	}
+	if _, err := wait(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("wait err = %v, want ErrClosed", err)
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Fatal("Done not closed after connection loss")
+	}
+	if err := client.Call(ctx, "", "Browser.getVersion", nil, nil); !errors.Is(err, ErrClosed) {
+		t.Fatalf("call after loss err = %v, want ErrClosed", err)
+	}
+}
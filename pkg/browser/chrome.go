@@ -0,0 +1,378 @@
+package browser
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/pkg/browser/cdp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// closeTimeout 关闭浏览器时等待进程退出的时间，超时后强制结束
+const closeTimeout = 5 * time.Second
+
+// execCandidates 未配置exec_path时依次查找的浏览器
+var execCandidates = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge",
+}
+
+// installPaths 各平台的常见安装位置
+var installPaths = map[string][]string{
+	"darwin": {
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+	},
+	"windows": {
+		`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`,
+	},
+}
+
+// CDPBrowser 通过Chrome DevTools Protocol控制的浏览器
+type CDPBrowser struct {
+	config *Config
+	client *cdp.Client
+
+	// cmd 本程序启动的浏览器进程，连接已有浏览器时为nil
+	cmd *exec.Cmd
+	// tempDir 未配置user_data_dir时创建的临时目录，退出后删除
+	tempDir string
+	exited  chan struct{}
+
+	mu     sync.Mutex
+	pages  map[string]*cdpPage
+	closed bool
+}
+
+// Launch 启动本机的Chromium或Chrome并连接
+func Launch(ctx context.Context, config *Config) (*CDPBrowser, error) {
+	execPath, err := findExecutable(config.ExecPath)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &CDPBrowser{config: config, pages: make(map[string]*cdpPage), exited: make(chan struct{})}
+	userDataDir := config.UserDataDir
+	if userDataDir == "" {
+		b.tempDir, err = os.MkdirTemp("", "gobrowseragent-")
+		if err != nil {
+			return nil, fmt.Errorf("创建临时用户数据目录失败: %v", err)
+		}
+		userDataDir = b.tempDir
+	}
+
+	args := []string{
+		"--remote-debugging-port=0",
+		"--user-data-dir=" + userDataDir,
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--disable-background-networking",
+		"--disable-popup-blocking",
+		fmt.Sprintf("--window-size=%d,%d", config.DefaultWidth, config.DefaultHeight),
+	}
+	if config.Headless {
+		args = append(args, "--headless=new", "--hide-scrollbars", "--mute-audio")
+	}
+	if os.Getuid() == 0 {
+		// 以root运行时Chromium要求关闭沙箱，常见于容器环境
+		args = append(args, "--no-sandbox")
+	}
+	args = append(args, config.Args...)
+	args = append(args, "about:blank")
+
+	// 浏览器的生命周期由Close控制，不随启动时的ctx结束
+	// 使用自己创建的管道而不是StderrPipe，Wait返回时不会关闭仍在读取的一端
+	b.cmd = exec.Command(execPath, args...)
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		b.removeTempDir()
+		return nil, err
+	}
+	b.cmd.Stderr = stderrWriter
+	err = b.cmd.Start()
+	stderrWriter.Close()
+	if err != nil {
+		stderr.Close()
+		b.removeTempDir()
+		return nil, fmt.Errorf("启动浏览器%s失败: %v", execPath, err)
+	}
+	logrus.Infof("已启动浏览器: %s (pid %d)", execPath, b.cmd.Process.Pid)
+	go func() {
+		b.cmd.Wait()
+		close(b.exited)
+	}()
+
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+	wsURL, err := waitDevToolsURL(ctx, stderr)
+	if err != nil {
+		b.kill()
+		return nil, err
+	}
+	if b.client, err = cdp.Dial(ctx, wsURL); err != nil {
+		b.kill()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Connect 连接已经运行的浏览器，浏览器需要以--remote-debugging-port启动
+func Connect(ctx context.Context, config *Config) (*CDPBrowser, error) {
+	b := &CDPBrowser{config: config, pages: make(map[string]*cdpPage)}
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+
+	wsURL := config.RemoteURL
+	if !strings.HasPrefix(wsURL, "ws://") && !strings.HasPrefix(wsURL, "wss://") {
+		var err error
+		if wsURL, err = discoverDevToolsURL(ctx, config.RemoteURL); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := cdp.Dial(ctx, wsURL)
+	if err != nil {
+		return nil, err
+	}
+	b.client = client
+	logrus.Infof("已连接浏览器: %s", wsURL)
+	return b, nil
+}
+
+// NewPage 打开新的空白页面
+func (b *CDPBrowser) NewPage(ctx context.Context) (Page, error) {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return nil, cdp.ErrClosed
+	}
+
+	ctx, cancel := b.withTimeout(ctx)
+	defer cancel()
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := b.client.Call(ctx, "", "Target.createTarget", map[string]interface{}{"url": "about:blank"}, &target); err != nil {
+		return nil, fmt.Errorf("打开页面失败: %v", err)
+	}
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	params := map[string]interface{}{"targetId": target.TargetID, "flatten": true}
+	if err := b.client.Call(ctx, "", "Target.attachToTarget", params, &attached); err != nil {
+		b.client.Call(ctx, "", "Target.closeTarget", map[string]string{"targetId": target.TargetID}, nil)
+		return nil, fmt.Errorf("连接页面失败: %v", err)
+	}
+
+	page := &cdpPage{browser: b, targetID: target.TargetID, sessionID: attached.SessionID}
+	if err := page.init(ctx); err != nil {
+		page.Close()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.pages[target.TargetID] = page
+	b.mu.Unlock()
+	return page, nil
+}
+
+// Close 关闭本程序打开的页面；浏览器由本程序启动时同时退出浏览器并清理临时目录
+func (b *CDPBrowser) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	pages := make([]*cdpPage, 0, len(b.pages))
+	for _, page := range b.pages {
+		pages = append(pages, page)
+	}
+	b.mu.Unlock()
+
+	if b.cmd == nil {
+		for _, page := range pages {
+			page.Close()
+		}
+		return b.client.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	if err := b.client.Call(ctx, "", "Browser.close", nil, nil); err != nil && !errors.Is(err, cdp.ErrClosed) {
+		logrus.Warnf("关闭浏览器失败: %v", err)
+	}
+	b.client.Close()
+	select {
+	case <-b.exited:
+	case <-ctx.Done():
+		logrus.Warn("浏览器未在规定时间内退出，强制结束")
+		b.kill()
+	}
+	b.removeTempDir()
+	return nil
+}
+
+// forget 页面关闭后从列表中移除
+func (b *CDPBrowser) forget(targetID string) {
+	b.mu.Lock()
+	delete(b.pages, targetID)
+	b.mu.Unlock()
+}
+
+// withTimeout ctx没有截止时间时加上配置的超时
+func (b *CDPBrowser) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return b.config.withTimeout(ctx)
+}
+
+// kill 强制结束浏览器进程并清理临时目录
+func (b *CDPBrowser) kill() {
+	if b.cmd != nil && b.cmd.Process != nil {
+		b.cmd.Process.Kill()
+		<-b.exited
+	}
+	b.removeTempDir()
+}
+
+func (b *CDPBrowser) removeTempDir() {
+	if b.tempDir == "" {
+		return
+	}
+	if err := os.RemoveAll(b.tempDir); err != nil {
+		logrus.Warnf("删除临时用户数据目录失败: %v", err)
+	}
+	b.tempDir = ""
+}
+
+// findExecutable 查找浏览器可执行文件
+func findExecutable(configured string) (string, error) {
+	if configured != "" {
+		path, err := exec.LookPath(configured)
+		if err != nil {
+			return "", fmt.Errorf("找不到浏览器%s: %v", configured, err)
+		}
+		return path, nil
+	}
+	for _, name := range execCandidates {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	for _, path := range installPaths[runtime.GOOS] {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("找不到Chromium或Chrome，请安装浏览器或在配置中设置browser.exec_path")
+}
+
+// waitDevToolsURL 从浏览器的标准错误输出中读取DevTools地址
+//
+// 读到地址后继续在后台读取剩余输出，避免管道写满导致浏览器阻塞，浏览器退出后关闭管道。
+func waitDevToolsURL(ctx context.Context, stderr io.ReadCloser) (string, error) {
+	const prefix = "DevTools listening on "
+	found := make(chan string, 1)
+	var (
+		mu     sync.Mutex
+		output []string
+	)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		sent := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			if sent {
+				logrus.Debugf("浏览器: %s", line)
+				continue
+			}
+			if strings.HasPrefix(line, prefix) {
+				found <- strings.TrimSpace(strings.TrimPrefix(line, prefix))
+				sent = true
+				continue
+			}
+			mu.Lock()
+			if len(output) < 20 {
+				output = append(output, line)
+			}
+			mu.Unlock()
+		}
+		stderr.Close()
+		if !sent {
+			close(found)
+		}
+	}()
+
+	select {
+	case wsURL, ok := <-found:
+		if ok {
+			return wsURL, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return "", fmt.Errorf("浏览器启动失败: %s", strings.Join(output, "\n"))
+	case <-ctx.Done():
+		return "", fmt.Errorf("等待浏览器启动超时: %v", ctx.Err())
+	}
+}
+
+// discoverDevToolsURL 通过调试地址的/json/version获取浏览器的DevTools地址
+func discoverDevToolsURL(ctx context.Context, debugURL string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(debugURL, "/"))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("无效的浏览器调试地址: %s", debugURL)
+	}
+	u.Path += "/json/version"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("连接浏览器调试地址失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("浏览器调试地址返回HTTP %d", resp.StatusCode)
+	}
+
+	var version struct {
+		Browser              string `json:"Browser"`
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&version); err != nil {
+		return "", fmt.Errorf("解析浏览器版本信息失败: %v", err)
+	}
+	if version.WebSocketDebuggerURL == "" {
+		return "", fmt.Errorf("浏览器没有返回DevTools地址")
+	}
+	logrus.Debugf("浏览器版本: %s", version.Browser)
+
+	// 浏览器返回的地址使用它自己看到的主机名，经过端口转发或容器访问时改用调试地址中的主机
+	wsURL, err := url.Parse(version.WebSocketDebuggerURL)
+	if err != nil {
+		return "", fmt.Errorf("无效的DevTools地址: %s", version.WebSocketDebuggerURL)
+	}
+	wsURL.Host = u.Host
+	if u.Scheme == "https" {
+		wsURL.Scheme = "wss"
+	}
+	return wsURL.String(), nil
+}
@@ -0,0 +1,74 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config 存储浏览器配置
+type Config struct {
+	// Backend 浏览器后端：chrome通过DevTools协议控制Chromium，http只用HTTP请求获取和解析页面，不执行JavaScript
+	Backend string `json:"backend"`
+	// Headless 是否以无界面模式启动浏览器
+	Headless bool `json:"headless"`
+	// UserDataDir 浏览器的用户数据目录，为空时每次启动使用新的临时目录，退出时删除
+	UserDataDir string `json:"user_data_dir"`
+	// DefaultWidth、DefaultHeight 页面视口的默认大小（像素）
+	DefaultWidth  int `json:"default_width"`
+	DefaultHeight int `json:"default_height"`
+	// Timeout 启动浏览器和单次页面操作的默认超时时间（秒）
+	Timeout int `json:"timeout"`
+	// ExecPath Chromium或Chrome可执行文件路径，为空时在PATH和常见安装位置中查找
+	ExecPath string `json:"exec_path"`
+	// RemoteURL 连接已经运行的浏览器而不是启动新的浏览器，
+	// 可以是http://host:9222形式的调试地址，也可以是ws://开头的DevTools地址
+	RemoteURL string `json:"remote_url"`
+	// Args 启动浏览器时附加的命令行参数，例如"--proxy-server=http://127.0.0.1:8080"
+	Args []string `json:"args"`
+	// UserAgent 覆盖浏览器的User-Agent，为空时chrome后端使用浏览器自带的值，http后端使用DefaultUserAgent
+	UserAgent string `json:"user_agent"`
+}
+
+// LoadConfig 从配置文件加载浏览器配置
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		logrus.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	configData := struct {
+		Browser *Config `json:"browser"`
+	}{
+		Browser: GetDefaultConfig(),
+	}
+
+	if err := json.Unmarshal(data, &configData); err != nil {
+		logrus.Errorf("解析配置文件失败: %v", err)
+		return nil, err
+	}
+
+	return configData.Browser, nil
+}
+
+// GetDefaultConfig 获取默认配置，默认以无界面模式启动本机的Chromium
+func GetDefaultConfig() *Config {
+	return &Config{
+		Headless:      true,
+		DefaultWidth:  1280,
+		DefaultHeight: 800,
+		Timeout:       30,
+	}
+}
+
+// withTimeout ctx没有截止时间时加上配置的超时
+func (c *Config) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(c.Timeout)*time.Second)
+}
@@ -0,0 +1,325 @@
+// Package dom 提供不依赖浏览器的HTML解析、CSS选择器和XPath
+//
+// 解析器面向服务端渲染的页面，按HTML的常见规则容错（省略的结束标签、自闭合元素、
+// script和style中的原始文本），但不执行脚本，也不计算样式和布局。
+package dom
+
+import (
+	"strings"
+)
+
+// NodeType 节点类型
+type NodeType int
+
+const (
+	DocumentNode NodeType = iota
+	ElementNode
+	TextNode
+	CommentNode
+)
+
+// Attribute 元素属性，名称统一为小写
+type Attribute struct {
+	Name  string
+	Value string
+}
+
+// Node DOM树中的节点
+type Node struct {
+	Type NodeType
+	// Tag 元素的小写标签名，其他节点为空
+	Tag string
+	// Data 文本和注释节点的内容
+	Data  string
+	Attrs []Attribute
+
+	Parent   *Node
+	Children []*Node
+
+	// Content iframe中加载的文档，由HTTP浏览器设置；Walk、Root和CSS选择器不会进入其中
+	Content *Node
+}
+
+// NewElement 创建没有父节点的元素
+func NewElement(tag string, attrs ...Attribute) *Node {
+	return &Node{Type: ElementNode, Tag: strings.ToLower(tag), Attrs: attrs}
+}
+
+// NewText 创建文本节点
+func NewText(data string) *Node {
+	return &Node{Type: TextNode, Data: data}
+}
+
+// Attr 返回属性值，第二个返回值表示属性是否存在
+func (n *Node) Attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// AttrOr 返回属性值，属性不存在时返回def
+func (n *Node) AttrOr(name, def string) string {
+	if v, ok := n.Attr(name); ok {
+		return v
+	}
+	return def
+}
+
+// HasAttr 判断属性是否存在
+func (n *Node) HasAttr(name string) bool {
+	_, ok := n.Attr(name)
+	return ok
+}
+
+// SetAttr 设置属性，属性不存在时追加
+func (n *Node) SetAttr(name, value string) {
+	for i := range n.Attrs {
+		if n.Attrs[i].Name == name {
+			n.Attrs[i].Value = value
+			return
+		}
+	}
+	n.Attrs = append(n.Attrs, Attribute{Name: name, Value: value})
+}
+
+// RemoveAttr 删除属性
+func (n *Node) RemoveAttr(name string) {
+	for i := range n.Attrs {
+		if n.Attrs[i].Name == name {
+			n.Attrs = append(n.Attrs[:i], n.Attrs[i+1:]...)
+			return
+		}
+	}
+}
+
+// AttrMap 以map返回所有属性
+func (n *Node) AttrMap() map[string]string {
+	m := make(map[string]string, len(n.Attrs))
+	for _, a := range n.Attrs {
+		m[a.Name] = a.Value
+	}
+	return m
+}
+
+// AppendChild 把child追加为最后一个子节点，child原来有父节点时先移除
+func (n *Node) AppendChild(child *Node) {
+	if child.Parent != nil {
+		child.Parent.RemoveChild(child)
+	}
+	child.Parent = n
+	n.Children = append(n.Children, child)
+}
+
+// RemoveChild 移除子节点
+func (n *Node) RemoveChild(child *Node) {
+	for i, c := range n.Children {
+		if c == child {
+			n.Children = append(n.Children[:i], n.Children[i+1:]...)
+			child.Parent = nil
+			return
+		}
+	}
+}
+
+// SetText 用一个文本节点替换所有子节点
+func (n *Node) SetText(text string) {
+	for _, c := range n.Children {
+		c.Parent = nil
+	}
+	n.Children = nil
+	if text != "" {
+		n.AppendChild(NewText(text))
+	}
+}
+
+// Text 返回所有后代文本节点的内容（与textContent相同）
+func (n *Node) Text() string {
+	if n.Type == TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	n.Walk(func(c *Node) bool {
+		if c.Type == TextNode {
+			b.WriteString(c.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+// VisibleText 返回大致对应innerText的文字：跳过script、style等不显示的内容，块级元素之间换行，
+// 连续空白合并为一个空格
+func (n *Node) VisibleText() string {
+	var b strings.Builder
+	var walk func(*Node)
+	walk = func(c *Node) {
+		switch c.Type {
+		case TextNode:
+			b.WriteString(c.Data)
+			return
+		case CommentNode:
+			return
+		case ElementNode:
+			if hiddenContent[c.Tag] && c.ShadowRootMode() == "" || c.HasAttr("hidden") {
+				return
+			}
+			if c.Tag == "br" {
+				b.WriteByte('\n')
+				return
+			}
+		}
+		block := c.Type == ElementNode && blockElements[c.Tag]
+		if block {
+			b.WriteByte('\n')
+		}
+		for _, child := range c.Children {
+			walk(child)
+		}
+		if block {
+			b.WriteByte('\n')
+		} else if c.Type == ElementNode && (c.Tag == "td" || c.Tag == "th") {
+			// 同一行的单元格之间用空格分隔
+			b.WriteByte(' ')
+		}
+	}
+	walk(n)
+
+	lines := strings.Split(b.String(), "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// hiddenContent 内容不会显示的元素
+var hiddenContent = map[string]bool{
+	"head": true, "script": true, "style": true, "template": true, "title": true, "meta": true, "link": true,
+}
+
+// blockElements 前后换行的块级元素
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true, "div": true, "dl": true,
+	"dt": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "tr": true, "ul": true, "option": true, "legend": true, "details": true, "summary": true,
+}
+
+// ShadowRootMode 声明式shadow DOM（带shadowrootmode属性的<template>）返回open或closed，其他元素返回空
+func (n *Node) ShadowRootMode() string {
+	if n.Tag != "template" {
+		return ""
+	}
+	mode, ok := n.Attr("shadowrootmode")
+	if !ok {
+		// 旧版Chrome使用的属性名
+		mode, ok = n.Attr("shadowroot")
+	}
+	if mode = strings.ToLower(strings.TrimSpace(mode)); !ok || mode != "open" && mode != "closed" {
+		return ""
+	}
+	return mode
+}
+
+// Walk 按文档顺序深度优先遍历n及其后代，fn返回false时跳过该节点的后代
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Elements 按文档顺序返回n的所有后代元素
+func (n *Node) Elements() []*Node {
+	var out []*Node
+	for _, c := range n.Children {
+		c.Walk(func(e *Node) bool {
+			if e.Type == ElementNode {
+				out = append(out, e)
+			}
+			return true
+		})
+	}
+	return out
+}
+
+// ElementChildren 返回子元素
+func (n *Node) ElementChildren() []*Node {
+	out := make([]*Node, 0, len(n.Children))
+	for _, c := range n.Children {
+		if c.Type == ElementNode {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Closest 返回满足条件的最近的祖先元素（包括自身），没有时返回nil
+func (n *Node) Closest(fn func(*Node) bool) *Node {
+	for e := n; e != nil; e = e.Parent {
+		if e.Type == ElementNode && fn(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// FindTag 返回第一个指定标签的后代元素
+func (n *Node) FindTag(tag string) *Node {
+	var found *Node
+	n.Walk(func(e *Node) bool {
+		if found != nil {
+			return false
+		}
+		if e != n && e.Type == ElementNode && e.Tag == tag {
+			found = e
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// GetElementByID 返回id匹配的第一个后代元素
+func (n *Node) GetElementByID(id string) *Node {
+	var found *Node
+	n.Walk(func(e *Node) bool {
+		if found != nil {
+			return false
+		}
+		if e.Type == ElementNode {
+			if v, ok := e.Attr("id"); ok && v == id {
+				found = e
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// Root 返回所在树的根节点
+func (n *Node) Root() *Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+// Contains 判断other是否是n本身或n的后代
+func (n *Node) Contains(other *Node) bool {
+	for ; other != nil; other = other.Parent {
+		if other == n {
+			return true
+		}
+	}
+	return false
+}
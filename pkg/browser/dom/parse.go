@@ -0,0 +1,381 @@
+package dom
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// maxDocumentSize 解析的HTML文档的上限
+const maxDocumentSize = 32 << 20
+
+// voidElements 没有内容和结束标签的元素
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements 内容按原始文本处理的元素，值表示是否解码其中的字符引用
+var rawTextElements = map[string]bool{
+	"script": false, "style": false, "xmp": false, "noembed": false, "noframes": false,
+	"textarea": true, "title": true,
+}
+
+// closesParagraph 开始标签会隐式结束打开的p元素
+var closesParagraph = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "details": true, "div": true,
+	"dl": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+// headElements 没有显式body时放进head的元素
+var headElements = map[string]bool{
+	"base": true, "link": true, "meta": true, "script": true, "style": true, "title": true, "noscript": true,
+}
+
+// Parse 读取并解析HTML文档
+func Parse(r io.Reader) (*Node, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取HTML失败: %v", err)
+	}
+	if len(data) > maxDocumentSize {
+		return nil, fmt.Errorf("HTML文档超过%dMB", maxDocumentSize>>20)
+	}
+	return ParseString(string(data)), nil
+}
+
+// ParseString 解析HTML文档，总是返回带有html、head和body元素的文档节点
+func ParseString(s string) *Node {
+	p := &parser{src: s, doc: &Node{Type: DocumentNode}}
+	p.stack = []*Node{p.doc}
+	p.run()
+	normalize(p.doc)
+	return p.doc
+}
+
+// parser 容错的HTML解析器，维护打开元素的栈
+type parser struct {
+	src   string
+	pos   int
+	doc   *Node
+	stack []*Node
+}
+
+func (p *parser) current() *Node {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *parser) run() {
+	for p.pos < len(p.src) {
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				p.appendNode(&Node{Type: CommentNode, Data: rest[4:]})
+				p.pos = len(p.src)
+			} else {
+				p.appendNode(&Node{Type: CommentNode, Data: rest[4 : 4+end]})
+				p.pos += 4 + end + 3
+			}
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			// DOCTYPE、CDATA和处理指令不进入DOM
+			p.skipPast(">")
+		case strings.HasPrefix(rest, "</") && len(rest) > 2 && isLetter(rest[2]):
+			p.pos += 2
+			name := p.readName()
+			p.skipPast(">")
+			p.endTag(name)
+		case len(rest) > 1 && rest[0] == '<' && isLetter(rest[1]):
+			p.pos++
+			p.startTag()
+		default:
+			end := strings.IndexByte(rest[1:], '<')
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end++
+			}
+			p.appendText(html.UnescapeString(rest[:end]))
+			p.pos += end
+		}
+	}
+}
+
+// skipPast 跳过直到sep之后
+func (p *parser) skipPast(sep string) {
+	end := strings.Index(p.src[p.pos:], sep)
+	if end < 0 {
+		p.pos = len(p.src)
+		return
+	}
+	p.pos += end + len(sep)
+}
+
+// readName 读取标签或属性名并转为小写
+func (p *parser) readName() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if isSpace(c) || c == '>' || c == '/' || c == '=' {
+			break
+		}
+		p.pos++
+	}
+	return strings.ToLower(p.src[start:p.pos])
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.src) && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// startTag 解析开始标签的名称和属性，并插入元素
+func (p *parser) startTag() {
+	el := &Node{Type: ElementNode, Tag: p.readName()}
+	selfClosing := false
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			break
+		}
+		c := p.src[p.pos]
+		if c == '>' {
+			p.pos++
+			break
+		}
+		if c == '/' {
+			p.pos++
+			if p.pos < len(p.src) && p.src[p.pos] == '>' {
+				selfClosing = true
+			}
+			continue
+		}
+		name := p.readName()
+		if name == "" {
+			// 无法识别的字符，例如单独的=
+			p.pos++
+			continue
+		}
+		value := ""
+		p.skipSpaces()
+		if p.pos < len(p.src) && p.src[p.pos] == '=' {
+			p.pos++
+			p.skipSpaces()
+			value = p.readAttrValue()
+		}
+		if !el.HasAttr(name) {
+			el.Attrs = append(el.Attrs, Attribute{Name: name, Value: value})
+		}
+	}
+
+	p.closeImplied(el.Tag)
+	p.appendNode(el)
+
+	if escaped, ok := rawTextElements[el.Tag]; ok && !selfClosing {
+		text := p.readRawText(el.Tag)
+		if escaped {
+			text = html.UnescapeString(text)
+		}
+		// textarea开头的一个换行会被忽略
+		if el.Tag == "textarea" {
+			text = strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")
+		}
+		if text != "" {
+			el.AppendChild(NewText(text))
+		}
+		return
+	}
+	if voidElements[el.Tag] || selfClosing {
+		return
+	}
+	p.stack = append(p.stack, el)
+}
+
+// readAttrValue 读取带引号或不带引号的属性值
+func (p *parser) readAttrValue() string {
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	if q := p.src[p.pos]; q == '"' || q == '\'' {
+		p.pos++
+		end := strings.IndexByte(p.src[p.pos:], q)
+		if end < 0 {
+			end = len(p.src) - p.pos
+		}
+		value := p.src[p.pos : p.pos+end]
+		p.pos += end
+		if p.pos < len(p.src) {
+			p.pos++
+		}
+		return html.UnescapeString(value)
+	}
+	start := p.pos
+	for p.pos < len(p.src) && !isSpace(p.src[p.pos]) && p.src[p.pos] != '>' {
+		p.pos++
+	}
+	return html.UnescapeString(p.src[start:p.pos])
+}
+
+// readRawText 读取直到</tag的原始文本
+func (p *parser) readRawText(tag string) string {
+	rest := p.src[p.pos:]
+	lower := strings.ToLower(rest)
+	end := strings.Index(lower, "</"+tag)
+	if end < 0 {
+		p.pos = len(p.src)
+		return rest
+	}
+	p.pos += end
+	p.skipPast(">")
+	return rest[:end]
+}
+
+// endTag 关闭最近的同名元素，找不到时忽略
+func (p *parser) endTag(tag string) {
+	for i := len(p.stack) - 1; i > 0; i-- {
+		if p.stack[i].Tag == tag {
+			p.stack = p.stack[:i]
+			return
+		}
+		// 表格和表单控件的结束标签不能越过表格边界
+		if p.stack[i].Tag == "table" && tag != "table" {
+			return
+		}
+	}
+}
+
+// closeImplied 处理省略的结束标签，例如新的<li>会结束上一个<li>
+func (p *parser) closeImplied(tag string) {
+	switch {
+	case closesParagraph[tag]:
+		p.closeUntil("p", "button", "table", "td", "th", "li")
+	case tag == "li":
+		p.closeUntil("li", "ul", "ol", "table")
+	case tag == "dt" || tag == "dd":
+		p.closeUntil("dt", "dl", "table")
+		p.closeUntil("dd", "dl", "table")
+	case tag == "option":
+		p.closeCurrent("option")
+	case tag == "optgroup":
+		p.closeCurrent("option")
+		p.closeCurrent("optgroup")
+	case tag == "tr":
+		p.closeUntil("td", "tr", "table")
+		p.closeUntil("th", "tr", "table")
+		p.closeUntil("tr", "table", "tbody", "thead", "tfoot")
+	case tag == "td" || tag == "th":
+		p.closeUntil("td", "tr", "table")
+		p.closeUntil("th", "tr", "table")
+	case tag == "thead" || tag == "tbody" || tag == "tfoot":
+		for _, t := range []string{"td", "th", "tr", "thead", "tbody", "tfoot"} {
+			p.closeUntil(t, "table")
+		}
+	}
+}
+
+// closeUntil 如果在遇到边界元素之前找到打开的tag元素，关闭它及其内部的元素
+func (p *parser) closeUntil(tag string, boundaries ...string) {
+	for i := len(p.stack) - 1; i > 0; i-- {
+		t := p.stack[i].Tag
+		if t == tag {
+			p.stack = p.stack[:i]
+			return
+		}
+		for _, b := range boundaries {
+			if t == b {
+				return
+			}
+		}
+	}
+}
+
+// closeCurrent 当前元素是tag时关闭它
+func (p *parser) closeCurrent(tag string) {
+	if len(p.stack) > 1 && p.current().Tag == tag {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+func (p *parser) appendNode(n *Node) {
+	p.current().AppendChild(n)
+}
+
+// appendText 追加文本，与前一个文本节点合并
+func (p *parser) appendText(text string) {
+	cur := p.current()
+	if len(cur.Children) > 0 {
+		if last := cur.Children[len(cur.Children)-1]; last.Type == TextNode {
+			last.Data += text
+			return
+		}
+	}
+	cur.AppendChild(NewText(text))
+}
+
+// normalize 补全缺少的html、head和body元素，使选择器和表单查找与浏览器中一致
+func normalize(doc *Node) {
+	root := doc.FindTag("html")
+	if root == nil || root.Parent != doc {
+		root = NewElement("html")
+		for len(doc.Children) > 0 {
+			child := doc.Children[0]
+			if child.Type == CommentNode {
+				doc.RemoveChild(child)
+				continue
+			}
+			root.AppendChild(child)
+		}
+		doc.AppendChild(root)
+	}
+
+	var head, body *Node
+	for _, c := range root.ElementChildren() {
+		switch c.Tag {
+		case "head":
+			head = c
+		case "body", "frameset":
+			body = c
+		}
+	}
+	if head == nil {
+		head = NewElement("head")
+		root.Children = append([]*Node{head}, root.Children...)
+		head.Parent = root
+	}
+	if body == nil {
+		body = NewElement("body")
+		// head之后连续的head类元素放进head，其余内容放进body
+		inHead := true
+		for _, child := range append([]*Node(nil), root.Children...) {
+			if child == head {
+				continue
+			}
+			if inHead {
+				if child.Type == TextNode && strings.TrimSpace(child.Data) == "" {
+					root.RemoveChild(child)
+					continue
+				}
+				if child.Type == ElementNode && headElements[child.Tag] {
+					head.AppendChild(child)
+					continue
+				}
+				inHead = false
+			}
+			body.AppendChild(child)
+		}
+		root.AppendChild(body)
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
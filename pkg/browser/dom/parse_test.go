@@ -0,0 +1,95 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+// outline 把文档序列化为只包含标签、id和文本的紧凑形式，便于比较树结构
+func outline(n *Node) string {
+	var b strings.Builder
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		switch n.Type {
+		case TextNode:
+			if text := strings.TrimSpace(n.Data); text != "" {
+				b.WriteString("'" + text + "'")
+			}
+			return
+		case ElementNode:
+			b.WriteString(n.Tag)
+			if id, ok := n.Attr("id"); ok {
+				b.WriteString("#" + id)
+			}
+		}
+		if len(n.Children) == 0 {
+			return
+		}
+		b.WriteString("(")
+		for _, c := range n.Children {
+			walk(c)
+		}
+		b.WriteString(")")
+	}
+	for _, c := range n.Children {
+		walk(c)
+	}
+	return b.String()
+}
+
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"fragment gets html head body", `<p>hi</p>`, `html(headbody(p('hi')))`},
+		{"head elements before content", `<title>T</title><meta charset=utf-8><p>x`, `html(head(title('T')meta)body(p('x')))`},
+		{"implied p end", `<p id=a>one<p id=b>two<div id=c>three</div>`, `html(headbody(p#a('one')p#b('two')div#c('three')))`},
+		{"implied li end", `<ul><li id=a>1<li id=b>2</ul>`, `html(headbody(ul(li#a('1')li#b('2'))))`},
+		{"implied option end", `<select><option id=a>x<option id=b>y</select>`, `html(headbody(select(option#a('x')option#b('y'))))`},
+		{"table cells", `<table><tr><td id=a>1<td id=b>2<tr><td id=c>3</table>`, `html(headbody(table(tr(td#a('1')td#b('2'))tr(td#c('3')))))`},
+		{"void and self closing", `<div><br><img src=x><span/>after</div>`, `html(headbody(div(brimgspan'after')))`},
+		{"stray end tag ignored", `<div id=a>x</span>y</div>`, `html(headbody(div#a('xy')))`},
+		{"unclosed elements", `<div id=a><span id=b>x`, `html(headbody(div#a(span#b('x'))))`},
+		{"script is raw text", `<script>if (a < b && c) { "</p>" }</script><p>x`, `html(head(script('if (a < b && c) { "</p>" }'))body(p('x')))`},
+		{"comments dropped", `<!-- c --><p>x<!-- y --></p>`, `html(headbody(p('x')))`},
+		{"explicit structure kept", `<html><head><title>a</title></head><body id=b><p>x</p></body></html>`, `html(head(title('a'))body#b(p('x')))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outline(ParseString(tt.html)); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	doc := ParseString(`<input id=a name="q" value='a &amp; b' data-x=1 disabled NAME="dup" type=TEXT>` +
+		`<textarea id=t>
+&lt;b&gt; kept</textarea>`)
+
+	input := doc.GetElementByID("a")
+	if input == nil {
+		t.Fatal("input not found")
+	}
+	want := map[string]string{"id": "a", "name": "q", "value": "a & b", "data-x": "1", "disabled": "", "type": "TEXT"}
+	got := input.AttrMap()
+	if len(got) != len(want) {
+		t.Errorf("attrs = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("attr %s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	textarea := doc.GetElementByID("t")
+	if textarea == nil {
+		t.Fatal("textarea not found")
+	}
+	if got := textarea.Text(); got != "<b> kept" {
+		t.Errorf("textarea text = %q, want %q", got, "<b> kept")
+	}
+}
@@ -0,0 +1,694 @@
+package dom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Selector 编译后的CSS选择器（可以是逗号分隔的选择器列表）
+//
+// 支持类型、通配、ID、类、属性（=、~=、|=、^=、$=、*=，可加i标志）选择器，
+// 后代、子、相邻兄弟和通用兄弟组合符，以及常用的结构和表单伪类：
+// :first-child、:last-child、:only-child、:nth-child()、:nth-last-child()、
+// :first-of-type、:last-of-type、:only-of-type、:nth-of-type()、:nth-last-of-type()、
+// :not()、:is()、:where()、:has()、:empty、:root、:checked、:disabled、:enabled、
+// :required、:optional、:link和:any-link。不支持伪元素。
+type Selector struct {
+	source string
+	groups []complexSelector
+}
+
+// complexSelector 由组合符连接的复合选择器，combinators[i]连接parts[i]和parts[i+1]
+type complexSelector struct {
+	parts       []compound
+	combinators []byte
+}
+
+// compound 作用于同一元素的一组条件
+type compound struct {
+	conds []func(*Node) bool
+}
+
+func (c compound) match(n *Node) bool {
+	for _, cond := range c.conds {
+		if !cond(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compile 解析CSS选择器，出错时返回的错误中包含出错的列号（从1开始）
+func Compile(selector string) (*Selector, error) {
+	p := &selectorParser{src: selector}
+	groups, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if !p.eof() {
+		return nil, p.errorf("无法识别的字符%q", p.peek())
+	}
+	return &Selector{source: selector, groups: groups}, nil
+}
+
+// MustCompile 同Compile，出错时panic，用于编译固定的选择器
+func MustCompile(selector string) *Selector {
+	s, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String 返回原始的选择器文本
+func (s *Selector) String() string {
+	return s.source
+}
+
+// Match 判断元素是否匹配选择器
+func (s *Selector) Match(n *Node) bool {
+	if n == nil || n.Type != ElementNode {
+		return false
+	}
+	for _, g := range s.groups {
+		if g.match(n, len(g.parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select 按文档顺序返回root的后代中匹配的元素
+func (s *Selector) Select(root *Node) []*Node {
+	var out []*Node
+	for _, e := range root.Elements() {
+		if s.Match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// SelectFirst 返回root的后代中第一个匹配的元素，没有时返回nil
+func (s *Selector) SelectFirst(root *Node) *Node {
+	var found *Node
+	for _, c := range root.Children {
+		c.Walk(func(e *Node) bool {
+			if found != nil {
+				return false
+			}
+			if e.Type == ElementNode && s.Match(e) {
+				found = e
+				return false
+			}
+			return true
+		})
+	}
+	return found
+}
+
+// QueryAll 编译选择器并返回root的后代中匹配的元素
+func QueryAll(root *Node, selector string) ([]*Node, error) {
+	s, err := Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(root), nil
+}
+
+// match 从右向左匹配：parts[i]匹配n，并且左边的部分按组合符匹配相应的元素
+func (c complexSelector) match(n *Node, i int) bool {
+	if !c.parts[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i-1] {
+	case '>':
+		parent := parentElement(n)
+		return parent != nil && c.match(parent, i-1)
+	case '+':
+		prev := previousElement(n)
+		return prev != nil && c.match(prev, i-1)
+	case '~':
+		for prev := previousElement(n); prev != nil; prev = previousElement(prev) {
+			if c.match(prev, i-1) {
+				return true
+			}
+		}
+		return false
+	default:
+		for a := parentElement(n); a != nil; a = parentElement(a) {
+			if c.match(a, i-1) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func parentElement(n *Node) *Node {
+	if n.Parent != nil && n.Parent.Type == ElementNode {
+		return n.Parent
+	}
+	return nil
+}
+
+func previousElement(n *Node) *Node {
+	if n.Parent == nil {
+		return nil
+	}
+	var prev *Node
+	for _, c := range n.Parent.Children {
+		if c == n {
+			return prev
+		}
+		if c.Type == ElementNode {
+			prev = c
+		}
+	}
+	return nil
+}
+
+// siblingIndex 返回元素在同级元素中的位置（从1开始），fromEnd时从后往前数，sameType时只数同类型元素
+func siblingIndex(n *Node, fromEnd, sameType bool) int {
+	if n.Parent == nil {
+		return 1
+	}
+	siblings := n.Parent.Children
+	index := 0
+	for i := range siblings {
+		c := siblings[i]
+		if fromEnd {
+			c = siblings[len(siblings)-1-i]
+		}
+		if c.Type != ElementNode || sameType && c.Tag != n.Tag {
+			continue
+		}
+		index++
+		if c == n {
+			return index
+		}
+	}
+	return index
+}
+
+// formControls 可以禁用的表单元素
+var formControls = map[string]bool{
+	"button": true, "input": true, "select": true, "textarea": true, "option": true, "optgroup": true, "fieldset": true,
+}
+
+// Disabled 判断表单控件是否被禁用，包括位于禁用的fieldset中（第一个legend内除外）
+func Disabled(n *Node) bool {
+	if n.Type != ElementNode || !formControls[n.Tag] {
+		return false
+	}
+	if n.HasAttr("disabled") {
+		return true
+	}
+	if n.Tag == "option" {
+		if p := n.Parent; p != nil && p.Tag == "optgroup" && p.HasAttr("disabled") {
+			return true
+		}
+	}
+	for child, a := n, n.Parent; a != nil; child, a = a, a.Parent {
+		if a.Tag != "fieldset" || !a.HasAttr("disabled") {
+			continue
+		}
+		if legend := firstChildTag(a, "legend"); legend != nil && legend == child {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func firstChildTag(n *Node, tag string) *Node {
+	for _, c := range n.Children {
+		if c.Type == ElementNode && c.Tag == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// selectorParser 递归下降解析选择器
+type selectorParser struct {
+	src string
+	pos int
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *selectorParser) peek() rune {
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return r
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	column := utf8.RuneCountInString(p.src[:min(p.pos, len(p.src))]) + 1
+	return fmt.Errorf("选择器%q第%d列: %s", p.src, column, fmt.Sprintf(format, args...))
+}
+
+func (p *selectorParser) skipSpaces() bool {
+	start := p.pos
+	for !p.eof() && isSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// parseList 解析逗号分隔的选择器列表，遇到)或结尾时停止
+func (p *selectorParser) parseList() ([]complexSelector, error) {
+	var groups []complexSelector
+	for {
+		p.skipSpaces()
+		g, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+		p.skipSpaces()
+		if p.eof() || p.src[p.pos] != ',' {
+			return groups, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseComplex() (complexSelector, error) {
+	var c complexSelector
+	for {
+		part, err := p.parseCompound()
+		if err != nil {
+			return c, err
+		}
+		c.parts = append(c.parts, part)
+
+		spaced := p.skipSpaces()
+		if p.eof() || p.src[p.pos] == ',' || p.src[p.pos] == ')' {
+			return c, nil
+		}
+		comb := byte(' ')
+		switch p.src[p.pos] {
+		case '>', '+', '~':
+			comb = p.src[p.pos]
+			p.pos++
+			p.skipSpaces()
+		default:
+			if !spaced {
+				return c, p.errorf("无法识别的字符%q", p.peek())
+			}
+		}
+		c.combinators = append(c.combinators, comb)
+	}
+}
+
+func (p *selectorParser) parseCompound() (compound, error) {
+	var c compound
+	if p.eof() {
+		return c, p.errorf("缺少选择器")
+	}
+	switch ch := p.src[p.pos]; {
+	case ch == '*':
+		p.pos++
+	case isIdentStart(p.src, p.pos):
+		tag := strings.ToLower(p.readIdent())
+		c.conds = append(c.conds, func(n *Node) bool { return n.Tag == tag })
+	}
+	for !p.eof() {
+		start := p.pos
+		switch p.src[p.pos] {
+		case '#':
+			p.pos++
+			if !isIdentStart(p.src, p.pos) && !(p.pos < len(p.src) && isDigit(p.src[p.pos])) {
+				return c, p.errorf("#后面缺少ID")
+			}
+			id := p.readIdent()
+			c.conds = append(c.conds, func(n *Node) bool { return n.AttrOr("id", "") == id })
+		case '.':
+			p.pos++
+			if !isIdentStart(p.src, p.pos) {
+				return c, p.errorf(".后面缺少类名")
+			}
+			class := p.readIdent()
+			c.conds = append(c.conds, func(n *Node) bool { return containsWord(n.AttrOr("class", ""), class, false) })
+		case '[':
+			cond, err := p.parseAttribute()
+			if err != nil {
+				return c, err
+			}
+			c.conds = append(c.conds, cond)
+		case ':':
+			cond, err := p.parsePseudo()
+			if err != nil {
+				return c, err
+			}
+			c.conds = append(c.conds, cond)
+		default:
+			if len(c.conds) == 0 && (start == 0 || p.src[start-1] != '*') {
+				return c, p.errorf("无法识别的字符%q", p.peek())
+			}
+			return c, nil
+		}
+	}
+	return c, nil
+}
+
+// parseAttribute 解析[name]、[name=value]等属性选择器
+func (p *selectorParser) parseAttribute() (func(*Node) bool, error) {
+	p.pos++
+	p.skipSpaces()
+	if !isIdentStart(p.src, p.pos) {
+		return nil, p.errorf("缺少属性名")
+	}
+	name := strings.ToLower(p.readIdent())
+	p.skipSpaces()
+	if p.eof() {
+		return nil, p.errorf("缺少]")
+	}
+	if p.src[p.pos] == ']' {
+		p.pos++
+		return func(n *Node) bool { return n.HasAttr(name) }, nil
+	}
+
+	op := ""
+	if strings.HasPrefix(p.src[p.pos:], "=") {
+		op = "="
+	} else if p.pos+1 < len(p.src) && p.src[p.pos+1] == '=' && strings.ContainsRune("~|^$*", rune(p.src[p.pos])) {
+		op = p.src[p.pos : p.pos+2]
+	} else {
+		return nil, p.errorf("无法识别的属性运算符%q", p.peek())
+	}
+	p.pos += len(op)
+	p.skipSpaces()
+	value, err := p.readValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	fold := false
+	if !p.eof() && (p.src[p.pos] == 'i' || p.src[p.pos] == 'I' || p.src[p.pos] == 's' || p.src[p.pos] == 'S') {
+		fold = p.src[p.pos] == 'i' || p.src[p.pos] == 'I'
+		p.pos++
+		p.skipSpaces()
+	}
+	if p.eof() || p.src[p.pos] != ']' {
+		return nil, p.errorf("缺少]")
+	}
+	p.pos++
+
+	if fold {
+		value = strings.ToLower(value)
+	}
+	return func(n *Node) bool {
+		v, ok := n.Attr(name)
+		if !ok {
+			return false
+		}
+		if fold {
+			v = strings.ToLower(v)
+		}
+		switch op {
+		case "=":
+			return v == value
+		case "~=":
+			return containsWord(v, value, false)
+		case "|=":
+			return v == value || strings.HasPrefix(v, value+"-")
+		case "^=":
+			return value != "" && strings.HasPrefix(v, value)
+		case "$=":
+			return value != "" && strings.HasSuffix(v, value)
+		default:
+			return value != "" && strings.Contains(v, value)
+		}
+	}, nil
+}
+
+// parsePseudo 解析伪类
+func (p *selectorParser) parsePseudo() (func(*Node) bool, error) {
+	p.pos++
+	if !p.eof() && p.src[p.pos] == ':' {
+		return nil, p.errorf("不支持伪元素")
+	}
+	if !isIdentStart(p.src, p.pos) {
+		return nil, p.errorf(":后面缺少伪类名")
+	}
+	start := p.pos
+	name := strings.ToLower(p.readIdent())
+
+	if p.eof() || p.src[p.pos] != '(' {
+		switch name {
+		case "first-child":
+			return func(n *Node) bool { return siblingIndex(n, false, false) == 1 }, nil
+		case "last-child":
+			return func(n *Node) bool { return siblingIndex(n, true, false) == 1 }, nil
+		case "only-child":
+			return func(n *Node) bool { return siblingIndex(n, false, false) == 1 && siblingIndex(n, true, false) == 1 }, nil
+		case "first-of-type":
+			return func(n *Node) bool { return siblingIndex(n, false, true) == 1 }, nil
+		case "last-of-type":
+			return func(n *Node) bool { return siblingIndex(n, true, true) == 1 }, nil
+		case "only-of-type":
+			return func(n *Node) bool { return siblingIndex(n, false, true) == 1 && siblingIndex(n, true, true) == 1 }, nil
+		case "empty":
+			return func(n *Node) bool {
+				for _, c := range n.Children {
+					if c.Type == ElementNode || c.Type == TextNode && c.Data != "" {
+						return false
+					}
+				}
+				return true
+			}, nil
+		case "root":
+			return func(n *Node) bool { return n.Parent != nil && n.Parent.Type == DocumentNode }, nil
+		case "checked":
+			return func(n *Node) bool {
+				switch n.Tag {
+				case "input":
+					t := strings.ToLower(n.AttrOr("type", ""))
+					return (t == "checkbox" || t == "radio") && n.HasAttr("checked")
+				case "option":
+					return n.HasAttr("selected")
+				}
+				return false
+			}, nil
+		case "disabled":
+			return Disabled, nil
+		case "enabled":
+			return func(n *Node) bool { return formControls[n.Tag] && !Disabled(n) }, nil
+		case "required":
+			return func(n *Node) bool { return isInputLike(n) && n.HasAttr("required") }, nil
+		case "optional":
+			return func(n *Node) bool { return isInputLike(n) && !n.HasAttr("required") }, nil
+		case "link", "any-link":
+			return func(n *Node) bool { return (n.Tag == "a" || n.Tag == "area") && n.HasAttr("href") }, nil
+		}
+		p.pos = start
+		return nil, p.errorf("不支持的伪类:%s", name)
+	}
+
+	p.pos++
+	switch name {
+	case "not", "is", "where", "has":
+		groups, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		inner := &Selector{groups: groups}
+		switch name {
+		case "not":
+			return func(n *Node) bool { return !inner.Match(n) }, nil
+		case "has":
+			return func(n *Node) bool { return inner.SelectFirst(n) != nil }, nil
+		}
+		return inner.Match, nil
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end < 0 {
+			return nil, p.errorf("缺少)")
+		}
+		a, b, err := parseNth(p.src[p.pos : p.pos+end])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos += end + 1
+		fromEnd := strings.Contains(name, "last")
+		sameType := strings.HasSuffix(name, "of-type")
+		return func(n *Node) bool { return nthMatch(a, b, siblingIndex(n, fromEnd, sameType)) }, nil
+	}
+	p.pos = start
+	return nil, p.errorf("不支持的伪类:%s()", name)
+}
+
+func (p *selectorParser) expect(c byte) error {
+	p.skipSpaces()
+	if p.eof() || p.src[p.pos] != c {
+		return p.errorf("缺少%c", c)
+	}
+	p.pos++
+	return nil
+}
+
+// readValue 读取属性值，可以带引号
+func (p *selectorParser) readValue() (string, error) {
+	if p.eof() {
+		return "", p.errorf("缺少属性值")
+	}
+	q := p.src[p.pos]
+	if q != '"' && q != '\'' {
+		if !isIdentStart(p.src, p.pos) && !isDigit(q) {
+			return "", p.errorf("缺少属性值")
+		}
+		return p.readIdent(), nil
+	}
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		switch {
+		case c == q:
+			p.pos++
+			return b.String(), nil
+		case c == '\\':
+			b.WriteString(p.readEscape())
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("字符串缺少结束引号")
+}
+
+// readIdent 读取标识符，处理反斜杠转义
+func (p *selectorParser) readIdent() string {
+	var b strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		switch {
+		case c == '\\':
+			b.WriteString(p.readEscape())
+		case c == '-' || c == '_' || isLetter(c) || isDigit(c) || c >= 0x80:
+			b.WriteByte(c)
+			p.pos++
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+// readEscape 读取\后面的十六进制码点或单个字符
+func (p *selectorParser) readEscape() string {
+	p.pos++
+	if p.eof() {
+		return ""
+	}
+	start := p.pos
+	for p.pos < len(p.src) && p.pos-start < 6 && isHex(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos > start {
+		code, _ := strconv.ParseUint(p.src[start:p.pos], 16, 32)
+		if p.pos < len(p.src) && isSpace(p.src[p.pos]) {
+			p.pos++
+		}
+		return string(rune(code))
+	}
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	return string(r)
+}
+
+// parseNth 解析an+b、odd和even
+func parseNth(s string) (int, int, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	case "":
+		return 0, 0, fmt.Errorf("缺少an+b参数")
+	}
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("无效的an+b参数%q", s)
+		}
+		return 0, b, nil
+	}
+	a := 1
+	switch prefix := s[:i]; prefix {
+	case "", "+":
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(prefix); err != nil {
+			return 0, 0, fmt.Errorf("无效的an+b参数%q", s)
+		}
+	}
+	b := 0
+	if rest := s[i+1:]; rest != "" {
+		var err error
+		if b, err = strconv.Atoi(rest); err != nil || rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, fmt.Errorf("无效的an+b参数%q", s)
+		}
+	}
+	return a, b, nil
+}
+
+// nthMatch 判断位置index是否满足an+b（n为非负整数）
+func nthMatch(a, b, index int) bool {
+	if a == 0 {
+		return index == b
+	}
+	diff := index - b
+	return diff%a == 0 && diff/a >= 0
+}
+
+// containsWord 判断以空白分隔的列表中是否包含word
+func containsWord(list, word string, fold bool) bool {
+	for _, w := range strings.Fields(list) {
+		if w == word || fold && strings.EqualFold(w, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInputLike(n *Node) bool {
+	return n.Tag == "input" || n.Tag == "select" || n.Tag == "textarea"
+}
+
+// isIdentStart 判断pos处是否可以开始一个标识符
+func isIdentStart(s string, pos int) bool {
+	if pos >= len(s) {
+		return false
+	}
+	c := s[pos]
+	if c == '-' {
+		return pos+1 < len(s) && (isLetter(s[pos+1]) || s[pos+1] == '_' || s[pos+1] == '-' || s[pos+1] >= 0x80 || s[pos+1] == '\\')
+	}
+	return isLetter(c) || c == '_' || c >= 0x80 || c == '\\'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,127 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+const selectorDoc = `<!DOCTYPE html>
+<html><head><title>t</title></head><body>
+<div id="main" class="box wide">
+  <p id="p1" class="intro" lang="en-US">one</p>
+  <p id="p2" data-role="note">two</p>
+  <span id="s1"></span>
+  <p id="p3" class="Intro">three</p>
+</div>
+<ul id="list"><li id="l1">a<li id="l2">b<li id="l3">c<li id="l4">d</ul>
+<form id="f">
+  <input id="user" name="username" required>
+  <input id="pass" type="password" name="password">
+  <input id="remember" type="checkbox" checked>
+  <fieldset disabled><input id="locked" name="locked"></fieldset>
+  <select id="lang"><option id="o1">zh<option id="o2" selected>en</select>
+</form>
+<a id="home" href="/">home</a><a id="anchor">x</a>
+</body></html>`
+
+// ids 返回节点的id，用逗号连接
+func ids(nodes []*Node) string {
+	out := make([]string, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.AttrOr("id", n.Tag)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestQueryAll(t *testing.T) {
+	doc := ParseString(selectorDoc)
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"p", "p1,p2,p3"},
+		{"#main > p", "p1,p2,p3"},
+		{"div p.intro", "p1"},
+		{".box.wide", "main"},
+		{".intro", "p1"},
+		{"[class=intro i]", "p1,p3"},
+		{"[data-role]", "p2"},
+		{"[lang|=en]", "p1"},
+		{`[name^="user"]`, "user"},
+		{`[name$=word]`, "pass"},
+		{`[name*=ock]`, "locked"},
+		{`[class~=wide]`, "main"},
+		{"#p1 + p", "p2"},
+		{"#p1 ~ p", "p2,p3"},
+		{"#p2 + p", ""},
+		{"li:first-child", "l1"},
+		{"li:last-child", "l4"},
+		{"li:nth-child(2n)", "l2,l4"},
+		{"li:nth-child(odd)", "l1,l3"},
+		{"li:nth-last-child(1)", "l4"},
+		{"#main p:nth-of-type(2)", "p2"},
+		{"#main span:only-of-type", "s1"},
+		{"span:empty", "s1"},
+		{"p:not(.intro)", "p2,p3"},
+		{"div:has(span)", "main"},
+		{":is(#p1, #s1)", "p1,s1"},
+		{"html:root", "html"},
+		{"input:checked, option:checked", "remember,o2"},
+		{"input:disabled", "locked"},
+		{"input:enabled", "user,pass,remember"},
+		{"input:required", "user"},
+		{"a:link", "home"},
+		{"#user, #main", "main,user"},
+		{"*#pass", "pass"},
+	}
+	for _, tt := range tests {
+		nodes, err := QueryAll(doc, tt.selector)
+		if err != nil {
+			t.Errorf("QueryAll(%q): %v", tt.selector, err)
+			continue
+		}
+		if got := ids(nodes); got != tt.want {
+			t.Errorf("QueryAll(%q) = %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"", "缺少选择器"},
+		{"div >", "缺少选择器"},
+		{"#", "#后面缺少ID"},
+		{"p.", ".后面缺少类名"},
+		{"[", "缺少属性名"},
+		{"[name", "缺少]"},
+		{"[name!=x]", "无法识别的属性运算符"},
+		{"p::before", "不支持伪元素"},
+		{"p:hover", "不支持的伪类:hover"},
+		{"p:not(.a", "缺少)"},
+		{"li:nth-child(x)", ""},
+		{"div)", "无法识别的字符"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.selector)
+		if err == nil {
+			t.Errorf("Compile(%q) succeeded, want error", tt.selector)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%q) error = %q, want it to contain %q", tt.selector, err, tt.want)
+		}
+	}
+}
+
+func TestSelectFirst(t *testing.T) {
+	doc := ParseString(selectorDoc)
+	if got := MustCompile("li").SelectFirst(doc); got == nil || got.AttrOr("id", "") != "l1" {
+		t.Fatalf("SelectFirst(li) = %v", got)
+	}
+	if got := MustCompile("table").SelectFirst(doc); got != nil {
+		t.Fatalf("SelectFirst(table) = %v, want nil", got)
+	}
+}
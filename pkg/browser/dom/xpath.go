@@ -0,0 +1,1150 @@
+package dom
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// XPath 编译后的XPath 1.0表达式
+//
+// 支持除namespace以外的所有轴和缩写（//、.、..、@），名称测试和node()、text()、comment()，
+// 谓词，|并集，算术、比较和逻辑运算，以及常用函数：last、position、count、name、local-name、
+// string、concat、contains、starts-with、ends-with、substring、substring-before、
+// substring-after、string-length、normalize-space、translate、not、true、false、boolean、
+// number、sum、floor、ceiling和round。HTML元素名和属性名不区分大小写；
+// 与浏览器一致，template的内容不属于文档树。不支持变量。
+type XPath struct {
+	source string
+	expr   xexpr
+}
+
+// CompileXPath 解析XPath表达式，出错时返回的错误中包含出错的列号（从1开始）
+func CompileXPath(expr string) (*XPath, error) {
+	tokens, err := tokenizeXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &xpathParser{src: expr, tokens: tokens}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != xEOF {
+		return nil, p.errorf(t, "无法识别的%q", t.text)
+	}
+	return &XPath{source: expr, expr: e}, nil
+}
+
+// String 返回原始的表达式
+func (x *XPath) String() string {
+	return x.source
+}
+
+// Select 以context为上下文节点求值，按文档顺序返回结果中的元素
+//
+// 结果中的文本节点换成所在的元素，属性节点换成所属的元素；结果不是节点集合时返回错误。
+func (x *XPath) Select(context *Node) ([]*Node, error) {
+	ctx := &xcontext{node: xnode{node: context, attr: -1}, position: 1, size: 1, doc: &xdocument{root: context.Root()}}
+	set, ok := x.expr(ctx).([]xnode)
+	if !ok {
+		return nil, fmt.Errorf("XPath %q的结果不是节点集合", x.source)
+	}
+	var out []*Node
+	seen := make(map[*Node]bool, len(set))
+	for _, n := range set {
+		e := n.node
+		if n.attr < 0 && e.Type != ElementNode {
+			e = e.Parent
+		}
+		if e != nil && e.Type == ElementNode && !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// xnode XPath中的节点，attr不小于0时表示node.Attrs[attr]这个属性节点
+type xnode struct {
+	node *Node
+	attr int
+}
+
+// xdocument 一次求值中共用的文档信息：节点的文档顺序，按需计算
+type xdocument struct {
+	root  *Node
+	order map[*Node]int
+	nodes []*Node
+}
+
+// index 返回节点的文档顺序，第一次调用时遍历整个文档
+func (d *xdocument) index(n *Node) int {
+	if d.order == nil {
+		d.order = make(map[*Node]int)
+		var walk func(*Node)
+		walk = func(c *Node) {
+			d.order[c] = len(d.nodes)
+			d.nodes = append(d.nodes, c)
+			for _, child := range xchildren(c) {
+				walk(child)
+			}
+		}
+		walk(d.root)
+	}
+	return d.order[n]
+}
+
+// sort 按文档顺序排列并去掉重复的节点
+func (d *xdocument) sort(set []xnode) []xnode {
+	sort.SliceStable(set, func(i, j int) bool {
+		a, b := d.index(set[i].node), d.index(set[j].node)
+		if a != b {
+			return a < b
+		}
+		return set[i].attr < set[j].attr
+	})
+	out := set[:0]
+	for i, n := range set {
+		if i == 0 || n != set[i-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// xcontext 求值的上下文：上下文节点、位置和大小
+type xcontext struct {
+	node     xnode
+	position int
+	size     int
+	doc      *xdocument
+}
+
+func (c *xcontext) with(n xnode, position, size int) *xcontext {
+	return &xcontext{node: n, position: position, size: size, doc: c.doc}
+}
+
+// xexpr 编译后的表达式，返回[]xnode、string、float64或bool
+type xexpr func(ctx *xcontext) interface{}
+
+// xchildren 返回XPath可见的子节点，template的内容不在文档树中
+func xchildren(n *Node) []*Node {
+	if n.Tag == "template" {
+		return nil
+	}
+	return n.Children
+}
+
+// stringValue 返回节点的字符串值
+func stringValue(n xnode) string {
+	if n.attr >= 0 {
+		return n.node.Attrs[n.attr].Value
+	}
+	switch n.node.Type {
+	case TextNode, CommentNode:
+		return n.node.Data
+	}
+	var b strings.Builder
+	var walk func(*Node)
+	walk = func(c *Node) {
+		if c.Type == TextNode {
+			b.WriteString(c.Data)
+		}
+		for _, child := range xchildren(c) {
+			walk(child)
+		}
+	}
+	walk(n.node)
+	return b.String()
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case []xnode:
+		if len(v) == 0 {
+			return ""
+		}
+		return stringValue(v[0])
+	case float64:
+		return formatNumber(v)
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return v.(string)
+}
+
+func toNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(toString(v)), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func toBoolean(v interface{}) bool {
+	switch v := v.(type) {
+	case []xnode:
+		return len(v) > 0
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	}
+	return v.(string) != ""
+}
+
+// formatNumber 按XPath的规则把数字转为字符串，整数不带小数点
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// compare 按XPath 1.0的规则比较两个值，节点集合中任一节点满足即为真
+func compare(op string, a, b interface{}) bool {
+	as, aSet := a.([]xnode)
+	bs, bSet := b.([]xnode)
+	switch {
+	case aSet && bSet:
+		for _, x := range as {
+			for _, y := range bs {
+				if compareAtoms(op, stringValue(x), stringValue(y)) {
+					return true
+				}
+			}
+		}
+		return false
+	case aSet:
+		if _, ok := b.(bool); ok {
+			return compareAtoms(op, toBoolean(a), b)
+		}
+		for _, x := range as {
+			if compareAtoms(op, stringValue(x), b) {
+				return true
+			}
+		}
+		return false
+	case bSet:
+		if _, ok := a.(bool); ok {
+			return compareAtoms(op, a, toBoolean(b))
+		}
+		for _, y := range bs {
+			if compareAtoms(op, a, stringValue(y)) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, a, b)
+}
+
+// compareAtoms 比较两个非节点集合的值
+func compareAtoms(op string, a, b interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, aBool := a.(bool)
+		_, bBool := b.(bool)
+		_, aNum := a.(float64)
+		_, bNum := b.(float64)
+		switch {
+		case aBool || bBool:
+			equal = toBoolean(a) == toBoolean(b)
+		case aNum || bNum:
+			equal = toNumber(a) == toNumber(b)
+		default:
+			equal = toString(a) == toString(b)
+		}
+		return equal == (op == "=")
+	}
+	x, y := toNumber(a), toNumber(b)
+	switch op {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	}
+	return x >= y
+}
+
+// 词法单元的类型
+const (
+	xEOF = iota
+	xName
+	xOperator
+	xNumber
+	xLiteral
+	xPunct
+)
+
+type xtoken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// tokenizeXPath 切分表达式；按规范，前面是值时*和and、or、div、mod是运算符
+func tokenizeXPath(src string) ([]xtoken, error) {
+	var tokens []xtoken
+	errorf := func(pos int, format string, args ...interface{}) error {
+		return fmt.Errorf("XPath %q第%d列: %s", src, utf8.RuneCountInString(src[:pos])+1, fmt.Sprintf(format, args...))
+	}
+	// operatorContext 下一个*或名称是否应作为运算符
+	operatorContext := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		last := tokens[len(tokens)-1]
+		switch last.kind {
+		case xOperator:
+			return false
+		case xPunct:
+			switch last.text {
+			case "@", "::", "(", "[", ",":
+				return false
+			}
+		}
+		return true
+	}
+
+	for pos := 0; pos < len(src); {
+		c := src[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[pos+1:], c)
+			if end < 0 {
+				return nil, errorf(pos, "字符串缺少结束引号")
+			}
+			tokens = append(tokens, xtoken{kind: xLiteral, text: src[pos+1 : pos+1+end], pos: pos})
+			pos += end + 2
+		case isDigit(c) || c == '.' && pos+1 < len(src) && isDigit(src[pos+1]):
+			start := pos
+			for pos < len(src) && (isDigit(src[pos]) || src[pos] == '.') {
+				pos++
+			}
+			tokens = append(tokens, xtoken{kind: xNumber, text: src[start:pos], pos: start})
+		case c == '/' || c == '.' || c == ':' || c == '!' || c == '<' || c == '>':
+			text := string(c)
+			if pos+1 < len(src) {
+				switch two := src[pos : pos+2]; two {
+				case "//", "..", "::", "!=", "<=", ">=":
+					text = two
+				}
+			}
+			switch text {
+			case "!", ":":
+				return nil, errorf(pos, "无法识别的字符%q", c)
+			case "/", "//", "!=", "<", "<=", ">", ">=":
+				tokens = append(tokens, xtoken{kind: xOperator, text: text, pos: pos})
+			default:
+				tokens = append(tokens, xtoken{kind: xPunct, text: text, pos: pos})
+			}
+			pos += len(text)
+		case c == '=' || c == '|' || c == '+' || c == '-':
+			tokens = append(tokens, xtoken{kind: xOperator, text: string(c), pos: pos})
+			pos++
+		case c == '*':
+			kind := xName
+			if operatorContext() {
+				kind = xOperator
+			}
+			tokens = append(tokens, xtoken{kind: kind, text: "*", pos: pos})
+			pos++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == '@' || c == ',':
+			tokens = append(tokens, xtoken{kind: xPunct, text: string(c), pos: pos})
+			pos++
+		case c == '$':
+			return nil, errorf(pos, "不支持变量")
+		case isLetter(c) || c == '_' || c >= 0x80:
+			start := pos
+			for pos < len(src) && isNameChar(src[pos]) {
+				pos++
+			}
+			// 带前缀的名称（如svg:rect或svg:*），不与轴的::混淆
+			if pos+1 < len(src) && src[pos] == ':' && src[pos+1] != ':' {
+				pos++
+				if src[pos] == '*' {
+					pos++
+				} else {
+					for pos < len(src) && isNameChar(src[pos]) {
+						pos++
+					}
+				}
+			}
+			name := src[start:pos]
+			kind := xName
+			if operatorContext() {
+				switch name {
+				case "and", "or", "div", "mod":
+					kind = xOperator
+				default:
+					return nil, errorf(start, "这里应为运算符，而不是%q", name)
+				}
+			}
+			tokens = append(tokens, xtoken{kind: kind, text: name, pos: start})
+		default:
+			r, _ := utf8.DecodeRuneInString(src[pos:])
+			return nil, errorf(pos, "无法识别的字符%q", r)
+		}
+	}
+	return tokens, nil
+}
+
+func isNameChar(c byte) bool {
+	return isLetter(c) || isDigit(c) || c == '-' || c == '_' || c == '.' || c >= 0x80
+}
+
+type xpathParser struct {
+	src    string
+	tokens []xtoken
+	pos    int
+}
+
+func (p *xpathParser) peek() xtoken {
+	if p.pos >= len(p.tokens) {
+		return xtoken{kind: xEOF, pos: len(p.src)}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *xpathParser) peekAt(offset int) xtoken {
+	if p.pos+offset >= len(p.tokens) {
+		return xtoken{kind: xEOF, pos: len(p.src)}
+	}
+	return p.tokens[p.pos+offset]
+}
+
+// accept 下一个词法单元是指定的运算符或标点时读取它
+func (p *xpathParser) accept(text string) bool {
+	if t := p.peek(); (t.kind == xOperator || t.kind == xPunct) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *xpathParser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf(p.peek(), "缺少%s", text)
+	}
+	return nil
+}
+
+func (p *xpathParser) errorf(t xtoken, format string, args ...interface{}) error {
+	return fmt.Errorf("XPath %q第%d列: %s", p.src, utf8.RuneCountInString(p.src[:t.pos])+1, fmt.Sprintf(format, args...))
+}
+
+// binaryLevels 二元运算符，按优先级从低到高排列
+var binaryLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+func (p *xpathParser) parseExpr() (xexpr, error) {
+	return p.parseBinary(0)
+}
+
+func (p *xpathParser) parseBinary(level int) (xexpr, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != xOperator || !containsString(binaryLevels[level], t.text) {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(t.text, left, right)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func binaryExpr(op string, left, right xexpr) xexpr {
+	switch op {
+	case "or":
+		return func(ctx *xcontext) interface{} { return toBoolean(left(ctx)) || toBoolean(right(ctx)) }
+	case "and":
+		return func(ctx *xcontext) interface{} { return toBoolean(left(ctx)) && toBoolean(right(ctx)) }
+	case "=", "!=", "<", "<=", ">", ">=":
+		return func(ctx *xcontext) interface{} { return compare(op, left(ctx), right(ctx)) }
+	}
+	return func(ctx *xcontext) interface{} {
+		a, b := toNumber(left(ctx)), toNumber(right(ctx))
+		switch op {
+		case "+":
+			return a + b
+		case "-":
+			return a - b
+		case "*":
+			return a * b
+		case "div":
+			return a / b
+		}
+		return math.Mod(a, b)
+	}
+}
+
+func (p *xpathParser) parseUnary() (xexpr, error) {
+	if p.accept("-") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *xcontext) interface{} { return -toNumber(inner(ctx)) }, nil
+	}
+	return p.parseUnion()
+}
+
+func (p *xpathParser) parseUnion() (xexpr, error) {
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx *xcontext) interface{} {
+			a, ok1 := l(ctx).([]xnode)
+			b, ok2 := right(ctx).([]xnode)
+			if !ok1 || !ok2 {
+				return []xnode{}
+			}
+			return ctx.doc.sort(append(append([]xnode(nil), a...), b...))
+		}
+	}
+	return left, nil
+}
+
+// startsPrimary 判断下一个词法单元是否开始一个基本表达式（括号、字符串、数字或函数调用）
+func (p *xpathParser) startsPrimary() bool {
+	t := p.peek()
+	switch t.kind {
+	case xLiteral, xNumber:
+		return true
+	case xPunct:
+		return t.text == "("
+	case xName:
+		next := p.peekAt(1)
+		if next.kind != xPunct || next.text != "(" {
+			return false
+		}
+		switch t.text {
+		case "node", "text", "comment", "processing-instruction":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (p *xpathParser) parsePath() (xexpr, error) {
+	if p.startsPrimary() {
+		filter, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+		t := p.peek()
+		if t.kind != xOperator || t.text != "/" && t.text != "//" {
+			return filter, nil
+		}
+		steps, err := p.parseRelative()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *xcontext) interface{} {
+			set, ok := filter(ctx).([]xnode)
+			if !ok {
+				return []xnode{}
+			}
+			return applySteps(ctx, set, steps)
+		}, nil
+	}
+
+	var steps []xstep
+	var err error
+	absolute := true
+	switch t := p.peek(); {
+	case t.kind == xOperator && t.text == "/":
+		p.pos++
+		if !p.startsStep() {
+			// 单独的/表示文档根
+			return func(ctx *xcontext) interface{} {
+				return []xnode{{node: ctx.node.node.Root(), attr: -1}}
+			}, nil
+		}
+		steps, err = p.parseRelativeFrom()
+	case t.kind == xOperator && t.text == "//":
+		steps, err = p.parseRelative()
+	case p.startsStep():
+		absolute = false
+		steps, err = p.parseRelativeFrom()
+	default:
+		return nil, p.errorf(t, "缺少表达式")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx *xcontext) interface{} {
+		start := ctx.node
+		if absolute {
+			start = xnode{node: ctx.node.node.Root(), attr: -1}
+		}
+		return applySteps(ctx, []xnode{start}, steps)
+	}, nil
+}
+
+// parseRelative 解析以/或//开头的后续步骤
+func (p *xpathParser) parseRelative() ([]xstep, error) {
+	var steps []xstep
+	for {
+		t := p.peek()
+		if t.kind != xOperator || t.text != "/" && t.text != "//" {
+			return steps, nil
+		}
+		p.pos++
+		if t.text == "//" {
+			steps = append(steps, xstep{axis: "descendant-or-self", test: anyNode})
+		}
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+}
+
+// parseRelativeFrom 解析相对路径：一个步骤和后续的/或//步骤
+func (p *xpathParser) parseRelativeFrom() ([]xstep, error) {
+	step, err := p.parseStep()
+	if err != nil {
+		return nil, err
+	}
+	rest, err := p.parseRelative()
+	if err != nil {
+		return nil, err
+	}
+	return append([]xstep{step}, rest...), nil
+}
+
+func (p *xpathParser) startsStep() bool {
+	t := p.peek()
+	switch t.kind {
+	case xName:
+		return true
+	case xPunct:
+		return t.text == "." || t.text == ".." || t.text == "@"
+	}
+	return false
+}
+
+func (p *xpathParser) parseFilter() (xexpr, error) {
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	preds, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	if len(preds) == 0 {
+		return primary, nil
+	}
+	return func(ctx *xcontext) interface{} {
+		set, ok := primary(ctx).([]xnode)
+		if !ok {
+			return []xnode{}
+		}
+		return filterPredicates(ctx, set, preds)
+	}, nil
+}
+
+func (p *xpathParser) parsePrimary() (xexpr, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case xLiteral:
+		s := t.text
+		return func(*xcontext) interface{} { return s }, nil
+	case xNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "无效的数字%q", t.text)
+		}
+		return func(*xcontext) interface{} { return f }, nil
+	case xPunct:
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return p.parseFunction(t)
+}
+
+// xfunction 内置函数，min和max是参数个数的范围，max为-1表示不限
+type xfunction struct {
+	min, max int
+	call     func(ctx *xcontext, args []xexpr) interface{}
+}
+
+var xfunctions map[string]xfunction
+
+func init() {
+	str := func(ctx *xcontext, args []xexpr) string {
+		if len(args) == 0 {
+			return stringValue(ctx.node)
+		}
+		return toString(args[0](ctx))
+	}
+	nodeName := func(ctx *xcontext, args []xexpr) string {
+		n := ctx.node
+		if len(args) > 0 {
+			set, ok := args[0](ctx).([]xnode)
+			if !ok || len(set) == 0 {
+				return ""
+			}
+			n = set[0]
+		}
+		if n.attr >= 0 {
+			return n.node.Attrs[n.attr].Name
+		}
+		return n.node.Tag
+	}
+	xfunctions = map[string]xfunction{
+		"last":     {0, 0, func(ctx *xcontext, args []xexpr) interface{} { return float64(ctx.size) }},
+		"position": {0, 0, func(ctx *xcontext, args []xexpr) interface{} { return float64(ctx.position) }},
+		"count": {1, 1, func(ctx *xcontext, args []xexpr) interface{} {
+			set, _ := args[0](ctx).([]xnode)
+			return float64(len(set))
+		}},
+		"name":       {0, 1, func(ctx *xcontext, args []xexpr) interface{} { return nodeName(ctx, args) }},
+		"local-name": {0, 1, func(ctx *xcontext, args []xexpr) interface{} { return nodeName(ctx, args) }},
+		"string":     {0, 1, func(ctx *xcontext, args []xexpr) interface{} { return str(ctx, args) }},
+		"concat": {2, -1, func(ctx *xcontext, args []xexpr) interface{} {
+			var b strings.Builder
+			for _, a := range args {
+				b.WriteString(toString(a(ctx)))
+			}
+			return b.String()
+		}},
+		"contains": {2, 2, func(ctx *xcontext, args []xexpr) interface{} {
+			return strings.Contains(toString(args[0](ctx)), toString(args[1](ctx)))
+		}},
+		"starts-with": {2, 2, func(ctx *xcontext, args []xexpr) interface{} {
+			return strings.HasPrefix(toString(args[0](ctx)), toString(args[1](ctx)))
+		}},
+		"ends-with": {2, 2, func(ctx *xcontext, args []xexpr) interface{} {
+			return strings.HasSuffix(toString(args[0](ctx)), toString(args[1](ctx)))
+		}},
+		"substring-before": {2, 2, func(ctx *xcontext, args []xexpr) interface{} {
+			before, _, found := strings.Cut(toString(args[0](ctx)), toString(args[1](ctx)))
+			if !found {
+				return ""
+			}
+			return before
+		}},
+		"substring-after": {2, 2, func(ctx *xcontext, args []xexpr) interface{} {
+			_, after, found := strings.Cut(toString(args[0](ctx)), toString(args[1](ctx)))
+			if !found {
+				return ""
+			}
+			return after
+		}},
+		"substring": {2, 3, func(ctx *xcontext, args []xexpr) interface{} {
+			runes := []rune(toString(args[0](ctx)))
+			// 位置从1开始并四舍五入，规范中的NaN和无穷大也按这个比较规则处理
+			start := math.Floor(toNumber(args[1](ctx)) + 0.5)
+			end := math.Inf(1)
+			if len(args) == 3 {
+				end = start + math.Floor(toNumber(args[2](ctx))+0.5)
+			}
+			var b strings.Builder
+			for i, r := range runes {
+				if pos := float64(i + 1); pos >= start && pos < end {
+					b.WriteRune(r)
+				}
+			}
+			return b.String()
+		}},
+		"string-length": {0, 1, func(ctx *xcontext, args []xexpr) interface{} {
+			return float64(utf8.RuneCountInString(str(ctx, args)))
+		}},
+		"normalize-space": {0, 1, func(ctx *xcontext, args []xexpr) interface{} {
+			return strings.Join(strings.Fields(str(ctx, args)), " ")
+		}},
+		"translate": {3, 3, func(ctx *xcontext, args []xexpr) interface{} {
+			from, to := []rune(toString(args[1](ctx))), []rune(toString(args[2](ctx)))
+			var b strings.Builder
+			for _, r := range toString(args[0](ctx)) {
+				i := indexRune(from, r)
+				switch {
+				case i < 0:
+					b.WriteRune(r)
+				case i < len(to):
+					b.WriteRune(to[i])
+				}
+			}
+			return b.String()
+		}},
+		"not":     {1, 1, func(ctx *xcontext, args []xexpr) interface{} { return !toBoolean(args[0](ctx)) }},
+		"true":    {0, 0, func(ctx *xcontext, args []xexpr) interface{} { return true }},
+		"false":   {0, 0, func(ctx *xcontext, args []xexpr) interface{} { return false }},
+		"boolean": {1, 1, func(ctx *xcontext, args []xexpr) interface{} { return toBoolean(args[0](ctx)) }},
+		"number": {0, 1, func(ctx *xcontext, args []xexpr) interface{} {
+			if len(args) == 0 {
+				return toNumber(stringValue(ctx.node))
+			}
+			return toNumber(args[0](ctx))
+		}},
+		"sum": {1, 1, func(ctx *xcontext, args []xexpr) interface{} {
+			set, _ := args[0](ctx).([]xnode)
+			total := 0.0
+			for _, n := range set {
+				total += toNumber(stringValue(n))
+			}
+			return total
+		}},
+		"floor":   {1, 1, func(ctx *xcontext, args []xexpr) interface{} { return math.Floor(toNumber(args[0](ctx))) }},
+		"ceiling": {1, 1, func(ctx *xcontext, args []xexpr) interface{} { return math.Ceil(toNumber(args[0](ctx))) }},
+		"round": {1, 1, func(ctx *xcontext, args []xexpr) interface{} {
+			f := toNumber(args[0](ctx))
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return f
+			}
+			return math.Floor(f + 0.5)
+		}},
+	}
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *xpathParser) parseFunction(name xtoken) (xexpr, error) {
+	fn, ok := xfunctions[name.text]
+	if !ok {
+		return nil, p.errorf(name, "不支持的函数%s()", name.text)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []xexpr
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(args) < fn.min || fn.max >= 0 && len(args) > fn.max {
+		return nil, p.errorf(name, "函数%s()的参数个数不对", name.text)
+	}
+	return func(ctx *xcontext) interface{} { return fn.call(ctx, args) }, nil
+}
+
+// xstep 路径中的一步：轴、节点测试和谓词
+type xstep struct {
+	axis  string
+	test  func(xnode) bool
+	preds []xexpr
+}
+
+// axes 支持的轴，值表示是否是反向轴（谓词中的位置从近到远计算）
+var axes = map[string]bool{
+	"child": false, "descendant": false, "descendant-or-self": false, "parent": true,
+	"ancestor": true, "ancestor-or-self": true, "following-sibling": false, "preceding-sibling": true,
+	"following": false, "preceding": true, "self": false, "attribute": false,
+}
+
+func anyNode(xnode) bool { return true }
+
+func (p *xpathParser) parseStep() (xstep, error) {
+	switch {
+	case p.accept("."):
+		return xstep{axis: "self", test: anyNode}, nil
+	case p.accept(".."):
+		return xstep{axis: "parent", test: anyNode}, nil
+	}
+
+	axis := "child"
+	if p.accept("@") {
+		axis = "attribute"
+	} else if t := p.peek(); t.kind == xName && p.peekAt(1).kind == xPunct && p.peekAt(1).text == "::" {
+		if _, ok := axes[t.text]; !ok {
+			return xstep{}, p.errorf(t, "不支持的轴%s", t.text)
+		}
+		axis = t.text
+		p.pos += 2
+	}
+
+	t := p.peek()
+	if t.kind != xName {
+		return xstep{}, p.errorf(t, "缺少节点测试")
+	}
+	p.pos++
+	var test func(xnode) bool
+	if next := p.peek(); next.kind == xPunct && next.text == "(" {
+		p.pos++
+		switch t.text {
+		case "node":
+			test = anyNode
+		case "text":
+			test = func(n xnode) bool { return n.attr < 0 && n.node.Type == TextNode }
+		case "comment":
+			test = func(n xnode) bool { return n.attr < 0 && n.node.Type == CommentNode }
+		case "processing-instruction":
+			// HTML文档中没有处理指令
+			if p.peek().kind == xLiteral {
+				p.pos++
+			}
+			test = func(xnode) bool { return false }
+		default:
+			return xstep{}, p.errorf(t, "不支持的节点测试%s()", t.text)
+		}
+		if err := p.expect(")"); err != nil {
+			return xstep{}, err
+		}
+	} else {
+		test = nameTest(t.text, axis == "attribute")
+	}
+
+	preds, err := p.parsePredicates()
+	if err != nil {
+		return xstep{}, err
+	}
+	return xstep{axis: axis, test: test, preds: preds}, nil
+}
+
+// nameTest 按名称匹配元素或属性，忽略大小写和命名空间前缀
+func nameTest(name string, attribute bool) func(xnode) bool {
+	if _, local, ok := strings.Cut(name, ":"); ok {
+		name = local
+	}
+	name = strings.ToLower(name)
+	return func(n xnode) bool {
+		if attribute {
+			return n.attr >= 0 && (name == "*" || n.node.Attrs[n.attr].Name == name)
+		}
+		return n.attr < 0 && n.node.Type == ElementNode && (name == "*" || n.node.Tag == name)
+	}
+}
+
+func (p *xpathParser) parsePredicates() ([]xexpr, error) {
+	var preds []xexpr
+	for p.accept("[") {
+		pred, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		preds = append(preds, pred)
+	}
+	return preds, nil
+}
+
+// filterPredicates 依次用谓词过滤，数字谓词表示位置
+func filterPredicates(ctx *xcontext, set []xnode, preds []xexpr) []xnode {
+	for _, pred := range preds {
+		var out []xnode
+		for i, n := range set {
+			v := pred(ctx.with(n, i+1, len(set)))
+			if f, ok := v.(float64); ok {
+				if f == float64(i+1) {
+					out = append(out, n)
+				}
+			} else if toBoolean(v) {
+				out = append(out, n)
+			}
+		}
+		set = out
+	}
+	return set
+}
+
+// applySteps 从input出发依次执行步骤，返回按文档顺序排列的结果
+func applySteps(ctx *xcontext, input []xnode, steps []xstep) []xnode {
+	set := input
+	for _, step := range steps {
+		var out []xnode
+		for _, n := range set {
+			var candidates []xnode
+			for _, c := range axisNodes(ctx.doc, step.axis, n) {
+				if step.test(c) {
+					candidates = append(candidates, c)
+				}
+			}
+			out = append(out, filterPredicates(ctx, candidates, step.preds)...)
+		}
+		set = ctx.doc.sort(out)
+	}
+	if set == nil {
+		set = []xnode{}
+	}
+	return set
+}
+
+// axisNodes 按轴的方向返回节点，反向轴从近到远
+func axisNodes(doc *xdocument, axis string, n xnode) []xnode {
+	var out []xnode
+	add := func(nodes ...*Node) {
+		for _, c := range nodes {
+			out = append(out, xnode{node: c, attr: -1})
+		}
+	}
+	var descendants func(*Node)
+	descendants = func(e *Node) {
+		for _, c := range xchildren(e) {
+			add(c)
+			descendants(c)
+		}
+	}
+
+	switch axis {
+	case "self":
+		return []xnode{n}
+	case "attribute":
+		if n.attr < 0 {
+			for i := range n.node.Attrs {
+				out = append(out, xnode{node: n.node, attr: i})
+			}
+		}
+		return out
+	case "parent", "ancestor", "ancestor-or-self":
+		if axis == "ancestor-or-self" {
+			out = append(out, n)
+		}
+		parent := n.node.Parent
+		if n.attr >= 0 {
+			parent = n.node
+		}
+		for e := parent; e != nil; e = e.Parent {
+			add(e)
+			if axis == "parent" {
+				break
+			}
+		}
+		return out
+	}
+	if n.attr >= 0 {
+		// 属性节点没有子节点和兄弟节点，following和preceding按所属元素计算
+		switch axis {
+		case "following":
+			descendants(n.node)
+			return append(out, axisNodes(doc, axis, xnode{node: n.node, attr: -1})...)
+		case "preceding":
+			return axisNodes(doc, axis, xnode{node: n.node, attr: -1})
+		}
+		return nil
+	}
+
+	switch axis {
+	case "child":
+		add(xchildren(n.node)...)
+	case "descendant-or-self":
+		add(n.node)
+		descendants(n.node)
+	case "descendant":
+		descendants(n.node)
+	case "following-sibling", "preceding-sibling":
+		if n.node.Parent == nil {
+			return nil
+		}
+		siblings := n.node.Parent.Children
+		for i, c := range siblings {
+			if c != n.node {
+				continue
+			}
+			if axis == "following-sibling" {
+				add(siblings[i+1:]...)
+			} else {
+				for j := i - 1; j >= 0; j-- {
+					add(siblings[j])
+				}
+			}
+			break
+		}
+	case "following":
+		index := doc.index(n.node)
+		for _, c := range doc.nodes[index+1:] {
+			if !n.node.Contains(c) {
+				add(c)
+			}
+		}
+	case "preceding":
+		index := doc.index(n.node)
+		for i := index - 1; i >= 0; i-- {
+			if c := doc.nodes[i]; !c.Contains(n.node) {
+				add(c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXPathSelect(t *testing.T) {
+	doc := ParseString(selectorDoc)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"//p", "p1,p2,p3"},
+		{"/html/body/div/p", "p1,p2,p3"},
+		{"//DIV/P", "p1,p2,p3"},
+		{"//p[@class='intro']", "p1"},
+		{"//p[@CLASS]", "p1,p3"},
+		{"//*[@id='main']/*[2]", "p2"},
+		{"//li[last()]", "l4"},
+		{"//li[position() mod 2 = 0]", "l2,l4"},
+		{"(//li)[2]", "l2"},
+		{"//ul/li[. = 'c']", "l3"},
+		{"//p[text()='two']", "p2"},
+		{"//p[contains(., 'hre')]", "p3"},
+		{"//p[starts-with(@id, 'p') and not(@class)]", "p2"},
+		{"//p[translate(@class, 'I', 'i') = 'intro']", "p1,p3"},
+		{"//input[@type='password' or @required]", "user,pass"},
+		{"//*[@id='p2']/following-sibling::*", "s1,p3"},
+		{"//*[@id='p2']/preceding-sibling::p", "p1"},
+		{"//span/..", "main"},
+		{"//li[@id='l3']/ancestor::*[@id]", "list"},
+		{"//option[@selected]/parent::select", "lang"},
+		{"//fieldset//input", "locked"},
+		{"//a[@href]/@href", "home"},
+		{"//p/text()", "p1,p2,p3"},
+		{"//span | //ul", "s1,list"},
+		{"//ul | //span", "s1,list"},
+		{"//li[count(preceding-sibling::li) = 1]", "l2"},
+		{"//p[string-length(normalize-space()) > 3]", "p3"},
+		{"//table", ""},
+	}
+	for _, tt := range tests {
+		x, err := CompileXPath(tt.expr)
+		if err != nil {
+			t.Errorf("CompileXPath(%q): %v", tt.expr, err)
+			continue
+		}
+		nodes, err := x.Select(doc)
+		if err != nil {
+			t.Errorf("Select(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := ids(nodes); got != tt.want {
+			t.Errorf("Select(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestXPathRelative(t *testing.T) {
+	doc := ParseString(selectorDoc)
+	main := doc.GetElementByID("main")
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"p", "p1,p2,p3"},
+		{".//p[2]", "p2"},
+		{"//li", "l1,l2,l3,l4"},
+		{"self::div", "main"},
+		{"../ul", "list"},
+	}
+	for _, tt := range tests {
+		nodes, err := mustXPath(t, tt.expr).Select(main)
+		if err != nil {
+			t.Errorf("Select(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := ids(nodes); got != tt.want {
+			t.Errorf("Select(%q) from #main = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestXPathTemplate(t *testing.T) {
+	doc := ParseString(`<div id="host"><template><p id="inner">x</p></template><p id="outer">y</p></div>`)
+	nodes, err := mustXPath(t, "//p").Select(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(nodes); got != "outer" {
+		t.Fatalf("Select(//p) = %q, template content should be skipped", got)
+	}
+}
+
+func TestXPathErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "第1列: 缺少表达式"},
+		{"//p[", "第5列: 缺少表达式"},
+		{"//p[@id='x'", "第12列: 缺少]"},
+		{"//p[@id='x]", "第9列: 字符串缺少结束引号"},
+		{"//p[$x]", "第5列: 不支持变量"},
+		{"//p[foo()]", "第5列: 不支持的函数foo()"},
+		{"//p[contains(.)]", "第5列: 函数contains()的参数个数不对"},
+		{"//namespace::x", "第3列: 不支持的轴namespace"},
+		{"//p#", "第4列: 无法识别的字符'#'"},
+		{"//标题 ]", "第6列: 无法识别的\"]\""},
+	}
+	for _, tt := range tests {
+		_, err := CompileXPath(tt.expr)
+		if err == nil {
+			t.Errorf("CompileXPath(%q) succeeded, want error", tt.expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileXPath(%q) error = %q, want it to contain %q", tt.expr, err, tt.want)
+		}
+	}
+
+	// 结果不是节点集合
+	if _, err := mustXPath(t, "count(//p)").Select(ParseString(selectorDoc)); err == nil || !strings.Contains(err.Error(), "不是节点集合") {
+		t.Fatalf("Select(count(//p)) error = %v", err)
+	}
+}
+
+// mustXPath 编译测试中的XPath，出错时结束测试
+func mustXPath(t *testing.T, expr string) *XPath {
+	t.Helper()
+	x, err := CompileXPath(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
@@ -0,0 +1,398 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"GoBrowserAgent/pkg/browser/dom"
+)
+
+// formField 提交表单时的一个字段
+type formField struct {
+	name  string
+	value string
+	// file 文件输入框，HTTP后端不能选择文件，总是提交空文件
+	file bool
+}
+
+// nonTextInputs 不能输入文字的input类型
+var nonTextInputs = map[string]bool{
+	"checkbox": true, "radio": true, "submit": true, "image": true, "reset": true,
+	"button": true, "file": true, "hidden": true,
+}
+
+// inputType 返回input的小写类型，未设置时为text
+func inputType(n *dom.Node) string {
+	if t := strings.ToLower(strings.TrimSpace(n.AttrOr("type", ""))); t != "" {
+		return t
+	}
+	return "text"
+}
+
+func isTextInput(n *dom.Node) bool {
+	return n.Tag == "input" && !nonTextInputs[inputType(n)]
+}
+
+func isCheckable(n *dom.Node) bool {
+	if n.Tag != "input" {
+		return false
+	}
+	t := inputType(n)
+	return t == "checkbox" || t == "radio"
+}
+
+// isSubmitButton 判断元素是否是提交按钮：未指定type的button、type=submit或image
+func isSubmitButton(n *dom.Node) bool {
+	switch n.Tag {
+	case "button":
+		t := strings.ToLower(n.AttrOr("type", "submit"))
+		return t == "submit" || t == ""
+	case "input":
+		t := inputType(n)
+		return t == "submit" || t == "image"
+	}
+	return false
+}
+
+// listedElements 属于表单的控件
+var listedElements = map[string]bool{
+	"button": true, "fieldset": true, "input": true, "object": true, "output": true, "select": true, "textarea": true,
+}
+
+// formOwner 返回控件所属的表单：form属性指定的表单，否则是最近的祖先form
+func formOwner(n *dom.Node) *dom.Node {
+	if id, ok := n.Attr("form"); ok && listedElements[n.Tag] {
+		if form := n.Root().GetElementByID(id); form != nil && form.Tag == "form" {
+			return form
+		}
+		return nil
+	}
+	if n.Parent == nil {
+		return nil
+	}
+	return n.Parent.Closest(func(e *dom.Node) bool { return e.Tag == "form" })
+}
+
+// labeledControl 返回label关联的控件：for属性指定的元素或label中的第一个控件
+func labeledControl(label *dom.Node) *dom.Node {
+	if id, ok := label.Attr("for"); ok {
+		if n := label.Root().GetElementByID(id); n != nil && listedElements[n.Tag] {
+			return n
+		}
+		return nil
+	}
+	for _, n := range label.Elements() {
+		switch n.Tag {
+		case "button", "select", "textarea":
+			return n
+		case "input":
+			if inputType(n) != "hidden" {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// setChecked 设置复选框或单选框的选中状态，选中单选框时取消同组的其他单选框
+func setChecked(n *dom.Node, checked bool) {
+	if !checked {
+		n.RemoveAttr("checked")
+		return
+	}
+	n.SetAttr("checked", "")
+	if inputType(n) != "radio" || n.AttrOr("name", "") == "" {
+		return
+	}
+	form := formOwner(n)
+	for _, other := range n.Root().Elements() {
+		if other != n && other.Tag == "input" && inputType(other) == "radio" &&
+			other.AttrOr("name", "") == n.AttrOr("name", "") && formOwner(other) == form {
+			other.RemoveAttr("checked")
+		}
+	}
+}
+
+// optionValue 返回option的value属性，没有时返回文字
+func optionValue(option *dom.Node) string {
+	if v, ok := option.Attr("value"); ok {
+		return v
+	}
+	return optionText(option)
+}
+
+func optionText(option *dom.Node) string {
+	return strings.Join(strings.Fields(option.Text()), " ")
+}
+
+func options(sel *dom.Node) []*dom.Node {
+	var out []*dom.Node
+	for _, n := range sel.Elements() {
+		if n.Tag == "option" {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// selectedOptions 返回选中的option；单选的下拉框没有选中项时与浏览器一样视为选中第一个可用的选项
+func selectedOptions(sel *dom.Node) []*dom.Node {
+	var selected []*dom.Node
+	all := options(sel)
+	for _, o := range all {
+		if o.HasAttr("selected") {
+			selected = append(selected, o)
+		}
+	}
+	if sel.HasAttr("multiple") {
+		return selected
+	}
+	if len(selected) > 0 {
+		// 单选时多个selected以最后一个为准
+		return selected[len(selected)-1:]
+	}
+	for _, o := range all {
+		if !dom.Disabled(o) {
+			return []*dom.Node{o}
+		}
+	}
+	return nil
+}
+
+// selectOption 选择值或文字等于value的选项，单选的下拉框同时取消其他选项
+func selectOption(sel *dom.Node, value string) error {
+	var match *dom.Node
+	all := options(sel)
+	for _, o := range all {
+		if optionValue(o) == value || optionText(o) == value {
+			match = o
+			break
+		}
+	}
+	if match == nil {
+		return fmt.Errorf("下拉框中没有值为%q的选项", value)
+	}
+	if dom.Disabled(match) {
+		return fmt.Errorf("选项%q已禁用", value)
+	}
+	if !sel.HasAttr("multiple") {
+		for _, o := range all {
+			o.RemoveAttr("selected")
+		}
+	}
+	match.SetAttr("selected", "")
+	return nil
+}
+
+// controlValue 返回表单控件的当前值
+func controlValue(n *dom.Node) string {
+	switch n.Tag {
+	case "textarea":
+		return n.Text()
+	case "select":
+		values := []string{}
+		for _, o := range selectedOptions(n) {
+			values = append(values, optionValue(o))
+		}
+		return strings.Join(values, ",")
+	}
+	if isCheckable(n) {
+		return n.AttrOr("value", "on")
+	}
+	return n.AttrOr("value", "")
+}
+
+// formData 按文档顺序收集表单中成功的控件，包括隐藏字段（例如CSRF令牌）和提交按钮本身
+func formData(form, submitter *dom.Node) []formField {
+	var fields []formField
+	for _, n := range form.Root().Elements() {
+		if !listedElements[n.Tag] || formOwner(n) != form || dom.Disabled(n) {
+			continue
+		}
+		name := n.AttrOr("name", "")
+		if n.Tag == "input" && inputType(n) == "image" {
+			// 图片按钮提交点击坐标
+			if n == submitter {
+				prefix := ""
+				if name != "" {
+					prefix = name + "."
+				}
+				fields = append(fields, formField{name: prefix + "x", value: "0"}, formField{name: prefix + "y", value: "0"})
+			}
+			continue
+		}
+		if name == "" {
+			continue
+		}
+
+		switch n.Tag {
+		case "select":
+			for _, o := range selectedOptions(n) {
+				if !dom.Disabled(o) {
+					fields = append(fields, formField{name: name, value: optionValue(o)})
+				}
+			}
+		case "textarea":
+			fields = append(fields, formField{name: name, value: normalizeNewlines(n.Text())})
+		case "button":
+			if n == submitter {
+				fields = append(fields, formField{name: name, value: n.AttrOr("value", "")})
+			}
+		case "input":
+			switch t := inputType(n); t {
+			case "checkbox", "radio":
+				if n.HasAttr("checked") {
+					fields = append(fields, formField{name: name, value: n.AttrOr("value", "on")})
+				}
+			case "submit":
+				if n == submitter {
+					fields = append(fields, formField{name: name, value: n.AttrOr("value", "Submit")})
+				}
+			case "reset", "button":
+			case "file":
+				fields = append(fields, formField{name: name, file: true})
+			default:
+				value := n.AttrOr("value", "")
+				if t == "hidden" && strings.EqualFold(name, "_charset_") {
+					value = "UTF-8"
+				}
+				fields = append(fields, formField{name: name, value: value})
+			}
+		}
+	}
+	return fields
+}
+
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// validateForm 检查必填字段，表单或提交按钮声明novalidate时跳过
+func validateForm(form, submitter *dom.Node) error {
+	if form.HasAttr("novalidate") || submitter != nil && submitter.HasAttr("formnovalidate") {
+		return nil
+	}
+	for _, n := range form.Root().Elements() {
+		if !n.HasAttr("required") || formOwner(n) != form || dom.Disabled(n) {
+			continue
+		}
+		empty := false
+		switch {
+		case n.Tag == "select":
+			values := selectedOptions(n)
+			empty = len(values) == 0 || optionValue(values[0]) == ""
+		case n.Tag == "textarea":
+			empty = n.Text() == ""
+		case isCheckable(n):
+			empty = !n.HasAttr("checked")
+			if inputType(n) == "radio" {
+				// 同组任意一个选中即可
+				for _, other := range n.Root().Elements() {
+					if other.Tag == "input" && inputType(other) == "radio" && other.HasAttr("checked") &&
+						other.AttrOr("name", "") == n.AttrOr("name", "") && formOwner(other) == form {
+						empty = false
+					}
+				}
+			}
+		case n.Tag == "input" && inputType(n) != "hidden" && !nonTextInputs[inputType(n)]:
+			empty = n.AttrOr("value", "") == ""
+		}
+		if empty {
+			name := n.AttrOr("name", n.AttrOr("id", n.Tag))
+			return fmt.Errorf("表单校验未通过: %s为必填项", name)
+		}
+	}
+	return nil
+}
+
+// submit 按表单的action、method和enctype提交，提交按钮的formaction等属性优先，调用方持有p.mu
+func (p *HTTPPage) submit(ctx context.Context, form, submitter *dom.Node) error {
+	attr := func(formAttr, buttonAttr string) string {
+		if submitter != nil {
+			if v, ok := submitter.Attr(buttonAttr); ok {
+				return v
+			}
+		}
+		return form.AttrOr(formAttr, "")
+	}
+
+	if err := validateForm(form, submitter); err != nil {
+		return err
+	}
+
+	action := attr("action", "formaction")
+	if strings.TrimSpace(action) == "" {
+		action = p.documentURL(form.Root()).String()
+	}
+	u, err := p.resolveFrom(form, action)
+	if err != nil {
+		return fmt.Errorf("提交表单到%s失败: %v", action, err)
+	}
+
+	fields := formData(form, submitter)
+	method := strings.ToLower(strings.TrimSpace(attr("method", "formmethod")))
+	switch method {
+	case "post":
+	case "dialog":
+		return nil
+	default:
+		// GET提交时字段替换地址中的查询参数
+		u.RawQuery = encodeURLFields(fields)
+		return p.load(ctx, http.MethodGet, u, nil, "")
+	}
+
+	enctype := strings.ToLower(strings.TrimSpace(attr("enctype", "formenctype")))
+	var body bytes.Buffer
+	contentType := "application/x-www-form-urlencoded"
+	switch enctype {
+	case "multipart/form-data":
+		w := multipart.NewWriter(&body)
+		for _, f := range fields {
+			if f.file {
+				header := make(textproto.MIMEHeader)
+				header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename=""`, escapeQuotes(f.name)))
+				header.Set("Content-Type", "application/octet-stream")
+				if _, err := w.CreatePart(header); err != nil {
+					return fmt.Errorf("编码表单失败: %v", err)
+				}
+				continue
+			}
+			if err := w.WriteField(f.name, f.value); err != nil {
+				return fmt.Errorf("编码表单失败: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("编码表单失败: %v", err)
+		}
+		contentType = w.FormDataContentType()
+	case "text/plain":
+		for _, f := range fields {
+			body.WriteString(f.name + "=" + f.value + "\r\n")
+		}
+		contentType = "text/plain; charset=utf-8"
+	default:
+		body.WriteString(encodeURLFields(fields))
+	}
+	return p.load(ctx, http.MethodPost, u, bytes.NewReader(body.Bytes()), contentType)
+}
+
+// encodeURLFields 按原顺序编码字段，url.Values.Encode会按名称排序，不能使用
+func encodeURLFields(fields []formField) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		parts = append(parts, url.QueryEscape(f.name)+"="+url.QueryEscape(f.value))
+	}
+	return strings.Join(parts, "&")
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
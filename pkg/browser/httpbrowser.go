@@ -0,0 +1,801 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"GoBrowserAgent/pkg/browser/dom"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultUserAgent HTTP后端默认发送的User-Agent
+const DefaultUserAgent = "Mozilla/5.0 (compatible; GoBrowserAgent/1.0)"
+
+// maxRedirects 一次导航最多跟随的重定向次数，包括0秒的meta refresh
+const maxRedirects = 10
+
+// maxTextSize 非HTML响应最多读取的字节数
+const maxTextSize = 4 << 20
+
+// maxFrames 每个页面最多加载的iframe数
+const maxFrames = 10
+
+// maxFrameDepth iframe最多嵌套的层数
+const maxFrameDepth = 3
+
+// ErrBrowserClosed 浏览器已关闭
+var ErrBrowserClosed = errors.New("浏览器已关闭")
+
+// HTTPBrowser 只用HTTP请求获取页面的浏览器
+//
+// 页面由pkg/browser/dom解析，不执行JavaScript、不加载图片和样式，也没有布局：
+// Query返回的元素位置和大小都是0，可见性根据hidden属性、type="hidden"和内联样式判断。
+// 页面只在导航、点击和提交时变化，因此操作元素和WaitFor系列方法只检查一次，条件不满足时立即返回错误。
+// 同源的iframe随页面一起加载，选择器可以找到其中的元素。
+// 同一个HTTPBrowser打开的页面共用Cookie，相当于同一个浏览器的多个标签页。
+type HTTPBrowser struct {
+	config *Config
+	client *http.Client
+	jar    *cookieJar
+
+	mu     sync.Mutex
+	pages  map[*HTTPPage]struct{}
+	closed bool
+}
+
+// NewHTTPBrowser 创建HTTP后端的浏览器，transport为nil时使用http.DefaultTransport
+//
+// 需要代理、自定义CA等出站设置时传入httpclient.Client的Transport()。
+func NewHTTPBrowser(config *Config, transport http.RoundTripper) *HTTPBrowser {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	jar := newCookieJar()
+	b := &HTTPBrowser{config: config, jar: jar, pages: make(map[*HTTPPage]struct{})}
+	b.client = &http.Client{
+		Transport: transport,
+		Jar:       jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("重定向次数超过%d次", maxRedirects)
+			}
+			return nil
+		},
+	}
+	return b
+}
+
+// NewPage 打开新的空白页面
+func (b *HTTPBrowser) NewPage(ctx context.Context) (Page, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBrowserClosed
+	}
+	page := &HTTPPage{browser: b}
+	page.reset()
+	b.pages[page] = struct{}{}
+	return page, nil
+}
+
+// Close 关闭所有页面
+func (b *HTTPBrowser) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	pages := make([]*HTTPPage, 0, len(b.pages))
+	for page := range b.pages {
+		pages = append(pages, page)
+	}
+	b.mu.Unlock()
+
+	for _, page := range pages {
+		page.Close()
+	}
+	return nil
+}
+
+func (b *HTTPBrowser) userAgent() string {
+	if b.config.UserAgent != "" {
+		return b.config.UserAgent
+	}
+	return DefaultUserAgent
+}
+
+// HistoryEntry 页面加载过的一个地址
+type HistoryEntry struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Status int    `json:"status"`
+	// Redirects 到达URL之前经过的重定向地址，按顺序排列
+	Redirects []string  `json:"redirects,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// HTTPPage HTTPBrowser中的一个页面
+//
+// 输入和选择直接修改DOM：输入框的value属性、textarea的文字、option的selected属性和
+// 复选框的checked属性，提交表单时按修改后的DOM收集字段。iframe中的链接和表单按iframe的地址
+// 解析，但打开的页面替换整个页面，而不是只替换iframe。
+type HTTPPage struct {
+	browser *HTTPBrowser
+
+	mu     sync.Mutex
+	url    *url.URL
+	doc    *dom.Node
+	status int
+	// frames iframe中加载的文档及其地址
+	frames  map[*dom.Node]*url.URL
+	history []HistoryEntry
+	closed  bool
+}
+
+// reset 回到about:blank
+func (p *HTTPPage) reset() {
+	p.url = &url.URL{Scheme: "about", Opaque: "blank"}
+	p.doc = dom.ParseString("")
+	p.frames = nil
+	p.status = 0
+}
+
+// History 返回页面按顺序加载过的地址
+func (p *HTTPPage) History() []HistoryEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]HistoryEntry(nil), p.history...)
+}
+
+// Status 返回当前页面的HTTP状态码，about:blank为0
+func (p *HTTPPage) Status() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// Document 返回当前页面的DOM，调用方不能修改它
+func (p *HTTPPage) Document() *dom.Node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.doc
+}
+
+// lock 加锁并检查页面是否已关闭
+func (p *HTTPPage) lock() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrBrowserClosed
+	}
+	return nil
+}
+
+// Navigate 打开地址，跟随重定向直到得到最终页面
+//
+// 与浏览器一致，4xx和5xx响应也作为页面加载，不返回错误，可以通过Status查看状态码。
+func (p *HTTPPage) Navigate(ctx context.Context, rawURL string) error {
+	if err := p.lock(); err != nil {
+		return err
+	}
+	defer p.mu.Unlock()
+
+	if rawURL == "about:blank" {
+		p.reset()
+		return nil
+	}
+	u, err := p.resolve(rawURL)
+	if err != nil {
+		return fmt.Errorf("打开%s失败: %v", rawURL, err)
+	}
+	return p.load(ctx, http.MethodGet, u, nil, "")
+}
+
+// resolve 以当前页面的基准地址解析相对地址，只接受http和https
+func (p *HTTPPage) resolve(ref string) (*url.URL, error) {
+	return p.resolveFrom(p.doc, ref)
+}
+
+// resolveFrom 以节点所在文档（页面或iframe）的基准地址解析相对地址
+func (p *HTTPPage) resolveFrom(n *dom.Node, ref string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return nil, err
+	}
+	if base := p.baseURL(n.Root()); base.Scheme == "http" || base.Scheme == "https" {
+		u = base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u, nil
+	case "javascript":
+		return nil, fmt.Errorf("javascript地址: %w", ErrUnsupported)
+	case "":
+		return nil, fmt.Errorf("缺少协议，需要以http://或https://开头")
+	}
+	return nil, fmt.Errorf("%s协议: %w", u.Scheme, ErrUnsupported)
+}
+
+// baseURL 返回文档解析相对地址的基准，文档有<base href>时使用它
+func (p *HTTPPage) baseURL(doc *dom.Node) *url.URL {
+	docURL := p.documentURL(doc)
+	if base := doc.FindTag("base"); base != nil {
+		if href, ok := base.Attr("href"); ok {
+			if u, err := docURL.Parse(href); err == nil {
+				return u
+			}
+		}
+	}
+	return docURL
+}
+
+// documentURL 返回文档的地址：iframe中的文档是iframe加载的地址，其他是页面的地址
+func (p *HTTPPage) documentURL(doc *dom.Node) *url.URL {
+	if u, ok := p.frames[doc]; ok {
+		return u
+	}
+	return p.url
+}
+
+// load 发送请求并把响应加载为当前页面，调用方持有p.mu
+func (p *HTTPPage) load(ctx context.Context, method string, u *url.URL, body io.Reader, contentType string) error {
+	ctx, cancel := p.browser.config.withTimeout(ctx)
+	defer cancel()
+
+	for refreshes := 0; ; refreshes++ {
+		doc, resp, err := p.fetch(ctx, method, u, body, contentType)
+		if err != nil {
+			return err
+		}
+		final := resp.Request.URL
+		if final.Fragment == "" {
+			final.Fragment = u.Fragment
+		}
+		var redirects []string
+		for r := resp.Request; r.Response != nil; r = r.Response.Request {
+			redirects = append([]string{r.Response.Request.URL.String()}, redirects...)
+		}
+		p.url, p.doc, p.status, p.frames = final, doc, resp.StatusCode, nil
+		p.history = append(p.history, HistoryEntry{
+			URL:       final.String(),
+			Method:    method,
+			Status:    resp.StatusCode,
+			Redirects: redirects,
+			Time:      time.Now(),
+		})
+		logrus.Debugf("HTTP浏览器: %s %s -> %d", method, final, resp.StatusCode)
+
+		// 内网登录页常用0秒的meta refresh跳转，按重定向处理
+		next := p.metaRefresh()
+		if next == nil {
+			p.loadFrames(ctx, p.doc, 1)
+			return nil
+		}
+		if refreshes >= maxRedirects {
+			return fmt.Errorf("打开%s失败: 重定向次数超过%d次", u, maxRedirects)
+		}
+		method, u, body, contentType = http.MethodGet, next, nil, ""
+	}
+}
+
+// fetch 发送请求并解析响应，返回的响应已经读完并关闭，调用方持有p.mu
+func (p *HTTPPage) fetch(ctx context.Context, method string, u *url.URL, body io.Reader, contentType string) (*dom.Node, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开%s失败: %v", u, err)
+	}
+	req.Header.Set("User-Agent", p.browser.userAgent())
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if p.url.Scheme == "http" || p.url.Scheme == "https" {
+		referer := *p.url
+		referer.Fragment = ""
+		referer.User = nil
+		req.Header.Set("Referer", referer.String())
+	}
+
+	resp, err := p.browser.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开%s失败: %v", u, err)
+	}
+	doc, err := readDocument(resp)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取%s失败: %v", u, err)
+	}
+	return doc, resp, nil
+}
+
+// loadFrames 加载文档中与页面同源的iframe，放进iframe元素的Content；
+// 加载失败的iframe保持为空，不影响页面本身，调用方持有p.mu
+func (p *HTTPPage) loadFrames(ctx context.Context, doc *dom.Node, depth int) {
+	if depth > maxFrameDepth {
+		return
+	}
+	for _, frame := range dom.MustCompile("iframe[src], frame[src]").Select(doc) {
+		if len(p.frames) >= maxFrames {
+			logrus.Debugf("HTTP浏览器: %s的iframe超过%d个，不再加载", p.url, maxFrames)
+			return
+		}
+		u, err := p.resolveFrom(frame, frame.AttrOr("src", ""))
+		if err != nil || u.Scheme != p.url.Scheme || u.Host != p.url.Host {
+			continue
+		}
+		content, resp, err := p.fetch(ctx, http.MethodGet, u, nil, "")
+		if err != nil {
+			logrus.Warnf("HTTP浏览器: 加载iframe失败: %v", err)
+			continue
+		}
+		if p.frames == nil {
+			p.frames = make(map[*dom.Node]*url.URL)
+		}
+		frame.Content = content
+		p.frames[content] = resp.Request.URL
+		logrus.Debugf("HTTP浏览器: iframe %s -> %d", resp.Request.URL, resp.StatusCode)
+		p.loadFrames(ctx, content, depth+1)
+	}
+}
+
+// readDocument 解析响应，非HTML的文本放进<pre>，其他类型得到空白文档
+func readDocument(resp *http.Response) (*dom.Node, error) {
+	mediaType, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if charset := strings.ToLower(params["charset"]); charset != "" && charset != "utf-8" && charset != "utf8" {
+		logrus.Warnf("页面%s的编码是%s，HTTP浏览器只按UTF-8解析，非ASCII文字可能显示为乱码", resp.Request.URL, charset)
+	}
+	switch {
+	case mediaType == "" || strings.Contains(mediaType, "html"):
+		return dom.Parse(resp.Body)
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") || strings.HasSuffix(mediaType, "xml"):
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxTextSize))
+		if err != nil {
+			return nil, err
+		}
+		doc := dom.ParseString("")
+		pre := dom.NewElement("pre")
+		pre.AppendChild(dom.NewText(string(data)))
+		doc.FindTag("body").AppendChild(pre)
+		return doc, nil
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxTextSize))
+	return dom.ParseString(""), nil
+}
+
+// metaRefresh 返回<meta http-equiv="refresh" content="0; url=...">指向的地址，延迟不为0时忽略
+func (p *HTTPPage) metaRefresh() *url.URL {
+	for _, meta := range dom.MustCompile(`meta[http-equiv="refresh" i]`).Select(p.doc) {
+		content := meta.AttrOr("content", "")
+		delay, target, _ := strings.Cut(content, ";")
+		if delay, err := strconv.ParseFloat(strings.TrimSpace(delay), 64); err != nil || delay > 0 {
+			continue
+		}
+		target = strings.TrimSpace(target)
+		if len(target) >= 4 && strings.EqualFold(target[:4], "url=") {
+			target = strings.Trim(strings.TrimSpace(target[4:]), `"'`)
+		}
+		if target == "" {
+			continue
+		}
+		if u, err := p.resolve(target); err == nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// URL 返回当前地址
+func (p *HTTPPage) URL(ctx context.Context) (string, error) {
+	if err := p.lock(); err != nil {
+		return "", err
+	}
+	defer p.mu.Unlock()
+	return p.url.String(), nil
+}
+
+// Title 返回<title>的文字
+func (p *HTTPPage) Title(ctx context.Context) (string, error) {
+	if err := p.lock(); err != nil {
+		return "", err
+	}
+	defer p.mu.Unlock()
+	if title := p.doc.FindTag("title"); title != nil {
+		return strings.Join(strings.Fields(title.Text()), " "), nil
+	}
+	return "", nil
+}
+
+// Query 返回选择器匹配的元素，位置和大小总是0
+func (p *HTTPPage) Query(ctx context.Context, selector string) ([]Element, error) {
+	if err := p.lock(); err != nil {
+		return nil, err
+	}
+	defer p.mu.Unlock()
+
+	nodes, err := p.selectAll(selector)
+	if err != nil {
+		return nil, err
+	}
+	elements := []Element{}
+	for _, n := range nodes {
+		if len(elements) >= maxQueryResults {
+			break
+		}
+		elements = append(elements, Element{
+			Tag:        n.Tag,
+			Text:       truncateText(elementText(n), maxElementText),
+			Attributes: n.AttrMap(),
+			Visible:    visible(n),
+		})
+	}
+	return elements, nil
+}
+
+// elementText 表单控件返回当前值，其他元素返回显示的文字
+func elementText(n *dom.Node) string {
+	switch n.Tag {
+	case "input", "textarea", "select":
+		return strings.TrimSpace(controlValue(n))
+	}
+	return n.VisibleText()
+}
+
+func truncateText(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+// visible 按hidden属性、隐藏的输入框和内联样式判断元素及其祖先是否可见
+func visible(n *dom.Node) bool {
+	for e := n; e != nil && e.Type == dom.ElementNode; e = e.Parent {
+		switch e.Tag {
+		case "template":
+			// 声明式shadow DOM的内容正常显示
+			if e.ShadowRootMode() == "" {
+				return false
+			}
+		case "head", "script", "style", "title", "meta", "link", "base":
+			return false
+		case "input":
+			if strings.EqualFold(e.AttrOr("type", ""), "hidden") {
+				return false
+			}
+		}
+		if e.HasAttr("hidden") {
+			return false
+		}
+		style := strings.ToLower(strings.Join(strings.Fields(e.AttrOr("style", "")), ""))
+		if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+			return false
+		}
+	}
+	return true
+}
+
+// find 返回第一个匹配的元素，requireVisible时同时检查可见性
+func (p *HTTPPage) find(selector string, requireVisible bool) (*dom.Node, error) {
+	nodes, err := p.selectAll(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%s: %w", selector, ErrElementNotFound)
+	}
+	n := nodes[0]
+	if requireVisible && !visible(n) {
+		return nil, fmt.Errorf("%s: %w", selector, ErrElementNotVisible)
+	}
+	return n, nil
+}
+
+// Click 模拟点击：链接跳转，提交按钮提交表单，复选框和单选框切换选中状态，label转给关联的控件
+func (p *HTTPPage) Click(ctx context.Context, selector string) error {
+	if err := p.lock(); err != nil {
+		return err
+	}
+	defer p.mu.Unlock()
+
+	n, err := p.find(selector, true)
+	if err != nil {
+		return err
+	}
+	if dom.Disabled(n) {
+		return fmt.Errorf("%s: %w", selector, ErrElementDisabled)
+	}
+	return p.activate(ctx, n)
+}
+
+// activate 执行元素被点击时的默认行为
+func (p *HTTPPage) activate(ctx context.Context, n *dom.Node) error {
+	if dom.Disabled(n) {
+		logrus.Debugf("HTTP浏览器: 忽略对禁用的<%s>的点击", n.Tag)
+		return nil
+	}
+	switch {
+	case n.Tag == "label":
+		if control := labeledControl(n); control != nil {
+			return p.activate(ctx, control)
+		}
+		return nil
+	case isCheckable(n):
+		setChecked(n, inputType(n) == "radio" || !n.HasAttr("checked"))
+		return nil
+	case isSubmitButton(n):
+		form := formOwner(n)
+		if form == nil {
+			return nil
+		}
+		return p.submit(ctx, form, n)
+	}
+
+	link := n.Closest(func(e *dom.Node) bool { return (e.Tag == "a" || e.Tag == "area") && e.HasAttr("href") })
+	if link == nil {
+		return nil
+	}
+	href := link.AttrOr("href", "")
+	u, err := p.resolveFrom(link, href)
+	if err != nil {
+		return fmt.Errorf("打开链接%s失败: %v", href, err)
+	}
+	// 只改变#片段的链接不重新加载页面
+	if u.Fragment != "" || strings.HasPrefix(href, "#") {
+		current, target := *p.documentURL(link.Root()), *u
+		current.Fragment, target.Fragment = "", ""
+		if current.String() == target.String() {
+			if link.Root() == p.doc {
+				p.url = u
+			}
+			return nil
+		}
+	}
+	return p.load(ctx, http.MethodGet, u, nil, "")
+}
+
+// Type 在输入框或textarea的已有内容后追加文字，下拉框选择值或文字相同的选项
+func (p *HTTPPage) Type(ctx context.Context, selector, text string) error {
+	return p.input(selector, text, true)
+}
+
+// Fill 替换输入框或textarea的内容，下拉框选择选项，复选框和单选框设置选中状态
+func (p *HTTPPage) Fill(ctx context.Context, selector, value string) error {
+	return p.input(selector, value, false)
+}
+
+func (p *HTTPPage) input(selector, text string, appendText bool) error {
+	if err := p.lock(); err != nil {
+		return err
+	}
+	defer p.mu.Unlock()
+
+	n, err := p.find(selector, true)
+	if err != nil {
+		return err
+	}
+	if dom.Disabled(n) {
+		return fmt.Errorf("%s: %w", selector, ErrElementDisabled)
+	}
+	if n.HasAttr("readonly") && n.Tag != "select" {
+		return fmt.Errorf("%s是只读的，不能输入", selector)
+	}
+
+	switch {
+	case n.Tag == "select":
+		return selectOption(n, text)
+	case isCheckable(n):
+		if appendText {
+			return fmt.Errorf("%s是复选框或单选框，请使用Click或Fill", selector)
+		}
+		setChecked(n, checkedValue(text))
+		return nil
+	case n.Tag == "textarea":
+		if appendText {
+			text = n.Text() + text
+		}
+		n.SetText(text)
+		return nil
+	case isTextInput(n):
+		if appendText {
+			text = n.AttrOr("value", "") + text
+		}
+		// 与浏览器一致，单行输入框去掉换行，并按maxlength截断
+		text = strings.NewReplacer("\r", "", "\n", "").Replace(text)
+		if max, err := strconv.Atoi(n.AttrOr("maxlength", "")); err == nil && max >= 0 {
+			text = truncateText(text, max)
+		}
+		n.SetAttr("value", text)
+		return nil
+	case strings.EqualFold(n.AttrOr("contenteditable", "false"), "true") || n.AttrOr("contenteditable", "false") == "":
+		if appendText {
+			text = n.Text() + text
+		}
+		n.SetText(text)
+		return nil
+	}
+	return fmt.Errorf("%s不是可输入的元素", selector)
+}
+
+// Submit 提交元素所在的表单，元素是提交按钮时以它作为提交者
+func (p *HTTPPage) Submit(ctx context.Context, selector string) error {
+	if err := p.lock(); err != nil {
+		return err
+	}
+	defer p.mu.Unlock()
+
+	n, err := p.find(selector, false)
+	if err != nil {
+		return err
+	}
+	form := n
+	if n.Tag != "form" {
+		form = formOwner(n)
+	}
+	if form == nil {
+		return fmt.Errorf("%s: %w", selector, ErrNotInForm)
+	}
+	if n != form && dom.Disabled(n) {
+		return fmt.Errorf("%s: %w", selector, ErrElementDisabled)
+	}
+	var submitter *dom.Node
+	if isSubmitButton(n) && formOwner(n) == form {
+		submitter = n
+	}
+	return p.submit(ctx, form, submitter)
+}
+
+// WaitForSelector 检查元素是否处于指定状态；页面不会自行变化，不满足时立即返回错误
+func (p *HTTPPage) WaitForSelector(ctx context.Context, selector string, state WaitState) error {
+	if state == "" {
+		state = WaitVisible
+	}
+	return waitError(selector+stateText(state), checkOnce(ctx, selectorCheck(p, selector, state)))
+}
+
+// WaitForURL 检查地址是否匹配正则表达式
+func (p *HTTPPage) WaitForURL(ctx context.Context, pattern *regexp.Regexp) error {
+	return waitError("地址匹配"+pattern.String(), checkOnce(ctx, urlCheck(p, pattern)))
+}
+
+// WaitForFunction HTTP后端不执行JavaScript
+func (p *HTTPPage) WaitForFunction(ctx context.Context, expression string) error {
+	return fmt.Errorf("执行JavaScript: %w", ErrUnsupported)
+}
+
+// WaitForNetworkIdle 每个请求都在操作返回前完成，网络总是空闲的
+func (p *HTTPPage) WaitForNetworkIdle(ctx context.Context) error {
+	if err := p.lock(); err != nil {
+		return err
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// Evaluate HTTP后端不执行JavaScript
+func (p *HTTPPage) Evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	return nil, fmt.Errorf("执行JavaScript: %w", ErrUnsupported)
+}
+
+// Screenshot HTTP后端没有渲染，不能截图
+func (p *HTTPPage) Screenshot(ctx context.Context) ([]byte, error) {
+	return nil, fmt.Errorf("截图: %w", ErrUnsupported)
+}
+
+// Cookies 返回当前地址可见的Cookie
+//
+// Domain以.开头的Cookie同时发给子域名，否则只发给该主机。
+func (p *HTTPPage) Cookies(ctx context.Context) ([]Cookie, error) {
+	if err := p.lock(); err != nil {
+		return nil, err
+	}
+	defer p.mu.Unlock()
+
+	cookies := []Cookie{}
+	if p.url.Scheme != "http" && p.url.Scheme != "https" {
+		return cookies, nil
+	}
+	return append(cookies, p.browser.jar.visible(p.url)...), nil
+}
+
+// SetCookies 把Cookie放进浏览器共用的Cookie容器
+func (p *HTTPPage) SetCookies(ctx context.Context, cookies []Cookie) error {
+	for _, c := range cookies {
+		var u *url.URL
+		if c.URL != "" {
+			parsed, err := url.Parse(c.URL)
+			if err != nil {
+				return fmt.Errorf("Cookie %s的url无效: %v", c.Name, err)
+			}
+			u = parsed
+		} else if c.Domain != "" {
+			u = &url.URL{Scheme: "http", Host: strings.TrimPrefix(c.Domain, "."), Path: c.Path}
+			if c.Secure {
+				u.Scheme = "https"
+			}
+		} else {
+			return fmt.Errorf("Cookie %s需要设置url或domain", c.Name)
+		}
+
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		// 以.开头的Domain表示同时发送给子域名
+		if strings.HasPrefix(c.Domain, ".") {
+			cookie.Domain = c.Domain
+		}
+		if c.Expires > 0 {
+			cookie.Expires = time.Unix(int64(c.Expires), 0)
+		}
+		switch strings.ToLower(c.SameSite) {
+		case "strict":
+			cookie.SameSite = http.SameSiteStrictMode
+		case "lax":
+			cookie.SameSite = http.SameSiteLaxMode
+		case "none":
+			cookie.SameSite = http.SameSiteNoneMode
+		}
+		p.browser.jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+	return nil
+}
+
+// StorageState 返回可以发给页面访问过的主机的Cookie，包括只发给其他路径的Cookie
+//
+// 保存的Cookie带有过期时间、Path、Secure和HttpOnly等属性。HTTP后端不执行脚本，
+// 没有localStorage和sessionStorage，Origins总是为空。
+func (p *HTTPPage) StorageState(ctx context.Context) (*StorageState, error) {
+	if err := p.lock(); err != nil {
+		return nil, err
+	}
+	defer p.mu.Unlock()
+
+	urls := []string{p.url.String()}
+	for _, entry := range p.history {
+		urls = append(urls, entry.Redirects...)
+		urls = append(urls, entry.URL)
+	}
+	var hosts []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		hosts = append(hosts, u.Hostname())
+	}
+	return &StorageState{Cookies: p.browser.jar.forHosts(hosts), Origins: []OriginStorage{}}, nil
+}
+
+// SetStorageState 设置没有过期的Cookie，HTTP后端没有页面存储，Origins被忽略
+func (p *HTTPPage) SetStorageState(ctx context.Context, state *StorageState) error {
+	if len(state.Origins) > 0 {
+		logrus.Debugf("HTTP后端不支持localStorage和sessionStorage，忽略%d个来源的存储", len(state.Origins))
+	}
+	return p.SetCookies(ctx, liveCookies(state.Cookies, time.Now()))
+}
+
+// Close 关闭页面
+func (p *HTTPPage) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.browser.mu.Lock()
+	delete(p.browser.pages, p)
+	p.browser.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,404 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const loginPage = `<!DOCTYPE html>
+<html><head><title>Sign in</title></head><body>
+<form id="login" method="post" action="/session">
+  <input type="hidden" name="csrf" value="tok-123">
+  <input name="username" id="username">
+  <input type="password" name="password" id="password" required>
+  <input type="checkbox" name="remember" value="yes" id="remember">
+  <input name="legacy" value="x" disabled>
+  <button type="submit" name="action" value="login" id="signin">Sign in</button>
+  <button type="submit" name="action" value="register" id="register">Register</button>
+</form>
+<form id="search" action="/search"><input type="hidden" name="scope" value="all"><input name="q" id="q"></form>
+<a id="home" href="home">home</a>
+</body></html>`
+
+// fakeSite 模拟一个带重定向、CSRF令牌和会话Cookie的登录站点
+type fakeSite struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	posts []string
+}
+
+func newFakeSite(t *testing.T) *fakeSite {
+	t.Helper()
+	site := &fakeSite{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/middle", http.StatusFound)
+	})
+	mux.HandleFunc("/middle", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, loginPage)
+	})
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		site.mu.Lock()
+		site.posts = append(site.posts, string(body))
+		site.mu.Unlock()
+		form, _ := url.ParseQuery(string(body))
+		if r.Method != http.MethodPost || form.Get("csrf") != "tok-123" || form.Get("password") != "s3cret" {
+			http.Error(w, "bad login", http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "user-" + form.Get("username"), Path: "/"})
+		http.Redirect(w, r, "/home", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("sid")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "<title>Unauthorized</title>")
+			return
+		}
+		fmt.Fprintf(w, "<title>Welcome %s</title>", strings.TrimPrefix(c.Value, "user-"))
+	})
+	mux.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<meta http-equiv="refresh" content="0; url=/login">`)
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<title>%s</title>", r.URL.RawQuery)
+	})
+	site.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(site.Close)
+	return site
+}
+
+func newTestPage(t *testing.T, b *HTTPBrowser) *HTTPPage {
+	t.Helper()
+	page, err := b.NewPage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page.(*HTTPPage)
+}
+
+func testBrowser() *HTTPBrowser {
+	config := GetDefaultConfig()
+	config.Timeout = 5
+	return NewHTTPBrowser(config, nil)
+}
+
+func title(t *testing.T, page Page) string {
+	t.Helper()
+	title, err := page.Title(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return title
+}
+
+func TestHTTPRedirectsAndHistory(t *testing.T) {
+	site := newFakeSite(t)
+	page := newTestPage(t, testBrowser())
+	ctx := context.Background()
+
+	if err := page.Navigate(ctx, site.URL+"/start"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := page.URL(ctx); got != site.URL+"/login" {
+		t.Fatalf("URL = %s, want /login", got)
+	}
+	if page.Status() != http.StatusOK {
+		t.Fatalf("Status = %d", page.Status())
+	}
+
+	// 4xx也作为页面加载
+	if err := page.Navigate(ctx, "/home"); err != nil {
+		t.Fatal(err)
+	}
+	if page.Status() != http.StatusUnauthorized || title(t, page) != "Unauthorized" {
+		t.Fatalf("Status = %d, title = %q", page.Status(), title(t, page))
+	}
+
+	// 0秒的meta refresh按重定向处理
+	if err := page.Navigate(ctx, site.URL+"/refresh"); err != nil {
+		t.Fatal(err)
+	}
+	if title(t, page) != "Sign in" {
+		t.Fatalf("meta refresh not followed, title = %q", title(t, page))
+	}
+
+	history := page.History()
+	want := []HistoryEntry{
+		{URL: site.URL + "/login", Method: "GET", Status: 200, Redirects: []string{site.URL + "/start", site.URL + "/middle"}},
+		{URL: site.URL + "/home", Method: "GET", Status: 401},
+		{URL: site.URL + "/refresh", Method: "GET", Status: 200},
+		{URL: site.URL + "/login", Method: "GET", Status: 200},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history has %d entries, want %d: %+v", len(history), len(want), history)
+	}
+	for i, w := range want {
+		got := history[i]
+		if got.URL != w.URL || got.Method != w.Method || got.Status != w.Status ||
+			strings.Join(got.Redirects, " ") != strings.Join(w.Redirects, " ") || got.Time.IsZero() {
+			t.Errorf("history[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestHTTPRedirectLoop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path, http.StatusFound)
+	}))
+	defer server.Close()
+
+	page := newTestPage(t, testBrowser())
+	err := page.Navigate(context.Background(), server.URL+"/loop")
+	if err == nil || !strings.Contains(err.Error(), "重定向次数超过") {
+		t.Fatalf("err = %v, want redirect limit error", err)
+	}
+}
+
+func TestHTTPFormSubmission(t *testing.T) {
+	site := newFakeSite(t)
+	page := newTestPage(t, testBrowser())
+	ctx := context.Background()
+
+	if err := page.Navigate(ctx, site.URL+"/login"); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.Fill(ctx, "#username", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	// 必填的密码为空时不提交
+	if err := page.Click(ctx, "#signin"); err == nil || !strings.Contains(err.Error(), "password为必填项") {
+		t.Fatalf("err = %v, want required field error", err)
+	}
+	if err := page.Fill(ctx, "#password", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.Click(ctx, "#remember"); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.Click(ctx, "#signin"); err != nil {
+		t.Fatal(err)
+	}
+
+	site.mu.Lock()
+	posts := append([]string(nil), site.posts...)
+	site.mu.Unlock()
+	// 隐藏的CSRF令牌按文档顺序提交，禁用的字段和未点击的按钮不提交
+	want := "csrf=tok-123&username=alice&password=s3cret&remember=yes&action=login"
+	if len(posts) != 1 || posts[0] != want {
+		t.Fatalf("posted %q, want %q", posts, want)
+	}
+	if got := title(t, page); got != "Welcome alice" {
+		t.Fatalf("title = %q", got)
+	}
+	history := page.History()
+	last := history[len(history)-1]
+	if last.Method != "POST" || last.URL != site.URL+"/home" || len(last.Redirects) != 1 || last.Redirects[0] != site.URL+"/session" {
+		t.Fatalf("last history entry = %+v", last)
+	}
+}
+
+func TestHTTPGetFormReplacesQuery(t *testing.T) {
+	site := newFakeSite(t)
+	page := newTestPage(t, testBrowser())
+	ctx := context.Background()
+
+	if err := page.Navigate(ctx, site.URL+"/login?old=1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.Submit(ctx, "#home"); !errors.Is(err, ErrNotInForm) {
+		t.Fatalf("submit outside form err = %v, want ErrNotInForm", err)
+	}
+	if err := page.Fill(ctx, "#q", "a b&c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.Submit(ctx, "#q"); err != nil {
+		t.Fatal(err)
+	}
+	if got := title(t, page); got != "scope=all&q=a+b%26c" {
+		t.Fatalf("query = %q", got)
+	}
+}
+
+func TestHTTPCookieJar(t *testing.T) {
+	site := newFakeSite(t)
+	b := testBrowser()
+	ctx := context.Background()
+
+	page := newTestPage(t, b)
+	page.Navigate(ctx, site.URL+"/login")
+	page.Fill(ctx, "#username", "bob")
+	page.Fill(ctx, "#password", "s3cret")
+	if err := page.Submit(ctx, "#signin"); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := page.Cookies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "sid" || cookies[0].Value != "user-bob" {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+
+	// 同一个浏览器的页面共用Cookie
+	other := newTestPage(t, b)
+	if err := other.Navigate(ctx, site.URL+"/home"); err != nil {
+		t.Fatal(err)
+	}
+	if got := title(t, other); got != "Welcome bob" {
+		t.Fatalf("second page title = %q", got)
+	}
+
+	// 另一个浏览器没有Cookie，SetCookies后才能访问
+	fresh := newTestPage(t, testBrowser())
+	fresh.Navigate(ctx, site.URL+"/home")
+	if fresh.Status() != http.StatusUnauthorized {
+		t.Fatalf("fresh browser status = %d, want 401", fresh.Status())
+	}
+	if err := fresh.SetCookies(ctx, []Cookie{{Name: "sid", Value: "user-carol", URL: site.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	fresh.Navigate(ctx, site.URL+"/home")
+	if got := title(t, fresh); got != "Welcome carol" {
+		t.Fatalf("title after SetCookies = %q", got)
+	}
+
+	// 过期的Cookie被删除
+	expired := float64(time.Now().Add(-time.Hour).Unix())
+	if err := fresh.SetCookies(ctx, []Cookie{{Name: "sid", Value: "gone", URL: site.URL, Expires: expired}}); err != nil {
+		t.Fatal(err)
+	}
+	fresh.Navigate(ctx, site.URL+"/home")
+	if fresh.Status() != http.StatusUnauthorized {
+		t.Fatalf("status after expiring cookie = %d, want 401", fresh.Status())
+	}
+}
+
+func TestHTTPStorageStateRoundTrip(t *testing.T) {
+	site := newFakeSite(t)
+	ctx := context.Background()
+
+	page := newTestPage(t, testBrowser())
+	page.Navigate(ctx, site.URL+"/login")
+	page.Fill(ctx, "#username", "dave")
+	page.Fill(ctx, "#password", "s3cret")
+	if err := page.Submit(ctx, "#signin"); err != nil {
+		t.Fatal(err)
+	}
+	state, err := page.StorageState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestPage(t, testBrowser())
+	if err := restored.SetStorageState(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	restored.Navigate(ctx, site.URL+"/home")
+	if got := title(t, restored); got != "Welcome dave" {
+		t.Fatalf("title after restoring state = %q", got)
+	}
+}
+
+func TestHTTPStorageStateCookieAttributes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/app", MaxAge: 3600, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+			http.SetCookie(w, &http.Cookie{Name: "secure", Value: "2", Path: "/", Expires: time.Now().Add(2 * time.Hour), Secure: true})
+			http.SetCookie(w, &http.Cookie{Name: "pref", Value: "3"})
+		case "/app/logout":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Path: "/app", MaxAge: -1})
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<title>%d</title>", len(r.Cookies()))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	host := strings.Split(strings.TrimPrefix(server.URL, "http://"), ":")[0]
+
+	page := newTestPage(t, testBrowser())
+	if err := page.Navigate(ctx, server.URL+"/app/login"); err != nil {
+		t.Fatal(err)
+	}
+	// 只发给/app的Cookie也要保存
+	if err := page.Navigate(ctx, server.URL+"/"); err != nil {
+		t.Fatal(err)
+	}
+	state, err := page.StorageState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]Cookie)
+	for _, c := range state.Cookies {
+		byName[c.Name] = c
+	}
+	if len(byName) != 3 {
+		t.Fatalf("cookies = %+v", state.Cookies)
+	}
+	now := float64(time.Now().Unix())
+	sid, secure, pref := byName["sid"], byName["secure"], byName["pref"]
+	if sid.Path != "/app" || !sid.HTTPOnly || sid.SameSite != "Lax" || sid.Domain != host || sid.Expires < now+3500 || sid.Expires > now+3700 {
+		t.Errorf("sid = %+v", sid)
+	}
+	if !secure.Secure || secure.Path != "/" || secure.Expires < now+7100 {
+		t.Errorf("secure = %+v", secure)
+	}
+	// 默认路径为请求路径去掉最后一段，没有过期时间的是会话Cookie
+	if pref.Path != "/app" || pref.Expires != 0 {
+		t.Errorf("pref = %+v", pref)
+	}
+	if !state.CookiesExpire().IsZero() {
+		t.Errorf("CookiesExpire = %v, want zero with a session cookie", state.CookiesExpire())
+	}
+
+	// 恢复后属性保持不变；标准库把localhost视为安全来源，这里的Secure Cookie也会发送
+	restored := newTestPage(t, testBrowser())
+	if err := restored.SetStorageState(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	restored.Navigate(ctx, server.URL+"/app/page")
+	if got := title(t, restored); got != "3" {
+		t.Fatalf("cookies sent after restore = %s, want 3", got)
+	}
+	again, err := restored.StorageState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Cookies) != len(state.Cookies) {
+		t.Fatalf("restored cookies = %+v", again.Cookies)
+	}
+	for i, c := range again.Cookies {
+		if c != state.Cookies[i] {
+			t.Errorf("restored cookie %+v, want %+v", c, state.Cookies[i])
+		}
+	}
+
+	// Max-Age为负数删除Cookie
+	restored.Navigate(ctx, server.URL+"/app/logout")
+	state, _ = restored.StorageState(ctx)
+	for _, c := range state.Cookies {
+		if c.Name == "sid" {
+			t.Fatalf("sid still saved after logout: %+v", state.Cookies)
+		}
+	}
+}
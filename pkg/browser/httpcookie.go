@@ -0,0 +1,223 @@
+package browser
+
+import (
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cookieJar HTTP后端的Cookie容器，在标准库的cookiejar.Jar之外记录每个Cookie的属性
+//
+// cookiejar.Jar决定发送哪些Cookie，但只能取回名称和值。保存登录状态时需要过期时间、
+// Secure、HttpOnly、Path以及是否只发给当前主机，因此设置Cookie时按RFC 6265的规则另外记录一份，
+// 读取时再与Jar返回的Cookie对应起来。
+type cookieJar struct {
+	jar *cookiejar.Jar
+
+	mu sync.Mutex
+	// entries 键与cookiejar相同：域名或主机;路径;名称
+	entries map[string]*cookieEntry
+}
+
+// cookieEntry 一个Cookie及其属性，Domain以.开头表示同时发给子域名，否则只发给该主机
+type cookieEntry struct {
+	Cookie
+	host string
+}
+
+func newCookieJar() *cookieJar {
+	// 不使用公共后缀列表时cookiejar仍然拒绝为IP地址设置域Cookie，内网站点足够使用
+	jar, _ := cookiejar.New(nil)
+	return &cookieJar{jar: jar, entries: make(map[string]*cookieEntry)}
+}
+
+// SetCookies 实现http.CookieJar，同时记录Cookie的属性
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+
+	now := time.Now()
+	host := strings.ToLower(u.Hostname())
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		domain, hostOnly, ok := cookieDomain(host, c.Domain)
+		if !ok {
+			continue
+		}
+		path := c.Path
+		if path == "" || path[0] != '/' {
+			path = defaultCookiePath(u.Path)
+		}
+		key := domain + ";" + path + ";" + c.Name
+
+		var expires float64
+		switch {
+		case c.MaxAge < 0:
+			delete(j.entries, key)
+			continue
+		case c.MaxAge > 0:
+			expires = float64(now.Add(time.Duration(c.MaxAge) * time.Second).Unix())
+		case !c.Expires.IsZero():
+			if !c.Expires.After(now) {
+				delete(j.entries, key)
+				continue
+			}
+			expires = float64(c.Expires.Unix())
+		}
+
+		entry := &cookieEntry{host: domain, Cookie: Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   domain,
+			Path:     path,
+			Expires:  expires,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+			SameSite: sameSiteName(c.SameSite),
+		}}
+		if !hostOnly {
+			entry.Domain = "." + domain
+		}
+		j.entries[key] = entry
+	}
+}
+
+// Cookies 实现http.CookieJar
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// visible 返回请求u时会发送的Cookie及其属性
+func (j *cookieJar) visible(u *url.URL) []Cookie {
+	sent := j.jar.Cookies(u)
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.expire(time.Now())
+	cookies := make([]Cookie, 0, len(sent))
+	used := make(map[*cookieEntry]bool)
+	for _, c := range sent {
+		var best *cookieEntry
+		for _, e := range j.entries {
+			if used[e] || e.Name != c.Name || e.Value != c.Value || !e.matchesHost(host) || !pathMatches(path, e.Path) {
+				continue
+			}
+			if best == nil || len(e.Path) > len(best.Path) {
+				best = e
+			}
+		}
+		if best == nil {
+			// 没有记录属性的Cookie按会话Cookie处理
+			cookies = append(cookies, Cookie{Name: c.Name, Value: c.Value, Domain: host, Path: "/"})
+			continue
+		}
+		used[best] = true
+		cookies = append(cookies, best.Cookie)
+	}
+	return cookies
+}
+
+// forHosts 返回可以发给这些主机的全部Cookie，不区分路径和协议，按域名、路径和名称排序
+func (j *cookieJar) forHosts(hosts []string) []Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.expire(time.Now())
+	cookies := []Cookie{}
+	for _, e := range j.entries {
+		for _, host := range hosts {
+			if e.matchesHost(strings.ToLower(host)) {
+				cookies = append(cookies, e.Cookie)
+				break
+			}
+		}
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		if cookies[a].Domain != cookies[b].Domain {
+			return cookies[a].Domain < cookies[b].Domain
+		}
+		if cookies[a].Path != cookies[b].Path {
+			return cookies[a].Path < cookies[b].Path
+		}
+		return cookies[a].Name < cookies[b].Name
+	})
+	return cookies
+}
+
+// expire 删除已经过期的记录，调用时持有j.mu
+func (j *cookieJar) expire(now time.Time) {
+	for key, e := range j.entries {
+		if e.Expires > 0 && e.Expires <= float64(now.Unix()) {
+			delete(j.entries, key)
+		}
+	}
+}
+
+// matchesHost 判断Cookie是否发给host
+func (e *cookieEntry) matchesHost(host string) bool {
+	if !strings.HasPrefix(e.Domain, ".") {
+		return host == e.host
+	}
+	return host == e.host || strings.HasSuffix(host, "."+e.host)
+}
+
+// cookieDomain 按cookiejar的规则确定Cookie所属的域名，ok为false时Jar会拒绝这个Cookie
+func cookieDomain(host, domain string) (string, bool, bool) {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" {
+		return host, true, true
+	}
+	if net.ParseIP(host) != nil {
+		// IP地址只能设置只发给自己的Cookie
+		return host, true, host == domain
+	}
+	if strings.HasSuffix(domain, ".") || host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// defaultCookiePath 没有Path属性时的默认路径：请求路径去掉最后一段
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if path == "" || path[0] != '/' || i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// pathMatches 判断请求路径是否在Cookie的Path之下
+func pathMatches(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// sameSiteName 把http.SameSite转换为Cookie.SameSite的写法
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
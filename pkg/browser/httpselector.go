@@ -0,0 +1,379 @@
+package browser
+
+import (
+	"strconv"
+	"strings"
+
+	"GoBrowserAgent/pkg/browser/dom"
+)
+
+// selectAll 按选择器的各段依次查找，返回匹配的元素，调用方持有p.mu
+func (p *HTTPPage) selectAll(selector string) ([]*dom.Node, error) {
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	found := []*dom.Node{p.doc}
+	for _, part := range parts {
+		if part.Engine == engineNth {
+			found = nthNode(found, part.Nth)
+			continue
+		}
+		var next []*dom.Node
+		seen := make(map[*dom.Node]bool)
+		for _, scope := range found {
+			nodes, err := queryPart(scope, part)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range nodes {
+				if !seen[n] {
+					seen[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		found = next
+	}
+	return found, nil
+}
+
+// nthNode 返回第n个元素组成的列表，n为负数时从末尾数
+func nthNode(nodes []*dom.Node, n int) []*dom.Node {
+	if n < 0 {
+		n += len(nodes)
+	}
+	if n < 0 || n >= len(nodes) {
+		return nil
+	}
+	return nodes[n : n+1]
+}
+
+// queryPart 在scope内查找选择器的一段
+func queryPart(scope *dom.Node, part selectorPart) ([]*dom.Node, error) {
+	roots := searchRoots(scope)
+	switch part.Engine {
+	case engineCSS:
+		sel, err := dom.Compile(part.Value)
+		if err != nil {
+			return nil, err
+		}
+		var out []*dom.Node
+		for _, root := range roots {
+			for _, n := range sel.Select(root) {
+				if !inTemplate(n) {
+					out = append(out, n)
+				}
+			}
+		}
+		return out, nil
+	case engineXPath:
+		var out []*dom.Node
+		for _, root := range roots {
+			expr := part.Value
+			if root.Type != dom.DocumentNode {
+				expr = scopedXPath(expr)
+			}
+			xpath, err := dom.CompileXPath(expr)
+			if err != nil {
+				return nil, err
+			}
+			nodes, err := xpath.Select(root)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, nodes...)
+		}
+		return out, nil
+	}
+
+	var candidates []*dom.Node
+	for _, root := range roots {
+		for _, n := range root.Elements() {
+			if n.Tag != "template" && !inTemplate(n) {
+				candidates = append(candidates, n)
+			}
+		}
+	}
+	labels := labelMap(roots)
+	var out []*dom.Node
+	switch part.Engine {
+	case engineText:
+		return innermost(candidates, func(n *dom.Node) bool {
+			return matchText(textContent(n), part.Value, part.Exact)
+		}), nil
+	case engineRole:
+		for _, n := range candidates {
+			if elementRole(n) == part.Value && (!part.HasName || matchText(accessibleName(n, labels), part.Name, part.NameExact)) {
+				out = append(out, n)
+			}
+		}
+	case engineLabel:
+		for _, n := range candidates {
+			for _, text := range labelTexts(n, labels) {
+				if matchText(text, part.Value, part.Exact) {
+					out = append(out, n)
+					break
+				}
+			}
+		}
+	case enginePlaceholder:
+		for _, n := range candidates {
+			if placeholder, ok := n.Attr("placeholder"); ok && matchText(placeholder, part.Value, part.Exact) {
+				out = append(out, n)
+			}
+		}
+	}
+	return out, nil
+}
+
+// searchRoots 返回在scope中查找时的根：scope本身和其中的iframe加载的文档
+func searchRoots(scope *dom.Node) []*dom.Node {
+	roots := []*dom.Node{scope}
+	visit := func(n *dom.Node) {
+		if n.Content != nil {
+			roots = append(roots, searchRoots(n.Content)...)
+		}
+	}
+	visit(scope)
+	for _, n := range scope.Elements() {
+		visit(n)
+	}
+	return roots
+}
+
+// inTemplate 判断元素是否在template的内容中；开放的shadow DOM除外，选择器可以进入其中
+func inTemplate(n *dom.Node) bool {
+	for e := n.Parent; e != nil; e = e.Parent {
+		if e.Tag == "template" && e.ShadowRootMode() != "open" {
+			return true
+		}
+	}
+	return false
+}
+
+// innermost 返回满足条件且没有后代也满足条件的元素
+func innermost(nodes []*dom.Node, match func(*dom.Node) bool) []*dom.Node {
+	var matched []*dom.Node
+	hasMatch := make(map[*dom.Node]bool)
+	for _, n := range nodes {
+		if !match(n) {
+			continue
+		}
+		matched = append(matched, n)
+		for e := n.Parent; e != nil; e = e.Parent {
+			hasMatch[e] = true
+		}
+	}
+	var out []*dom.Node
+	for _, n := range matched {
+		if !hasMatch[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// textContent 返回text:匹配的文字：按钮形式的输入框是value，其他元素是显示的文字
+func textContent(n *dom.Node) string {
+	if n.Tag == "input" {
+		switch inputType(n) {
+		case "button", "submit", "reset":
+			return n.AttrOr("value", "")
+		}
+		return ""
+	}
+	return n.VisibleText()
+}
+
+// labelable 可以用<label>标注的元素
+var labelable = map[string]bool{
+	"button": true, "input": true, "meter": true, "output": true, "progress": true, "select": true, "textarea": true,
+}
+
+// labelMap 返回各控件关联的<label>
+func labelMap(roots []*dom.Node) map[*dom.Node][]*dom.Node {
+	labels := make(map[*dom.Node][]*dom.Node)
+	for _, root := range roots {
+		for _, label := range root.Elements() {
+			if label.Tag != "label" {
+				continue
+			}
+			if control := labeledControl(label); control != nil {
+				labels[control] = append(labels[control], label)
+			}
+		}
+	}
+	return labels
+}
+
+// labelTexts 返回label:可以匹配的文字：关联的<label>、aria-labelledby指向的元素和aria-label
+func labelTexts(n *dom.Node, labels map[*dom.Node][]*dom.Node) []string {
+	var texts []string
+	for _, label := range labels[n] {
+		texts = append(texts, label.VisibleText())
+	}
+	if text := labelledByText(n); text != "" {
+		texts = append(texts, text)
+	}
+	if label, ok := n.Attr("aria-label"); ok {
+		texts = append(texts, label)
+	}
+	return texts
+}
+
+// labelledByText 返回aria-labelledby指向的元素的文字，用空格连接
+func labelledByText(n *dom.Node) string {
+	var texts []string
+	for _, id := range strings.Fields(n.AttrOr("aria-labelledby", "")) {
+		if e := n.Root().GetElementByID(id); e != nil {
+			texts = append(texts, e.VisibleText())
+		}
+	}
+	return normalizeSpace(strings.Join(texts, " "))
+}
+
+// elementRole 返回元素的ARIA角色：role属性的第一个值，没有时是隐含角色
+func elementRole(n *dom.Node) string {
+	if roles := strings.Fields(n.AttrOr("role", "")); len(roles) > 0 {
+		return strings.ToLower(roles[0])
+	}
+	switch n.Tag {
+	case "a", "area":
+		if n.HasAttr("href") {
+			return "link"
+		}
+	case "button":
+		return "button"
+	case "input":
+		switch inputType(n) {
+		case "button", "submit", "reset", "image":
+			return "button"
+		case "checkbox":
+			return "checkbox"
+		case "radio":
+			return "radio"
+		case "range":
+			return "slider"
+		case "number":
+			return "spinbutton"
+		case "search":
+			return "searchbox"
+		case "text", "email", "tel", "url":
+			return "textbox"
+		}
+	case "textarea":
+		return "textbox"
+	case "select":
+		if size, _ := strconv.Atoi(n.AttrOr("size", "")); n.HasAttr("multiple") || size > 1 {
+			return "listbox"
+		}
+		return "combobox"
+	case "option":
+		return "option"
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return "heading"
+	case "img":
+		if alt, ok := n.Attr("alt"); ok && alt == "" {
+			return "presentation"
+		}
+		return "img"
+	case "ul", "ol", "menu":
+		return "list"
+	case "li":
+		return "listitem"
+	case "table":
+		return "table"
+	case "tr":
+		return "row"
+	case "td":
+		return "cell"
+	case "th":
+		return "columnheader"
+	case "nav":
+		return "navigation"
+	case "main":
+		return "main"
+	case "aside":
+		return "complementary"
+	case "header":
+		return "banner"
+	case "footer":
+		return "contentinfo"
+	case "form":
+		return "form"
+	case "dialog":
+		return "dialog"
+	case "article":
+		return "article"
+	case "section":
+		return "region"
+	case "fieldset", "details":
+		return "group"
+	case "p":
+		return "paragraph"
+	case "hr":
+		return "separator"
+	case "progress":
+		return "progressbar"
+	}
+	return ""
+}
+
+// nameFromContent 可访问名称可以来自元素文字的角色
+var nameFromContent = map[string]bool{
+	"button": true, "link": true, "heading": true, "option": true, "cell": true, "columnheader": true,
+	"rowheader": true, "row": true, "checkbox": true, "radio": true, "switch": true, "tab": true,
+	"menuitem": true, "menuitemcheckbox": true, "menuitemradio": true, "treeitem": true, "tooltip": true,
+}
+
+// accessibleName 按可访问名称计算规则的主要步骤返回元素的名称：aria-labelledby、aria-label、
+// 控件的label或按钮的value、图片的alt、元素的文字、title和placeholder
+func accessibleName(n *dom.Node, labels map[*dom.Node][]*dom.Node) string {
+	if name := labelledByText(n); name != "" {
+		return name
+	}
+	if name := normalizeSpace(n.AttrOr("aria-label", "")); name != "" {
+		return name
+	}
+	switch {
+	case n.Tag == "input" && (inputType(n) == "button" || inputType(n) == "submit" || inputType(n) == "reset"):
+		if name := normalizeSpace(n.AttrOr("value", "")); name != "" {
+			return name
+		}
+	case n.Tag == "input" && inputType(n) == "image", n.Tag == "img", n.Tag == "area":
+		if name := normalizeSpace(n.AttrOr("alt", "")); name != "" {
+			return name
+		}
+	case labelable[n.Tag]:
+		var texts []string
+		for _, label := range labels[n] {
+			texts = append(texts, label.VisibleText())
+		}
+		if name := normalizeSpace(strings.Join(texts, " ")); name != "" {
+			return name
+		}
+	case n.Tag == "fieldset" || n.Tag == "table":
+		caption := "legend"
+		if n.Tag == "table" {
+			caption = "caption"
+		}
+		for _, c := range n.ElementChildren() {
+			if c.Tag == caption {
+				if name := normalizeSpace(c.VisibleText()); name != "" {
+					return name
+				}
+				break
+			}
+		}
+	}
+	if nameFromContent[elementRole(n)] {
+		if name := normalizeSpace(textContent(n)); name != "" {
+			return name
+		}
+	}
+	if name := normalizeSpace(n.AttrOr("title", "")); name != "" {
+		return name
+	}
+	return normalizeSpace(n.AttrOr("placeholder", ""))
+}
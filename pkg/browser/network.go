@@ -0,0 +1,59 @@
+package browser
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/pkg/browser/cdp"
+)
+
+// networkTracker 根据Network域的事件记录页面进行中的请求
+type networkTracker struct {
+	mu       sync.Mutex
+	inflight map[string]bool
+	// changed 最近一次有请求开始或结束的时间
+	changed time.Time
+}
+
+func newNetworkTracker() *networkTracker {
+	return &networkTracker{inflight: make(map[string]bool), changed: time.Now()}
+}
+
+// handle 处理页面会话上的事件，在CDP客户端读取消息的goroutine中调用
+func (t *networkTracker) handle(ev cdp.Event) {
+	var started bool
+	switch ev.Method {
+	case "Network.requestWillBeSent":
+		started = true
+	case "Network.loadingFinished", "Network.loadingFailed":
+	default:
+		return
+	}
+	var params struct {
+		RequestID string `json:"requestId"`
+	}
+	if err := json.Unmarshal(ev.Params, &params); err != nil || params.RequestID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// 重定向沿用同一个requestId，仍然算作一个请求
+	if started {
+		t.inflight[params.RequestID] = true
+	} else {
+		delete(t.inflight, params.RequestID)
+	}
+	t.changed = time.Now()
+}
+
+// idle 返回已经空闲的时长，有进行中的请求时返回0
+func (t *networkTracker) idle() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.inflight) > 0 {
+		return 0
+	}
+	return time.Since(t.changed)
+}
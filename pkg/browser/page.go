@@ -0,0 +1,988 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"GoBrowserAgent/pkg/browser/cdp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxQueryResults Query最多返回的元素数
+const maxQueryResults = 100
+
+// maxElementText Query返回的元素文字的最大长度
+const maxElementText = 500
+
+// cdpPage CDPBrowser中的一个页面
+type cdpPage struct {
+	browser   *CDPBrowser
+	targetID  string
+	sessionID string
+
+	// network 记录进行中的请求，stopNetwork取消事件监听
+	network     *networkTracker
+	stopNetwork func()
+
+	closeOnce sync.Once
+}
+
+// init 启用页面需要的事件并设置视口大小
+func (p *cdpPage) init(ctx context.Context) error {
+	p.network = newNetworkTracker()
+	p.stopNetwork = p.browser.client.Listen(func(ev cdp.Event) {
+		if ev.SessionID == p.sessionID {
+			p.network.handle(ev)
+		}
+	})
+	for _, method := range []string{"Page.enable", "Runtime.enable", "Network.enable"} {
+		if err := p.call(ctx, method, nil, nil); err != nil {
+			return fmt.Errorf("初始化页面失败: %v", err)
+		}
+	}
+	// 连接已有浏览器时窗口大小不受启动参数控制，统一设置视口
+	metrics := map[string]interface{}{
+		"width":             p.browser.config.DefaultWidth,
+		"height":            p.browser.config.DefaultHeight,
+		"deviceScaleFactor": 0,
+		"mobile":            false,
+	}
+	if err := p.call(ctx, "Emulation.setDeviceMetricsOverride", metrics, nil); err != nil {
+		return fmt.Errorf("设置页面大小失败: %v", err)
+	}
+	if ua := p.browser.config.UserAgent; ua != "" {
+		if err := p.call(ctx, "Network.setUserAgentOverride", map[string]string{"userAgent": ua}, nil); err != nil {
+			return fmt.Errorf("设置User-Agent失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// call 在页面会话上发送命令
+func (p *cdpPage) call(ctx context.Context, method string, params, result interface{}) error {
+	return p.browser.client.Call(ctx, p.sessionID, method, params, result)
+}
+
+// Navigate 打开地址并等待load事件
+func (p *cdpPage) Navigate(ctx context.Context, url string) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	wait, stop := p.browser.client.WaitEvent(p.sessionID, "Page.loadEventFired", nil)
+	defer stop()
+	var result struct {
+		FrameID   string `json:"frameId"`
+		LoaderID  string `json:"loaderId"`
+		ErrorText string `json:"errorText"`
+	}
+	if err := p.call(ctx, "Page.navigate", map[string]string{"url": url}, &result); err != nil {
+		return fmt.Errorf("打开%s失败: %v", url, err)
+	}
+	if result.ErrorText != "" {
+		return fmt.Errorf("打开%s失败: %s", url, result.ErrorText)
+	}
+	if result.LoaderID == "" {
+		// 同一文档内的跳转（例如只改变#片段）不会触发load事件
+		return nil
+	}
+	if _, err := wait(ctx); err != nil {
+		return fmt.Errorf("等待%s加载完成失败: %v", url, err)
+	}
+	return nil
+}
+
+// URL 返回当前地址
+func (p *cdpPage) URL(ctx context.Context) (string, error) {
+	var url string
+	err := p.evaluateInto(ctx, "location.href", &url)
+	return url, err
+}
+
+// Title 返回页面标题
+func (p *cdpPage) Title(ctx context.Context) (string, error) {
+	var title string
+	err := p.evaluateInto(ctx, "document.title", &title)
+	return title, err
+}
+
+// selectorEngine 页面中的选择器引擎，规则与HTTP后端（httpselector.go）相同；
+// query按selectorPart列表查找元素，rect返回元素相对于顶层视口的位置
+const selectorEngine = `(() => {
+	const blocks = new Set(['address', 'article', 'aside', 'blockquote', 'dd', 'div', 'dl', 'dt', 'fieldset',
+		'figcaption', 'figure', 'footer', 'form', 'h1', 'h2', 'h3', 'h4', 'h5', 'h6', 'header', 'hr', 'li', 'main',
+		'nav', 'ol', 'p', 'pre', 'section', 'table', 'tr', 'ul', 'option', 'legend', 'details', 'summary']);
+	const hiddenContent = new Set(['head', 'script', 'style', 'template', 'title', 'meta', 'link']);
+	const labelable = new Set(['button', 'input', 'meter', 'output', 'progress', 'select', 'textarea']);
+	const nameFromContent = new Set(['button', 'link', 'heading', 'option', 'cell', 'columnheader', 'rowheader',
+		'row', 'checkbox', 'radio', 'switch', 'tab', 'menuitem', 'menuitemcheckbox', 'menuitemradio', 'treeitem',
+		'tooltip']);
+	const normalize = s => (s || '').replace(/\s+/g, ' ').trim();
+	const matchText = (text, value, exact) => {
+		text = normalize(text);
+		return exact ? text === value : text.toLowerCase().includes(value.toLowerCase());
+	};
+	const inputType = el => (el.getAttribute('type') || '').trim().toLowerCase() || 'text';
+	const isButtonInput = el => el.localName === 'input' && ['button', 'submit', 'reset'].includes(inputType(el));
+
+	// roots 在scope中查找时的根：scope本身、开放的shadow root和同源iframe中的文档
+	const roots = scope => {
+		const out = [];
+		const add = root => {
+			out.push(root);
+			const visit = el => {
+				if (el.shadowRoot) add(el.shadowRoot);
+				if (el.localName === 'iframe' || el.localName === 'frame') {
+					let doc = null;
+					try { doc = el.contentDocument; } catch (e) {}
+					if (doc && doc.documentElement) add(doc);
+				}
+			};
+			if (root.nodeType === 1) visit(root);
+			for (const el of root.querySelectorAll('*')) visit(el);
+		};
+		add(scope);
+		return out;
+	};
+	const elements = scope => roots(scope).flatMap(root => [...root.querySelectorAll('*')]).filter(el => el.localName !== 'template');
+	const parentOf = el => el.parentElement || (el.parentNode && el.parentNode.nodeType === 11 ? el.parentNode.host : null);
+
+	// textOf 显示的文字：跳过不显示的元素，块级元素前后加空白，shadow DOM的内容在前
+	const texts = new Map();
+	const textOf = el => {
+		if (texts.has(el)) return texts.get(el);
+		let text = '';
+		if (el.localName === 'input') {
+			text = isButtonInput(el) ? el.value : '';
+		} else if (!hiddenContent.has(el.localName) && !el.hidden) {
+			if (el.localName === 'br') text = '\n';
+			const nodes = [...(el.shadowRoot ? el.shadowRoot.childNodes : []), ...el.childNodes];
+			for (const node of nodes) {
+				if (node.nodeType === 3) text += node.data;
+				else if (node.nodeType === 1) text += textOf(node);
+			}
+			if (blocks.has(el.localName)) text = '\n' + text + '\n';
+			else if (el.localName === 'td' || el.localName === 'th') text += ' ';
+		}
+		texts.set(el, text);
+		return text;
+	};
+	const byId = (el, id) => {
+		const root = el.getRootNode();
+		return root.getElementById ? root.getElementById(id) : null;
+	};
+	const labelledBy = el => normalize((el.getAttribute('aria-labelledby') || '').split(/\s+/).filter(Boolean)
+		.map(id => byId(el, id)).filter(Boolean).map(textOf).join(' '));
+	const labelsOf = el => labelable.has(el.localName) && el.labels ? [...el.labels] : [];
+
+	const roleOf = el => {
+		const explicit = (el.getAttribute('role') || '').trim().split(/\s+/)[0].toLowerCase();
+		if (explicit) return explicit;
+		switch (el.localName) {
+		case 'a': case 'area': return el.hasAttribute('href') ? 'link' : '';
+		case 'button': return 'button';
+		case 'input':
+			switch (inputType(el)) {
+			case 'button': case 'submit': case 'reset': case 'image': return 'button';
+			case 'checkbox': return 'checkbox';
+			case 'radio': return 'radio';
+			case 'range': return 'slider';
+			case 'number': return 'spinbutton';
+			case 'search': return 'searchbox';
+			case 'text': case 'email': case 'tel': case 'url': return 'textbox';
+			}
+			return '';
+		case 'textarea': return 'textbox';
+		case 'select': return el.multiple || el.size > 1 ? 'listbox' : 'combobox';
+		case 'option': return 'option';
+		case 'h1': case 'h2': case 'h3': case 'h4': case 'h5': case 'h6': return 'heading';
+		case 'img': return el.getAttribute('alt') === '' ? 'presentation' : 'img';
+		case 'ul': case 'ol': case 'menu': return 'list';
+		case 'li': return 'listitem';
+		case 'table': return 'table';
+		case 'tr': return 'row';
+		case 'td': return 'cell';
+		case 'th': return 'columnheader';
+		case 'nav': return 'navigation';
+		case 'main': return 'main';
+		case 'aside': return 'complementary';
+		case 'header': return 'banner';
+		case 'footer': return 'contentinfo';
+		case 'form': return 'form';
+		case 'dialog': return 'dialog';
+		case 'article': return 'article';
+		case 'section': return 'region';
+		case 'fieldset': case 'details': return 'group';
+		case 'p': return 'paragraph';
+		case 'hr': return 'separator';
+		case 'progress': return 'progressbar';
+		}
+		return '';
+	};
+	const nameOf = el => {
+		let name = labelledBy(el) || normalize(el.getAttribute('aria-label'));
+		if (name) return name;
+		const tag = el.localName;
+		if (isButtonInput(el)) {
+			name = normalize(el.value);
+		} else if (tag === 'input' && inputType(el) === 'image' || tag === 'img' || tag === 'area') {
+			name = normalize(el.getAttribute('alt'));
+		} else if (labelable.has(tag)) {
+			name = normalize(labelsOf(el).map(textOf).join(' '));
+		} else if (tag === 'fieldset' || tag === 'table') {
+			const caption = [...el.children].find(c => c.localName === (tag === 'table' ? 'caption' : 'legend'));
+			name = caption ? normalize(textOf(caption)) : '';
+		}
+		if (!name && nameFromContent.has(roleOf(el))) name = normalize(textOf(el));
+		return name || normalize(el.getAttribute('title')) || normalize(el.getAttribute('placeholder'));
+	};
+
+	const engines = {
+		css: (scope, part) => roots(scope).flatMap(root => [...root.querySelectorAll(part.value)]),
+		xpath: (scope, part) => {
+			const out = [];
+			for (const root of roots(scope)) {
+				// XPath不进入shadow DOM
+				if (root.nodeType === 11) continue;
+				let expr = part.value;
+				if (root.nodeType !== 9) {
+					if (expr.startsWith('/')) expr = '.' + expr;
+					else if (expr.startsWith('(/')) expr = '(.' + expr.slice(1);
+				}
+				const doc = root.nodeType === 9 ? root : root.ownerDocument;
+				const result = doc.evaluate(expr, root, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+				for (let i = 0; i < result.snapshotLength; i++) {
+					const node = result.snapshotItem(i);
+					const el = node.nodeType === 1 ? node : node.nodeType === 2 ? node.ownerElement : node.parentElement;
+					if (el) out.push(el);
+				}
+			}
+			return out;
+		},
+		text: (scope, part) => {
+			const matched = elements(scope).filter(el => matchText(textOf(el), part.value, part.exact));
+			const hasMatch = new Set();
+			for (const el of matched) {
+				for (let p = parentOf(el); p; p = parentOf(p)) hasMatch.add(p);
+			}
+			return matched.filter(el => !hasMatch.has(el));
+		},
+		role: (scope, part) => elements(scope).filter(el => roleOf(el) === part.value &&
+			(!part.hasName || matchText(nameOf(el), part.name, part.nameExact))),
+		label: (scope, part) => elements(scope).filter(el => {
+			const candidates = labelsOf(el).map(textOf);
+			const labelled = labelledBy(el);
+			if (labelled) candidates.push(labelled);
+			if (el.hasAttribute('aria-label')) candidates.push(el.getAttribute('aria-label'));
+			return candidates.some(text => matchText(text, part.value, part.exact));
+		}),
+		placeholder: (scope, part) => elements(scope).filter(el =>
+			el.hasAttribute('placeholder') && matchText(el.getAttribute('placeholder'), part.value, part.exact)),
+	};
+
+	const query = parts => {
+		let found = [document];
+		for (const part of parts) {
+			if (part.engine === 'nth') {
+				const i = part.nth < 0 ? found.length + part.nth : part.nth;
+				found = i >= 0 && i < found.length ? [found[i]] : [];
+				continue;
+			}
+			const next = new Set();
+			for (const scope of found) {
+				for (const el of engines[part.engine](scope, part)) next.add(el);
+			}
+			found = [...next];
+		}
+		return found;
+	};
+
+	// rect 元素相对于顶层视口的位置，iframe中的元素加上iframe的偏移
+	const rect = el => {
+		const r = el.getBoundingClientRect();
+		let x = r.x, y = r.y;
+		for (let win = el.ownerDocument.defaultView; win && win.frameElement; win = win.parent) {
+			const frame = win.frameElement;
+			const fr = frame.getBoundingClientRect();
+			x += fr.x + frame.clientLeft;
+			y += fr.y + frame.clientTop;
+		}
+		return {x, y, width: r.width, height: r.height};
+	};
+
+	return {query, rect};
+})()`
+
+// withSelector 给函数加上选择器引擎，函数中用selector.query(parts)查找元素
+func withSelector(function string) string {
+	return "((selector) => " + function + ")(" + selectorEngine + ")"
+}
+
+// queryScript 返回匹配元素的信息
+const queryScript = `(parts, limit, maxText) => {
+	const out = [];
+	for (const el of selector.query(parts)) {
+		if (out.length >= limit) break;
+		const rect = selector.rect(el);
+		const style = el.ownerDocument.defaultView.getComputedStyle(el);
+		const attributes = {};
+		for (const attr of el.attributes) attributes[attr.name] = attr.value;
+		out.push({
+			tag: el.tagName.toLowerCase(),
+			text: (el.innerText || el.value || '').trim().slice(0, maxText),
+			attributes,
+			visible: rect.width > 0 && rect.height > 0 && style.visibility !== 'hidden' && style.display !== 'none',
+			x: rect.x, y: rect.y, width: rect.width, height: rect.height,
+		});
+	}
+	return out;
+}`
+
+// Query 返回选择器匹配的元素
+func (p *cdpPage) Query(ctx context.Context, selector string) ([]Element, error) {
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	var elements []Element
+	if err := p.callFunction(ctx, withSelector(queryScript), &elements, parts, maxQueryResults, maxElementText); err != nil {
+		return nil, err
+	}
+	if elements == nil {
+		elements = []Element{}
+	}
+	return elements, nil
+}
+
+// actionScript 检查第一个匹配的元素能否操作：滚动到视口中间后检查是否可见、是否禁用，
+// 并比较前后两帧的位置；可以操作时返回中心点，否则返回原因
+const actionScript = `async (parts, focus) => {
+	const el = selector.query(parts)[0];
+	if (!el) return {state: 'missing'};
+	el.scrollIntoView({block: 'center', inline: 'center'});
+	const style = el.ownerDocument.defaultView.getComputedStyle(el);
+	const before = selector.rect(el);
+	if (before.width === 0 || before.height === 0 || style.visibility === 'hidden') return {state: 'hidden'};
+	if (el.matches(':disabled') || el.getAttribute('aria-disabled') === 'true') return {state: 'disabled'};
+	// 后台标签页不执行requestAnimationFrame，最多等100毫秒
+	await new Promise(resolve => {
+		requestAnimationFrame(() => requestAnimationFrame(resolve));
+		setTimeout(resolve, 100);
+	});
+	if (!el.isConnected) return {state: 'missing'};
+	const after = selector.rect(el);
+	if (after.x !== before.x || after.y !== before.y || after.width !== before.width || after.height !== before.height) {
+		return {state: 'moving'};
+	}
+	if (focus) el.focus();
+	return {state: 'ready', x: after.x + after.width / 2, y: after.y + after.height / 2};
+}`
+
+// actionable 等待第一个匹配的元素可以操作，返回点击位置
+func (p *cdpPage) actionable(ctx context.Context, selector string, focus bool) (float64, float64, error) {
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return 0, 0, err
+	}
+	var point struct {
+		State string  `json:"state"`
+		X     float64 `json:"x"`
+		Y     float64 `json:"y"`
+	}
+	err = poll(ctx, func(ctx context.Context) (bool, error) {
+		if err := p.callFunction(ctx, withSelector(actionScript), &point, parts, focus); err != nil {
+			return false, err
+		}
+		switch point.State {
+		case "missing":
+			return false, ErrElementNotFound
+		case "hidden":
+			return false, ErrElementNotVisible
+		case "disabled":
+			return false, ErrElementDisabled
+		case "moving":
+			return false, ErrElementNotStable
+		}
+		return true, nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", selector, err)
+	}
+	return point.X, point.Y, nil
+}
+
+// Click 用鼠标点击第一个匹配元素的中心
+func (p *cdpPage) Click(ctx context.Context, selector string) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	x, y, err := p.actionable(ctx, selector, false)
+	if err != nil {
+		return err
+	}
+	for _, event := range []map[string]interface{}{
+		{"type": "mouseMoved", "x": x, "y": y},
+		{"type": "mousePressed", "x": x, "y": y, "button": "left", "clickCount": 1},
+		{"type": "mouseReleased", "x": x, "y": y, "button": "left", "clickCount": 1},
+	} {
+		if err := p.call(ctx, "Input.dispatchMouseEvent", event, nil); err != nil {
+			return fmt.Errorf("点击%s失败: %v", selector, err)
+		}
+	}
+	return nil
+}
+
+// Type 聚焦第一个匹配的元素并输入文字
+//
+// 使用Input.insertText模拟输入法提交文字，页面会收到正常的input事件，中文等字符也能正确输入。
+func (p *cdpPage) Type(ctx context.Context, selector, text string) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	if _, _, err := p.actionable(ctx, selector, true); err != nil {
+		return err
+	}
+	if err := p.call(ctx, "Input.insertText", map[string]string{"text": text}, nil); err != nil {
+		return fmt.Errorf("向%s输入失败: %v", selector, err)
+	}
+	return nil
+}
+
+// fillScript 清空输入框并聚焦，返回需要输入文字的位置；下拉框、复选框和单选框直接在脚本中设置，
+// 返回{done: true}
+const fillScript = `(parts, value, checked) => {
+	const el = selector.query(parts)[0];
+	if (!el) return null;
+	el.scrollIntoView({block: 'center', inline: 'center'});
+	const rect = el.getBoundingClientRect();
+	if (rect.width === 0 || rect.height === 0) return {hidden: true};
+	const fire = () => {
+		el.dispatchEvent(new Event('input', {bubbles: true}));
+		el.dispatchEvent(new Event('change', {bubbles: true}));
+	};
+	if (el.tagName === 'SELECT') {
+		const option = [...el.options].find(o => o.value === value || o.text.trim() === value);
+		if (!option) return {error: 'option'};
+		el.value = option.value;
+		fire();
+		return {done: true};
+	}
+	if (el.type === 'checkbox' || el.type === 'radio') {
+		if (el.checked !== checked) el.click();
+		return {done: true};
+	}
+	el.focus();
+	if ('value' in el) {
+		el.value = '';
+		fire();
+	} else if (el.isContentEditable) {
+		el.textContent = '';
+	} else {
+		return {error: 'editable'};
+	}
+	return {};
+}`
+
+// Fill 清空输入框后输入文字，下拉框、复选框和单选框直接设置值
+func (p *cdpPage) Fill(ctx context.Context, selector, value string) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	if _, _, err := p.actionable(ctx, selector, false); err != nil {
+		return err
+	}
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return err
+	}
+	var result *struct {
+		Hidden bool   `json:"hidden"`
+		Done   bool   `json:"done"`
+		Error  string `json:"error"`
+	}
+	if err := p.callFunction(ctx, withSelector(fillScript), &result, parts, value, checkedValue(value)); err != nil {
+		return err
+	}
+	switch {
+	case result == nil:
+		return fmt.Errorf("%s: %w", selector, ErrElementNotFound)
+	case result.Hidden:
+		return fmt.Errorf("%s: %w", selector, ErrElementNotVisible)
+	case result.Error == "option":
+		return fmt.Errorf("%s中没有值为%q的选项", selector, value)
+	case result.Error != "":
+		return fmt.Errorf("%s不是可输入的元素", selector)
+	case result.Done || value == "":
+		return nil
+	}
+	if err := p.call(ctx, "Input.insertText", map[string]string{"text": value}, nil); err != nil {
+		return fmt.Errorf("向%s输入失败: %v", selector, err)
+	}
+	return nil
+}
+
+// submitScript 用requestSubmit提交表单，会触发校验和submit事件；
+// 返回submit事件是否触发、是否被脚本取消（由脚本自行发送请求），以及表单是否在iframe中；
+// 元素已禁用时不提交，返回{disabled: true}
+const submitScript = `(parts) => {
+	const el = selector.query(parts)[0];
+	if (!el) return null;
+	const form = el.tagName === 'FORM' ? el : (el.form || el.closest('form'));
+	if (!form) return {noForm: true};
+	if (el !== form && (el.matches(':disabled') || el.getAttribute('aria-disabled') === 'true')) return {disabled: true};
+	const submitter = el !== form && el.form === form && (el.type === 'submit' || el.type === 'image') ? el : undefined;
+	const win = form.ownerDocument.defaultView;
+	let fired = false, prevented = false;
+	const check = e => { fired = true; prevented = e.defaultPrevented; };
+	win.addEventListener('submit', check);
+	try {
+		form.requestSubmit(submitter);
+	} finally {
+		win.removeEventListener('submit', check);
+	}
+	return {fired, prevented, frame: form.ownerDocument !== document};
+}`
+
+// Submit 等待元素出现且没有禁用后提交表单，并等待新页面加载；表单由脚本处理（取消了submit事件）时不等待
+func (p *cdpPage) Submit(ctx context.Context, selector string) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return err
+	}
+	wait, stop := p.browser.client.WaitEvent(p.sessionID, "Page.loadEventFired", nil)
+	defer stop()
+	// iframe中的表单只重新加载iframe，主页面没有load事件
+	waitFrame, stopFrame := p.browser.client.WaitEvent(p.sessionID, "Page.frameStoppedLoading", nil)
+	defer stopFrame()
+	var result *struct {
+		Disabled  bool `json:"disabled"`
+		NoForm    bool `json:"noForm"`
+		Fired     bool `json:"fired"`
+		Prevented bool `json:"prevented"`
+		Frame     bool `json:"frame"`
+	}
+	err = poll(ctx, func(ctx context.Context) (bool, error) {
+		if err := p.callFunction(ctx, withSelector(submitScript), &result, parts); err != nil {
+			return false, err
+		}
+		switch {
+		case result == nil:
+			return false, ErrElementNotFound
+		case result.Disabled:
+			return false, ErrElementDisabled
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", selector, err)
+	}
+	switch {
+	case result.NoForm:
+		return fmt.Errorf("%s: %w", selector, ErrNotInForm)
+	case !result.Fired:
+		return fmt.Errorf("提交%s所在的表单失败: 表单校验未通过", selector)
+	case result.Prevented:
+		return nil
+	case result.Frame:
+		wait = waitFrame
+	}
+	if _, err := wait(ctx); err != nil {
+		return fmt.Errorf("等待表单提交后的页面加载失败: %v", err)
+	}
+	return nil
+}
+
+// WaitForSelector 等待元素达到指定状态
+func (p *cdpPage) WaitForSelector(ctx context.Context, selector string, state WaitState) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := parseSelector(selector); err != nil {
+		return err
+	}
+	if state == "" {
+		state = WaitVisible
+	}
+	return waitError(selector+stateText(state), poll(ctx, selectorCheck(p, selector, state)))
+}
+
+// WaitForURL 等待地址匹配正则表达式
+func (p *cdpPage) WaitForURL(ctx context.Context, pattern *regexp.Regexp) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+	return waitError("地址匹配"+pattern.String(), poll(ctx, urlCheck(p, pattern)))
+}
+
+// WaitForFunction 反复执行表达式，直到结果为真值
+func (p *cdpPage) WaitForFunction(ctx context.Context, expression string) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+	return waitError(expression, poll(ctx, func(ctx context.Context) (bool, error) {
+		result, err := p.evaluate(ctx, expression)
+		if err != nil {
+			return false, err
+		}
+		if _, err := result.value(); err != nil {
+			return false, err
+		}
+		return result.truthy(), nil
+	}))
+}
+
+// WaitForNetworkIdle 等待页面没有进行中的请求并持续NetworkIdleTime
+//
+// 长轮询、EventSource等一直不结束的请求会使页面始终不空闲，这时改用wait for <选择器>。
+func (p *cdpPage) WaitForNetworkIdle(ctx context.Context) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+	return waitError("网络空闲", poll(ctx, func(ctx context.Context) (bool, error) {
+		return p.network.idle() >= NetworkIdleTime, nil
+	}))
+}
+
+// remoteObject Runtime.evaluate的返回值
+type remoteObject struct {
+	Result struct {
+		Type                string          `json:"type"`
+		Value               json.RawMessage `json:"value"`
+		UnserializableValue string          `json:"unserializableValue"`
+		Description         string          `json:"description"`
+	} `json:"result"`
+	ExceptionDetails *struct {
+		Text      string `json:"text"`
+		Exception *struct {
+			Description string `json:"description"`
+		} `json:"exception"`
+	} `json:"exceptionDetails"`
+}
+
+// value 返回JSON编码的结果，脚本抛出异常时返回错误
+func (r *remoteObject) value() (json.RawMessage, error) {
+	if r.ExceptionDetails != nil {
+		message := r.ExceptionDetails.Text
+		if r.ExceptionDetails.Exception != nil && r.ExceptionDetails.Exception.Description != "" {
+			message = r.ExceptionDetails.Exception.Description
+		}
+		return nil, &scriptError{message: message}
+	}
+	switch {
+	case r.Result.UnserializableValue != "":
+		// NaN、Infinity、-0和BigInt无法用JSON表示，按字符串返回
+		return json.Marshal(r.Result.UnserializableValue)
+	case r.Result.Type == "undefined" || len(r.Result.Value) == 0:
+		return json.RawMessage("null"), nil
+	}
+	return r.Result.Value, nil
+}
+
+// truthy 按JavaScript的规则判断结果是否为真值
+func (r *remoteObject) truthy() bool {
+	switch r.Result.UnserializableValue {
+	case "":
+	case "NaN", "-0", "0n", "-0n":
+		return false
+	default:
+		return true
+	}
+	var value interface{}
+	if len(r.Result.Value) == 0 || json.Unmarshal(r.Result.Value, &value) != nil {
+		// undefined，或者无法按值返回的对象
+		return r.Result.Type == "object" || r.Result.Type == "function" || r.Result.Type == "symbol"
+	}
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+// Evaluate 执行JavaScript表达式
+func (p *cdpPage) Evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	result, err := p.evaluate(ctx, expression)
+	if err != nil {
+		return nil, err
+	}
+	return result.value()
+}
+
+// evaluate 执行表达式，返回原始的结果
+func (p *cdpPage) evaluate(ctx context.Context, expression string) (*remoteObject, error) {
+	var result remoteObject
+	params := map[string]interface{}{
+		"expression":    expression,
+		"returnByValue": true,
+		"awaitPromise":  true,
+		"userGesture":   true,
+	}
+	if err := p.call(ctx, "Runtime.evaluate", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// evaluateInto 执行表达式并把结果解码到out
+func (p *cdpPage) evaluateInto(ctx context.Context, expression string, out interface{}) error {
+	value, err := p.Evaluate(ctx, expression)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(value, out); err != nil {
+		return fmt.Errorf("解析脚本结果失败: %v", err)
+	}
+	return nil
+}
+
+// callFunction 以JSON参数调用页面中的函数并解码结果，参数不会拼接进脚本
+func (p *cdpPage) callFunction(ctx context.Context, function string, out interface{}, args ...interface{}) error {
+	encoded := make([]string, len(args))
+	for i, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return fmt.Errorf("编码脚本参数失败: %v", err)
+		}
+		encoded[i] = string(data)
+	}
+	return p.evaluateInto(ctx, "("+function+")("+strings.Join(encoded, ", ")+")", out)
+}
+
+// Screenshot 截取当前视口
+func (p *cdpPage) Screenshot(ctx context.Context) ([]byte, error) {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	var result struct {
+		Data string `json:"data"`
+	}
+	if err := p.call(ctx, "Page.captureScreenshot", map[string]string{"format": "png"}, &result); err != nil {
+		return nil, fmt.Errorf("截图失败: %v", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Data)
+	if err != nil {
+		return nil, fmt.Errorf("解码截图失败: %v", err)
+	}
+	return data, nil
+}
+
+// Cookies 返回当前页面可见的Cookie
+func (p *cdpPage) Cookies(ctx context.Context) ([]Cookie, error) {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	var result struct {
+		Cookies []Cookie `json:"cookies"`
+	}
+	if err := p.call(ctx, "Network.getCookies", nil, &result); err != nil {
+		return nil, fmt.Errorf("读取Cookie失败: %v", err)
+	}
+	if result.Cookies == nil {
+		result.Cookies = []Cookie{}
+	}
+	return result.Cookies, nil
+}
+
+// SetCookies 设置Cookie
+func (p *cdpPage) SetCookies(ctx context.Context, cookies []Cookie) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	for _, c := range cookies {
+		if c.URL == "" && c.Domain == "" {
+			return fmt.Errorf("Cookie %s需要设置url或domain", c.Name)
+		}
+	}
+	if err := p.call(ctx, "Network.setCookies", map[string]interface{}{"cookies": cookies}, nil); err != nil {
+		return fmt.Errorf("设置Cookie失败: %v", err)
+	}
+	return nil
+}
+
+// storageScript 返回当前页面和可以访问的iframe所在来源的存储，跨域的iframe无法读取，跳过
+const storageScript = `() => {
+	const origins = new Map();
+	const items = storage => {
+		const out = [];
+		for (let i = 0; i < storage.length; i++) {
+			const name = storage.key(i);
+			out.push({name, value: storage.getItem(name)});
+		}
+		return out;
+	};
+	const visit = win => {
+		try {
+			const origin = win.location.origin;
+			if (origin && origin !== 'null' && !origins.has(origin)) {
+				origins.set(origin, {origin, localStorage: items(win.localStorage), sessionStorage: items(win.sessionStorage)});
+			}
+		} catch (e) {
+			return;
+		}
+		for (let i = 0; i < win.frames.length; i++) visit(win.frames[i]);
+	};
+	visit(window);
+	return [...origins.values()].filter(o => o.localStorage.length > 0 || o.sessionStorage.length > 0);
+}`
+
+// StorageState 返回浏览器中的所有Cookie和当前页面各来源的存储
+func (p *cdpPage) StorageState(ctx context.Context) (*StorageState, error) {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	var result struct {
+		Cookies []Cookie `json:"cookies"`
+	}
+	if err := p.call(ctx, "Network.getAllCookies", nil, &result); err != nil {
+		return nil, fmt.Errorf("读取Cookie失败: %v", err)
+	}
+	state := &StorageState{Cookies: result.Cookies}
+	if err := p.callFunction(ctx, storageScript, &state.Origins); err != nil {
+		return nil, fmt.Errorf("读取页面存储失败: %v", err)
+	}
+	if state.Cookies == nil {
+		state.Cookies = []Cookie{}
+	}
+	if state.Origins == nil {
+		state.Origins = []OriginStorage{}
+	}
+	return state, nil
+}
+
+// SetStorageState 设置Cookie，并在每个来源下写入localStorage和sessionStorage
+func (p *cdpPage) SetStorageState(ctx context.Context, state *StorageState) error {
+	ctx, cancel := p.browser.withTimeout(ctx)
+	defer cancel()
+
+	if cookies := liveCookies(state.Cookies, time.Now()); len(cookies) > 0 {
+		if err := p.SetCookies(ctx, cookies); err != nil {
+			return err
+		}
+	}
+	if len(state.Origins) == 0 {
+		return nil
+	}
+	for _, origin := range state.Origins {
+		if err := p.restoreOrigin(ctx, origin); err != nil {
+			return fmt.Errorf("恢复%s的存储失败: %v", origin.Origin, err)
+		}
+	}
+	return p.Navigate(ctx, "about:blank")
+}
+
+// blankDocument 恢复存储时代替站点页面返回的空白文档
+const blankDocument = "<!DOCTYPE html><title></title>"
+
+// restoreScript 在来源的空白页中写入存储
+const restoreScript = `(origin) => {
+	if (location.origin !== origin.origin) return false;
+	for (const item of origin.localStorage || []) localStorage.setItem(item.name, item.value);
+	for (const item of origin.sessionStorage || []) sessionStorage.setItem(item.name, item.value);
+	return true;
+}`
+
+// restoreOrigin 打开来源下的空白页并写入存储
+//
+// 请求由Fetch域拦截并直接返回空白文档，不会真的访问站点，也不会执行站点的脚本。
+// sessionStorage属于标签页，之后在同一个页面中打开该来源的地址时仍然可以读到。
+func (p *cdpPage) restoreOrigin(ctx context.Context, origin OriginStorage) error {
+	u, err := url.Parse(origin.Origin)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("无效的来源%q", origin.Origin)
+	}
+
+	paused := make(chan string, 16)
+	stopListen := p.browser.client.Listen(func(ev cdp.Event) {
+		if ev.SessionID != p.sessionID || ev.Method != "Fetch.requestPaused" {
+			return
+		}
+		var params struct {
+			RequestID string `json:"requestId"`
+		}
+		if json.Unmarshal(ev.Params, &params) == nil {
+			select {
+			case paused <- params.RequestID:
+			default:
+			}
+		}
+	})
+	defer stopListen()
+
+	patterns := []map[string]string{{"urlPattern": origin.Origin + "/*"}}
+	if err := p.call(ctx, "Fetch.enable", map[string]interface{}{"patterns": patterns}, nil); err != nil {
+		return fmt.Errorf("拦截请求失败: %v", err)
+	}
+	defer func() {
+		// ctx可能已经超时，用新的context关闭拦截，否则之后的请求会一直挂起
+		disableCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		if err := p.call(disableCtx, "Fetch.disable", nil, nil); err != nil {
+			logrus.Warnf("关闭请求拦截失败: %v", err)
+		}
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		body := base64.StdEncoding.EncodeToString([]byte(blankDocument))
+		for {
+			select {
+			case id := <-paused:
+				params := map[string]interface{}{
+					"requestId":       id,
+					"responseCode":    200,
+					"responseHeaders": []map[string]string{{"name": "Content-Type", "value": "text/html; charset=utf-8"}},
+					"body":            body,
+				}
+				if err := p.call(ctx, "Fetch.fulfillRequest", params, nil); err != nil {
+					logrus.Debugf("返回空白页失败: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := p.Navigate(ctx, origin.Origin+"/"); err != nil {
+		return err
+	}
+	var ok bool
+	if err := p.callFunction(ctx, restoreScript, &ok, origin); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("页面的来源与%s不一致", origin.Origin)
+	}
+	return nil
+}
+
+// Close 关闭页面
+func (p *cdpPage) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		if p.stopNetwork != nil {
+			p.stopNetwork()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		err = p.browser.client.Call(ctx, "", "Target.closeTarget", map[string]string{"targetId": p.targetID}, nil)
+		p.browser.forget(p.targetID)
+	})
+	return err
+}
@@ -0,0 +1,215 @@
+package browser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"GoBrowserAgent/pkg/browser/dom"
+)
+
+// 选择器语法，两种后端相同
+//
+// 选择器由一段或多段组成，段之间用>>连接，后一段在前一段匹配的元素内部查找：
+//
+//	#kw、css:form .submit       CSS选择器，不带前缀时按CSS处理
+//	xpath://button[@type]      XPath，以//开头时可以省略前缀；在前一段的元素内查找时/开头的路径相对于该元素
+//	text:登录                  文字包含“登录”的最内层元素，忽略大小写和多余的空白
+//	text:"登录"                引号表示文字必须完全相同
+//	role:button[name=登录]     ARIA角色（显式的role属性或元素的隐含角色），name按可访问名称匹配，规则同text
+//	label:用户名               标签文字匹配的表单控件，包括<label>、aria-label和aria-labelledby
+//	placeholder:请输入关键词   placeholder匹配的输入框
+//	nth=0                      取前一段结果中的第N个，从0开始，负数从末尾数
+//
+// 查找会进入开放的shadow DOM和同源的iframe；CSS的组合符和XPath不跨越shadow DOM的边界，
+// 这时用>>连接，例如my-login >> button。
+
+// 选择器引擎
+const (
+	engineCSS         = "css"
+	engineXPath       = "xpath"
+	engineText        = "text"
+	engineRole        = "role"
+	engineLabel       = "label"
+	enginePlaceholder = "placeholder"
+	engineNth         = "nth"
+)
+
+// selectorPart 选择器中的一段，作为参数传给页面中的选择器引擎，字段名与脚本一致
+type selectorPart struct {
+	Engine string `json:"engine"`
+	// Value CSS选择器、XPath、要匹配的文字或角色名
+	Value string `json:"value"`
+	// Exact 文字用引号括起，要求完全相同
+	Exact bool `json:"exact"`
+	// Name role的[name=...]，HasName表示是否指定
+	Name      string `json:"name"`
+	HasName   bool   `json:"hasName"`
+	NameExact bool   `json:"nameExact"`
+	// Nth nth=N的序号
+	Nth int `json:"nth"`
+}
+
+// parseSelector 把选择器切分为各段并检查语法
+func parseSelector(selector string) ([]selectorPart, error) {
+	var parts []selectorPart
+	for _, text := range splitSelector(selector) {
+		part, err := parseSelectorPart(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("选择器%q有误: %v", selector, err)
+		}
+		parts = append(parts, part)
+	}
+	if parts[0].Engine == engineNth {
+		return nil, fmt.Errorf("选择器%q有误: nth=前面缺少选择器", selector)
+	}
+	return parts, nil
+}
+
+// splitSelector 在引号、方括号和圆括号之外的>>处切分
+func splitSelector(selector string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == '>' && depth <= 0 && strings.HasPrefix(selector[i:], ">>"):
+			parts = append(parts, selector[start:i])
+			i++
+			start = i + 1
+		}
+	}
+	return append(parts, selector[start:])
+}
+
+func parseSelectorPart(text string) (selectorPart, error) {
+	if text == "" {
+		return selectorPart{}, fmt.Errorf(">>两边缺少选择器")
+	}
+	if rest, ok := strings.CutPrefix(text, "nth="); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(rest))
+		if err != nil {
+			return selectorPart{}, fmt.Errorf("nth=后面应为整数")
+		}
+		return selectorPart{Engine: engineNth, Nth: n}, nil
+	}
+	if strings.HasPrefix(text, "//") || strings.HasPrefix(text, "(//") {
+		return xpathPart(text)
+	}
+
+	engine, value, ok := strings.Cut(text, ":")
+	if !ok {
+		return cssPart(text)
+	}
+	value = strings.TrimSpace(value)
+	switch engine {
+	case engineCSS:
+		return cssPart(value)
+	case engineXPath:
+		return xpathPart(value)
+	case engineLabel:
+		// label:first-child等本身是合法的CSS选择器
+		if _, err := dom.Compile(text); err == nil {
+			return cssPart(text)
+		}
+		fallthrough
+	case engineText, enginePlaceholder:
+		value, exact := unquote(value)
+		if value == "" {
+			return selectorPart{}, fmt.Errorf("%s:后面缺少文字", engine)
+		}
+		return selectorPart{Engine: engine, Value: value, Exact: exact}, nil
+	case engineRole:
+		return rolePart(value)
+	}
+	return cssPart(text)
+}
+
+func cssPart(value string) (selectorPart, error) {
+	if value == "" {
+		return selectorPart{}, fmt.Errorf("css:后面缺少选择器")
+	}
+	return selectorPart{Engine: engineCSS, Value: value}, nil
+}
+
+func xpathPart(value string) (selectorPart, error) {
+	if _, err := dom.CompileXPath(value); err != nil {
+		return selectorPart{}, err
+	}
+	return selectorPart{Engine: engineXPath, Value: value}, nil
+}
+
+// rolePart 解析role:button[name=登录]
+func rolePart(value string) (selectorPart, error) {
+	role, attrs, hasAttrs := strings.Cut(value, "[")
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		return selectorPart{}, fmt.Errorf("role:后面缺少角色名")
+	}
+	for _, c := range role {
+		if c < 'a' || c > 'z' {
+			return selectorPart{}, fmt.Errorf("无效的角色名%q", role)
+		}
+	}
+	part := selectorPart{Engine: engineRole, Value: role}
+	if !hasAttrs {
+		return part, nil
+	}
+	attrs = strings.TrimSpace(attrs)
+	if !strings.HasSuffix(attrs, "]") {
+		return selectorPart{}, fmt.Errorf("role选择器缺少]")
+	}
+	key, name, ok := strings.Cut(strings.TrimSuffix(attrs, "]"), "=")
+	if !ok || strings.TrimSpace(key) != "name" {
+		return selectorPart{}, fmt.Errorf("role选择器只支持[name=...]")
+	}
+	part.Name, part.NameExact = unquote(strings.TrimSpace(name))
+	part.HasName = true
+	return part, nil
+}
+
+// unquote 去掉成对的引号并合并多余的空白，返回值表示是否带引号
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return normalizeSpace(s[1 : len(s)-1]), true
+	}
+	return normalizeSpace(s), false
+}
+
+// normalizeSpace 合并连续的空白并去掉首尾空白
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// scopedXPath 在元素内查找时把/开头的路径改为相对于该元素，例如//a改为.//a
+func scopedXPath(expr string) string {
+	switch {
+	case strings.HasPrefix(expr, "/"):
+		return "." + expr
+	case strings.HasPrefix(expr, "(/"):
+		return "(." + expr[1:]
+	}
+	return expr
+}
+
+// matchText 按选择器的规则比较文字：exact时合并空白后完全相同，否则忽略大小写包含即可
+func matchText(text, value string, exact bool) bool {
+	text = normalizeSpace(text)
+	if exact {
+		return text == value
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(value))
+}
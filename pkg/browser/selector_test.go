@@ -0,0 +1,211 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const selectorPage = `<!DOCTYPE html>
+<html><head><title>Selectors</title></head><body>
+<form id="login">
+  <label id="lu" for="user">用户名</label><input id="user" name="u">
+  <label id="lp">密码 <input id="pass" type="password"></label>
+  <span id="mail-label">邮箱地址</span><input id="mail" aria-labelledby="mail-label">
+  <input id="q" aria-label="搜索" placeholder="请输入关键词">
+  <textarea id="note" placeholder="备注"></textarea>
+  <button id="submit" type="submit">登录</button>
+  <input id="register" type="submit" value="注册账号">
+  <button id="close" aria-label="关闭"><img alt=""></button>
+</form>
+<div id="cards">
+  <div class="card" id="c1"><h2 id="h1">第一张</h2><a id="a1" href="/1">详情</a></div>
+  <div class="card" id="c2"><h2 id="h2">第二张</h2><a id="a2" href="/2">查看  详情</a></div>
+</div>
+<p id="hello">Hello   World</p>
+<template><button id="inert">登录</button></template>
+<my-login id="host"><template shadowrootmode="open"><button id="shadow">影子登录</button><input id="shadow-input" placeholder="影子输入"></template></my-login>
+<closed-box><template shadowrootmode="closed"><button id="closed">不可见</button></template></closed-box>
+<iframe id="frame" src="/frame"></iframe>
+<iframe id="foreign" src="http://foreign.invalid/frame"></iframe>
+</body></html>`
+
+const framePage = `<!DOCTYPE html>
+<html><body><button id="frame-button">框架按钮</button><label for="frame-user">框架用户</label><input id="frame-user"></body></html>`
+
+// newSelectorPage 打开带shadow DOM和iframe的测试页面
+func newSelectorPage(t *testing.T) *HTTPPage {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Path == "/frame" {
+			fmt.Fprint(w, framePage)
+			return
+		}
+		fmt.Fprint(w, selectorPage)
+	}))
+	t.Cleanup(server.Close)
+
+	page := newTestPage(t, testBrowser())
+	if err := page.Navigate(context.Background(), server.URL+"/"); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// queryIDs 返回选择器匹配的元素的id，用逗号连接
+func queryIDs(page Page, selector string) (string, error) {
+	elements, err := page.Query(context.Background(), selector)
+	if err != nil {
+		return "", err
+	}
+	ids := make([]string, len(elements))
+	for i, e := range elements {
+		ids[i] = e.Attributes["id"]
+	}
+	return strings.Join(ids, ","), nil
+}
+
+func TestHTTPSelectorEngines(t *testing.T) {
+	page := newSelectorPage(t)
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		// CSS进入开放的shadow DOM和同源iframe，不进入template、封闭的shadow DOM和跨域iframe
+		{"#user", "user"},
+		{"css:#cards .card", "c1,c2"},
+		{"css: form  button", "submit,close"},
+		{"button", "submit,close,shadow,frame-button"},
+		{"label:first-child", "lu"},
+
+		{"xpath://h2", "h1,h2"},
+		{"//a[@href='/2']", "a2"},
+		{"(//a)[1]", "a1"},
+		{"xpath://button[@id='frame-button']", "frame-button"},
+
+		// text:取最内层的元素，不带引号时忽略大小写和多余的空白
+		{"text:详情", "a1,a2"},
+		{`text:"详情"`, "a1"},
+		{`text:'查看 详情'`, "a2"},
+		{"text:hello world", "hello"},
+		{`text:"hello world"`, ""},
+		{"text:登录", "submit,shadow"},
+		{"text:注册账号", "register"},
+		{"text:框架按钮", "frame-button"},
+		{"text:不可见", ""},
+
+		{"role:button", "submit,register,close,shadow,frame-button"},
+		{"role:BUTTON[name=登录]", "submit,shadow"},
+		{`role:button[name="登录"]`, "submit"},
+		{"role:button[name=关闭]", "close"},
+		{"role:button[ name = 注册 ]", "register"},
+		{"role:heading", "h1,h2"},
+		{"role:link[name=查看 详情]", "a2"},
+		{"role:textbox[name=用户名]", "user"},
+		{"role:textbox[name=邮箱地址]", "mail"},
+		{"role:img", ""},
+		{"role:form", "login"},
+
+		{"label:用户名", "user"},
+		{"label:密码", "pass"},
+		{"label:邮箱", "mail"},
+		{"label:搜索", "q"},
+		{`label:"框架用户"`, "frame-user"},
+
+		{"placeholder:关键词", "q"},
+		{`placeholder:"备注"`, "note"},
+		{"placeholder:影子", "shadow-input"},
+
+		// >>在前一段的元素内查找
+		{"#c2 >> text:详情", "a2"},
+		{".card >> a", "a1,a2"},
+		{".card >> nth=1", "c2"},
+		{".card >> nth=-1", "c2"},
+		{".card >> nth=2", ""},
+		{"role:button >> nth=0", "submit"},
+		{"#cards >> role:link >> nth=-1", "a2"},
+		{"my-login >> button", "shadow"},
+		{"#frame >> button", "frame-button"},
+		{"#foreign >> button", ""},
+		{"#c1 >> xpath://a", "a1"},
+		{"#c1 >> xpath:/h2", "h1"},
+		{"#c1 >> xpath:(//a)[1]", "a1"},
+		{"#login >> label:用户名", "user"},
+		{".card>>a>>nth=0", "a1"},
+	}
+	for _, tt := range tests {
+		got, err := queryIDs(page, tt.selector)
+		if err != nil {
+			t.Errorf("Query(%q): %v", tt.selector, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Query(%q) = %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []selectorPart
+	}{
+		{"#kw", []selectorPart{{Engine: engineCSS, Value: "#kw"}}},
+		{"a[title='x >> y'] >> nth=0", []selectorPart{{Engine: engineCSS, Value: "a[title='x >> y']"}, {Engine: engineNth}}},
+		{`text:"a >> b"`, []selectorPart{{Engine: engineText, Value: "a >> b", Exact: true}}},
+		{"text:  a   b ", []selectorPart{{Engine: engineText, Value: "a b"}}},
+		{"xpath://p[contains(., '>>')]", []selectorPart{{Engine: engineXPath, Value: "//p[contains(., '>>')]"}}},
+		{"role:Button[name='OK']", []selectorPart{{Engine: engineRole, Value: "button", Name: "OK", HasName: true, NameExact: true}}},
+		{"div >> nth=-2", []selectorPart{{Engine: engineCSS, Value: "div"}, {Engine: engineNth, Nth: -2}}},
+		{"label:用户名", []selectorPart{{Engine: engineLabel, Value: "用户名"}}},
+		{"label:checked", []selectorPart{{Engine: engineCSS, Value: "label:checked"}}},
+		{"input:checked", []selectorPart{{Engine: engineCSS, Value: "input:checked"}}},
+	}
+	for _, tt := range tests {
+		got, err := parseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("parseSelector(%q): %v", tt.selector, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelector(%q) = %+v, want %+v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	page := newSelectorPage(t)
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"nth=1", "nth=前面缺少选择器"},
+		{"a >> ", ">>两边缺少选择器"},
+		{">> a", ">>两边缺少选择器"},
+		{"a >> nth=x", "nth=后面应为整数"},
+		{"css:", "css:后面缺少选择器"},
+		{"text:", "text:后面缺少文字"},
+		{`placeholder:""`, "placeholder:后面缺少文字"},
+		{"role:", "role:后面缺少角色名"},
+		{"role:button1", "无效的角色名"},
+		{"role:button[name=x", "role选择器缺少]"},
+		{"role:button[label=x]", "role选择器只支持[name=...]"},
+		{"xpath://p[", "第5列: 缺少表达式"},
+		{"div >", "缺少选择器"},
+	}
+	for _, tt := range tests {
+		_, err := page.Query(context.Background(), tt.selector)
+		if err == nil {
+			t.Errorf("Query(%q) succeeded, want error", tt.selector)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Query(%q) error = %q, want it to contain %q", tt.selector, err, tt.want)
+		}
+	}
+}
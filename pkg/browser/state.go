@@ -0,0 +1,58 @@
+package browser
+
+import (
+	"time"
+)
+
+// StorageState 浏览器的存储状态，用于保存登录后的会话并在以后恢复
+type StorageState struct {
+	Cookies []Cookie        `json:"cookies"`
+	Origins []OriginStorage `json:"origins"`
+}
+
+// OriginStorage 一个来源（协议、主机和端口）的localStorage和sessionStorage
+type OriginStorage struct {
+	// Origin 例如https://example.com
+	Origin         string        `json:"origin"`
+	LocalStorage   []StorageItem `json:"localStorage,omitempty"`
+	SessionStorage []StorageItem `json:"sessionStorage,omitempty"`
+}
+
+// StorageItem 存储中的一项
+type StorageItem struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// liveCookies 返回还没有过期的Cookie，会话Cookie的Expires统一清零，
+// 否则CDP会把-1当作1970年的过期时间
+func liveCookies(cookies []Cookie, now time.Time) []Cookie {
+	live := make([]Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		switch {
+		case c.Expires <= 0:
+			c.Expires = 0
+		case c.Expires < float64(now.Unix()):
+			continue
+		}
+		live = append(live, c)
+	}
+	return live
+}
+
+// CookiesExpire 返回所有Cookie都过期的时间；有会话Cookie或者没有Cookie时无法判断，返回零值
+func (s *StorageState) CookiesExpire() time.Time {
+	var latest float64
+	for _, c := range s.Cookies {
+		if c.Expires <= 0 {
+			return time.Time{}
+		}
+		if c.Expires > latest {
+			latest = c.Expires
+		}
+	}
+	if latest == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(latest), 0)
+}
@@ -0,0 +1,155 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"GoBrowserAgent/pkg/browser/cdp"
+)
+
+// pollInterval 等待条件时两次检查之间的间隔
+const pollInterval = 100 * time.Millisecond
+
+// NetworkIdleTime 没有进行中的请求持续多久算作网络空闲
+const NetworkIdleTime = 500 * time.Millisecond
+
+// WaitState WaitForSelector等待的元素状态
+type WaitState string
+
+const (
+	// WaitVisible 至少有一个匹配的元素可见
+	WaitVisible WaitState = "visible"
+	// WaitHidden 没有可见的匹配元素，包括元素不存在
+	WaitHidden WaitState = "hidden"
+	// WaitAttached 至少有一个匹配的元素在页面中，不论是否可见
+	WaitAttached WaitState = "attached"
+)
+
+// ParseWaitState 解析元素状态，空字符串表示WaitVisible
+func ParseWaitState(s string) (WaitState, error) {
+	switch state := WaitState(s); state {
+	case "":
+		return WaitVisible, nil
+	case WaitVisible, WaitHidden, WaitAttached:
+		return state, nil
+	}
+	return "", fmt.Errorf("无效的元素状态%q，可用: visible、hidden、attached", s)
+}
+
+// scriptError 页面中的脚本抛出了异常，重新检查也不会改变结果
+type scriptError struct {
+	message string
+}
+
+func (e *scriptError) Error() string {
+	return "脚本执行出错: " + e.message
+}
+
+// poll 每隔pollInterval调用一次check，直到返回true
+//
+// check返回的错误视为条件暂时不满足（例如元素还没有出现，或者页面正在跳转），超时后随超时说明一起返回；
+// 脚本异常、后端不支持和浏览器关闭等等待也无法恢复的错误立即返回。
+func poll(ctx context.Context, check func(ctx context.Context) (bool, error)) error {
+	start := time.Now()
+	var last error
+	for {
+		ok, err := check(ctx)
+		if err == nil && ok {
+			return nil
+		}
+		if err != nil && permanent(err) {
+			return err
+		}
+		// 截止时间到达导致的检查失败不作为原因
+		if ctx.Err() == nil {
+			last = err
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ctx.Err()
+			}
+			if last == nil {
+				last = ErrConditionNotMet
+			}
+			return fmt.Errorf("%w，等待%s后超时", last, time.Since(start).Round(pollInterval))
+		}
+	}
+}
+
+// checkOnce 只检查一次，用于页面不会自行变化的HTTP后端
+func checkOnce(ctx context.Context, check func(ctx context.Context) (bool, error)) error {
+	ok, err := check(ctx)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("%w，HTTP后端的页面只在导航、点击和提交时变化", ErrConditionNotMet)
+	}
+	return nil
+}
+
+// permanent 判断错误能否通过继续等待恢复
+func permanent(err error) bool {
+	var script *scriptError
+	return errors.As(err, &script) || errors.Is(err, ErrUnsupported) || errors.Is(err, ErrBrowserClosed) ||
+		errors.Is(err, cdp.ErrClosed)
+}
+
+// selectorCheck 返回检查元素状态的函数
+func selectorCheck(page Page, selector string, state WaitState) func(ctx context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		elements, err := page.Query(ctx, selector)
+		if err != nil {
+			return false, err
+		}
+		if state == WaitAttached {
+			return len(elements) > 0, nil
+		}
+		visible := false
+		for _, e := range elements {
+			if e.Visible {
+				visible = true
+				break
+			}
+		}
+		return visible == (state == WaitVisible), nil
+	}
+}
+
+// urlCheck 返回检查页面地址的函数
+func urlCheck(page Page, pattern *regexp.Regexp) func(ctx context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		url, err := page.URL(ctx)
+		if err != nil {
+			return false, err
+		}
+		return pattern.MatchString(url), nil
+	}
+}
+
+// waitError 给等待失败的错误加上等待的内容
+func waitError(what string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("等待%s: %w", what, err)
+}
+
+// stateText 返回元素状态的说明
+func stateText(state WaitState) string {
+	switch state {
+	case WaitHidden:
+		return "隐藏"
+	case WaitAttached:
+		return "出现"
+	}
+	return "可见"
+}
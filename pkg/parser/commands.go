@@ -0,0 +1,164 @@
+package parser
+
+import "fmt"
+
+// NewDefaultRegistry 创建注册了内置命令的注册表
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, spec := range builtinCommands() {
+		r.MustRegister(spec)
+	}
+	return r
+}
+
+// builtinCommands 返回README中列出的内置命令
+func builtinCommands() []*Spec {
+	return []*Spec{
+		{
+			Name:    "go",
+			Aliases: []string{"navigate"},
+			Summary: "导航到指定URL",
+			Args: []Arg{
+				{Name: "url", Type: URL, Positional: true, Required: true, Help: "要打开的地址，省略协议时使用https"},
+			},
+			Examples: []string{"go https://www.baidu.com"},
+		},
+		{
+			Name:    "login",
+			Summary: "登录到指定网站",
+			Description: "site为vault set保存的站点配置名称，或登录页地址（按主机名匹配站点配置）；用户名和密码从凭据保险库读取，" +
+				"命令中给出的参数优先。未指定输入框时自动查找用户名、密码输入框和登录按钮，支持先输入用户名再输入密码的两步登录。" +
+				"提交后等待success条件满足，未设置时等待密码输入框消失。站点配置的登录状态会加密保存，有效期内再次登录时直接复用。",
+			Args: []Arg{
+				{Name: "site", Type: String, Positional: true, Required: true, Placeholder: "profile|url", Help: "站点配置名称或登录页地址"},
+				{Name: "username", Type: String, Help: "用户名，默认使用站点配置中的用户名"},
+				{Name: "password", Type: String, Help: "密码，默认使用站点配置中的密码；不建议写在命令和脚本中"},
+				{Name: "username_selector", Type: Selector, Placeholder: "selector", Help: "用户名输入框"},
+				{Name: "password_selector", Type: Selector, Placeholder: "selector", Help: "密码输入框"},
+				{Name: "submit", Type: Selector, Placeholder: "selector", Help: "登录按钮，为空时提交密码框所在的表单或点击页面上的登录按钮"},
+				{Name: "success", Type: String, Placeholder: "condition", Help: "登录成功的条件，写法与wait命令相同，例如\"for url ~ /home\""},
+				{Name: "fresh", Type: Bool, Help: "忽略保存的登录状态，重新登录"},
+			},
+			Examples: []string{
+				"login intranet",
+				`login https://example.com/login success="for url ~ /dashboard"`,
+				"login intranet fresh=true",
+			},
+			Validate: func(cmd *Command) error {
+				if site := cmd.String("site"); !IsProfileName(site) {
+					if _, err := NormalizeURL(site); err != nil {
+						return err
+					}
+				}
+				if cmd.Has("success") {
+					if _, err := ParseSuccessCondition(cmd.String("success")); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "state",
+			Summary: "保存或恢复浏览器状态",
+			Description: "state save把所有Cookie和当前页面各来源的localStorage、sessionStorage加密保存到指定名称下；" +
+				"state load恢复保存的状态，状态不存在或已经过期时失败，恢复后页面停在about:blank。名称与login命令的站点名称共用同一份登录状态。",
+			Args: []Arg{
+				{Name: "action", Type: String, Positional: true, Required: true, Placeholder: "save|load", Help: "save保存，load恢复"},
+				{Name: "name", Type: String, Positional: true, Required: true, Help: "状态的名称，只能包含字母、数字、_和-"},
+				{Name: "ttl", Type: Duration, Placeholder: "duration", Help: "保存的状态的有效期，默认使用配置中的session_hours；Cookie先过期时以Cookie为准"},
+			},
+			Examples: []string{"state save crm ttl=8h", "state load crm"},
+			Validate: func(cmd *Command) error {
+				action := cmd.String("action")
+				if action != "save" && action != "load" {
+					return fmt.Errorf("未知的操作%q，应为save或load", action)
+				}
+				if !IsProfileName(cmd.String("name")) {
+					return fmt.Errorf("无效的名称%q，只能包含字母、数字、_和-", cmd.String("name"))
+				}
+				if action == "load" && cmd.Has("ttl") {
+					return fmt.Errorf("ttl只能用于state save")
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "screenshot",
+			Summary: "截取当前页面",
+			Args: []Arg{
+				{Name: "path", Type: String, Placeholder: "filename", Help: "保存路径，默认按时间生成文件名"},
+			},
+			Examples: []string{"screenshot path=baidu.png"},
+		},
+		{
+			Name:    "wait",
+			Summary: "等待指定时间或等待条件满足",
+			Description: "wait <时长>固定等待；wait for <选择器> [visible|hidden|attached]等待元素显示（默认）、隐藏或出现在页面中；" +
+				"wait for url ~ <正则>等待地址匹配；wait for text <文字>等待页面上显示文字；" +
+				"wait for network idle等待500毫秒内没有进行中的请求；wait until <表达式>等待JavaScript表达式的结果为真。" +
+				"选择器、文字和表达式包含空格或=时加引号。",
+			Args: []Arg{
+				{Name: "condition", Type: String, Positional: true, Required: true, Variadic: true, Help: "时长或等待条件"},
+				{Name: "timeout", Type: Duration, Placeholder: "duration", Help: "等待条件的最长时间，默认使用配置中的timeout"},
+			},
+			Examples: []string{
+				"wait 5s",
+				"wait for #result",
+				"wait for .loading hidden timeout=1m",
+				"wait for url ~ /dashboard",
+				`wait for text "保存成功"`,
+				"wait for network idle",
+				`wait until "document.querySelectorAll('.item').length > 10"`,
+			},
+			Validate: func(cmd *Command) error {
+				_, err := ParseWaitCondition(cmd.Strings("condition"))
+				return err
+			},
+		},
+		{
+			Name:    "search",
+			Summary: "在网站上执行搜索",
+			Args: []Arg{
+				{Name: "url", Type: URL, Positional: true, Required: true, Help: "搜索页地址"},
+				{Name: "query", Type: String, Required: true, Help: "搜索关键词，包含空格时加引号"},
+				{Name: "input", Type: Selector, Required: true, Placeholder: "selector", Help: "搜索框"},
+				{Name: "button", Type: Selector, Placeholder: "selector", Help: "搜索按钮，为空时直接提交搜索框所在的表单"},
+			},
+			Examples: []string{`search https://www.baidu.com query="golang 教程" input=#kw button=#su`},
+		},
+		{
+			Name:        "form",
+			Summary:     "填写并提交表单",
+			Description: "field_后面是字段的name属性，以#、.或[开头时作为选择器；既没有submit也没有form时提交第一个字段所在的表单。",
+			Args: []Arg{
+				{Name: "url", Type: URL, Positional: true, Help: "表单所在的页面，省略时使用当前页面"},
+				{Name: "form", Type: Selector, Placeholder: "selector", Help: "表单，设置后只在该表单内查找字段"},
+				{Name: "field_", Type: String, Prefix: true, Placeholder: "value", Help: "字段的值"},
+				{Name: "submit", Type: Selector, Placeholder: "selector", Help: "提交按钮"},
+			},
+			Examples: []string{"form https://example.com field_username=user field_password=pass submit=#login-button"},
+		},
+		{
+			Name:    "script",
+			Summary: "执行JavaScript代码",
+			Args: []Arg{
+				{Name: "code", Type: String, Positional: true, Required: true, Placeholder: "javascript-code", Help: "要执行的代码，用引号包含"},
+			},
+			Examples: []string{`script "document.querySelector('.title').style.color = 'red';"`},
+		},
+		{
+			Name:    "exit",
+			Aliases: []string{"quit"},
+			Summary: "退出程序",
+		},
+		{
+			Name:    "help",
+			Summary: "显示帮助信息",
+			Args: []Arg{
+				{Name: "command", Type: String, Positional: true, Help: "查看指定命令的参数和示例"},
+			},
+			Examples: []string{"help", "help search"},
+		},
+	}
+}
@@ -0,0 +1,257 @@
+// Package parser 解析命令行和脚本中的浏览器命令
+//
+// 一条命令由命令名、位置参数和key=value参数组成，参数值可以用双引号或单引号包含空格：
+//
+//	search https://www.baidu.com query="golang 教程" input=#kw button=#su
+//	script "document.querySelector('.title').style.color = 'red';"
+//
+// 每个命令在Registry中注册参数定义（Spec），解析时按定义检查参数并转换类型，
+// 出错时返回带列号的*Error。
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error 解析错误，Column是出错位置在行中的列号（从1开始，按字符计算）
+type Error struct {
+	Line    string
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("第%d列: %s", e.Column, e.Message)
+}
+
+// Pretty 返回带原始行和指示出错位置的^的多行说明
+func (e *Error) Pretty() string {
+	prefix := []rune(e.Line)
+	if e.Column-1 < len(prefix) {
+		prefix = prefix[:e.Column-1]
+	}
+	// 中文等宽字符占两列，制表符原样保留，保证^对齐
+	var pad strings.Builder
+	for _, r := range prefix {
+		if r == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteString(strings.Repeat(" ", runeWidth(r)))
+		}
+	}
+	return fmt.Sprintf("%s\n%s^\n%s", e.Line, pad.String(), e.Error())
+}
+
+// runeWidth 返回字符在终端中大致占用的列数
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F, r >= 0x2E80 && r <= 0xA4CF, r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF, r >= 0xFE30 && r <= 0xFE4F, r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6, r >= 0x1F300 && r <= 0x1FAFF, r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
+
+// Token 一个以空白分隔的词
+type Token struct {
+	// Key key=value形式的参数名，位置参数为空
+	Key string
+	// Value 去掉引号并处理转义后的值
+	Value string
+	// Column 词开始的列号；ValueColumn 值开始的列号，位置参数与Column相同
+	Column      int
+	ValueColumn int
+	// Quoted 值中是否包含引号部分
+	Quoted bool
+	// Raw 词的原始文本
+	Raw string
+}
+
+// Tokenize 把一行拆分为词
+//
+// 引号可以出现在词的任意位置并与相邻的字符拼接，例如query="a b"与"query=a b"相同。
+// 双引号中支持\"、\\、\n、\t、\r和\uXXXX转义，其他反斜杠原样保留，方便书写JavaScript中的正则；
+// 单引号中除\'外不处理转义。
+func Tokenize(line string) ([]Token, error) {
+	var tokens []Token
+	l := &lexer{line: line}
+	for {
+		l.skipSpaces()
+		if l.eof() {
+			return tokens, nil
+		}
+		token, err := l.word()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// lexer 按字符扫描一行，pos是字节位置
+type lexer struct {
+	line string
+	pos  int
+}
+
+func (l *lexer) eof() bool {
+	return l.pos >= len(l.line)
+}
+
+// column 返回字节位置对应的列号
+func (l *lexer) column(pos int) int {
+	return utf8.RuneCountInString(l.line[:pos]) + 1
+}
+
+func (l *lexer) errorAt(pos int, format string, args ...interface{}) *Error {
+	return &Error{Line: l.line, Column: l.column(pos), Message: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) skipSpaces() {
+	for !l.eof() && isSpace(l.line[l.pos]) {
+		l.pos++
+	}
+}
+
+// word 读取一个词，遇到不在引号中的空白时结束
+func (l *lexer) word() (Token, error) {
+	start := l.pos
+	token := Token{Column: l.column(start), ValueColumn: l.column(start)}
+	var b strings.Builder
+	for !l.eof() && !isSpace(l.line[l.pos]) {
+		c := l.line[l.pos]
+		switch c {
+		case '"', '\'':
+			token.Quoted = true
+			s, err := l.quoted(c)
+			if err != nil {
+				return token, err
+			}
+			b.WriteString(s)
+		case '=':
+			// 第一个=之前是合法标识符且还没有出现引号时，这是key=value参数
+			if token.Key == "" && !token.Quoted && isIdentifier(b.String()) {
+				token.Key = b.String()
+				b.Reset()
+				l.pos++
+				token.ValueColumn = l.column(l.pos)
+				continue
+			}
+			b.WriteByte(c)
+			l.pos++
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	token.Value = b.String()
+	token.Raw = l.line[start:l.pos]
+	return token, nil
+}
+
+// quoted 读取引号中的内容，pos指向开始的引号
+func (l *lexer) quoted(q byte) (string, error) {
+	open := l.pos
+	l.pos++
+	var b strings.Builder
+	for !l.eof() {
+		c := l.line[l.pos]
+		switch {
+		case c == q:
+			l.pos++
+			return b.String(), nil
+		case c == '\\' && l.pos+1 < len(l.line):
+			next := l.line[l.pos+1]
+			if q == '\'' {
+				if next == '\'' {
+					b.WriteByte('\'')
+					l.pos += 2
+				} else {
+					b.WriteByte(c)
+					l.pos++
+				}
+				continue
+			}
+			s, n, err := l.escape(next)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+			l.pos += n
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorAt(open, "引号%c没有结束", q)
+}
+
+// escape 处理双引号中的转义，返回结果和消耗的字节数
+func (l *lexer) escape(next byte) (string, int, error) {
+	switch next {
+	case '"', '\\':
+		return string(next), 2, nil
+	case 'n':
+		return "\n", 2, nil
+	case 't':
+		return "\t", 2, nil
+	case 'r':
+		return "\r", 2, nil
+	case 'u':
+		if l.pos+6 > len(l.line) {
+			return "", 0, l.errorAt(l.pos, "\\u后面需要4位十六进制数")
+		}
+		code, err := strconv.ParseUint(l.line[l.pos+2:l.pos+6], 16, 32)
+		if err != nil {
+			return "", 0, l.errorAt(l.pos, "\\u后面需要4位十六进制数")
+		}
+		return string(rune(code)), 6, nil
+	}
+	// 其他转义原样保留反斜杠，后面的字符按普通字符读取
+	return "\\", 1, nil
+}
+
+// isIdentifier 参数名由字母、数字、_和-组成，以字母或_开头
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Incomplete 判断一行是否还没有结束：引号没有闭合，或者以引号外的反斜杠结尾。
+// 交互模式据此继续读取下一行，例如多行的script命令。
+func Incomplete(line string) bool {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote != 0 && c == quote:
+			quote = 0
+		case c == '\\' && i+1 < len(line) && (quote == '"' || quote == '\'' && line[i+1] == '\''):
+			i++
+		case c == '\\' && i+1 == len(line) && quote == 0:
+			return true
+		}
+	}
+	return quote != 0
+}
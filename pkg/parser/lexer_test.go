@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// words 返回词的值，key=value参数写成key=value
+func words(tokens []Token) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		if t.Key != "" {
+			out[i] = t.Key + "=" + t.Value
+		} else {
+			out[i] = t.Value
+		}
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{}},
+		{"  \t ", []string{}},
+		{"go https://example.com", []string{"go", "https://example.com"}},
+		{`search  query="golang 教程"   input=#kw`, []string{"search", "query=golang 教程", "input=#kw"}},
+		{`"query=a b"`, []string{"query=a b"}},
+		{`a"b c"d`, []string{"ab cd"}},
+		{`x=""`, []string{"x="}},
+		{`""`, []string{""}},
+		{`s="say \"hi\""`, []string{`s=say "hi"`}},
+		{`s="a\\b\n\t\r"`, []string{"s=a\\b\n\t\r"}},
+		{`s="中文"`, []string{"s=中文"}},
+		{`s="/\d+\.html/"`, []string{`s=/\d+\.html/`}},
+		{`s='it\'s'`, []string{"s=it's"}},
+		{`s='a\nb "c"'`, []string{`s=a\nb "c"`}},
+		{"url=https://a.example/?x=1&y=2", []string{"url=https://a.example/?x=1&y=2"}},
+		{"1x=2", []string{"1x=2"}},
+		{"=a", []string{"=a"}},
+		{"field_user-name=bob", []string{"field_user-name=bob"}},
+		{`"a"=b`, []string{"a=b"}},
+		{"a\tb\nc", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.line)
+		if err != nil {
+			t.Errorf("Tokenize(%q): %v", tt.line, err)
+			continue
+		}
+		if got := words(tokens); strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeKeys(t *testing.T) {
+	tokens, err := Tokenize(`go 标题="a b" q=x "k=v" 1x=2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Token{
+		{Value: "go", Column: 1, ValueColumn: 1, Raw: "go"},
+		{Value: `标题=a b`, Column: 4, ValueColumn: 4, Quoted: true, Raw: `标题="a b"`},
+		{Key: "q", Value: "x", Column: 13, ValueColumn: 15, Raw: "q=x"},
+		{Value: "k=v", Column: 17, ValueColumn: 17, Quoted: true, Raw: `"k=v"`},
+		{Value: "1x=2", Column: 23, ValueColumn: 23, Raw: "1x=2"},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %d = %+v, want %+v", i, tokens[i], want[i])
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		line    string
+		column  int
+		message string
+	}{
+		{`go "https://a`, 4, "引号\"没有结束"},
+		{`script 'alert(1)`, 8, "引号'没有结束"},
+		{`打开 "中文`, 4, "引号\"没有结束"},
+		{`s="it\'s`, 3, "引号\"没有结束"},
+		{`s="\u12"`, 4, "\\u后面需要4位十六进制数"},
+		{`s="\uzzzz"`, 4, "\\u后面需要4位十六进制数"},
+		{`s='it\'`, 3, "引号'没有结束"},
+	}
+	for _, tt := range tests {
+		_, err := Tokenize(tt.line)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Tokenize(%q) error = %v, want *Error", tt.line, err)
+			continue
+		}
+		if perr.Column != tt.column || perr.Message != tt.message || perr.Line != tt.line {
+			t.Errorf("Tokenize(%q) error = %+v, want column %d %q", tt.line, perr, tt.column, tt.message)
+		}
+	}
+}
+
+func TestErrorPretty(t *testing.T) {
+	err := &Error{Line: "打开\t\"x", Column: 4, Message: "引号\"没有结束"}
+	want := "打开\t\"x\n    \t^\n第4列: 引号\"没有结束"
+	if got := err.Pretty(); got != want {
+		t.Fatalf("Pretty() = %q, want %q", got, want)
+	}
+	// 列号超出行尾时^在行尾之后
+	err = &Error{Line: "go", Column: 3, Message: "缺少参数url"}
+	if got := err.Pretty(); got != "go\n  ^\n第3列: 缺少参数url" {
+		t.Fatalf("Pretty() = %q", got)
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"go https://a.example", false},
+		{`script "alert(1)`, true},
+		{`script "alert(1)"`, false},
+		{`script 'it\'s`, true},
+		{`script 'it\'s'`, false},
+		{`script "say \"hi\"`, true},
+		{`script "say \"hi\""`, false},
+		{`script "a\\"`, false},
+		{`script 'a\'`, true},
+		{`script "it's"`, false},
+		{`script 'say "hi"'`, false},
+		{`go \`, true},
+		{`go "\`, true},
+		{`go "a" \`, true},
+		{`go a\b`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Incomplete(tt.line); got != tt.want {
+			t.Errorf("Incomplete(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,600 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ArgType 参数的类型，决定解析时的检查和转换
+type ArgType int
+
+const (
+	// String 任意文字
+	String ArgType = iota
+	// Int 整数
+	Int
+	// Bool true/false、yes/no、on/off或1/0
+	Bool
+	// Duration 时长，例如500ms、5s、1m30s；不带单位的数字按秒计算
+	Duration
+	// URL http或https地址，省略协议时补全为https://
+	URL
+	// Selector 元素选择器，不能为空
+	Selector
+)
+
+// String 返回类型在帮助中显示的名称
+func (t ArgType) String() string {
+	switch t {
+	case Int:
+		return "整数"
+	case Bool:
+		return "布尔值"
+	case Duration:
+		return "时长"
+	case URL:
+		return "地址"
+	case Selector:
+		return "选择器"
+	}
+	return "文字"
+}
+
+// Arg 命令参数的定义
+type Arg struct {
+	// Name 参数名；Prefix为true时是参数名前缀，例如field_匹配field_username、field_password
+	Name string
+	Type ArgType
+	// Positional 按位置传入，不写参数名；位置参数按定义的顺序匹配
+	Positional bool
+	Required   bool
+	// Prefix 匹配所有以Name开头的key=value参数，按出现顺序保存
+	Prefix bool
+	// Variadic 收集剩余的所有位置参数，只能用于最后一个位置参数，用Command.Strings读取
+	Variadic bool
+	// Placeholder 帮助中显示的参数值占位符，为空时使用Name
+	Placeholder string
+	Help        string
+}
+
+// Spec 一个命令的定义
+type Spec struct {
+	Name    string
+	Aliases []string
+	// Summary 一行说明，显示在命令列表中
+	Summary string
+	// Description 详细说明，显示在help <命令>中
+	Description string
+	Args        []Arg
+	// Examples 用法示例
+	Examples []string
+	// Validate 在参数检查通过后检查参数之间的组合，为nil时不检查；
+	// 有参数值要到执行时才能确定时不调用
+	Validate func(cmd *Command) error
+}
+
+// Usage 返回命令的用法，例如search <url> query=<query> input=<selector> [button=<selector>]
+func (s *Spec) Usage() string {
+	parts := []string{s.Name}
+	for _, a := range s.Args {
+		placeholder := a.Placeholder
+		if placeholder == "" {
+			placeholder = a.Name
+		}
+		var part string
+		switch {
+		case a.Positional && a.Variadic:
+			part = "<" + placeholder + ">..."
+		case a.Positional:
+			part = "<" + placeholder + ">"
+		case a.Prefix:
+			part = a.Name + "<name>=<" + placeholder + ">..."
+		default:
+			part = a.Name + "=<" + placeholder + ">"
+		}
+		if !a.Required {
+			part = "[" + part + "]"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Help 返回命令的详细帮助
+func (s *Spec) Help() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n  %s\n", s.Usage(), s.Summary)
+	if len(s.Aliases) > 0 {
+		fmt.Fprintf(&b, "\n别名: %s\n", strings.Join(s.Aliases, ", "))
+	}
+	if s.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", s.Description)
+	}
+	if len(s.Args) > 0 {
+		b.WriteString("\n参数:\n")
+		for _, a := range s.Args {
+			name := a.Name
+			if a.Prefix {
+				name += "<name>"
+			}
+			required := ""
+			if a.Required {
+				required = "，必填"
+			}
+			fmt.Fprintf(&b, "  %-16s %s（%s%s）\n", name, a.Help, a.Type, required)
+		}
+	}
+	if len(s.Examples) > 0 {
+		b.WriteString("\n示例:\n")
+		for _, e := range s.Examples {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// arg 按参数名查找非位置参数的定义
+func (s *Spec) arg(key string) *Arg {
+	for i := range s.Args {
+		a := &s.Args[i]
+		if a.Positional {
+			continue
+		}
+		if a.Prefix && strings.HasPrefix(key, a.Name) && len(key) > len(a.Name) || !a.Prefix && a.Name == key {
+			return a
+		}
+	}
+	return nil
+}
+
+// KeyValue 前缀参数中的一项，Key是去掉前缀后的名称
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// Command 解析后的命令
+type Command struct {
+	// Name 命令的正式名称（别名会转换为正式名称）
+	Name string
+	Spec *Spec
+	// Line 原始输入
+	Line string
+
+	values   map[string]interface{}
+	prefixed map[string][]KeyValue
+}
+
+// Has 判断是否传入了参数
+func (c *Command) Has(name string) bool {
+	_, ok := c.values[name]
+	return ok
+}
+
+// String 返回文字、地址或选择器参数，未传入时返回空字符串
+func (c *Command) String(name string) string {
+	s, _ := c.values[name].(string)
+	return s
+}
+
+// Int 返回整数参数，未传入时返回0
+func (c *Command) Int(name string) int {
+	n, _ := c.values[name].(int)
+	return n
+}
+
+// Bool 返回布尔参数，未传入时返回false
+func (c *Command) Bool(name string) bool {
+	b, _ := c.values[name].(bool)
+	return b
+}
+
+// Strings 返回可变位置参数的所有值，未传入时返回nil
+func (c *Command) Strings(name string) []string {
+	values, _ := c.values[name].([]string)
+	return values
+}
+
+// Duration 返回时长参数，未传入时返回0
+func (c *Command) Duration(name string) time.Duration {
+	d, _ := c.values[name].(time.Duration)
+	return d
+}
+
+// Prefixed 按出现顺序返回前缀参数，例如Prefixed("field_")返回所有field_<name>=<value>
+func (c *Command) Prefixed(prefix string) []KeyValue {
+	return c.prefixed[prefix]
+}
+
+// Registry 命令注册表，可以并发使用
+type Registry struct {
+	mu      sync.RWMutex
+	specs   map[string]*Spec
+	aliases map[string]string
+}
+
+// NewRegistry 创建空的注册表
+func NewRegistry() *Registry {
+	return &Registry{specs: make(map[string]*Spec), aliases: make(map[string]string)}
+}
+
+// Register 注册命令，命令名或别名与已有命令冲突时返回错误
+func (r *Registry) Register(spec *Spec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := append([]string{spec.Name}, spec.Aliases...)
+	for _, name := range names {
+		if name == "" || name != strings.ToLower(name) || strings.ContainsAny(name, " \t\"'=") {
+			return fmt.Errorf("无效的命令名%q，命令名必须是不含空白、引号和=的小写字符串", name)
+		}
+		if _, ok := r.specs[name]; ok {
+			return fmt.Errorf("命令%s已注册", name)
+		}
+		if owner, ok := r.aliases[name]; ok {
+			return fmt.Errorf("%s已是命令%s的别名", name, owner)
+		}
+	}
+
+	positionalOptional, variadic := false, ""
+	for _, a := range spec.Args {
+		if !a.Positional {
+			continue
+		}
+		if variadic != "" {
+			return fmt.Errorf("命令%s的可变位置参数%s必须是最后一个位置参数", spec.Name, variadic)
+		}
+		if a.Required && positionalOptional {
+			return fmt.Errorf("命令%s的必填位置参数%s不能在可选位置参数之后", spec.Name, a.Name)
+		}
+		if !a.Required {
+			positionalOptional = true
+		}
+		if a.Variadic {
+			variadic = a.Name
+		}
+	}
+
+	r.specs[spec.Name] = spec
+	for _, alias := range spec.Aliases {
+		r.aliases[alias] = spec.Name
+	}
+	return nil
+}
+
+// MustRegister 同Register，出错时panic，用于注册内置命令
+func (r *Registry) MustRegister(spec *Spec) {
+	if err := r.Register(spec); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup 按命令名或别名查找命令定义
+func (r *Registry) Lookup(name string) (*Spec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name = strings.ToLower(name)
+	if alias, ok := r.aliases[name]; ok {
+		name = alias
+	}
+	spec, ok := r.specs[name]
+	return spec, ok
+}
+
+// Commands 按名称排序返回所有命令
+func (r *Registry) Commands() []*Spec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	specs := make([]*Spec, 0, len(r.specs))
+	for _, spec := range r.specs {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// Names 按字母顺序返回所有命令名和别名
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.specs)+len(r.aliases))
+	for name := range r.specs {
+		names = append(names, name)
+	}
+	for alias := range r.aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Help 返回所有命令的列表；name不为空时返回该命令的详细帮助
+func (r *Registry) Help(name string) (string, error) {
+	if name != "" {
+		spec, ok := r.Lookup(name)
+		if !ok {
+			return "", fmt.Errorf("未知命令: %s", name)
+		}
+		return spec.Help(), nil
+	}
+	var b strings.Builder
+	b.WriteString("可用命令:\n")
+	for _, spec := range r.Commands() {
+		fmt.Fprintf(&b, "  %-10s %s\n", spec.Name, spec.Summary)
+	}
+	b.WriteString("\n输入 help <命令> 查看命令的参数和示例")
+	return b.String(), nil
+}
+
+// Expander 在转换类型之前替换参数值中的变量引用，返回替换后的值
+type Expander func(value string) (string, error)
+
+// SkipValue 由Expander返回，表示参数值要到执行时才能确定：只检查参数名和必填参数，不检查值的类型
+var SkipValue = errors.New("参数值在执行时确定")
+
+// Parse 解析一行命令；空行返回nil和nil
+func (r *Registry) Parse(line string) (*Command, error) {
+	return r.ParseWith(line, nil)
+}
+
+// ParseWith 解析一行命令，每个参数值先经过expand替换，expand为nil时与Parse相同
+//
+// 替换在拆分词之后进行，替换结果中的空格和引号不会影响参数的划分。
+func (r *Registry) ParseWith(line string, expand Expander) (*Command, error) {
+	tokens, err := Tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	errorAt := func(column int, format string, args ...interface{}) *Error {
+		return &Error{Line: line, Column: column, Message: fmt.Sprintf(format, args...)}
+	}
+
+	head := tokens[0]
+	if head.Key != "" || head.Quoted {
+		return nil, errorAt(head.Column, "行首应为命令名")
+	}
+	spec, ok := r.Lookup(head.Value)
+	if !ok {
+		message := fmt.Sprintf("未知命令%q", head.Value)
+		if suggestion := closest(head.Value, r.Names()); suggestion != "" {
+			message += fmt.Sprintf("，是否要输入%s？", suggestion)
+		}
+		return nil, errorAt(head.Column, "%s", message)
+	}
+
+	cmd := &Command{
+		Name:     spec.Name,
+		Spec:     spec,
+		Line:     line,
+		values:   make(map[string]interface{}),
+		prefixed: make(map[string][]KeyValue),
+	}
+	var positional []*Arg
+	for i := range spec.Args {
+		if spec.Args[i].Positional {
+			positional = append(positional, &spec.Args[i])
+		}
+	}
+
+	next, skipped := 0, false
+	for _, t := range tokens[1:] {
+		// skip 值在执行时才能确定，用nil占位，只参与参数名和必填检查
+		skip := false
+		if expand != nil {
+			value, err := expand(t.Value)
+			if err != nil && err != SkipValue {
+				return nil, errorAt(t.ValueColumn, "%v", err)
+			}
+			t.Value, skip = value, err == SkipValue
+			skipped = skipped || skip
+		}
+		var a *Arg
+		if t.Key == "" {
+			switch {
+			case next < len(positional):
+				a = positional[next]
+				next++
+			case next > 0 && positional[next-1].Variadic:
+				a = positional[next-1]
+			default:
+				return nil, errorAt(t.Column, "多余的参数%q，%s", t.Raw, usageHint(spec))
+			}
+			if a.Variadic {
+				value := t.Value
+				if !skip {
+					converted, err := convert(a.Type, t.Value)
+					if err != nil {
+						return nil, errorAt(t.ValueColumn, "参数%s: %v", a.Name, err)
+					}
+					value = fmt.Sprint(converted)
+				}
+				values, _ := cmd.values[a.Name].([]string)
+				cmd.values[a.Name] = append(values, value)
+				continue
+			}
+		} else {
+			a = spec.arg(t.Key)
+			if a == nil {
+				var names []string
+				for _, candidate := range spec.Args {
+					if !candidate.Positional && !candidate.Prefix {
+						names = append(names, candidate.Name)
+					}
+				}
+				if suggestion := closest(t.Key, names); suggestion != "" {
+					return nil, errorAt(t.Column, "命令%s没有参数%s，是否要输入%s？", spec.Name, t.Key, suggestion)
+				}
+				return nil, errorAt(t.Column, "命令%s没有参数%s，%s", spec.Name, t.Key, usageHint(spec))
+			}
+			if a.Prefix {
+				if skip {
+					cmd.prefixed[a.Name] = append(cmd.prefixed[a.Name], KeyValue{Key: strings.TrimPrefix(t.Key, a.Name)})
+					continue
+				}
+				value, err := convert(a.Type, t.Value)
+				if err != nil {
+					return nil, errorAt(t.ValueColumn, "参数%s: %v", t.Key, err)
+				}
+				cmd.prefixed[a.Name] = append(cmd.prefixed[a.Name], KeyValue{Key: strings.TrimPrefix(t.Key, a.Name), Value: fmt.Sprint(value)})
+				continue
+			}
+			if cmd.Has(a.Name) {
+				return nil, errorAt(t.Column, "参数%s重复", a.Name)
+			}
+		}
+		if skip {
+			cmd.values[a.Name] = nil
+			continue
+		}
+		value, err := convert(a.Type, t.Value)
+		if err != nil {
+			return nil, errorAt(t.ValueColumn, "参数%s: %v", a.Name, err)
+		}
+		cmd.values[a.Name] = value
+	}
+
+	end := utf8.RuneCountInString(strings.TrimRight(line, " \t\r\n")) + 1
+	for _, a := range spec.Args {
+		if !a.Required {
+			continue
+		}
+		if a.Prefix && len(cmd.prefixed[a.Name]) == 0 || !a.Prefix && !cmd.Has(a.Name) {
+			return nil, errorAt(end, "缺少参数%s，%s", a.Name, usageHint(spec))
+		}
+	}
+	if spec.Validate != nil && !skipped {
+		column := end
+		if len(tokens) > 1 {
+			column = tokens[1].Column
+		}
+		if err := spec.Validate(cmd); err != nil {
+			return nil, errorAt(column, "%v", err)
+		}
+	}
+	return cmd, nil
+}
+
+// usageHint 错误信息中附带的用法提示
+func usageHint(spec *Spec) string {
+	return "用法: " + spec.Usage()
+}
+
+// convert 按参数类型检查并转换值
+func convert(t ArgType, value string) (interface{}, error) {
+	switch t {
+	case Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q不是整数", value)
+		}
+		return n, nil
+	case Bool:
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q不是布尔值，应为true或false", value)
+	case Duration:
+		return ParseDuration(value)
+	case URL:
+		return NormalizeURL(value)
+	case Selector:
+		if strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("选择器不能为空")
+		}
+	}
+	return value, nil
+}
+
+// ParseDuration 解析时长，例如500ms、5s、1m、1h30m；不带单位的数字按秒计算
+func ParseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("时长不能为负数")
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q不是有效的时长，示例: 500ms、5s、1m30s", value)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("时长不能为负数")
+	}
+	return d, nil
+}
+
+// NormalizeURL 检查地址，省略协议时补全为https://
+func NormalizeURL(value string) (string, error) {
+	if value == "about:blank" {
+		return value, nil
+	}
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("%q不是有效的地址", value)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("不支持的协议%s，地址应以http://或https://开头", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("地址%q缺少主机名", value)
+	}
+	return value, nil
+}
+
+// profileNamePattern 站点配置的名称，不含.、:和/，因此不会与地址混淆
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// IsProfileName 判断login命令的site参数是站点配置名称还是地址
+func IsProfileName(site string) bool {
+	return profileNamePattern.MatchString(site)
+}
+
+// closest 返回与name编辑距离不超过2的最接近的候选
+func closest(name string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(name), c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance 计算两个字符串的Levenshtein距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRegistry 注册内置命令和一个包含各种参数类型的typed命令
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewDefaultRegistry()
+	err := r.Register(&Spec{
+		Name:    "typed",
+		Aliases: []string{"tp"},
+		Args: []Arg{
+			{Name: "target", Type: URL, Positional: true, Required: true},
+			{Name: "rest", Type: Int, Positional: true, Variadic: true},
+			{Name: "count", Type: Int},
+			{Name: "enabled", Type: Bool},
+			{Name: "delay", Type: Duration},
+			{Name: "at", Type: Selector},
+			{Name: "h_", Type: String, Prefix: true},
+		},
+		Validate: func(cmd *Command) error {
+			if cmd.Int("count") < 0 {
+				return fmt.Errorf("count不能为负数")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestParseTypedValues(t *testing.T) {
+	r := testRegistry(t)
+	cmd, err := r.Parse(`TP example.com 1 2 count=3 enabled=YES delay=1.5 at="#a b" h_x=1 h_y="2 3" h_x=4`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "typed" || cmd.Spec.Name != "typed" {
+		t.Fatalf("name = %s", cmd.Name)
+	}
+	if got := cmd.String("target"); got != "https://example.com" {
+		t.Errorf("target = %q", got)
+	}
+	if got := cmd.Strings("rest"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("rest = %q", got)
+	}
+	if cmd.Int("count") != 3 || !cmd.Bool("enabled") || cmd.Duration("delay") != 1500*time.Millisecond || cmd.String("at") != "#a b" {
+		t.Errorf("values = %d %v %v %q", cmd.Int("count"), cmd.Bool("enabled"), cmd.Duration("delay"), cmd.String("at"))
+	}
+	want := []KeyValue{{"x", "1"}, {"y", "2 3"}, {"x", "4"}}
+	if got := cmd.Prefixed("h_"); !reflect.DeepEqual(got, want) {
+		t.Errorf("prefixed = %+v", got)
+	}
+	if cmd.Has("missing") || cmd.String("missing") != "" || cmd.Strings("missing") != nil {
+		t.Errorf("missing argument has a value")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		t     ArgType
+		value string
+		want  interface{}
+		err   string
+	}{
+		{Int, "-12", -12, ""},
+		{Int, "1.5", nil, `"1.5"不是整数`},
+		{Bool, "On", true, ""},
+		{Bool, "0", false, ""},
+		{Bool, "no", false, ""},
+		{Bool, "maybe", nil, `"maybe"不是布尔值`},
+		{Duration, "500ms", 500 * time.Millisecond, ""},
+		{Duration, "2", 2 * time.Second, ""},
+		{Duration, "1m30s", 90 * time.Second, ""},
+		{Duration, "-1s", nil, "时长不能为负数"},
+		{Duration, "-1", nil, "时长不能为负数"},
+		{Duration, "soon", nil, `"soon"不是有效的时长`},
+		{URL, "example.com/a?b=1", "https://example.com/a?b=1", ""},
+		{URL, "http://localhost:8080", "http://localhost:8080", ""},
+		{URL, "about:blank", "about:blank", ""},
+		{URL, "ftp://example.com", nil, "不支持的协议ftp"},
+		{URL, "https://", nil, "缺少主机名"},
+		{URL, "https://a b.com/%zz", nil, "不是有效的地址"},
+		{Selector, "#kw", "#kw", ""},
+		{Selector, "  ", nil, "选择器不能为空"},
+		{String, "", "", ""},
+	}
+	for _, tt := range tests {
+		got, err := convert(tt.t, tt.value)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("convert(%s, %q) error = %v, want %q", tt.t, tt.value, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("convert(%s, %q) = %v, %v, want %v", tt.t, tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	r := testRegistry(t)
+	tests := []struct {
+		line    string
+		column  int
+		message string
+	}{
+		{`"go" x`, 1, "行首应为命令名"},
+		{"url=x", 1, "行首应为命令名"},
+		{"  gp example.com", 3, `未知命令"gp"，是否要输入go？`},
+		{"fly", 1, `未知命令"fly"`},
+		{"go", 3, "缺少参数url"},
+		{"go   ", 3, "缺少参数url"},
+		{"go a.com b.com", 10, `多余的参数"b.com"`},
+		{"go ftp://a.com", 4, "参数url: 不支持的协议ftp"},
+		{"typed a.com x", 13, `参数rest: "x"不是整数`},
+		{"typed a.com count=x", 19, `参数count: "x"不是整数`},
+		{"typed 中文.com count=1 count=2", 22, "参数count重复"},
+		{"typed a.com cont=1", 13, "命令typed没有参数cont，是否要输入count？"},
+		{"typed a.com zzzzzz=1", 13, "命令typed没有参数zzzzzz，用法: typed"},
+		{`typed a.com at=""`, 16, "参数at: 选择器不能为空"},
+		{"typed a.com delay=1x", 19, `参数delay: "1x"不是有效的时长`},
+		{"typed a.com h_=1", 13, "命令typed没有参数h_"},
+		{"typed a.com count=-1", 7, "count不能为负数"},
+		{"wait", 5, "缺少参数condition"},
+		{"wait for url ~ (", 6, "无效的正则表达式"},
+		{"state drop x", 7, `未知的操作"drop"`},
+		{"state load x ttl=1h", 7, "ttl只能用于state save"},
+		{`login example.com success="wait 5s"`, 7, "无效的登录成功条件"},
+		{`login example.com success=5s`, 7, "登录成功条件不能是固定时长"},
+		{`script "unterminated`, 8, "引号\"没有结束"},
+	}
+	for _, tt := range tests {
+		_, err := r.Parse(tt.line)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q) error = %v, want *Error", tt.line, err)
+			continue
+		}
+		if perr.Column != tt.column || !strings.Contains(perr.Message, tt.message) {
+			t.Errorf("Parse(%q) error = %d %q, want %d %q", tt.line, perr.Column, perr.Message, tt.column, tt.message)
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, line := range []string{"", "   ", "\t"} {
+		if cmd, err := NewDefaultRegistry().Parse(line); cmd != nil || err != nil {
+			t.Errorf("Parse(%q) = %v, %v", line, cmd, err)
+		}
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	r := testRegistry(t)
+	vars := map[string]string{"${site}": "example.com", "${n}": "5", "${sel}": "#a b"}
+	expand := func(value string) (string, error) {
+		switch {
+		case value == "${later}":
+			return "", SkipValue
+		case value == "${bad}":
+			return "", fmt.Errorf("变量bad没有定义")
+		case vars[value] != "":
+			return vars[value], nil
+		}
+		return value, nil
+	}
+
+	cmd, err := r.ParseWith("typed ${site} ${n} count=${n} at=${sel}", expand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 替换结果中的空格不影响参数的划分
+	if cmd.String("target") != "https://example.com" || cmd.Int("count") != 5 || cmd.String("at") != "#a b" {
+		t.Fatalf("values = %q %d %q", cmd.String("target"), cmd.Int("count"), cmd.String("at"))
+	}
+
+	// 执行时才确定的值只检查参数名和必填参数，也不调用Validate
+	cmd, err = r.ParseWith("typed ${later} ${later} count=${later} h_x=${later}", expand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Has("target") || cmd.String("target") != "" || len(cmd.Prefixed("h_")) != 1 {
+		t.Fatalf("skipped values = %+v", cmd)
+	}
+	if _, err := r.ParseWith("typed ${later} count=${later} cont=1", expand); err == nil {
+		t.Fatal("unknown argument accepted")
+	}
+	if _, err := r.ParseWith("wait for ${later} bogus", expand); err != nil {
+		t.Fatalf("Validate called with skipped values: %v", err)
+	}
+
+	_, err = r.ParseWith("typed a.com count=${bad}", expand)
+	var perr *Error
+	if !errors.As(err, &perr) || perr.Column != 19 || perr.Message != "变量bad没有定义" {
+		t.Fatalf("expand error = %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := NewDefaultRegistry()
+	tests := []struct {
+		spec *Spec
+		err  string
+	}{
+		{&Spec{Name: "Go"}, "无效的命令名"},
+		{&Spec{Name: "a b"}, "无效的命令名"},
+		{&Spec{Name: ""}, "无效的命令名"},
+		{&Spec{Name: "go"}, "命令go已注册"},
+		{&Spec{Name: "open", Aliases: []string{"navigate"}}, "navigate已是命令go的别名"},
+		{&Spec{Name: "x", Args: []Arg{{Name: "a", Positional: true}, {Name: "b", Positional: true, Required: true}}}, "不能在可选位置参数之后"},
+		{&Spec{Name: "y", Args: []Arg{{Name: "a", Positional: true, Variadic: true}, {Name: "b", Positional: true}}}, "必须是最后一个位置参数"},
+	}
+	for _, tt := range tests {
+		if err := r.Register(tt.spec); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Register(%s) error = %v, want %q", tt.spec.Name, err, tt.err)
+		}
+	}
+	if _, ok := r.Lookup("x"); ok {
+		t.Fatal("invalid spec registered")
+	}
+	if spec, ok := r.Lookup("QUIT"); !ok || spec.Name != "exit" {
+		t.Fatalf("Lookup(QUIT) = %v, %v", spec, ok)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	r := testRegistry(t)
+	tests := map[string]string{
+		"search": "search <url> query=<query> input=<selector> [button=<selector>]",
+		"form":   "form [<url>] [form=<selector>] [field_<name>=<value>...] [submit=<selector>]",
+		"wait":   "wait <condition>... [timeout=<duration>]",
+		"typed":  "typed <target> [<rest>...] [count=<count>] [enabled=<enabled>] [delay=<delay>] [at=<at>] [h_<name>=<h_>...]",
+	}
+	for name, want := range tests {
+		spec, _ := r.Lookup(name)
+		if got := spec.Usage(); got != want {
+			t.Errorf("Usage(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestIsProfileName(t *testing.T) {
+	for site, want := range map[string]bool{
+		"intranet": true, "crm_prod-2": true, "example.com": false, "https://a": false, "": false, "中文": false,
+	} {
+		if got := IsProfileName(site); got != want {
+			t.Errorf("IsProfileName(%q) = %v, want %v", site, got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// wait命令等待的内容
+const (
+	// WaitDuration 固定等待一段时间
+	WaitDuration = "duration"
+	// WaitSelector 等待元素出现、显示或隐藏
+	WaitSelector = "selector"
+	// WaitURL 等待地址匹配正则表达式
+	WaitURL = "url"
+	// WaitText 等待页面上显示文字
+	WaitText = "text"
+	// WaitNetworkIdle 等待网络空闲
+	WaitNetworkIdle = "network idle"
+	// WaitFunction 等待JavaScript表达式的结果为真
+	WaitFunction = "until"
+)
+
+// 等待元素时可以指定的状态，省略时为visible
+var waitStates = []string{"visible", "hidden", "attached"}
+
+// WaitCondition 解析后的wait条件
+type WaitCondition struct {
+	Kind     string
+	Duration time.Duration
+	// Selector、State 等待的元素和状态
+	Selector string
+	State    string
+	// Pattern 地址要匹配的正则表达式
+	Pattern string
+	// Text 页面上要出现的文字
+	Text string
+	// Expression JavaScript表达式
+	Expression string
+}
+
+// String 返回条件的说明，用于结果和错误信息
+func (w *WaitCondition) String() string {
+	switch w.Kind {
+	case WaitSelector:
+		return fmt.Sprintf("%s %s", w.Selector, w.State)
+	case WaitURL:
+		return "地址匹配" + w.Pattern
+	case WaitText:
+		return fmt.Sprintf("文字%q", w.Text)
+	case WaitNetworkIdle:
+		return "网络空闲"
+	case WaitFunction:
+		return w.Expression
+	}
+	return w.Duration.String()
+}
+
+// ParseWaitCondition 解析wait命令的位置参数：
+//
+//	<时长>
+//	for <选择器> [visible|hidden|attached]
+//	for url ~ <正则表达式>
+//	for text <文字>
+//	for network idle
+//	until <JavaScript表达式>
+func ParseWaitCondition(words []string) (*WaitCondition, error) {
+	const usage = "应为<时长>、for <选择器> [visible|hidden|attached]、for url ~ <正则>、for text <文字>、for network idle或until <表达式>"
+	if len(words) == 0 {
+		return nil, fmt.Errorf("缺少等待条件，%s", usage)
+	}
+	switch words[0] {
+	case "until":
+		if len(words) != 2 || strings.TrimSpace(words[1]) == "" {
+			return nil, fmt.Errorf("until后面应为一个JavaScript表达式，包含空格时加引号")
+		}
+		return &WaitCondition{Kind: WaitFunction, Expression: words[1]}, nil
+	case "for":
+	default:
+		if len(words) != 1 {
+			return nil, fmt.Errorf("无法识别的等待条件，%s", usage)
+		}
+		d, err := ParseDuration(words[0])
+		if err != nil {
+			return nil, fmt.Errorf("%v；等待条件%s", err, usage)
+		}
+		return &WaitCondition{Kind: WaitDuration, Duration: d}, nil
+	}
+
+	words = words[1:]
+	if len(words) == 0 {
+		return nil, fmt.Errorf("for后面缺少等待的内容，%s", usage)
+	}
+	switch words[0] {
+	case "url":
+		if len(words) != 3 || words[1] != "~" {
+			return nil, fmt.Errorf("用法: wait for url ~ <正则表达式>")
+		}
+		if _, err := regexp.Compile(words[2]); err != nil {
+			return nil, fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		return &WaitCondition{Kind: WaitURL, Pattern: words[2]}, nil
+	case "text":
+		if len(words) != 2 || strings.TrimSpace(words[1]) == "" {
+			return nil, fmt.Errorf("用法: wait for text <文字>，文字包含空格时加引号")
+		}
+		return &WaitCondition{Kind: WaitText, Text: words[1]}, nil
+	case "network":
+		if len(words) != 2 || words[1] != "idle" {
+			return nil, fmt.Errorf("用法: wait for network idle")
+		}
+		return &WaitCondition{Kind: WaitNetworkIdle}, nil
+	}
+
+	cond := &WaitCondition{Kind: WaitSelector, Selector: words[0], State: waitStates[0]}
+	if strings.TrimSpace(cond.Selector) == "" {
+		return nil, fmt.Errorf("选择器不能为空")
+	}
+	switch len(words) {
+	case 1:
+	case 2:
+		if !contains(waitStates, words[1]) {
+			return nil, fmt.Errorf("无效的元素状态%q，可用: %s；选择器包含空格时加引号", words[1], strings.Join(waitStates, "、"))
+		}
+		cond.State = words[1]
+	default:
+		return nil, fmt.Errorf("用法: wait for <选择器> [visible|hidden|attached]，选择器包含空格时加引号")
+	}
+	return cond, nil
+}
+
+// ParseWaitString 解析写在一个字符串中的等待条件，例如站点配置中的`for url ~ /home`，
+// 引号和转义的规则与命令行相同
+func ParseWaitString(s string) (*WaitCondition, error) {
+	tokens, err := Tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if t.Key != "" {
+			words = append(words, t.Key+"="+t.Value)
+		} else {
+			words = append(words, t.Value)
+		}
+	}
+	return ParseWaitCondition(words)
+}
+
+// ParseSuccessCondition 解析登录成功的条件，固定时长无法说明登录是否成功，不能作为条件
+func ParseSuccessCondition(s string) (*WaitCondition, error) {
+	cond, err := ParseWaitString(s)
+	if err != nil {
+		return nil, fmt.Errorf("无效的登录成功条件: %v", err)
+	}
+	if cond.Kind == WaitDuration {
+		return nil, fmt.Errorf("登录成功条件不能是固定时长，应为for <选择器>、for url ~ <正则>、for text <文字>或until <表达式>")
+	}
+	return cond, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
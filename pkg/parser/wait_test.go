@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWaitCondition(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  WaitCondition
+		str   string
+	}{
+		{[]string{"5s"}, WaitCondition{Kind: WaitDuration, Duration: 5 * time.Second}, "5s"},
+		{[]string{"0.5"}, WaitCondition{Kind: WaitDuration, Duration: 500 * time.Millisecond}, "500ms"},
+		{[]string{"for", "#result"}, WaitCondition{Kind: WaitSelector, Selector: "#result", State: "visible"}, "#result visible"},
+		{[]string{"for", ".loading", "hidden"}, WaitCondition{Kind: WaitSelector, Selector: ".loading", State: "hidden"}, ".loading hidden"},
+		{[]string{"for", "div span", "attached"}, WaitCondition{Kind: WaitSelector, Selector: "div span", State: "attached"}, "div span attached"},
+		{[]string{"for", "url", "~", `/dash\d+`}, WaitCondition{Kind: WaitURL, Pattern: `/dash\d+`}, `地址匹配/dash\d+`},
+		{[]string{"for", "text", "保存 成功"}, WaitCondition{Kind: WaitText, Text: "保存 成功"}, `文字"保存 成功"`},
+		{[]string{"for", "network", "idle"}, WaitCondition{Kind: WaitNetworkIdle}, "网络空闲"},
+		{[]string{"until", "window.ready === true"}, WaitCondition{Kind: WaitFunction, Expression: "window.ready === true"}, "window.ready === true"},
+		// text:等带前缀的选择器按普通选择器处理，不是for text
+		{[]string{"for", "text:登录"}, WaitCondition{Kind: WaitSelector, Selector: "text:登录", State: "visible"}, "text:登录 visible"},
+	}
+	for _, tt := range tests {
+		cond, err := ParseWaitCondition(tt.words)
+		if err != nil {
+			t.Errorf("ParseWaitCondition(%q): %v", tt.words, err)
+			continue
+		}
+		if *cond != tt.want {
+			t.Errorf("ParseWaitCondition(%q) = %+v, want %+v", tt.words, *cond, tt.want)
+		}
+		if got := cond.String(); got != tt.str {
+			t.Errorf("ParseWaitCondition(%q).String() = %q, want %q", tt.words, got, tt.str)
+		}
+	}
+}
+
+func TestParseWaitConditionErrors(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{nil, "缺少等待条件"},
+		{[]string{"soon"}, `"soon"不是有效的时长`},
+		{[]string{"-1s"}, "时长不能为负数"},
+		{[]string{"5s", "10s"}, "无法识别的等待条件"},
+		{[]string{"until"}, "until后面应为一个JavaScript表达式"},
+		{[]string{"until", " "}, "until后面应为一个JavaScript表达式"},
+		{[]string{"until", "a", "b"}, "until后面应为一个JavaScript表达式"},
+		{[]string{"for"}, "for后面缺少等待的内容"},
+		{[]string{"for", "url", "/home"}, "用法: wait for url ~ <正则表达式>"},
+		{[]string{"for", "url", "=", "/home"}, "用法: wait for url ~ <正则表达式>"},
+		{[]string{"for", "url", "~", "("}, "无效的正则表达式"},
+		{[]string{"for", "text"}, "用法: wait for text <文字>"},
+		{[]string{"for", "text", "a", "b"}, "用法: wait for text <文字>"},
+		{[]string{"for", "network"}, "用法: wait for network idle"},
+		{[]string{"for", "network", "busy"}, "用法: wait for network idle"},
+		{[]string{"for", " "}, "选择器不能为空"},
+		{[]string{"for", "#a", "shown"}, `无效的元素状态"shown"`},
+		{[]string{"for", "div", "span", "visible"}, "选择器包含空格时加引号"},
+	}
+	for _, tt := range tests {
+		_, err := ParseWaitCondition(tt.words)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseWaitCondition(%q) error = %v, want %q", tt.words, err, tt.want)
+		}
+	}
+}
+
+func TestParseWaitString(t *testing.T) {
+	cond, err := ParseWaitString(`for text "欢迎 回来"`)
+	if err != nil || cond.Kind != WaitText || cond.Text != "欢迎 回来" {
+		t.Fatalf("ParseWaitString = %+v, %v", cond, err)
+	}
+	// key=value形式的词原样保留
+	cond, err = ParseWaitString(`for [name=q] hidden`)
+	if err != nil || cond.Selector != "[name=q]" || cond.State != "hidden" {
+		t.Fatalf("ParseWaitString = %+v, %v", cond, err)
+	}
+	cond, err = ParseWaitString(`until "document.title == 'x'"`)
+	if err != nil || cond.Expression != "document.title == 'x'" {
+		t.Fatalf("ParseWaitString = %+v, %v", cond, err)
+	}
+	if _, err := ParseWaitString(`for text "open`); err == nil || !strings.Contains(err.Error(), "没有结束") {
+		t.Fatalf("unterminated quote error = %v", err)
+	}
+}
+
+func TestParseSuccessCondition(t *testing.T) {
+	tests := []struct {
+		s    string
+		kind string
+		err  string
+	}{
+		{"for url ~ /home", WaitURL, ""},
+		{"for #logout", WaitSelector, ""},
+		{"for .avatar attached", WaitSelector, ""},
+		{`for text "退出登录"`, WaitText, ""},
+		{"for network idle", WaitNetworkIdle, ""},
+		{"until window.user", WaitFunction, ""},
+		{"5s", "", "登录成功条件不能是固定时长"},
+		{"", "", "无效的登录成功条件: 缺少等待条件"},
+		{"for url ~ (", "", "无效的登录成功条件: 无效的正则表达式"},
+		{`for text "x`, "", "无效的登录成功条件: 第10列: 引号\"没有结束"},
+	}
+	for _, tt := range tests {
+		cond, err := ParseSuccessCondition(tt.s)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseSuccessCondition(%q) error = %v, want %q", tt.s, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || cond.Kind != tt.kind {
+			t.Errorf("ParseSuccessCondition(%q) = %+v, %v, want kind %s", tt.s, cond, err, tt.kind)
+		}
+	}
+}
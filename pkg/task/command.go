@@ -0,0 +1,67 @@
+package task
+
+import (
+	"fmt"
+
+	"GoBrowserAgent/pkg/parser"
+)
+
+// FromCommand 把解析后的内置命令转换为任务；exit、help等不操作页面的命令返回错误
+func FromCommand(cmd *parser.Command) (Task, error) {
+	switch cmd.Name {
+	case "go":
+		return &NavigateTask{URL: cmd.String("url")}, nil
+	case "login":
+		t := &LoginTask{
+			Username:         cmd.String("username"),
+			Password:         cmd.String("password"),
+			UsernameSelector: cmd.String("username_selector"),
+			PasswordSelector: cmd.String("password_selector"),
+			SubmitSelector:   cmd.String("submit"),
+			Fresh:            cmd.Bool("fresh"),
+		}
+		if site := cmd.String("site"); parser.IsProfileName(site) {
+			t.Profile = site
+		} else {
+			u, err := parser.NormalizeURL(site)
+			if err != nil {
+				return nil, err
+			}
+			t.URL = u
+		}
+		if cmd.Has("success") {
+			cond, err := parser.ParseSuccessCondition(cmd.String("success"))
+			if err != nil {
+				return nil, err
+			}
+			t.Success = cond
+		}
+		return t, nil
+	case "state":
+		return &StateTask{Action: cmd.String("action"), StateName: cmd.String("name"), TTL: cmd.Duration("ttl")}, nil
+	case "screenshot":
+		return &ScreenshotTask{Path: cmd.String("path")}, nil
+	case "wait":
+		cond, err := parser.ParseWaitCondition(cmd.Strings("condition"))
+		if err != nil {
+			return nil, err
+		}
+		return &WaitTask{Condition: *cond, Timeout: cmd.Duration("timeout")}, nil
+	case "search":
+		return &SearchTask{
+			URL:    cmd.String("url"),
+			Query:  cmd.String("query"),
+			Input:  cmd.String("input"),
+			Button: cmd.String("button"),
+		}, nil
+	case "form":
+		t := &FormTask{URL: cmd.String("url"), Form: cmd.String("form"), Submit: cmd.String("submit")}
+		for _, kv := range cmd.Prefixed("field_") {
+			t.Fields = append(t.Fields, Field{Name: kv.Key, Value: kv.Value})
+		}
+		return t, nil
+	case "script":
+		return &ScriptTask{Script: cmd.String("code")}, nil
+	}
+	return nil, fmt.Errorf("命令%s不是页面任务", cmd.Name)
+}
@@ -0,0 +1,99 @@
+package task
+
+import (
+	"context"
+	"fmt"
+
+	"GoBrowserAgent/pkg/browser"
+)
+
+// Field 表单中的一个字段
+type Field struct {
+	// Name 字段的name属性；以#、.或[开头时作为选择器
+	Name  string
+	Value string
+}
+
+// FormTask 填写并提交表单
+type FormTask struct {
+	// URL 表单所在的页面，为空时使用当前页面
+	URL string
+	// Form 表单的选择器，设置后只在该表单内查找字段
+	Form   string
+	Fields []Field
+	// Submit 提交按钮的选择器；为空时提交表单本身，既没有表单也没有字段时只填写不提交
+	Submit string
+}
+
+func (t *FormTask) Name() string { return "form" }
+
+func (t *FormTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	if t.URL != "" {
+		if err := page.Navigate(ctx, t.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	var first string
+	for _, field := range t.Fields {
+		selector := nameSelector(field.Name)
+		if t.Form != "" {
+			selector = t.Form + " >> " + selector
+		}
+		if first == "" {
+			first = selector
+		}
+		if err := page.Fill(ctx, selector, field.Value); err != nil {
+			return nil, fmt.Errorf("填写字段%s失败: %v", field.Name, err)
+		}
+	}
+
+	submit := t.Submit
+	switch {
+	case submit != "":
+	case t.Form != "":
+		submit = t.Form
+	default:
+		submit = first
+	}
+	if submit != "" {
+		if err := page.Submit(ctx, submit); err != nil {
+			return nil, fmt.Errorf("提交表单失败: %v", err)
+		}
+	}
+	return newResult(ctx, page, "表单填写成功!"), nil
+}
+
+// SearchTask 在搜索框中输入关键词并提交
+type SearchTask struct {
+	URL   string
+	Query string
+	// Input 搜索框的选择器
+	Input string
+	// Button 搜索按钮的选择器，为空时直接提交搜索框所在的表单
+	Button string
+}
+
+func (t *SearchTask) Name() string { return "search" }
+
+func (t *SearchTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	if t.Input == "" {
+		return nil, fmt.Errorf("缺少搜索框的选择器")
+	}
+	if t.URL != "" {
+		if err := page.Navigate(ctx, t.URL); err != nil {
+			return nil, err
+		}
+	}
+	if err := page.Fill(ctx, t.Input, t.Query); err != nil {
+		return nil, fmt.Errorf("输入搜索关键词失败: %v", err)
+	}
+	submit := t.Button
+	if submit == "" {
+		submit = t.Input
+	}
+	if err := page.Submit(ctx, submit); err != nil {
+		return nil, fmt.Errorf("提交搜索失败: %v", err)
+	}
+	return newResult(ctx, page, "搜索成功!"), nil
+}
@@ -0,0 +1,285 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"GoBrowserAgent/pkg/browser"
+	"GoBrowserAgent/pkg/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// usernameSelectors 未指定用户名输入框时按顺序尝试的选择器
+var usernameSelectors = []string{
+	`input[autocomplete="username"]`,
+	`input[type="email"]`,
+	`input[name*="user" i]`,
+	`input[id*="user" i]`,
+	`input[name*="login" i]`,
+	`input[name*="account" i]`,
+	`input[name*="email" i]`,
+	`input[name*="phone" i]`,
+	`input[type="text"]`,
+	`input:not([type])`,
+}
+
+// passwordSelectors 未指定密码输入框时使用的选择器
+var passwordSelectors = []string{`input[type="password"]`}
+
+// submitButtons 输入框不在表单中（常见于单页应用）且未指定登录按钮时按顺序尝试的按钮，
+// 按钮名称按包含匹配并忽略大小写
+var submitButtons = []string{
+	`button[type="submit"]`,
+	`input[type="submit"]`,
+	`role:button[name=登录]`,
+	`role:button[name=log in]`,
+	`role:button[name=login]`,
+	`role:button[name=sign in]`,
+	`role:button[name=下一步]`,
+	`role:button[name=next]`,
+	`role:button[name=continue]`,
+}
+
+// errNoFields 登录页上没有用户名或密码输入框
+var errNoFields = errors.New("页面上没有找到用户名或密码输入框，请指定选择器")
+
+// verifyTimeout 没有设置成功条件时，等待密码输入框消失的最长时间
+const verifyTimeout = 10 * time.Second
+
+// SessionStore 按名称保存浏览器状态，由凭据保险库实现
+type SessionStore interface {
+	// LoadState 返回保存的状态，没有保存过或者已经过期时返回nil
+	LoadState(name string) (*browser.StorageState, error)
+	// OpenState 返回保存的状态和过期时间，没有保存过或者已经过期时返回说明原因的错误
+	OpenState(name string) (*browser.StorageState, time.Time, error)
+	// SaveState 保存状态，ttl后过期，为0时使用默认的有效期；返回实际的过期时间
+	SaveState(name string, state *browser.StorageState, ttl time.Duration) (time.Time, error)
+	// DeleteState 删除保存的状态
+	DeleteState(name string) error
+}
+
+// LoginTask 打开登录页，填写用户名和密码并提交，成功后保存登录状态
+type LoginTask struct {
+	// Profile 站点配置的名称，由执行器从凭据保险库补全其余字段
+	Profile  string
+	URL      string
+	Username string
+	Password string
+	// UsernameSelector、PasswordSelector 输入框的选择器，为空时自动查找
+	UsernameSelector string
+	PasswordSelector string
+	// SubmitSelector 登录按钮的选择器，为空时提交密码框所在的表单或点击页面上的登录按钮
+	SubmitSelector string
+	// Success 登录成功的条件，为nil时等待密码输入框消失
+	Success *parser.WaitCondition
+	// Sessions 保存登录状态的位置，为nil时每次都重新登录
+	Sessions SessionStore
+	// Session 登录状态的名称，SessionTTL 登录状态的有效期
+	Session    string
+	SessionTTL time.Duration
+	// Fresh 忽略保存的登录状态，重新登录
+	Fresh bool
+}
+
+func (t *LoginTask) Name() string { return "login" }
+
+func (t *LoginTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	if t.URL == "" {
+		return nil, fmt.Errorf("缺少登录页地址")
+	}
+	saving := t.Sessions != nil && t.Session != ""
+	if saving && !t.Fresh {
+		restored, err := t.restore(ctx, page)
+		if err != nil {
+			logrus.Warnf("复用%s的登录状态失败，将重新登录: %v", t.Session, err)
+		}
+		if restored {
+			return newResult(ctx, page, "已复用保存的登录状态"), nil
+		}
+	}
+	if t.Password == "" {
+		return nil, fmt.Errorf("缺少密码，请用vault set保存站点配置，或在命令中给出password")
+	}
+
+	if err := page.Navigate(ctx, t.URL); err != nil {
+		return nil, err
+	}
+	passwordSelector, err := t.fillFields(ctx, page)
+	if errors.Is(err, errNoFields) && t.verify(ctx, page, "") == nil {
+		// 登录页直接跳转到了登录后的页面，浏览器已经处于登录状态
+		return t.finish(ctx, page, "页面已经处于登录状态")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := t.submit(ctx, page, passwordSelector); err != nil {
+		return nil, fmt.Errorf("提交登录表单失败: %v", err)
+	}
+	if err := t.verify(ctx, page, passwordSelector); err != nil {
+		return nil, fmt.Errorf("登录失败: %v", err)
+	}
+	return t.finish(ctx, page, "登录成功")
+}
+
+// finish 保存登录状态并返回结果
+func (t *LoginTask) finish(ctx context.Context, page browser.Page, message string) (*Result, error) {
+	if t.Sessions == nil || t.Session == "" {
+		return newResult(ctx, page, message+"!"), nil
+	}
+	expires, err := saveState(ctx, page, t.Sessions, t.Session, t.SessionTTL)
+	if err != nil {
+		logrus.Warnf("保存%s的登录状态失败: %v", t.Session, err)
+		return newResult(ctx, page, message+"!"), nil
+	}
+	return newResult(ctx, page, fmt.Sprintf("%s，登录状态已保存，%s过期", message, formatTime(expires))), nil
+}
+
+// restore 恢复保存的登录状态并打开登录页，确认仍然处于登录状态；服务端已经失效的状态会被删除
+func (t *LoginTask) restore(ctx context.Context, page browser.Page) (bool, error) {
+	state, err := t.Sessions.LoadState(t.Session)
+	if err != nil || state == nil {
+		return false, err
+	}
+	if err := page.SetStorageState(ctx, state); err != nil {
+		return false, err
+	}
+	if err := page.Navigate(ctx, t.URL); err != nil {
+		return false, err
+	}
+	if t.Success == nil {
+		// 单页应用的登录表单在脚本运行后才渲染，等页面稳定后再判断密码输入框是否存在
+		idleCtx, cancel := context.WithTimeout(ctx, verifyTimeout)
+		page.WaitForNetworkIdle(idleCtx)
+		cancel()
+	}
+	if err := t.verify(ctx, page, ""); err != nil {
+		logrus.Infof("%s保存的登录状态已失效: %v", t.Session, err)
+		return false, t.Sessions.DeleteState(t.Session)
+	}
+	return true, nil
+}
+
+// fillFields 填写用户名和密码，返回密码输入框的选择器
+//
+// 页面上只有用户名输入框时按两步登录处理：先提交用户名，再等待密码输入框出现。
+func (t *LoginTask) fillFields(ctx context.Context, page browser.Page) (string, error) {
+	passwordCandidates := candidates(t.PasswordSelector, passwordSelectors)
+	usernameCandidates := candidates(t.UsernameSelector, usernameSelectors)
+	all := append(append([]string(nil), passwordCandidates...), usernameCandidates...)
+	if err := waitAnyVisible(ctx, page, all); err != nil {
+		if errors.Is(err, browser.ErrConditionNotMet) {
+			return "", errNoFields
+		}
+		return "", err
+	}
+
+	passwordSelector, err := firstVisible(ctx, page, passwordCandidates)
+	if err != nil {
+		return "", err
+	}
+	if t.Username != "" {
+		usernameSelector, err := firstVisible(ctx, page, usernameCandidates)
+		if err != nil {
+			return "", err
+		}
+		if usernameSelector == "" && passwordSelector == "" {
+			return "", errNoFields
+		}
+		if usernameSelector != "" {
+			if err := page.Fill(ctx, usernameSelector, t.Username); err != nil {
+				return "", fmt.Errorf("填写用户名失败: %v", err)
+			}
+		}
+		if passwordSelector == "" {
+			if err := submitField(ctx, page, usernameSelector); err != nil {
+				return "", fmt.Errorf("提交用户名失败: %v", err)
+			}
+			if err := waitAnyVisible(ctx, page, passwordCandidates); err != nil {
+				return "", fmt.Errorf("提交用户名后没有出现密码输入框: %v", err)
+			}
+			if passwordSelector, err = firstVisible(ctx, page, passwordCandidates); err != nil {
+				return "", err
+			}
+		}
+	}
+	if passwordSelector == "" {
+		return "", errNoFields
+	}
+	if err := page.Fill(ctx, passwordSelector, t.Password); err != nil {
+		return "", fmt.Errorf("填写密码失败: %v", err)
+	}
+	return passwordSelector, nil
+}
+
+// submit 点击配置的登录按钮，未配置时提交密码输入框
+func (t *LoginTask) submit(ctx context.Context, page browser.Page, passwordSelector string) error {
+	if t.SubmitSelector == "" {
+		return submitField(ctx, page, passwordSelector)
+	}
+	err := page.Submit(ctx, t.SubmitSelector)
+	if errors.Is(err, browser.ErrNotInForm) {
+		return page.Click(ctx, t.SubmitSelector)
+	}
+	return err
+}
+
+// submitField 提交输入框所在的表单，输入框不在表单中时点击页面上的登录按钮
+func submitField(ctx context.Context, page browser.Page, field string) error {
+	err := page.Submit(ctx, field)
+	if !errors.Is(err, browser.ErrNotInForm) {
+		return err
+	}
+	button, err := firstVisible(ctx, page, submitButtons)
+	if err != nil {
+		return err
+	}
+	if button == "" {
+		return fmt.Errorf("%s不在表单中，页面上也没有找到登录按钮，请指定submit", field)
+	}
+	return page.Click(ctx, button)
+}
+
+// verify 等待登录成功的条件；没有设置条件时，密码输入框在verifyTimeout内消失视为成功
+func (t *LoginTask) verify(ctx context.Context, page browser.Page, passwordSelector string) error {
+	if t.Success != nil {
+		return waitFor(ctx, page, *t.Success)
+	}
+	if passwordSelector == "" {
+		passwordSelector = candidates(t.PasswordSelector, passwordSelectors)[0]
+	}
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	err := page.WaitForSelector(ctx, passwordSelector, browser.WaitHidden)
+	if errors.Is(err, browser.ErrConditionNotMet) {
+		return fmt.Errorf("页面上仍有密码输入框")
+	}
+	return err
+}
+
+// candidates 返回配置的选择器，未配置时返回自动查找的候选
+func candidates(configured string, defaults []string) []string {
+	if configured != "" {
+		return []string{configured}
+	}
+	return defaults
+}
+
+// selectorEngine 匹配带引擎前缀或>>的选择器，这样的选择器不能合并到CSS选择器列表中
+var selectorEngine = regexp.MustCompile(`^\s*(css|xpath|text|role|label|placeholder):|^\s*\(?//|>>`)
+
+// waitAnyVisible 等待任意一个选择器匹配到可见元素
+//
+// 候选合并为一个CSS选择器列表等待；指定了其他引擎的选择器时无法合并，不等待，由调用方直接查找。
+func waitAnyVisible(ctx context.Context, page browser.Page, selectors []string) error {
+	for _, s := range selectors {
+		if selectorEngine.MatchString(s) {
+			return nil
+		}
+	}
+	return page.WaitForSelector(ctx, strings.Join(selectors, ", "), browser.WaitVisible)
+}
@@ -0,0 +1,130 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"GoBrowserAgent/pkg/browser"
+	"GoBrowserAgent/pkg/parser"
+)
+
+// NavigateTask 打开地址
+type NavigateTask struct {
+	URL string
+}
+
+func (t *NavigateTask) Name() string { return "go" }
+
+func (t *NavigateTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	if err := page.Navigate(ctx, t.URL); err != nil {
+		return nil, err
+	}
+	result := newResult(ctx, page, "")
+	result.Message = "已导航到: " + result.URL
+	return result, nil
+}
+
+// WaitTask 固定等待一段时间，或者等待条件满足
+type WaitTask struct {
+	Condition parser.WaitCondition
+	// Timeout 等待条件的最长时间，为0时使用浏览器配置中的timeout
+	Timeout time.Duration
+}
+
+func (t *WaitTask) Name() string { return "wait" }
+
+func (t *WaitTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	cond := t.Condition
+	if cond.Kind == parser.WaitDuration {
+		timer := time.NewTimer(cond.Duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return &Result{Message: fmt.Sprintf("已等待%s", cond.Duration)}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	if err := waitFor(ctx, page, cond); err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	return newResult(ctx, page, fmt.Sprintf("已等到%s，用时%s", cond.String(), elapsed)), nil
+}
+
+// waitFor 等待固定时长以外的条件满足，wait命令和登录成功的判断共用
+func waitFor(ctx context.Context, page browser.Page, cond parser.WaitCondition) error {
+	switch cond.Kind {
+	case parser.WaitSelector:
+		return page.WaitForSelector(ctx, cond.Selector, browser.WaitState(cond.State))
+	case parser.WaitText:
+		return page.WaitForSelector(ctx, "text:"+cond.Text, browser.WaitVisible)
+	case parser.WaitURL:
+		pattern, err := regexp.Compile(cond.Pattern)
+		if err != nil {
+			return fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		return page.WaitForURL(ctx, pattern)
+	case parser.WaitNetworkIdle:
+		return page.WaitForNetworkIdle(ctx)
+	case parser.WaitFunction:
+		return page.WaitForFunction(ctx, cond.Expression)
+	}
+	return fmt.Errorf("未知的等待条件: %s", cond.Kind)
+}
+
+// ScreenshotTask 截取当前页面并保存为PNG文件
+type ScreenshotTask struct {
+	// Path 保存路径，为空时按时间生成文件名
+	Path string
+}
+
+func (t *ScreenshotTask) Name() string { return "screenshot" }
+
+func (t *ScreenshotTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	data, err := page.Screenshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path := t.Path
+	if path == "" {
+		path = fmt.Sprintf("screenshot_%s.png", time.Now().Format("20060102_150405"))
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建截图目录失败: %v", err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("保存截图失败: %v", err)
+	}
+	return newResult(ctx, page, "截图已保存到: "+path), nil
+}
+
+// ScriptTask 在页面中执行JavaScript
+type ScriptTask struct {
+	Script string
+}
+
+func (t *ScriptTask) Name() string { return "script" }
+
+func (t *ScriptTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	value, err := page.Evaluate(ctx, t.Script)
+	if err != nil {
+		return nil, err
+	}
+	result := newResult(ctx, page, "脚本执行成功!")
+	result.Data = value
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"GoBrowserAgent/pkg/browser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// state命令的操作
+const (
+	StateSave = "save"
+	StateLoad = "load"
+)
+
+// expiryWarning 恢复的状态在这段时间内过期时给出提醒
+const expiryWarning = time.Hour
+
+// StateTask 把当前浏览器的Cookie和页面存储加密保存到指定名称下，或者恢复保存的状态
+//
+// 保存的状态与login命令保存的登录状态是同一份，名称相同时可以互相使用。
+type StateTask struct {
+	Action string
+	// StateName 状态的名称
+	StateName string
+	// TTL 保存的状态的有效期，为0时使用默认的有效期
+	TTL time.Duration
+	// States 保存状态的位置，由执行器设置
+	States SessionStore
+}
+
+func (t *StateTask) Name() string { return "state" }
+
+func (t *StateTask) Run(ctx context.Context, page browser.Page) (*Result, error) {
+	if t.States == nil {
+		return nil, fmt.Errorf("未配置凭据保险库，无法保存和恢复状态")
+	}
+	switch t.Action {
+	case StateSave:
+		expires, err := saveState(ctx, page, t.States, t.StateName, t.TTL)
+		if err != nil {
+			return nil, err
+		}
+		return newResult(ctx, page, fmt.Sprintf("已保存状态%s，%s过期", t.StateName, formatTime(expires))), nil
+	case StateLoad:
+		state, expires, err := t.States.OpenState(t.StateName)
+		if err != nil {
+			return nil, err
+		}
+		if err := page.SetStorageState(ctx, state); err != nil {
+			return nil, fmt.Errorf("恢复状态失败: %v", err)
+		}
+		if left := time.Until(expires); left < expiryWarning {
+			logrus.Warnf("状态%s将在%s后过期，请及时重新登录并保存", t.StateName, left.Round(time.Minute))
+		}
+		return newResult(ctx, page, fmt.Sprintf("已恢复状态%s: %d个Cookie，%d个来源的存储，%s过期",
+			t.StateName, len(state.Cookies), len(state.Origins), formatTime(expires))), nil
+	}
+	return nil, fmt.Errorf("未知的state操作%q，可用: save、load", t.Action)
+}
+
+// saveState 读取页面的状态并保存，返回过期时间
+func saveState(ctx context.Context, page browser.Page, store SessionStore, name string, ttl time.Duration) (time.Time, error) {
+	state, err := page.StorageState(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return store.SaveState(name, state, ttl)
+}
+
+// formatTime 按本地时间格式化过期时间
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,62 @@
+// Package task 定义在浏览器页面上执行的任务
+//
+// 任务只依赖browser.Page接口，因此同样的导航、登录、表单和搜索任务既可以在Chromium中运行，
+// 也可以在不执行JavaScript的HTTP后端中运行。
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"GoBrowserAgent/pkg/browser"
+)
+
+// Task 在页面上执行的一个任务
+type Task interface {
+	// Name 返回任务名称，与命令名相同
+	Name() string
+	// Run 在页面上执行任务
+	Run(ctx context.Context, page browser.Page) (*Result, error)
+}
+
+// Result 任务的执行结果
+type Result struct {
+	// Message 给用户看的结果说明
+	Message string `json:"message"`
+	// URL、Title 任务结束时页面的地址和标题
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+	// Data 任务返回的数据，例如脚本的执行结果
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// newResult 创建结果并记录页面当前的地址和标题
+func newResult(ctx context.Context, page browser.Page, message string) *Result {
+	result := &Result{Message: message}
+	result.URL, _ = page.URL(ctx)
+	result.Title, _ = page.Title(ctx)
+	return result
+}
+
+// firstVisible 返回第一个能匹配到可见元素的选择器，都没有匹配时返回空字符串
+func firstVisible(ctx context.Context, page browser.Page, selectors []string) (string, error) {
+	for _, selector := range selectors {
+		elements, err := page.Query(ctx, selector)
+		if err != nil {
+			return "", err
+		}
+		if len(elements) > 0 && elements[0].Visible {
+			return selector, nil
+		}
+	}
+	return "", nil
+}
+
+// nameSelector 返回按name属性匹配的选择器；以#、.或[开头的名称直接作为选择器
+func nameSelector(name string) string {
+	if strings.HasPrefix(name, "#") || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "[") {
+		return name
+	}
+	return `[name="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"]`
+}
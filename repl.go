@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"GoBrowserAgent/internal/command"
+	"GoBrowserAgent/internal/httpclient"
+	"GoBrowserAgent/internal/repl"
+	"GoBrowserAgent/internal/vault"
+	"GoBrowserAgent/pkg/browser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// historyFile 交互模式的命令历史，保存在用户主目录下
+const historyFile = ".gobrowseragent_history"
+
+// runREPL 进入交互模式，返回进程退出码
+func runREPL(configPath string) int {
+	executor, err := newExecutor(configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, historyFile)
+	} else {
+		logrus.Warnf("无法确定用户主目录，命令历史不会保存: %v", err)
+	}
+
+	if err := repl.New(executor, historyPath).Run(); err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	return 0
+}
+
+// newExecutor 加载浏览器、出站HTTP和凭据保险库配置并创建命令执行器
+func newExecutor(configPath string) (*command.Executor, error) {
+	browserConfig, transport, v, err := loadBrowserSetup(configPath)
+	if err != nil {
+		return nil, err
+	}
+	executor := command.NewExecutor(browserConfig, transport)
+	executor.Vault = v
+	return executor, nil
+}
+
+// loadBrowserSetup 加载浏览器配置、创建http后端使用的出站传输并打开凭据保险库
+//
+// 保险库打不开时只记录警告并返回nil，login命令仍可使用命令中的用户名和密码。
+func loadBrowserSetup(configPath string) (*browser.Config, http.RoundTripper, *vault.Vault, error) {
+	browserConfig, err := browser.LoadConfig(configPath)
+	if err != nil {
+		logrus.Warnf("加载浏览器配置失败: %v, 将使用默认配置", err)
+		browserConfig = browser.GetDefaultConfig()
+	}
+
+	// http后端的请求与LLM请求共用代理和TLS设置
+	httpConfig, err := httpclient.LoadConfig(configPath)
+	if err != nil {
+		logrus.Warnf("加载出站HTTP配置失败: %v, 将使用默认配置", err)
+		httpConfig = httpclient.GetDefaultConfig()
+	}
+	httpClient, err := httpclient.New(httpConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("创建出站HTTP客户端失败: %v", err)
+	}
+
+	vaultConfig, err := vault.LoadConfig(configPath)
+	if err != nil {
+		logrus.Warnf("加载凭据保险库配置失败: %v, 将使用默认配置", err)
+		vaultConfig = vault.GetDefaultConfig()
+	}
+	v, err := vault.Open(vaultConfig)
+	if err != nil {
+		logrus.Warnf("打开凭据保险库失败，login命令只能使用命令中的用户名和密码: %v", err)
+		v = nil
+	}
+	return browserConfig, httpClient.Transport(), v, nil
+}
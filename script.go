@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"GoBrowserAgent/internal/script"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runScript 执行脚本文件，返回进程退出码：全部成功为0，有命令失败或脚本有错误为1
+func runScript(configPath, path string, options script.Options) int {
+	if options.Verbose {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	lines, err := script.Load(path)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	executor, err := newExecutor(configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	defer executor.Close()
+
+	runner := script.NewRunner(executor, options, os.Stdout)
+	program, errs := runner.Check(lines)
+	if len(errs) > 0 {
+		// 一次报告所有语法错误，不启动浏览器
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e.Pretty())
+		}
+		logrus.Errorf("脚本有%d处错误，未执行任何命令", len(errs))
+		return 1
+	}
+
+	// 收到中断信号时取消当前命令并跳过剩余的命令，仍然输出统计
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	summary := runner.Run(ctx, program)
+	summary.Print(os.Stdout)
+	if summary.Failed > 0 || summary.Skipped > 0 {
+		return 1
+	}
+	return 0
+}
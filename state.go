@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"GoBrowserAgent/internal/service/llm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stateUsage state子命令的用法
+const stateUsage = `用法:
+  GoBrowserAgent state list [-config 配置文件]        列出保存的浏览器状态和过期时间
+  GoBrowserAgent state delete [-config 配置文件] <名称>
+在交互模式或脚本中用state save <名称>和state load <名称>保存和恢复状态`
+
+// runState 执行state子命令，查看和删除state save及login保存的浏览器状态，返回进程退出码
+func runState(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, stateUsage)
+		return 2
+	}
+	switch args[0] {
+	case "list":
+		return runStateList(args[1:])
+	case "delete":
+		return runStateDelete(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "未知的state命令: %s\n%s\n", args[0], stateUsage)
+	return 2
+}
+
+func runStateList(args []string) int {
+	flags := flag.NewFlagSet("state list", flag.ContinueOnError)
+	configPath := flags.String("config", llm.GetConfigPath(), "配置文件路径")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	v, err := openVault(*configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	states, err := v.States()
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	if len(states) == 0 {
+		fmt.Println("还没有保存的状态")
+		return 0
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "名称\t保存时间\t过期时间\t状态\tCookie\t存储的来源")
+	for _, s := range states {
+		status := "有效，剩余" + s.ExpiresAt.Sub(now).Round(time.Minute).String()
+		if s.Expired(now) {
+			status = "已过期"
+		}
+		origins := strings.Join(s.Origins, ", ")
+		if origins == "" {
+			origins = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", s.Name, s.SavedAt.Format("2006-01-02 15:04"),
+			s.ExpiresAt.Format("2006-01-02 15:04"), status, s.Cookies, origins)
+	}
+	w.Flush()
+	return 0
+}
+
+func runStateDelete(args []string) int {
+	flags := flag.NewFlagSet("state delete", flag.ContinueOnError)
+	configPath := flags.String("config", llm.GetConfigPath(), "配置文件路径")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "用法: GoBrowserAgent state delete [-config 配置文件] <名称>")
+		return 2
+	}
+	v, err := openVault(*configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	if err := v.DeleteState(positional[0]); err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	fmt.Printf("已删除状态%s\n", positional[0])
+	return 0
+}
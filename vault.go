@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"GoBrowserAgent/internal/service/llm"
+	"GoBrowserAgent/internal/vault"
+
+	"github.com/sirupsen/logrus"
+)
+
+// vaultUsage vault子命令的用法
+const vaultUsage = `用法:
+  GoBrowserAgent vault list [-config 配置文件]
+  GoBrowserAgent vault set [选项] <名称>     从标准输入读取密码
+  GoBrowserAgent vault delete [-config 配置文件] <名称>`
+
+// runVault 执行vault子命令，管理login命令使用的站点配置，返回进程退出码
+func runVault(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, vaultUsage)
+		return 2
+	}
+	switch args[0] {
+	case "list":
+		return runVaultList(args[1:])
+	case "set":
+		return runVaultSet(args[1:])
+	case "delete":
+		return runVaultDelete(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "未知的vault命令: %s\n%s\n", args[0], vaultUsage)
+	return 2
+}
+
+// openVault 按配置文件打开保险库
+func openVault(configPath string) (*vault.Vault, error) {
+	config, err := vault.LoadConfig(configPath)
+	if err != nil {
+		logrus.Warnf("加载凭据保险库配置失败: %v, 将使用默认配置", err)
+		config = vault.GetDefaultConfig()
+	}
+	return vault.Open(config)
+}
+
+// parseInterspersed 解析参数，允许选项写在位置参数之后，例如vault set intranet -url ...
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func runVaultList(args []string) int {
+	flags := flag.NewFlagSet("vault list", flag.ContinueOnError)
+	configPath := flags.String("config", llm.GetConfigPath(), "配置文件路径")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	v, err := openVault(*configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	profiles, err := v.Profiles()
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	if len(profiles) == 0 {
+		fmt.Println("保险库中还没有站点配置，可以用vault set添加")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "名称\t登录页\t用户名\t成功条件\t更新时间")
+	for _, p := range profiles {
+		success := p.Success
+		if success == "" {
+			success = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Name, p.URL, p.Username, success, p.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	return 0
+}
+
+func runVaultSet(args []string) int {
+	flags := flag.NewFlagSet("vault set", flag.ContinueOnError)
+	configPath := flags.String("config", llm.GetConfigPath(), "配置文件路径")
+	loginURL := flags.String("url", "", "登录页地址，新建站点时必须指定")
+	username := flags.String("username", "", "用户名")
+	usernameSelector := flags.String("username-selector", "", "用户名输入框，为空时自动查找")
+	passwordSelector := flags.String("password-selector", "", "密码输入框，为空时自动查找")
+	submit := flags.String("submit", "", "登录按钮，为空时自动查找")
+	success := flags.String("success", "", `登录成功的条件，写法与wait命令相同，例如"for url ~ /home"`)
+	sessionTTL := flags.Duration("session-ttl", 0, "登录状态的有效期，例如8h，0表示使用配置中的session_hours")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: echo -n 密码 | GoBrowserAgent vault set -url https://example.com/login -username admin <名称>")
+		fmt.Fprintln(flags.Output(), "修改已有站点时只更新指定的选项，输入空密码保留原来的密码")
+		flags.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return 2
+	}
+	name := positional[0]
+
+	v, err := openVault(*configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	profile, err := v.Profile(name)
+	switch {
+	case errors.Is(err, vault.ErrNotFound):
+		profile = &vault.Profile{Name: name}
+	case err != nil:
+		logrus.Errorf("%v", err)
+		return 1
+	}
+
+	// 只覆盖命令行中出现的选项，允许用空值清除选择器等字段
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "url":
+			profile.URL = *loginURL
+		case "username":
+			profile.Username = *username
+		case "username-selector":
+			profile.UsernameSelector = *usernameSelector
+		case "password-selector":
+			profile.PasswordSelector = *passwordSelector
+		case "submit":
+			profile.SubmitSelector = *submit
+		case "success":
+			profile.Success = *success
+		case "session-ttl":
+			profile.SessionTTL = *sessionTTL
+		}
+	})
+
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		if profile.Password != "" {
+			fmt.Fprint(os.Stderr, "请输入密码（直接回车保留原密码）: ")
+		} else {
+			fmt.Fprint(os.Stderr, "请输入密码: ")
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" && profile.Password == "" {
+		logrus.Errorf("读取密码失败: %v", err)
+		return 1
+	}
+	if password := strings.TrimRight(line, "\r\n"); password != "" {
+		profile.Password = password
+	}
+	if profile.Password == "" {
+		logrus.Error("密码不能为空")
+		return 2
+	}
+
+	if err := v.Put(profile); err != nil {
+		logrus.Errorf("保存站点配置失败: %v", err)
+		return 1
+	}
+	// 密码或登录方式改变后，之前保存的登录状态可能属于另一个账号
+	if err := v.DeleteState(name); err != nil {
+		logrus.Warnf("%v", err)
+	}
+	fmt.Printf("已保存站点配置%s，使用: login %s\n", name, name)
+	return 0
+}
+
+func runVaultDelete(args []string) int {
+	flags := flag.NewFlagSet("vault delete", flag.ContinueOnError)
+	configPath := flags.String("config", llm.GetConfigPath(), "配置文件路径")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		fmt.Fprintln(os.Stderr, "用法: GoBrowserAgent vault delete [-config 配置文件] <名称>")
+		return 2
+	}
+	v, err := openVault(*configPath)
+	if err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	if err := v.Delete(positional[0]); err != nil {
+		logrus.Errorf("%v", err)
+		return 1
+	}
+	fmt.Printf("已删除站点配置%s及其登录状态\n", positional[0])
+	return 0
+}